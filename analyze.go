@@ -0,0 +1,173 @@
+package jsonpatch
+
+import (
+	"slices"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// Analysis reports the JSON Pointers a patch reads and writes and the
+// capabilities it requires, derived from its operations without a document.
+// A pointer stands for the value at it and everything below it; writes into
+// an array may also move the elements after the written index.
+type Analysis struct {
+	// Reads lists the pointers whose values the patch depends on, in order of
+	// first use.
+	Reads []string
+	// Writes lists the pointers the patch may change, in order of first use.
+	Writes []string
+	// Capabilities is the smallest set WithCapabilities must enable for the
+	// patch to compile.
+	Capabilities Capability
+	// Ops analyzes each operation in patch order.
+	Ops []OpAnalysis
+}
+
+// OpAnalysis reports the pointers one operation reads and writes and the
+// capability it requires.
+type OpAnalysis struct {
+	// Reads lists the test and predicate targets, including every operand of
+	// and, or, and not, the from of move and copy, and the target of
+	// operations that edit a value in place.
+	Reads []string
+	// Writes lists the targets of mutating operations and the from of move.
+	// A split writes the parent of its target, which it may grow.
+	Writes []string
+	// Capabilities is the capability the operation requires.
+	Capabilities Capability
+}
+
+// Analyze reports what p reads, writes, and requires.
+func (p *Patch) Analyze() Analysis {
+	var analysis Analysis
+	if p == nil {
+		return analysis
+	}
+	analysis.Ops = make([]OpAnalysis, len(p.ops))
+	read, written := map[string]bool{}, map[string]bool{}
+	for i, operation := range p.ops {
+		opAnalysis := analyzeOp(operation)
+		analysis.Ops[i] = opAnalysis
+		for _, pointer := range opAnalysis.Reads {
+			if !read[pointer] {
+				read[pointer] = true
+				analysis.Reads = append(analysis.Reads, pointer)
+			}
+		}
+		for _, pointer := range opAnalysis.Writes {
+			if !written[pointer] {
+				written[pointer] = true
+				analysis.Writes = append(analysis.Writes, pointer)
+			}
+		}
+		analysis.Capabilities |= opAnalysis.Capabilities
+	}
+	return analysis
+}
+
+func analyzeOp(operation Op) OpAnalysis {
+	reads, writes := accesses(operation)
+	return OpAnalysis{
+		Reads:        formatPointers(reads),
+		Writes:       formatPointers(writes),
+		Capabilities: requiredCapability(operation),
+	}
+}
+
+// accesses returns the paths operation reads and writes, without duplicates.
+func accesses(operation Op) (reads, writes [][]string) {
+	path := operation.Path()
+	switch operation.Op() {
+	case internal.OpAddType, internal.OpRemoveType, internal.OpReplaceType:
+		writes = [][]string{path}
+	case internal.OpMoveType:
+		from := operationFrom(operation)
+		reads = [][]string{from}
+		writes = appendPaths([][]string{from}, path)
+	case internal.OpCopyType:
+		reads = [][]string{operationFrom(operation)}
+		writes = [][]string{path}
+	case internal.OpFlipType, internal.OpIncType, internal.OpStrInsType, internal.OpStrDelType,
+		internal.OpMergeType, internal.OpExtendType, internal.OpMergePatchType:
+		reads = [][]string{path}
+		writes = [][]string{path}
+	case internal.OpSplitType:
+		reads = [][]string{path}
+		if len(path) > 0 {
+			writes = [][]string{path[:len(path)-1]}
+		} else {
+			writes = [][]string{path}
+		}
+	default:
+		if spec, ok := internal.LookupOperation(operation.Op()); ok && spec.Families&internal.FamilyCustom != 0 {
+			reads = [][]string{path}
+			writes = [][]string{path}
+			break
+		}
+		WalkPredicates(operation, func(predicate Op, _ int) bool {
+			if _, ok := predicate.(internal.SecondOrderPredicateOp); !ok {
+				reads = appendPaths(reads, predicate.Path())
+			}
+			return true
+		})
+	}
+	return reads, writes
+}
+
+// WalkPredicates calls visit for operation and then, depth first, for each
+// operand of the and, or, and not predicates it contains. depth is 0 for
+// operation and grows by one per composite level. When visit returns false
+// the operands of that operation are skipped.
+func WalkPredicates(operation Op, visit func(operation Op, depth int) bool) {
+	walkPredicates(operation, 0, visit)
+}
+
+func walkPredicates(operation Op, depth int, visit func(Op, int) bool) {
+	if wildcard, ok := operation.(*wildcardOp); ok {
+		operation = wildcard.template
+	}
+	if operation == nil || !visit(operation, depth) {
+		return
+	}
+	composite, ok := operation.(internal.SecondOrderPredicateOp)
+	if !ok {
+		return
+	}
+	for _, operand := range composite.Ops() {
+		walkPredicates(operand, depth+1, visit)
+	}
+}
+
+func operationFrom(operation Op) []string {
+	if wildcard, ok := operation.(*wildcardOp); ok {
+		operation = wildcard.template
+	}
+	if from, ok := operation.(interface{ From() []string }); ok {
+		return from.From()
+	}
+	return nil
+}
+
+// appendPaths appends the paths not already in the short list of one
+// operation.
+func appendPaths(list [][]string, paths ...[]string) [][]string {
+	for _, path := range paths {
+		if !slices.ContainsFunc(list, func(listed []string) bool { return slices.Equal(listed, path) }) {
+			list = append(list, path)
+		}
+	}
+	return list
+}
+
+func formatPointers(paths [][]string) []string {
+	if paths == nil {
+		return nil
+	}
+	pointers := make([]string, len(paths))
+	for i, path := range paths {
+		pointers[i] = jsonpointer.Format(path...)
+	}
+	return pointers
+}
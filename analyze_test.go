@@ -0,0 +1,167 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestAnalyzeOperations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		build      func(*jsonpatch.Builder) *jsonpatch.Builder
+		wantReads  []string
+		wantWrites []string
+		wantCaps   jsonpatch.Capability
+	}{
+		{name: "add", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Add("/a/-", 1) }, wantWrites: []string{"/a/-"}, wantCaps: jsonpatch.RFC6902},
+		{name: "remove", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/a") }, wantWrites: []string{"/a"}, wantCaps: jsonpatch.RFC6902},
+		{name: "replace root", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("", 1) }, wantWrites: []string{""}, wantCaps: jsonpatch.RFC6902},
+		{
+			name:       "move",
+			build:      func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Move("/b", "/a") },
+			wantReads:  []string{"/a"},
+			wantWrites: []string{"/a", "/b"},
+			wantCaps:   jsonpatch.RFC6902,
+		},
+		{
+			name:       "copy",
+			build:      func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Copy("/b", "/a") },
+			wantReads:  []string{"/a"},
+			wantWrites: []string{"/b"},
+			wantCaps:   jsonpatch.RFC6902,
+		},
+		{name: "test", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/a", 1) }, wantReads: []string{"/a"}, wantCaps: jsonpatch.RFC6902},
+		{name: "predicate", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Less("/a", 1) }, wantReads: []string{"/a"}, wantCaps: jsonpatch.Predicate},
+		{
+			name:      "regex predicate",
+			build:     func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Matches("/a", "^x", false) },
+			wantReads: []string{"/a"},
+			wantCaps:  jsonpatch.RegexPredicate,
+		},
+		{
+			name: "nested composites",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder {
+				return b.And("/user", func(b *jsonpatch.Builder) {
+					b.Defined("/name").Not("", func(b *jsonpatch.Builder) {
+						b.Or("/roles", func(b *jsonpatch.Builder) { b.Contains("/0", "admin").Defined("/0") })
+					})
+				})
+			},
+			wantReads: []string{"/user/name", "/user/roles/0"},
+			wantCaps:  jsonpatch.Predicate,
+		},
+		{
+			name:       "in-place edit",
+			build:      func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Inc("/n", 1) },
+			wantReads:  []string{"/n"},
+			wantWrites: []string{"/n"},
+			wantCaps:   jsonpatch.Extended,
+		},
+		{
+			name:       "split writes the parent",
+			build:      func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Split("/nodes/0", 2, nil) },
+			wantReads:  []string{"/nodes/0"},
+			wantWrites: []string{"/nodes"},
+			wantCaps:   jsonpatch.Extended,
+		},
+		{
+			name:       "merge patch",
+			build:      func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.MergePatch("/a", map[string]any{"b": nil}) },
+			wantReads:  []string{"/a"},
+			wantWrites: []string{"/a"},
+			wantCaps:   jsonpatch.MergePatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := tt.build(jsonpatch.NewBuilder()).Compile()
+			require.NoError(t, err)
+
+			analysis := patch.Analyze()
+			require.Len(t, analysis.Ops, 1)
+			assert.Equal(t, tt.wantReads, analysis.Ops[0].Reads)
+			assert.Equal(t, tt.wantWrites, analysis.Ops[0].Writes)
+			assert.Equal(t, tt.wantCaps, analysis.Ops[0].Capabilities)
+		})
+	}
+}
+
+func TestAnalyzePatch(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.NewBuilder().
+		Test("/version", 1).
+		Replace("/version", 2).
+		Move("/owner", "/author").
+		Copy("/backup/owner", "/owner").
+		Inc("/views", 1).
+		Test("/version", 2).
+		Compile()
+	require.NoError(t, err)
+
+	analysis := patch.Analyze()
+	assert.Equal(t, []string{"/version", "/author", "/owner", "/views"}, analysis.Reads)
+	assert.Equal(t, []string{"/version", "/author", "/owner", "/backup/owner", "/views"}, analysis.Writes)
+	assert.Equal(t, jsonpatch.RFC6902|jsonpatch.Extended, analysis.Capabilities)
+	assert.Len(t, analysis.Ops, 6)
+
+	_, err = jsonpatch.CompileOps(patch.Ops(), jsonpatch.WithCapabilities(analysis.Capabilities))
+	require.NoError(t, err)
+
+	var empty *jsonpatch.Patch
+	assert.Equal(t, jsonpatch.Analysis{}, empty.Analyze())
+}
+
+func TestWalkPredicates(t *testing.T) {
+	t.Parallel()
+
+	predicate := op.NewAnd([]string{}, []any{
+		op.NewDefined([]string{"a"}),
+		op.NewOr([]string{}, []any{
+			op.NewNot(op.NewDefined([]string{"b"})),
+			op.NewLess([]string{"c"}, 1),
+		}),
+		op.NewMore([]string{"d"}, 1),
+	})
+
+	type visit struct {
+		op    jsonpatch.OpType
+		path  string
+		depth int
+	}
+	var visits []visit
+	jsonpatch.WalkPredicates(predicate, func(operation jsonpatch.Op, depth int) bool {
+		path := ""
+		if len(operation.Path()) > 0 {
+			path = operation.Path()[0]
+		}
+		visits = append(visits, visit{op: operation.Op(), path: path, depth: depth})
+		return operation.Op() != jsonpatch.OpNotType
+	})
+
+	assert.Equal(t, []visit{
+		{op: jsonpatch.OpAndType, depth: 0},
+		{op: jsonpatch.OpDefinedType, path: "a", depth: 1},
+		{op: jsonpatch.OpOrType, depth: 1},
+		{op: jsonpatch.OpNotType, path: "b", depth: 2},
+		{op: jsonpatch.OpLessType, path: "c", depth: 2},
+		{op: jsonpatch.OpMoreType, path: "d", depth: 1},
+	}, visits)
+
+	var count int
+	jsonpatch.WalkPredicates(op.NewAdd([]string{"a"}, 1), func(jsonpatch.Op, int) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,182 @@
+package jsonpatch_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestApplyContext(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(op.NewAdd([]string{"role"}, "admin"))
+	require.NoError(t, err)
+
+	t.Run("applies with live context", func(t *testing.T) {
+		t.Parallel()
+
+		result, err := jsonpatch.ApplyContext(t.Context(), patch, map[string]any{"name": "Ada"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"name": "Ada", "role": "admin"}, result.Doc)
+	})
+
+	t.Run("stops when canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancelCause(t.Context())
+		cause := errors.New("client went away")
+		cancel(cause)
+
+		result, err := jsonpatch.ApplyContext(ctx, patch, map[string]any{"name": "Ada"}, jsonpatch.WithContinueOnError())
+		require.Error(t, err)
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, cause)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, 0, patchErr.Index())
+		assert.Equal(t, "add", patchErr.Op())
+	})
+}
+
+func TestApplyBudgets(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		doc    map[string]any
+		ops    []jsonpatch.Op
+		option jsonpatch.ApplyOption
+		index  int
+	}{
+		{
+			name:   "copy doubles document",
+			doc:    map[string]any{"list": []any{"aaaa", "bbbb", "cccc"}},
+			ops:    []jsonpatch.Op{op.NewCopy([]string{"backup"}, []string{"list"})},
+			option: jsonpatch.WithMaxDocumentSize(40),
+		},
+		{
+			name: "size grows across operations",
+			doc:  map[string]any{"text": ""},
+			ops: []jsonpatch.Op{
+				op.NewStrIns([]string{"text"}, 0, "hello"),
+				op.NewStrIns([]string{"text"}, 5, " world"),
+			},
+			option: jsonpatch.WithMaxDocumentSize(20),
+			index:  1,
+		},
+		{
+			name:   "nested value too deep",
+			doc:    map[string]any{"user": map[string]any{}},
+			ops:    []jsonpatch.Op{op.NewAdd([]string{"user", "profile"}, map[string]any{"address": map[string]any{"city": "Paris"}})},
+			option: jsonpatch.WithMaxDocumentDepth(3),
+		},
+		{
+			name:   "str_ins string too long",
+			doc:    map[string]any{"text": "hello"},
+			ops:    []jsonpatch.Op{op.NewStrIns([]string{"text"}, 5, " world")},
+			option: jsonpatch.WithMaxStringLength(8),
+		},
+		{
+			name:   "merge string too long",
+			doc:    map[string]any{"list": []any{"hello", " world"}},
+			ops:    []jsonpatch.Op{op.NewMerge([]string{"list"}, 1, nil)},
+			option: jsonpatch.WithMaxStringLength(8),
+		},
+		{
+			name:   "add grows array",
+			doc:    map[string]any{"tags": []any{"a", "b"}},
+			ops:    []jsonpatch.Op{op.NewAdd([]string{"tags", "-"}, "c")},
+			option: jsonpatch.WithMaxArrayLength(2),
+		},
+		{
+			name:   "split grows array",
+			doc:    map[string]any{"list": []any{"hello"}},
+			ops:    []jsonpatch.Op{op.NewSplit([]string{"list", "0"}, 2, nil)},
+			option: jsonpatch.WithMaxArrayLength(1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileOps(tt.ops, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+
+			_, err = jsonpatch.Apply(patch, tt.doc, tt.option)
+			require.ErrorIs(t, err, jsonpatch.ErrBudgetExceeded)
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, tt.index, patchErr.Index())
+			assert.Equal(t, jsonpatch.ErrBudgetExceeded, patchErr.Kind())
+		})
+	}
+}
+
+func TestApplyBudgetsAllowWithinLimits(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewAdd([]string{"tags", "-"}, "c"),
+		op.NewStrIns([]string{"name"}, 3, "!"),
+		op.NewRemove([]string{"tags", "0"}),
+		op.NewCopy([]string{"alias"}, []string{"name"}),
+	}, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+	require.NoError(t, err)
+
+	result, err := jsonpatch.Apply(patch, []byte(`{"name":"Ada","tags":["a","b"]}`),
+		jsonpatch.WithMaxDocumentSize(64),
+		jsonpatch.WithMaxDocumentDepth(2),
+		jsonpatch.WithMaxStringLength(4),
+		jsonpatch.WithMaxArrayLength(3),
+	)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"Ada!","alias":"Ada!","tags":["b","c"]}`, string(result.Doc))
+}
+
+func TestApplyBudgetResolvesKeySegments(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewReplace([]string{"items", "[id=1]", "note"}, "short"),
+		op.NewReplace([]string{"items", "[id=1]", "id"}, float64(2)),
+		op.NewAdd([]string{"items", "[id=2]", "tags", "-"}, "c"),
+	}, keyCaps)
+	require.NoError(t, err)
+
+	doc := []byte(`{"items":[{"id":1,"note":"a note that is far too long","tags":["a","b"]}]}`)
+	result, err := jsonpatch.Apply(patch, doc, jsonpatch.WithMaxDocumentSize(60), jsonpatch.WithMaxArrayLength(3))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"items":[{"id":2,"note":"short","tags":["a","b","c"]}]}`, string(result.Doc))
+
+	_, err = jsonpatch.Apply(patch, doc, jsonpatch.WithMaxDocumentSize(60), jsonpatch.WithMaxArrayLength(2))
+	require.ErrorIs(t, err, jsonpatch.ErrBudgetExceeded)
+}
+
+func TestApplyBudgetSkipsViolationWithContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewAdd([]string{"tags", "-"}, "b"),
+		op.NewAdd([]string{"tags", "-"}, "c"),
+		op.NewReplace([]string{"name"}, "Grace"),
+	)
+	require.NoError(t, err)
+
+	doc := map[string]any{"name": "Ada", "tags": []any{"a"}}
+	err = jsonpatch.ApplyInPlace(patch, &doc, jsonpatch.WithContinueOnError(), jsonpatch.WithMaxArrayLength(2))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "Grace", "tags": []any{"a", "b"}}, doc)
+
+	err = jsonpatch.ApplyInPlace(patch, &doc, jsonpatch.WithMaxArrayLength(2))
+	require.ErrorIs(t, err, jsonpatch.ErrBudgetExceeded)
+	assert.Equal(t, map[string]any{"name": "Grace", "tags": []any{"a", "b"}}, doc)
+}
@@ -0,0 +1,127 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestApplyContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewReplace([]string{"name"}, "Grace"),
+		op.NewRemove([]string{"missing"}),
+		op.NewMove([]string{"tags", "5"}, []string{"tags", "0"}),
+		op.NewTest([]string{"name"}, "Ada"),
+		op.NewAdd([]string{"tags", "-"}, "c"),
+	)
+	require.NoError(t, err)
+
+	doc := map[string]any{"name": "Ada", "tags": []any{"a", "b"}}
+	result, err := jsonpatch.Apply(patch, doc, jsonpatch.WithContinueOnError())
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{"name": "Grace", "tags": []any{"a", "b", "c"}}, result.Doc)
+	assert.Equal(t, map[string]any{"name": "Ada", "tags": []any{"a", "b"}}, doc)
+
+	require.Len(t, result.Steps, 5)
+	applied := make([]bool, 0, len(result.Steps))
+	for _, step := range result.Steps {
+		applied = append(applied, step.Applied())
+		if step.Applied() {
+			assert.NoError(t, step.Err())
+		}
+	}
+	assert.Equal(t, []bool{true, false, false, false, true}, applied)
+
+	skipped := result.Steps[1]
+	assert.Equal(t, 1, skipped.Index())
+	assert.Equal(t, "remove", skipped.Op())
+	assert.Equal(t, "/missing", skipped.Path())
+	assert.Nil(t, skipped.Old())
+	var patchErr *jsonpatch.Error
+	require.True(t, errors.As(skipped.Err(), &patchErr))
+	assert.Equal(t, 1, patchErr.Index())
+	assert.ErrorIs(t, skipped.Err(), jsonpatch.ErrRuntimeConflict)
+
+	assert.Equal(t, "/tags/0", result.Steps[2].From())
+	assert.ErrorIs(t, result.Steps[3].Err(), jsonpatch.ErrTestFailed)
+
+	assert.ErrorIs(t, result.Err(), jsonpatch.ErrRuntimeConflict)
+	assert.ErrorIs(t, result.Err(), jsonpatch.ErrTestFailed)
+}
+
+func TestApplyInPlaceContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewRemove([]string{"missing"}),
+		op.NewAdd([]string{"role"}, "admin"),
+	)
+	require.NoError(t, err)
+
+	doc := map[string]any{"name": "Ada"}
+	err = jsonpatch.ApplyInPlace(patch, &doc, jsonpatch.WithContinueOnError())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "Ada", "role": "admin"}, doc)
+}
+
+func TestApplyDryRun(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewTest([]string{"name"}, "Grace"),
+		op.NewRemove([]string{"name"}),
+		op.NewRemove([]string{"name"}),
+		op.NewAdd([]string{"tags", "0"}, "x"),
+	)
+	require.NoError(t, err)
+
+	t.Run("reports every conflict", func(t *testing.T) {
+		t.Parallel()
+
+		doc := []byte(`{"name":"Ada"}`)
+		result, err := jsonpatch.Apply(patch, doc, jsonpatch.WithDryRun())
+		require.NoError(t, err)
+		assert.Nil(t, result.Doc)
+		assert.JSONEq(t, `{"name":"Ada"}`, string(doc))
+
+		var failed []int
+		for _, step := range result.Steps {
+			if !step.Applied() {
+				failed = append(failed, step.Index())
+			}
+		}
+		assert.Equal(t, []int{0, 2, 3}, failed)
+		assert.ErrorIs(t, result.Err(), jsonpatch.ErrTestFailed)
+	})
+
+	t.Run("leaves in-place document unchanged", func(t *testing.T) {
+		t.Parallel()
+
+		doc := map[string]any{"name": "Ada"}
+		err := jsonpatch.ApplyInPlace(patch, &doc, jsonpatch.WithDryRun())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"name": "Ada"}, doc)
+	})
+
+	t.Run("clean patch", func(t *testing.T) {
+		t.Parallel()
+
+		clean, err := jsonpatch.Compile(op.NewReplace([]string{"name"}, "Grace"))
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(clean, map[string]any{"name": "Ada"}, jsonpatch.WithDryRun())
+		require.NoError(t, err)
+		assert.Nil(t, result.Doc)
+		require.Len(t, result.Steps, 1)
+		assert.True(t, result.Steps[0].Applied())
+		assert.NoError(t, result.Err())
+	})
+}
@@ -0,0 +1,287 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// WithMaxDocumentSize limits the approximate JSON-encoded size, in bytes, of
+// the patched document. An operation that would grow the document past the
+// limit fails with ErrBudgetExceeded; add, copy, and replace are checked
+// before they run, so an oversized copy is never made. Operations that shrink
+// the document are allowed even when it already exceeds the limit.
+func WithMaxDocumentSize(bytes int) ApplyOption {
+	return func(o *applyOptions) {
+		o.limits().maxSize = bytes
+	}
+}
+
+// WithMaxDocumentDepth limits the nesting depth of values written by
+// operations. The depth of a value is the length of its path plus the depth
+// of its nested objects and arrays.
+func WithMaxDocumentDepth(depth int) ApplyOption {
+	return func(o *applyOptions) {
+		o.limits().maxDepth = depth
+	}
+}
+
+// WithMaxStringLength limits the length, in bytes, of strings produced by
+// str_ins, split, and merge. For Slate-style nodes the "text" member is
+// checked.
+func WithMaxStringLength(length int) ApplyOption {
+	return func(o *applyOptions) {
+		o.limits().maxString = length
+	}
+}
+
+// WithMaxArrayLength limits the length of arrays that add, copy, move, and
+// split insert into, and of arrays they write as values.
+func WithMaxArrayLength(length int) ApplyOption {
+	return func(o *applyOptions) {
+		o.limits().maxArray = length
+	}
+}
+
+func (o *applyOptions) limits() *budget {
+	if o.budget == nil {
+		o.budget = &budget{}
+	}
+	return o.budget
+}
+
+// budget enforces per-call limits. A zero limit is disabled. size tracks the
+// approximate encoded size of the working document when maxSize is set.
+type budget struct {
+	maxSize   int
+	maxDepth  int
+	maxString int
+	maxArray  int
+	size      int
+}
+
+// charge is the size change of one operation. When measured is set, the
+// change is unknown before the operation runs and region is measured before
+// and after it instead.
+type charge struct {
+	delta    int
+	measured bool
+	region   []string
+	before   int
+}
+
+// start measures the document the first operation applies to.
+func (b *budget) start(doc any) {
+	if b == nil || b.maxSize <= 0 {
+		return
+	}
+	b.size = internal.EncodedSize(doc)
+}
+
+// reserve computes the size change of operation before it runs and rejects
+// changes known to exceed the limit.
+func (b *budget) reserve(operation Op, doc any) (charge, error) {
+	if b == nil || b.maxSize <= 0 {
+		return charge{}, nil
+	}
+	path := operation.Path()
+	var c charge
+	switch typed := operation.(type) {
+	case *oppkg.AddOperation:
+		c.delta = insertSize(doc, path, typed.Value)
+	case *oppkg.CopyOperation:
+		value, _ := lookup(doc, typed.From())
+		c.delta = insertSize(doc, path, value)
+	case *oppkg.ReplaceOperation:
+		prior, _ := lookup(doc, path)
+		c.delta = internal.EncodedSize(typed.Value) - internal.EncodedSize(prior)
+	case *oppkg.MoveOperation:
+		if prior, ok := memberAt(doc, path); ok {
+			c.delta = -internal.EncodedSize(prior)
+		}
+	case *oppkg.RemoveOperation:
+		if prior, ok := lookup(doc, path); ok {
+			c.delta = -internal.EncodedSize(prior)
+		}
+	case *oppkg.SplitOperation:
+		c.measured = true
+		c.region = path
+		if len(path) > 0 {
+			if parent, _ := lookup(doc, path[:len(path)-1]); isArray(parent) {
+				c.region = path[:len(path)-1]
+			}
+		}
+	case *oppkg.MergeOperation, *oppkg.IncOperation, *oppkg.FlipOperation,
+		*oppkg.StrInsOperation, *oppkg.StrDelOperation,
+		*oppkg.ExtendOperation, *oppkg.MergePatchOperation:
+		c.measured = true
+		c.region = path
+	case internal.PredicateOp:
+		return c, nil
+	default:
+		c.measured = true
+	}
+
+	if c.measured {
+		value, _ := lookup(doc, c.region)
+		c.before = internal.EncodedSize(value)
+		return c, nil
+	}
+	return c, b.checkSize(c.delta)
+}
+
+// settle checks the document an operation produced and records its size.
+func (b *budget) settle(c charge, operation Op, doc any) error {
+	if b == nil {
+		return nil
+	}
+	if _, ok := operation.(internal.PredicateOp); ok {
+		return nil
+	}
+	if b.maxSize > 0 && c.measured {
+		value, _ := lookup(doc, c.region)
+		c.delta = internal.EncodedSize(value) - c.before
+		if err := b.checkSize(c.delta); err != nil {
+			return err
+		}
+	}
+	if err := b.checkWritten(operation, doc); err != nil {
+		return err
+	}
+	b.size += c.delta
+	return nil
+}
+
+func (b *budget) checkSize(delta int) error {
+	if delta > 0 && b.size+delta > b.maxSize {
+		return fmt.Errorf("document size %d exceeds limit %d", b.size+delta, b.maxSize)
+	}
+	return nil
+}
+
+// checkWritten checks the depth, string, and array limits against the values
+// operation wrote. Operations the budget does not know are checked against
+// the whole document.
+func (b *budget) checkWritten(operation Op, doc any) error {
+	path := operation.Path()
+	var texts, arrays [][]string
+	switch typed := operation.(type) {
+	case *oppkg.RemoveOperation:
+		return nil
+	case *oppkg.AddOperation, *oppkg.CopyOperation, *oppkg.MoveOperation:
+		path = appendedPath(path, doc)
+		arrays = [][]string{path}
+		if len(path) > 0 {
+			arrays = append(arrays, path[:len(path)-1])
+		}
+	case *oppkg.StrInsOperation:
+		texts = [][]string{path}
+	case *oppkg.SplitOperation:
+		texts = [][]string{path}
+		if index, ok := splitSibling(doc, path); ok {
+			texts = append(texts, indexPath(path[:len(path)-1], index))
+			arrays = [][]string{path[:len(path)-1]}
+		}
+	case *oppkg.MergeOperation:
+		texts = [][]string{indexPath(path, int(typed.Pos)-1)}
+	case *oppkg.ReplaceOperation, *oppkg.IncOperation, *oppkg.FlipOperation,
+		*oppkg.StrDelOperation, *oppkg.ExtendOperation, *oppkg.MergePatchOperation:
+	default:
+		path = nil
+	}
+
+	if b.maxDepth > 0 {
+		if value, ok := lookup(doc, path); ok {
+			if depth := len(path) + nestingDepth(value); depth > b.maxDepth {
+				return fmt.Errorf("nesting depth %d exceeds limit %d", depth, b.maxDepth)
+			}
+		}
+	}
+	if b.maxString > 0 {
+		for _, at := range texts {
+			value, _ := lookup(doc, at)
+			if length := stringLength(value); length > b.maxString {
+				return fmt.Errorf("string length %d exceeds limit %d", length, b.maxString)
+			}
+		}
+	}
+	if b.maxArray > 0 {
+		for _, at := range arrays {
+			value, _ := lookup(doc, at)
+			if array, ok := value.([]any); ok && len(array) > b.maxArray {
+				return fmt.Errorf("array length %d exceeds limit %d", len(array), b.maxArray)
+			}
+		}
+	}
+	return nil
+}
+
+// insertSize returns the size change of adding value at path: an array
+// insert adds an element, and an object member or the root is overwritten.
+func insertSize(doc any, path []string, value any) int {
+	size := internal.EncodedSize(value)
+	if len(path) == 0 {
+		return size - internal.EncodedSize(doc)
+	}
+	if prior, ok := memberAt(doc, path); ok {
+		return size - internal.EncodedSize(prior)
+	}
+	parent, _ := lookup(doc, path[:len(path)-1])
+	if isArray(parent) {
+		return size + 1
+	}
+	return size + len(path[len(path)-1]) + 4
+}
+
+// splitSibling returns the index of the second part of a split array
+// element.
+func splitSibling(doc any, path []string) (int, bool) {
+	if len(path) == 0 {
+		return 0, false
+	}
+	parent, _ := lookup(doc, path[:len(path)-1])
+	if !isArray(parent) {
+		return 0, false
+	}
+	index, err := strconv.Atoi(path[len(path)-1])
+	return index + 1, err == nil
+}
+
+func isArray(value any) bool {
+	_, ok := value.([]any)
+	return ok
+}
+
+// stringLength returns the length of a string or of the text of a
+// Slate-style node.
+func stringLength(value any) int {
+	switch typed := value.(type) {
+	case string:
+		return len(typed)
+	case map[string]any:
+		text, _ := typed["text"].(string)
+		return len(text)
+	default:
+		return 0
+	}
+}
+
+// nestingDepth returns the number of nested objects and arrays in value.
+func nestingDepth(value any) int {
+	deepest := 0
+	switch typed := value.(type) {
+	case map[string]any:
+		for _, member := range typed {
+			deepest = max(deepest, nestingDepth(member))
+		}
+	case []any:
+		for _, element := range typed {
+			deepest = max(deepest, nestingDepth(element))
+		}
+	default:
+		return 0
+	}
+	return deepest + 1
+}
@@ -0,0 +1,312 @@
+package jsonpatch
+
+import (
+	"errors"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// Builder assembles a patch from RFC 6901 JSON Pointer strings:
+//
+//	patch, err := jsonpatch.NewBuilder().
+//		Test("/version", 3).
+//		Add("/tags/-", "beta").
+//		Inc("/count", 1).
+//		Compile()
+//
+// Methods record invalid pointers instead of failing, and Compile reports
+// them together. The zero Builder is ready to use.
+type Builder struct {
+	ops  []func(compileOptions) Op
+	errs []error
+	// Operand builders of a composite resolve pointers under base and
+	// attribute their errors to the composite's index.
+	base  []string
+	index int
+	child bool
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Compile compiles the built operations. Without WithCapabilities the patch
+// enables exactly the vocabularies its operations need; with it, operations
+// outside the given vocabularies fail with ErrUnsupportedCapability. Invalid
+// pointers fail with ErrPayloadInvalid, one joined *Error per pointer.
+func (b *Builder) Compile(opts ...CompileOption) (*Patch, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+	options := buildCompileOptions(opts)
+	ops := b.build(options)
+	if !options.explicitCaps {
+		options.capabilities = 0
+		for _, operation := range ops {
+			if operation != nil {
+				options.capabilities |= requiredCapability(operation)
+			}
+		}
+	}
+	if err := checkOpLimits(ops, options); err != nil {
+		return nil, err
+	}
+	return compileOps(ops, options)
+}
+
+func (b *Builder) build(options compileOptions) []Op {
+	ops := make([]Op, len(b.ops))
+	for i, build := range b.ops {
+		ops[i] = build(options)
+	}
+	return ops
+}
+
+// Op appends a Go-built operation, such as one of the op package variants
+// the Builder has no method for. Its paths are used as is, also inside a
+// composite.
+func (b *Builder) Op(operation Op) *Builder {
+	b.ops = append(b.ops, func(compileOptions) Op { return operation })
+	return b
+}
+
+// Add appends an add operation.
+func (b *Builder) Add(path string, value any) *Builder {
+	return b.pathOp(OpAddType, path, func(p []string) Op { return oppkg.NewAdd(p, value) })
+}
+
+// Remove appends a remove operation.
+func (b *Builder) Remove(path string) *Builder {
+	return b.pathOp(OpRemoveType, path, func(p []string) Op { return oppkg.NewRemove(p) })
+}
+
+// Replace appends a replace operation.
+func (b *Builder) Replace(path string, value any) *Builder {
+	return b.pathOp(OpReplaceType, path, func(p []string) Op { return oppkg.NewReplace(p, value) })
+}
+
+// Move appends a move operation from from to path.
+func (b *Builder) Move(path, from string) *Builder {
+	return b.fromOp(OpMoveType, path, from, func(p, f []string) Op { return oppkg.NewMove(p, f) })
+}
+
+// Copy appends a copy operation from from to path.
+func (b *Builder) Copy(path, from string) *Builder {
+	return b.fromOp(OpCopyType, path, from, func(p, f []string) Op { return oppkg.NewCopy(p, f) })
+}
+
+// Test appends a test operation.
+func (b *Builder) Test(path string, value any) *Builder {
+	return b.pathOp(OpTestType, path, func(p []string) Op { return oppkg.NewTest(p, value) })
+}
+
+// TestNot appends a test operation that passes when the value differs.
+func (b *Builder) TestNot(path string, value any) *Builder {
+	return b.pathOp(OpTestType, path, func(p []string) Op { return oppkg.NewTestWithNot(p, value, true) })
+}
+
+// Defined appends a defined predicate.
+func (b *Builder) Defined(path string) *Builder {
+	return b.pathOp(OpDefinedType, path, func(p []string) Op { return oppkg.NewDefined(p) })
+}
+
+// Undefined appends an undefined predicate.
+func (b *Builder) Undefined(path string) *Builder {
+	return b.pathOp(OpUndefinedType, path, func(p []string) Op { return oppkg.NewUndefined(p) })
+}
+
+// Contains appends a contains predicate.
+func (b *Builder) Contains(path, substring string) *Builder {
+	return b.pathOp(OpContainsType, path, func(p []string) Op { return oppkg.NewContains(p, substring) })
+}
+
+// Starts appends a starts predicate.
+func (b *Builder) Starts(path, prefix string) *Builder {
+	return b.pathOp(OpStartsType, path, func(p []string) Op { return oppkg.NewStarts(p, prefix) })
+}
+
+// Ends appends an ends predicate.
+func (b *Builder) Ends(path, suffix string) *Builder {
+	return b.pathOp(OpEndsType, path, func(p []string) Op { return oppkg.NewEnds(p, suffix) })
+}
+
+// Matches appends a matches predicate. The pattern is compiled with the
+// WithCompileMatcher factory passed to Compile, if any.
+func (b *Builder) Matches(path, pattern string, ignoreCase bool) *Builder {
+	segments, ok := b.parse(OpMatchesType, path, "")
+	b.ops = append(b.ops, func(options compileOptions) Op {
+		if !ok {
+			return nil
+		}
+		return oppkg.NewMatches(segments, pattern, ignoreCase, options.createMatcher)
+	})
+	return b
+}
+
+// In appends an in predicate.
+func (b *Builder) In(path string, values ...any) *Builder {
+	return b.pathOp(OpInType, path, func(p []string) Op { return oppkg.NewIn(p, values) })
+}
+
+// Less appends a less predicate.
+func (b *Builder) Less(path string, value float64) *Builder {
+	return b.pathOp(OpLessType, path, func(p []string) Op { return oppkg.NewLess(p, value) })
+}
+
+// More appends a more predicate.
+func (b *Builder) More(path string, value float64) *Builder {
+	return b.pathOp(OpMoreType, path, func(p []string) Op { return oppkg.NewMore(p, value) })
+}
+
+// Type appends a type predicate.
+func (b *Builder) Type(path, expectedType string) *Builder {
+	return b.pathOp(OpTypeType, path, func(p []string) Op { return oppkg.NewType(p, expectedType) })
+}
+
+// TestType appends a test_type predicate that passes for any of types.
+func (b *Builder) TestType(path string, types ...string) *Builder {
+	return b.pathOp(OpTestTypeType, path, func(p []string) Op { return oppkg.NewTestTypeMultiple(p, types) })
+}
+
+// TestString appends a test_string predicate comparing str at pos.
+func (b *Builder) TestString(path, str string, pos int) *Builder {
+	return b.pathOp(OpTestStringType, path, func(p []string) Op {
+		return oppkg.NewTestString(p, str, float64(pos), false, false)
+	})
+}
+
+// TestStringLen appends a test_string_len predicate.
+func (b *Builder) TestStringLen(path string, length int) *Builder {
+	return b.pathOp(OpTestStringLenType, path, func(p []string) Op { return oppkg.NewTestStringLen(p, float64(length)) })
+}
+
+// And appends an and predicate over the operands build adds. Operand paths
+// are relative to path.
+func (b *Builder) And(path string, build func(*Builder)) *Builder {
+	return b.composite(OpAndType, path, build, func(p []string, ops []any) Op { return oppkg.NewAnd(p, ops) })
+}
+
+// Or appends an or predicate over the operands build adds. Operand paths are
+// relative to path.
+func (b *Builder) Or(path string, build func(*Builder)) *Builder {
+	return b.composite(OpOrType, path, build, func(p []string, ops []any) Op { return oppkg.NewOr(p, ops) })
+}
+
+// Not appends a not predicate over the operands build adds. Operand paths are
+// relative to path.
+func (b *Builder) Not(path string, build func(*Builder)) *Builder {
+	return b.composite(OpNotType, path, build, func(p []string, ops []any) Op { return oppkg.NewNotMultiple(p, ops) })
+}
+
+// Flip appends a flip operation.
+func (b *Builder) Flip(path string) *Builder {
+	return b.pathOp(OpFlipType, path, func(p []string) Op { return oppkg.NewFlip(p) })
+}
+
+// Inc appends an inc operation.
+func (b *Builder) Inc(path string, delta float64) *Builder {
+	return b.pathOp(OpIncType, path, func(p []string) Op { return oppkg.NewInc(p, delta) })
+}
+
+// StrIns appends a str_ins operation inserting str at pos.
+func (b *Builder) StrIns(path string, pos int, str string) *Builder {
+	return b.pathOp(OpStrInsType, path, func(p []string) Op { return oppkg.NewStrIns(p, float64(pos), str) })
+}
+
+// StrDel appends a str_del operation deleting length characters at pos.
+func (b *Builder) StrDel(path string, pos, length int) *Builder {
+	return b.pathOp(OpStrDelType, path, func(p []string) Op { return oppkg.NewStrDel(p, float64(pos), float64(length)) })
+}
+
+// Split appends a split operation at pos.
+func (b *Builder) Split(path string, pos int, props any) *Builder {
+	return b.pathOp(OpSplitType, path, func(p []string) Op { return oppkg.NewSplit(p, float64(pos), props) })
+}
+
+// Merge appends a merge operation at array position pos.
+func (b *Builder) Merge(path string, pos int, props map[string]any) *Builder {
+	return b.pathOp(OpMergeType, path, func(p []string) Op { return oppkg.NewMerge(p, float64(pos), props) })
+}
+
+// Extend appends an extend operation.
+func (b *Builder) Extend(path string, props map[string]any, deleteNull bool) *Builder {
+	return b.pathOp(OpExtendType, path, func(p []string) Op { return oppkg.NewExtend(p, props, deleteNull) })
+}
+
+// MergePatch appends a JSON Merge Patch operation.
+func (b *Builder) MergePatch(path string, value any) *Builder {
+	return b.pathOp(OpMergePatchType, path, func(p []string) Op { return oppkg.NewMergePatch(p, value) })
+}
+
+func (b *Builder) pathOp(opType OpType, path string, build func([]string) Op) *Builder {
+	segments, ok := b.parse(opType, path, "")
+	b.ops = append(b.ops, func(compileOptions) Op {
+		if !ok {
+			return nil
+		}
+		return build(segments)
+	})
+	return b
+}
+
+func (b *Builder) fromOp(opType OpType, path, from string, build func(path, from []string) Op) *Builder {
+	segments, ok := b.parse(opType, path, from)
+	fromSegments, fromOK := b.parsePointer(opType, path, from, from)
+	b.ops = append(b.ops, func(compileOptions) Op {
+		if !ok || !fromOK {
+			return nil
+		}
+		return build(segments, fromSegments)
+	})
+	return b
+}
+
+func (b *Builder) composite(opType OpType, path string, build func(*Builder), combine func([]string, []any) Op) *Builder {
+	base, ok := b.parse(opType, path, "")
+	operands := &Builder{base: base, index: b.nextIndex(), child: true}
+	if build != nil {
+		build(operands)
+	}
+	b.errs = append(b.errs, operands.errs...)
+	b.ops = append(b.ops, func(options compileOptions) Op {
+		if !ok {
+			return nil
+		}
+		ops := make([]any, 0, len(operands.ops))
+		for _, operand := range operands.build(options) {
+			if operand == nil {
+				return nil
+			}
+			ops = append(ops, operand)
+		}
+		return combine(base, ops)
+	})
+	return b
+}
+
+func (b *Builder) parse(opType OpType, path, from string) ([]string, bool) {
+	return b.parsePointer(opType, path, from, path)
+}
+
+// parsePointer parses pointer, one of the path or from of an operation, and
+// records an error attributed to the operation when it is invalid.
+func (b *Builder) parsePointer(opType OpType, path, from, pointer string) ([]string, bool) {
+	if err := jsonpointer.Validate(pointer); err != nil {
+		b.errs = append(b.errs, newFieldError(ErrPayloadInvalid, b.nextIndex(), string(opType), path, from, "", err))
+		return nil, false
+	}
+	parsed := jsonpointer.Parse(pointer)
+	segments := make([]string, 0, len(b.base)+len(parsed))
+	segments = append(segments, b.base...)
+	return append(segments, parsed...), true
+}
+
+func (b *Builder) nextIndex() int {
+	if b.child {
+		return b.index
+	}
+	return len(b.ops)
+}
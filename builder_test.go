@@ -0,0 +1,237 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestBuilderCompilesEveryOperation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		build func(*jsonpatch.Builder) *jsonpatch.Builder
+		want  jsonpatch.Op
+	}{
+		{name: "add", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Add("/a/b", 1) }, want: op.NewAdd([]string{"a", "b"}, 1)},
+		{name: "remove", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/a") }, want: op.NewRemove([]string{"a"})},
+		{name: "replace", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/a", 1) }, want: op.NewReplace([]string{"a"}, 1)},
+		{name: "move", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Move("/a", "/b") }, want: op.NewMove([]string{"a"}, []string{"b"})},
+		{name: "copy", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Copy("/a", "/b") }, want: op.NewCopy([]string{"a"}, []string{"b"})},
+		{name: "test", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/a", 1) }, want: op.NewTest([]string{"a"}, 1)},
+		{name: "test not", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.TestNot("/a", 1) }, want: op.NewTestWithNot([]string{"a"}, 1, true)},
+		{name: "defined", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Defined("/a") }, want: op.NewDefined([]string{"a"})},
+		{name: "undefined", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Undefined("/a") }, want: op.NewUndefined([]string{"a"})},
+		{name: "contains", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Contains("/a", "x") }, want: op.NewContains([]string{"a"}, "x")},
+		{name: "starts", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Starts("/a", "x") }, want: op.NewStarts([]string{"a"}, "x")},
+		{name: "ends", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Ends("/a", "x") }, want: op.NewEnds([]string{"a"}, "x")},
+		{name: "in", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.In("/a", 1, 2) }, want: op.NewIn([]string{"a"}, []any{1, 2})},
+		{name: "less", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Less("/a", 1) }, want: op.NewLess([]string{"a"}, 1)},
+		{name: "more", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.More("/a", 1) }, want: op.NewMore([]string{"a"}, 1)},
+		{name: "type", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Type("/a", "string") }, want: op.NewType([]string{"a"}, "string")},
+		{
+			name:  "test type",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.TestType("/a", "string", "null") },
+			want:  op.NewTestTypeMultiple([]string{"a"}, []string{"string", "null"}),
+		},
+		{
+			name:  "test string",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.TestString("/a", "x", 2) },
+			want:  op.NewTestString([]string{"a"}, "x", 2, false, false),
+		},
+		{
+			name:  "test string len",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.TestStringLen("/a", 3) },
+			want:  op.NewTestStringLen([]string{"a"}, 3),
+		},
+		{name: "flip", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Flip("/a") }, want: op.NewFlip([]string{"a"})},
+		{name: "inc", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Inc("/a", 2) }, want: op.NewInc([]string{"a"}, 2)},
+		{name: "str_ins", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.StrIns("/a", 1, "x") }, want: op.NewStrIns([]string{"a"}, 1, "x")},
+		{name: "str_del", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.StrDel("/a", 1, 2) }, want: op.NewStrDel([]string{"a"}, 1, 2)},
+		{name: "split", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Split("/a", 1, nil) }, want: op.NewSplit([]string{"a"}, 1, nil)},
+		{name: "merge", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Merge("/a", 1, nil) }, want: op.NewMerge([]string{"a"}, 1, nil)},
+		{
+			name:  "extend",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Extend("/a", map[string]any{"b": nil}, true) },
+			want:  op.NewExtend([]string{"a"}, map[string]any{"b": nil}, true),
+		},
+		{
+			name:  "merge_patch",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.MergePatch("/a", map[string]any{"b": 1}) },
+			want:  op.NewMergePatch([]string{"a"}, map[string]any{"b": 1}),
+		},
+		{
+			name:  "escaped pointer",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Add("/a~1b/c~0d", 1) },
+			want:  op.NewAdd([]string{"a/b", "c~d"}, 1),
+		},
+		{name: "root pointer", build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("", 1) }, want: op.NewReplace([]string{}, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := tt.build(jsonpatch.NewBuilder()).Compile()
+			require.NoError(t, err)
+
+			want, err := jsonpatch.CompileOps([]jsonpatch.Op{tt.want}, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+			assert.Equal(t, want.Ops(), patch.Ops())
+		})
+	}
+}
+
+func TestBuilderComposites(t *testing.T) {
+	t.Parallel()
+
+	var builder jsonpatch.Builder
+	patch, err := builder.
+		And("/user", func(b *jsonpatch.Builder) {
+			b.Defined("/name").
+				Or("/roles", func(b *jsonpatch.Builder) {
+					b.Contains("/0", "admin").Matches("/0", "^own", true)
+				})
+		}).
+		Not("", func(b *jsonpatch.Builder) { b.Defined("/deleted") }).
+		Replace("/user/name", "Ada").
+		Compile()
+	require.NoError(t, err)
+
+	want, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewAnd([]string{"user"}, []any{
+			op.NewDefined([]string{"user", "name"}),
+			op.NewOr([]string{"user", "roles"}, []any{
+				op.NewContains([]string{"user", "roles", "0"}, "admin"),
+				op.NewMatches([]string{"user", "roles", "0"}, "^own", true, nil),
+			}),
+		}),
+		op.NewNotMultiple([]string{}, []any{op.NewDefined([]string{"deleted"})}),
+		op.NewReplace([]string{"user", "name"}, "Ada"),
+	}, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+	require.NoError(t, err)
+	require.Len(t, patch.Ops(), 3)
+	for i := range want.Ops() {
+		assert.Equal(t, mustOpJSON(t, want.Ops()[i]), mustOpJSON(t, patch.Ops()[i]))
+	}
+
+	doc := map[string]any{"user": map[string]any{"name": "Bob", "roles": []any{"Owner"}}}
+	result, err := jsonpatch.Apply(patch, doc)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", result.Doc["user"].(map[string]any)["name"])
+}
+
+func mustOpJSON(t *testing.T, operation jsonpatch.Op) jsoncodec.Operation {
+	t.Helper()
+
+	jsonOp, ok := operation.(interface {
+		ToJSON() (jsoncodec.Operation, error)
+	})
+	require.True(t, ok)
+	projected, err := jsonOp.ToJSON()
+	require.NoError(t, err)
+	return projected
+}
+
+func TestBuilderCapabilities(t *testing.T) {
+	t.Parallel()
+
+	t.Run("inferred from operations", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.NewBuilder().Test("/count", 1).Inc("/count", 1).Less("/count", 5).Compile()
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, map[string]any{"count": 1})
+		require.NoError(t, err)
+		assert.InDelta(t, 2.0, result.Doc["count"], 0)
+	})
+
+	t.Run("checked against explicit capabilities", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.NewBuilder().
+			Add("/a", 1).
+			Inc("/count", 1).
+			Compile(jsonpatch.WithCapabilities(jsonpatch.RFC6902))
+		require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+		assert.Nil(t, patch)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, 1, patchErr.Index())
+		assert.Equal(t, "inc", patchErr.Op())
+	})
+}
+
+func TestBuilderCollectsPointerErrors(t *testing.T) {
+	t.Parallel()
+
+	builder := jsonpatch.NewBuilder().
+		Add("/ok", 1).
+		Replace("name", "Ada").
+		Move("/to", "from").
+		And("/user", func(b *jsonpatch.Builder) { b.Defined("/a~2") })
+
+	patch, err := builder.Compile()
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	assert.Nil(t, patch)
+
+	var joined interface{ Unwrap() []error }
+	require.True(t, errors.As(err, &joined))
+	errs := joined.Unwrap()
+	require.Len(t, errs, 3)
+
+	want := []struct {
+		index      int
+		op         string
+		path, from string
+	}{
+		{index: 1, op: "replace", path: "name"},
+		{index: 2, op: "move", path: "/to", from: "from"},
+		{index: 3, op: "defined", path: "/a~2"},
+	}
+	for i, w := range want {
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(errs[i], &patchErr))
+		assert.Equal(t, jsonpatch.ErrPayloadInvalid, patchErr.Kind())
+		assert.Equal(t, w.index, patchErr.Index())
+		assert.Equal(t, w.op, patchErr.Op())
+		assert.Equal(t, w.path, patchErr.Path())
+		assert.Equal(t, w.from, patchErr.From())
+	}
+}
+
+func TestBuilderRejectsInvalidOperands(t *testing.T) {
+	t.Parallel()
+
+	_, err := jsonpatch.NewBuilder().
+		And("", func(b *jsonpatch.Builder) { b.Add("/a", 1) }).
+		Compile()
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+}
+
+func TestBuilderAppliesCompileOptions(t *testing.T) {
+	t.Parallel()
+
+	var patterns []string
+	matcher := func(pattern string, _ bool) jsonpatch.RegexMatcher {
+		patterns = append(patterns, pattern)
+		return func(string) bool { return true }
+	}
+
+	builder := jsonpatch.NewBuilder().Matches("/a", "^x", false).Add("/b", 1)
+	_, err := builder.Compile(jsonpatch.WithCompileMatcher(matcher))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"^x"}, patterns)
+
+	_, err = builder.Compile(jsonpatch.WithMaxOperations(1))
+	require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+}
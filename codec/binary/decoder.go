@@ -1,7 +1,9 @@
 package binary
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"slices"
 
 	"github.com/tinylib/msgp/msgp"
@@ -10,18 +12,52 @@ import (
 	"github.com/kaptinlin/jsonpatch/op"
 )
 
+// decoder reads operations from one MessagePack payload. Array and string
+// headers are never trusted beyond the payload size, because every element
+// takes at least one byte.
+type decoder struct {
+	r             *msgp.Reader
+	limits        Limits
+	createMatcher internal.CreateRegexMatcher
+	maxElements   uint32
+	depth         int
+}
+
+func newDecoder(data []byte, options Options) *decoder {
+	r := msgp.NewReader(bytes.NewReader(data))
+	maxElements := uint32(min(len(data), math.MaxUint32))
+	r.SetMaxElements(maxElements)
+	r.SetMaxStringLength(uint64(len(data)))
+	return &decoder{r: r, limits: options.Limits, createMatcher: options.CreateMatcher, maxElements: maxElements}
+}
+
+// readArrayHeader reads an array header and rejects sizes the payload cannot
+// hold.
+func (d *decoder) readArrayHeader() (int, error) {
+	size, err := d.r.ReadArrayHeader()
+	if err != nil {
+		return 0, err
+	}
+	if size > d.maxElements {
+		return 0, fmt.Errorf("array of %d elements exceeds payload size: %w", size, ErrLimitExceeded)
+	}
+	return int(size), nil
+}
+
 // decodeOps reads the operation count and decodes each operation.
-func decodeOps(r *msgp.Reader) ([]internal.Op, error) {
-	arrSize, err := r.ReadArrayHeader()
+func (d *decoder) decodeOps() ([]internal.Op, error) {
+	size, err := d.readArrayHeader()
 	if err != nil {
 		return nil, err
 	}
-	size := int(arrSize)
+	if err := d.limits.CheckOperations(size); err != nil {
+		return nil, err
+	}
 	ops := make([]internal.Op, size)
 	for i := range size {
-		decoded, err := decodeOp(r)
+		decoded, err := d.decodeOp()
 		if err != nil {
-			return nil, err
+			return nil, &OperationError{Index: i, Err: err}
 		}
 		ops[i] = decoded
 	}
@@ -30,38 +66,41 @@ func decodeOps(r *msgp.Reader) ([]internal.Op, error) {
 
 // decodeOp reads the array header, operation code, and path,
 // then dispatches to the appropriate decoder.
-func decodeOp(r *msgp.Reader) (internal.Op, error) {
-	return decodeOpWithParent(r, nil)
+func (d *decoder) decodeOp() (internal.Op, error) {
+	return d.decodeOpWithParent(nil)
 }
 
-func decodeOpWithParent(r *msgp.Reader, parent []string) (internal.Op, error) {
-	arrSize, err := r.ReadArrayHeader()
+func (d *decoder) decodeOpWithParent(parent []string) (internal.Op, error) {
+	arrSize, err := d.r.ReadArrayHeader()
 	if err != nil {
 		return nil, err
 	}
-	code, err := r.ReadUint8()
+	code, err := d.r.ReadUint8()
 	if err != nil {
 		return nil, err
 	}
-	path, err := decodePath(r)
+	path, err := d.decodePath()
 	if err != nil {
 		return nil, err
 	}
 	if parent != nil {
 		path = mergePaths(parent, path)
 	}
+	if err := d.limits.CheckPath(path); err != nil {
+		return nil, err
+	}
 
 	switch code {
 	// Standard RFC 6902
 	case internal.OpAddCode:
-		value, err := decodeValue(r)
+		value, err := d.decodeValue()
 		if err != nil {
 			return nil, err
 		}
 		return op.NewAdd(path, value), nil
 	case internal.OpRemoveCode:
 		if arrSize >= 3 {
-			oldValue, err := decodeValue(r)
+			oldValue, err := d.decodeValue()
 			if err != nil {
 				return nil, err
 			}
@@ -69,29 +108,35 @@ func decodeOpWithParent(r *msgp.Reader, parent []string) (internal.Op, error) {
 		}
 		return op.NewRemove(path), nil
 	case internal.OpReplaceCode:
-		value, err := decodeValue(r)
+		value, err := d.decodeValue()
 		if err != nil {
 			return nil, err
 		}
 		return op.NewReplace(path, value), nil
 	case internal.OpMoveCode:
-		from, err := decodePath(r)
+		from, err := d.decodePath()
 		if err != nil {
 			return nil, err
 		}
+		if err := d.limits.CheckPath(from); err != nil {
+			return nil, err
+		}
 		return op.NewMove(path, from), nil
 	case internal.OpCopyCode:
-		from, err := decodePath(r)
+		from, err := d.decodePath()
 		if err != nil {
 			return nil, err
 		}
+		if err := d.limits.CheckPath(from); err != nil {
+			return nil, err
+		}
 		return op.NewCopy(path, from), nil
 	case internal.OpTestCode:
-		value, err := decodeValue(r)
+		value, err := d.decodeValue()
 		if err != nil {
 			return nil, err
 		}
-		not, err := decodeOptionalBool(r, arrSize, 4)
+		not, err := d.decodeOptionalBool(arrSize, 4)
 		if err != nil {
 			return nil, err
 		}
@@ -103,95 +148,120 @@ func decodeOpWithParent(r *msgp.Reader, parent []string) (internal.Op, error) {
 	case internal.OpUndefinedCode:
 		return op.NewUndefined(path), nil
 	case internal.OpTestTypeCode:
-		return decodeTestType(r, path)
+		return d.decodeTestType(path)
 	case internal.OpLessCode:
-		v, err := r.ReadFloat64()
+		v, err := d.r.ReadFloat64()
 		if err != nil {
 			return nil, err
 		}
 		return op.NewLess(path, v), nil
 	case internal.OpMoreCode:
-		v, err := r.ReadFloat64()
+		v, err := d.r.ReadFloat64()
 		if err != nil {
 			return nil, err
 		}
 		return op.NewMore(path, v), nil
 	case internal.OpContainsCode:
-		v, err := r.ReadString()
+		v, err := d.readOperand()
 		if err != nil {
 			return nil, err
 		}
-		ignoreCase, err := decodeOptionalBool(r, arrSize, 4)
+		ignoreCase, err := d.decodeOptionalBool(arrSize, 4)
 		if err != nil {
 			return nil, err
 		}
 		return op.NewContainsWithIgnoreCase(path, v, ignoreCase), nil
 	case internal.OpStartsCode:
-		v, err := r.ReadString()
+		v, err := d.readOperand()
 		if err != nil {
 			return nil, err
 		}
-		ignoreCase, err := decodeOptionalBool(r, arrSize, 4)
+		ignoreCase, err := d.decodeOptionalBool(arrSize, 4)
 		if err != nil {
 			return nil, err
 		}
 		return op.NewStartsWithIgnoreCase(path, v, ignoreCase), nil
 	case internal.OpEndsCode:
-		v, err := r.ReadString()
+		v, err := d.readOperand()
 		if err != nil {
 			return nil, err
 		}
-		ignoreCase, err := decodeOptionalBool(r, arrSize, 4)
+		ignoreCase, err := d.decodeOptionalBool(arrSize, 4)
 		if err != nil {
 			return nil, err
 		}
 		return op.NewEndsWithIgnoreCase(path, v, ignoreCase), nil
 	case internal.OpInCode:
-		return decodeIn(r, path)
+		return d.decodeIn(path)
 	case internal.OpMatchesCode:
-		return decodeMatches(r, path, arrSize)
+		return d.decodeMatches(path, arrSize)
 	case internal.OpTestStringCode:
-		return decodeTestString(r, path, arrSize)
+		return d.decodeTestString(path, arrSize)
 	case internal.OpTestStringLenCode:
-		return decodeTestStringLen(r, path, arrSize)
+		return d.decodeTestStringLen(path, arrSize)
 	case internal.OpTypeCode:
-		return decodeType(r, path)
+		return d.decodeType(path)
 	case internal.OpAndCode:
-		return decodeComposite(r, path, internal.OpAndType)
+		return d.decodeComposite(path, internal.OpAndType)
 	case internal.OpOrCode:
-		return decodeComposite(r, path, internal.OpOrType)
+		return d.decodeComposite(path, internal.OpOrType)
 	case internal.OpNotCode:
-		return decodeComposite(r, path, internal.OpNotType)
+		return d.decodeComposite(path, internal.OpNotType)
 
 	// Extended operations
 	case internal.OpFlipCode:
 		return op.NewFlip(path), nil
 	case internal.OpIncCode:
-		inc, err := r.ReadFloat64()
+		inc, err := d.r.ReadFloat64()
 		if err != nil {
 			return nil, err
 		}
 		return op.NewInc(path, inc), nil
 	case internal.OpStrInsCode:
-		return decodeStrIns(r, path)
+		return d.decodeStrIns(path)
 	case internal.OpStrDelCode:
-		return decodeStrDel(r, path)
+		return d.decodeStrDel(path)
 	case internal.OpSplitCode:
-		return decodeSplit(r, path, arrSize)
+		return d.decodeSplit(path, arrSize)
 	case internal.OpExtendCode:
-		return decodeExtend(r, path, arrSize)
+		return d.decodeExtend(path, arrSize)
 	case internal.OpMergeCode:
-		return decodeMerge(r, path, arrSize)
+		return d.decodeMerge(path, arrSize)
+
+	// JSON Merge Patch
+	case internal.OpMergePatchCode:
+		value, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		return op.NewMergePatch(path, value), nil
 
 	default:
+		if spec, ok := internal.LookupOperationCode(int(code)); ok && spec.DecodeCompact != nil {
+			return d.decodeCustom(spec, path, arrSize)
+		}
 		return nil, fmt.Errorf("unsupported op code %d: %w",
 			code, ErrUnsupportedOp)
 	}
 }
 
+// decodeCustom decodes a registered operation from the operands after its
+// path.
+func (d *decoder) decodeCustom(spec internal.OperationSpec, path []string, arrSize uint32) (internal.Op, error) {
+	operands := make([]any, max(int(arrSize)-2, 0))
+	for i := range operands {
+		value, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		operands[i] = value
+	}
+	return spec.DecodeCompact(path, operands)
+}
+
 // decodeTestType decodes a test_type operation.
-func decodeTestType(r *msgp.Reader, path []string) (internal.Op, error) {
-	raw, err := decodeValue(r)
+func (d *decoder) decodeTestType(path []string) (internal.Op, error) {
+	raw, err := d.decodeValue()
 	if err != nil {
 		return nil, err
 	}
@@ -211,8 +281,8 @@ func decodeTestType(r *msgp.Reader, path []string) (internal.Op, error) {
 }
 
 // decodeIn decodes an in predicate operation.
-func decodeIn(r *msgp.Reader, path []string) (internal.Op, error) {
-	raw, err := decodeValue(r)
+func (d *decoder) decodeIn(path []string) (internal.Op, error) {
+	raw, err := d.decodeValue()
 	if err != nil {
 		return nil, err
 	}
@@ -224,29 +294,32 @@ func decodeIn(r *msgp.Reader, path []string) (internal.Op, error) {
 }
 
 // decodeMatches decodes a matches predicate operation.
-func decodeMatches(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, error) {
-	pattern, err := r.ReadString()
+func (d *decoder) decodeMatches(path []string, arrSize uint32) (internal.Op, error) {
+	pattern, err := d.r.ReadString()
 	if err != nil {
 		return nil, err
 	}
-	ignoreCase, err := decodeOptionalBool(r, arrSize, 4)
+	if err := d.limits.CheckPattern(pattern); err != nil {
+		return nil, err
+	}
+	ignoreCase, err := d.decodeOptionalBool(arrSize, 4)
 	if err != nil {
 		return nil, err
 	}
-	return op.NewMatches(path, pattern, ignoreCase, nil), nil
+	return op.NewMatches(path, pattern, ignoreCase, d.createMatcher), nil
 }
 
 // decodeTestString decodes a test_string operation.
-func decodeTestString(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, error) {
-	pos, err := r.ReadFloat64()
+func (d *decoder) decodeTestString(path []string, arrSize uint32) (internal.Op, error) {
+	pos, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
-	str, err := r.ReadString()
+	str, err := d.readOperand()
 	if err != nil {
 		return nil, err
 	}
-	not, err := decodeOptionalBool(r, arrSize, 5)
+	not, err := d.decodeOptionalBool(arrSize, 5)
 	if err != nil {
 		return nil, err
 	}
@@ -254,12 +327,12 @@ func decodeTestString(r *msgp.Reader, path []string, arrSize uint32) (internal.O
 }
 
 // decodeTestStringLen decodes a test_string_len operation.
-func decodeTestStringLen(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, error) {
-	length, err := r.ReadFloat64()
+func (d *decoder) decodeTestStringLen(path []string, arrSize uint32) (internal.Op, error) {
+	length, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
-	not, err := decodeOptionalBool(r, arrSize, 4)
+	not, err := d.decodeOptionalBool(arrSize, 4)
 	if err != nil {
 		return nil, err
 	}
@@ -267,8 +340,8 @@ func decodeTestStringLen(r *msgp.Reader, path []string, arrSize uint32) (interna
 }
 
 // decodeType decodes a type predicate operation.
-func decodeType(r *msgp.Reader, path []string) (internal.Op, error) {
-	expected, err := r.ReadString()
+func (d *decoder) decodeType(path []string) (internal.Op, error) {
+	expected, err := d.r.ReadString()
 	if err != nil {
 		return nil, err
 	}
@@ -276,12 +349,12 @@ func decodeType(r *msgp.Reader, path []string) (internal.Op, error) {
 }
 
 // decodeStrIns decodes a str_ins operation.
-func decodeStrIns(r *msgp.Reader, path []string) (internal.Op, error) {
-	pos, err := r.ReadFloat64()
+func (d *decoder) decodeStrIns(path []string) (internal.Op, error) {
+	pos, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
-	str, err := r.ReadString()
+	str, err := d.readOperand()
 	if err != nil {
 		return nil, err
 	}
@@ -289,12 +362,12 @@ func decodeStrIns(r *msgp.Reader, path []string) (internal.Op, error) {
 }
 
 // decodeStrDel decodes a str_del operation.
-func decodeStrDel(r *msgp.Reader, path []string) (internal.Op, error) {
-	pos, err := r.ReadFloat64()
+func (d *decoder) decodeStrDel(path []string) (internal.Op, error) {
+	pos, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
-	length, err := r.ReadFloat64()
+	length, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
@@ -302,14 +375,14 @@ func decodeStrDel(r *msgp.Reader, path []string) (internal.Op, error) {
 }
 
 // decodeSplit decodes a split operation.
-func decodeSplit(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, error) {
-	pos, err := r.ReadFloat64()
+func (d *decoder) decodeSplit(path []string, arrSize uint32) (internal.Op, error) {
+	pos, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
 	var props any
 	if arrSize >= 4 {
-		props, err = decodeValue(r)
+		props, err = d.decodeValue()
 		if err != nil {
 			return nil, err
 		}
@@ -318,8 +391,8 @@ func decodeSplit(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, er
 }
 
 // decodeExtend decodes an extend operation.
-func decodeExtend(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, error) {
-	raw, err := decodeValue(r)
+func (d *decoder) decodeExtend(path []string, arrSize uint32) (internal.Op, error) {
+	raw, err := d.decodeValue()
 	if err != nil {
 		return nil, err
 	}
@@ -327,15 +400,20 @@ func decodeExtend(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, e
 	if !ok {
 		return nil, fmt.Errorf("extend properties must be an object, got %T: %w", raw, ErrInvalidValueType)
 	}
-	deleteNull, err := decodeOptionalBool(r, arrSize, 4)
+	deleteNull, err := d.decodeOptionalBool(arrSize, 4)
 	if err != nil {
 		return nil, err
 	}
 	return op.NewExtend(path, props, deleteNull), nil
 }
 
-func decodeComposite(r *msgp.Reader, path []string, opType internal.OpType) (internal.Op, error) {
-	ops, err := decodePredicateOps(r, path)
+func (d *decoder) decodeComposite(path []string, opType internal.OpType) (internal.Op, error) {
+	d.depth++
+	defer func() { d.depth-- }()
+	if err := d.limits.CheckDepth(d.depth); err != nil {
+		return nil, err
+	}
+	ops, err := d.decodePredicateOps(path)
 	if err != nil {
 		return nil, err
 	}
@@ -353,14 +431,14 @@ func decodeComposite(r *msgp.Reader, path []string, opType internal.OpType) (int
 	}
 }
 
-func decodePredicateOps(r *msgp.Reader, parent []string) ([]any, error) {
-	size, err := r.ReadArrayHeader()
+func (d *decoder) decodePredicateOps(parent []string) ([]any, error) {
+	size, err := d.readArrayHeader()
 	if err != nil {
 		return nil, err
 	}
-	ops := make([]any, int(size))
+	ops := make([]any, size)
 	for i := range ops {
-		decoded, err := decodeOpWithParent(r, parent)
+		decoded, err := d.decodeOpWithParent(parent)
 		if err != nil {
 			return nil, err
 		}
@@ -373,22 +451,22 @@ func decodePredicateOps(r *msgp.Reader, parent []string) ([]any, error) {
 	return ops, nil
 }
 
-func decodeOptionalBool(r *msgp.Reader, arrSize, presentAt uint32) (bool, error) {
+func (d *decoder) decodeOptionalBool(arrSize, presentAt uint32) (bool, error) {
 	if arrSize < presentAt {
 		return false, nil
 	}
-	return r.ReadBool()
+	return d.r.ReadBool()
 }
 
 // decodeMerge decodes a merge operation.
-func decodeMerge(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, error) {
-	pos, err := r.ReadFloat64()
+func (d *decoder) decodeMerge(path []string, arrSize uint32) (internal.Op, error) {
+	pos, err := d.r.ReadFloat64()
 	if err != nil {
 		return nil, err
 	}
 	var props map[string]any
 	if arrSize >= 4 {
-		raw, err := decodeValue(r)
+		raw, err := d.decodeValue()
 		if err != nil {
 			return nil, err
 		}
@@ -402,14 +480,14 @@ func decodeMerge(r *msgp.Reader, path []string, arrSize uint32) (internal.Op, er
 }
 
 // decodePath reads a path as a msgpack native array of string segments.
-func decodePath(r *msgp.Reader) ([]string, error) {
-	size, err := r.ReadArrayHeader()
+func (d *decoder) decodePath() ([]string, error) {
+	size, err := d.readArrayHeader()
 	if err != nil {
 		return nil, err
 	}
 	path := make([]string, size)
 	for i := range size {
-		seg, err := r.ReadString()
+		seg, err := d.r.ReadString()
 		if err != nil {
 			return nil, err
 		}
@@ -429,10 +507,27 @@ func mergePaths(base, child []string) []string {
 }
 
 // decodeValue reads an arbitrary msgp value and normalizes map types.
-func decodeValue(r *msgp.Reader) (any, error) {
-	v, err := r.ReadIntf()
+func (d *decoder) decodeValue() (any, error) {
+	v, err := d.r.ReadIntf()
 	if err != nil {
 		return nil, err
 	}
-	return normalizeMap(v), nil
+	v = normalizeMap(v)
+	if err := d.limits.CheckValue(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// readOperand reads a string operand and checks it against the value size
+// limit.
+func (d *decoder) readOperand() (string, error) {
+	s, err := d.r.ReadString()
+	if err != nil {
+		return "", err
+	}
+	if err := d.limits.CheckValue(s); err != nil {
+		return "", err
+	}
+	return s, nil
 }
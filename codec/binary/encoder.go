@@ -113,11 +113,44 @@ func encodeOpWithParent(w *msgp.Writer, v internal.Op, parent []string) error {
 		}
 		return encodeSplitOrMerge(w, o.Code(), path, o.Pos, props)
 
+	// JSON Merge Patch
+	case *op.MergePatchOperation:
+		return encodePathValue(w, o.Code(), path, o.Value)
+
 	default:
+		if spec, ok := internal.LookupOperation(v.Op()); ok && spec.DecodeCompact != nil {
+			if compactOp, ok := v.(internal.CompactOp); ok {
+				return encodeCustom(w, compactOp, spec.Code, path)
+			}
+		}
 		return fmt.Errorf("unsupported op type %T: %w", v, ErrUnsupportedOp)
 	}
 }
 
+// encodeCustom encodes a registered operation with format: [code, path,
+// ...operands], where the operands are its compact members after the path.
+func encodeCustom(w *msgp.Writer, o internal.CompactOp, code int, path []string) error {
+	raw, err := o.ToCompact()
+	if err != nil {
+		return err
+	}
+	if len(raw) < 2 {
+		return fmt.Errorf("operation %s compact form has %d members: %w", o.Op(), len(raw), ErrUnsupportedOp)
+	}
+	if err := writeHeader(w, uint32(len(raw)), code); err != nil { //nolint:gosec // compact forms are short.
+		return err
+	}
+	if err := encodePath(w, path); err != nil {
+		return err
+	}
+	for _, operand := range raw[2:] {
+		if err := w.WriteIntf(operand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // writeHeader writes the array header and operation code.
 func writeHeader(w *msgp.Writer, size uint32, code int) error {
 	if err := w.WriteArrayHeader(size); err != nil {
@@ -1,6 +1,11 @@
 package binary
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
 
 var (
 	// ErrUnsupportedOp indicates an unknown or unsupported operation code.
@@ -13,4 +18,25 @@ var (
 	ErrInvalidTestTypeFormat = errors.New("invalid test_type types format")
 	// ErrInvalidValueType indicates the decoded value has an unexpected type.
 	ErrInvalidValueType = errors.New("invalid value type")
+	// ErrLimitExceeded indicates a payload that exceeds a decode limit or
+	// declares more elements than it contains.
+	ErrLimitExceeded = internal.ErrLimitExceeded
 )
+
+// OperationError reports the top-level operation Decode failed on.
+type OperationError struct {
+	// Index is the position of the operation in the payload.
+	Index int
+	// Err is the decode failure.
+	Err error
+}
+
+// Error returns the failure with the operation index.
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+// Unwrap returns the decode failure.
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
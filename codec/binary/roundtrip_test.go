@@ -2,6 +2,7 @@ package binary
 
 import (
 	"bytes"
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -49,6 +50,7 @@ func TestCodecRoundTripPreservesOperationJSON(t *testing.T) {
 		{name: "extend operation", op: op.NewExtend([]string{"profile"}, map[string]any{"name": "Ada", "nested": map[string]any{"ok": true}}, true)},
 		{name: "merge without props", op: op.NewMerge([]string{"nodes", "1"}, 1, nil)},
 		{name: "merge with props", op: op.NewMerge([]string{"nodes", "1"}, 1, map[string]any{"merged": true})},
+		{name: "merge patch", op: op.NewMergePatch([]string{"profile"}, map[string]any{"name": "Ada", "age": nil})},
 		{name: "and predicate", op: op.NewAnd([]string{"profile"}, []any{
 			op.NewDefined([]string{"profile", "name"}),
 			op.NewContains([]string{"profile", "role"}, "admin"),
@@ -590,6 +592,117 @@ func TestCodecDecodeRejectsMalformedOperations(t *testing.T) {
 	}
 }
 
+func TestCodecDecodeEnforcesLimits(t *testing.T) {
+	t.Parallel()
+
+	nested := op.NewAnd([]string{}, []any{
+		op.NewOr([]string{}, []any{op.NewDefined([]string{"name"})}),
+	})
+
+	tests := []struct {
+		name   string
+		ops    []internal.Op
+		limits Limits
+	}{
+		{
+			name:   "operation count",
+			ops:    []internal.Op{op.NewRemove([]string{"a"}), op.NewRemove([]string{"b"})},
+			limits: Limits{MaxOperations: 1},
+		},
+		{
+			name:   "predicate depth",
+			ops:    []internal.Op{nested},
+			limits: Limits{MaxPredicateDepth: 1},
+		},
+		{
+			name:   "pointer segments",
+			ops:    []internal.Op{op.NewRemove([]string{"a", "b", "c"})},
+			limits: Limits{MaxPointerSegments: 2},
+		},
+		{
+			name:   "escaped pointer length",
+			ops:    []internal.Op{op.NewMove([]string{"a"}, []string{"a/b"})},
+			limits: Limits{MaxPointerLength: 4},
+		},
+		{
+			name:   "pattern length",
+			ops:    []internal.Op{op.NewMatches([]string{"name"}, "^(a+)+$", false, nil)},
+			limits: Limits{MaxPatternLength: 4},
+		},
+		{
+			name:   "value size",
+			ops:    []internal.Op{op.NewAdd([]string{"list"}, []any{"aaaa", "bbbb"})},
+			limits: Limits{MaxValueSize: 8},
+		},
+		{
+			name:   "string operand size",
+			ops:    []internal.Op{op.NewStrIns([]string{"text"}, 0, "hello world")},
+			limits: Limits{MaxValueSize: 8},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := New().Encode(tc.ops)
+			require.NoError(t, err)
+
+			_, err = New().Decode(data)
+			require.NoError(t, err)
+
+			decoded, err := New(WithLimits(tc.limits)).Decode(data)
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			assert.Nil(t, decoded)
+		})
+	}
+}
+
+func TestCodecDecodeRejectsOversizedHeaders(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		write func(*msgp.Writer)
+	}{
+		{
+			name: "operation count",
+			write: func(writer *msgp.Writer) {
+				require.NoError(t, writer.WriteArrayHeader(math.MaxUint32))
+			},
+		},
+		{
+			name: "path segments",
+			write: func(writer *msgp.Writer) {
+				require.NoError(t, writer.WriteArrayHeader(1))
+				require.NoError(t, writer.WriteArrayHeader(2))
+				require.NoError(t, writer.WriteUint8(internal.OpRemoveCode))
+				require.NoError(t, writer.WriteArrayHeader(math.MaxUint32))
+			},
+		},
+		{
+			name: "value elements",
+			write: func(writer *msgp.Writer) {
+				require.NoError(t, writer.WriteArrayHeader(1))
+				require.NoError(t, writer.WriteArrayHeader(3))
+				require.NoError(t, writer.WriteUint8(internal.OpAddCode))
+				writeBinaryPath(t, writer, "list")
+				require.NoError(t, writer.WriteArrayHeader(math.MaxUint32))
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			decoded, err := New().Decode(binaryFixture(t, tc.write))
+			require.ErrorIs(t, err, ErrLimitExceeded)
+			assert.Nil(t, decoded)
+		})
+	}
+}
+
 func TestCodecDecodeNormalizesNestedMessagePackMaps(t *testing.T) {
 	t.Parallel()
 
@@ -2,18 +2,56 @@ package binary
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 
 	"github.com/tinylib/msgp/msgp"
 
 	"github.com/kaptinlin/jsonpatch/internal"
 )
 
+// Limits bounds untrusted payloads during Decode. A zero field is unlimited.
+type Limits = internal.Limits
+
+// Options configures the binary codec.
+type Options struct {
+	// Limits bounds decoded payloads.
+	Limits Limits
+	// CreateMatcher overrides regex compilation for decoded matches
+	// predicates.
+	CreateMatcher internal.CreateRegexMatcher
+}
+
+// Option is a functional option for configuring the codec.
+type Option func(*Options)
+
+// WithLimits sets the limits Decode enforces.
+func WithLimits(limits Limits) Option {
+	return func(o *Options) {
+		o.Limits = limits
+	}
+}
+
+// WithMatcher sets the regex matcher factory Decode builds matches
+// predicates with.
+func WithMatcher(createMatcher internal.CreateRegexMatcher) Option {
+	return func(o *Options) {
+		o.CreateMatcher = createMatcher
+	}
+}
+
 // Codec encodes and decodes JSON Patch operations in MessagePack binary format.
-type Codec struct{}
+type Codec struct {
+	options Options
+}
 
 // New creates a new binary Codec.
-func New() *Codec {
-	return &Codec{}
+func New(opts ...Option) *Codec {
+	c := &Codec{}
+	for _, opt := range opts {
+		opt(&c.options)
+	}
+	return c
 }
 
 // Encode serializes operations into MessagePack binary format.
@@ -30,8 +68,13 @@ func (c *Codec) Encode(ops []internal.Op) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// Decode deserializes operations from MessagePack binary format.
+// Decode deserializes operations from MessagePack binary format. Payloads
+// exceeding the codec limits fail with ErrLimitExceeded before the offending
+// operation is built. A failure inside an operation is an *OperationError.
 func (c *Codec) Decode(data []byte) ([]internal.Op, error) {
-	r := msgp.NewReader(bytes.NewReader(data))
-	return decodeOps(r)
+	ops, err := newDecoder(data, c.options).decodeOps()
+	if errors.Is(err, msgp.ErrLimitExceeded) {
+		return nil, fmt.Errorf("%w: %w", ErrLimitExceeded, err)
+	}
+	return ops, err
 }
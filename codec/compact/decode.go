@@ -11,23 +11,30 @@ import (
 )
 
 // Decoder decodes compact format operations.
-type Decoder struct{}
+type Decoder struct {
+	options Options
+}
 
-// NewDecoder creates a new compact decoder.
-func NewDecoder() *Decoder {
-	return &Decoder{}
+// NewDecoder creates a new compact decoder. WithMatcher and WithLimits
+// configure how it builds matches predicates.
+func NewDecoder(opts ...Option) *Decoder {
+	d := &Decoder{}
+	for _, opt := range opts {
+		opt(&d.options)
+	}
+	return d
 }
 
 // Decode decodes a single compact operation.
 func (d *Decoder) Decode(raw Op) (internal.Op, error) {
-	return parseOp(raw)
+	return d.parseOp(raw)
 }
 
 // DecodeSlice decodes multiple compact operations.
 func (d *Decoder) DecodeSlice(ops []Op) ([]internal.Op, error) {
 	result := make([]internal.Op, len(ops))
 	for i, raw := range ops {
-		parsed, err := parseOp(raw)
+		parsed, err := d.parseOp(raw)
 		if err != nil {
 			return nil, fmt.Errorf("compact operation %d: %w", i, err)
 		}
@@ -37,17 +44,17 @@ func (d *Decoder) DecodeSlice(ops []Op) ([]internal.Op, error) {
 }
 
 // Decode decodes compact format operations.
-func Decode(ops []Op) ([]internal.Op, error) {
-	return NewDecoder().DecodeSlice(ops)
+func Decode(ops []Op, opts ...Option) ([]internal.Op, error) {
+	return NewDecoder(opts...).DecodeSlice(ops)
 }
 
 // DecodeJSON decodes compact format JSON bytes into operations.
-func DecodeJSON(data []byte) ([]internal.Op, error) {
+func DecodeJSON(data []byte, opts ...Option) ([]internal.Op, error) {
 	var ops []Op
 	if err := json.Unmarshal(data, &ops); err != nil {
 		return nil, fmt.Errorf("unmarshal compact ops: %w", err)
 	}
-	return Decode(ops)
+	return Decode(ops, opts...)
 }
 
 // parseHeader extracts and validates the opcode and path from a compact operation.
@@ -70,7 +77,7 @@ func parseHeader(raw Op) (internal.OpType, []string, error) {
 }
 
 // parseOp converts a compact operation to an operation instance.
-func parseOp(raw Op) (internal.Op, error) {
+func (d *Decoder) parseOp(raw Op) (internal.Op, error) {
 	opType, path, err := parseHeader(raw)
 	if err != nil {
 		return nil, err
@@ -84,10 +91,18 @@ func parseOp(raw Op) (internal.Op, error) {
 		internal.OpStrInsType, internal.OpStrDelType,
 		internal.OpSplitType, internal.OpMergeType, internal.OpExtendType:
 		return parseExtendedOp(opType, path, raw)
+	case internal.OpMergePatchType:
+		if len(raw) < 3 {
+			return nil, ErrMergePatchMissingValue
+		}
+		return op.NewMergePatch(path, raw[2]), nil
 	case internal.OpAndType, internal.OpOrType, internal.OpNotType:
-		return parseCompositeOp(opType, path, raw)
+		return d.parseCompositeOp(opType, path, raw)
 	default:
-		return parsePredicateOp(opType, path, raw)
+		if spec, ok := internal.LookupOperation(opType); ok && spec.DecodeCompact != nil {
+			return spec.DecodeCompact(path, raw[2:])
+		}
+		return d.parsePredicateOp(opType, path, raw)
 	}
 }
 
@@ -242,7 +257,7 @@ func parseExtendedOp(opType internal.OpType, path []string, raw Op) (internal.Op
 }
 
 // parsePredicateOp decodes JSON Predicate operations.
-func parsePredicateOp(opType internal.OpType, path []string, raw Op) (internal.Op, error) {
+func (d *Decoder) parsePredicateOp(opType internal.OpType, path []string, raw Op) (internal.Op, error) {
 	switch opType {
 	case internal.OpDefinedType:
 		return op.NewDefined(path), nil
@@ -352,8 +367,12 @@ func parsePredicateOp(opType internal.OpType, path []string, raw Op) (internal.O
 		if !ok {
 			return nil, ErrMatchesPatternNotString
 		}
+		// The pattern is compiled when the operation is built.
+		if err := d.options.Limits.CheckPattern(pattern); err != nil {
+			return nil, err
+		}
 		ignoreCase := boolAt(raw, 3)
-		return op.NewMatches(path, pattern, ignoreCase, nil), nil
+		return op.NewMatches(path, pattern, ignoreCase, d.options.CreateMatcher), nil
 
 	default:
 		return nil, fmt.Errorf("%w: %s", ErrUnsupportedOp, opType)
@@ -361,7 +380,7 @@ func parsePredicateOp(opType internal.OpType, path []string, raw Op) (internal.O
 }
 
 // parseCompositeOp decodes second-order predicate operations (and, or, not).
-func parseCompositeOp(opType internal.OpType, path []string, raw Op) (internal.Op, error) {
+func (d *Decoder) parseCompositeOp(opType internal.OpType, path []string, raw Op) (internal.Op, error) {
 	if len(raw) < 3 {
 		switch opType {
 		case internal.OpAndType:
@@ -373,7 +392,7 @@ func parseCompositeOp(opType internal.OpType, path []string, raw Op) (internal.O
 		}
 	}
 
-	subOps, err := parsePredicateOps(raw[2], path)
+	subOps, err := d.parsePredicateOps(raw[2], path)
 	if err != nil {
 		return nil, err
 	}
@@ -424,7 +443,7 @@ func requireFloat64(raw Op, index int, errMissing, errNotNumber error) (float64,
 }
 
 // parsePredicateOps decodes an array of compact operations into predicate ops.
-func parsePredicateOps(value any, base []string) ([]any, error) {
+func (d *Decoder) parsePredicateOps(value any, base []string) ([]any, error) {
 	arr, ok := value.([]any)
 	if !ok {
 		return nil, ErrPredicateNotArray
@@ -440,7 +459,7 @@ func parsePredicateOps(value any, base []string) ([]any, error) {
 		if err != nil {
 			return nil, err
 		}
-		decoded, err := parseOp(merged)
+		decoded, err := d.parseOp(merged)
 		if err != nil {
 			return nil, err
 		}
@@ -1,11 +1,18 @@
 package compact
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
 
 // Base errors for compact operation validation.
 var (
 	ErrMinLength     = errors.New("compact operation must have at least opcode and path")
 	ErrPathNotString = errors.New("compact operation path must be a string array")
+	// ErrLimitExceeded indicates a matches pattern longer than the decoder
+	// limit.
+	ErrLimitExceeded = internal.ErrLimitExceeded
 )
 
 // Core operation (RFC 6902) errors.
@@ -37,6 +44,11 @@ var (
 	ErrExtendPropsNotObject = errors.New("extend operation props must be an object")
 )
 
+// Merge patch operation errors.
+var (
+	ErrMergePatchMissingValue = errors.New("merge_patch operation requires value")
+)
+
 // Predicate operation errors.
 var (
 	ErrContainsMissingValue    = errors.New("contains operation requires value")
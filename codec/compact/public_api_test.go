@@ -106,3 +106,26 @@ func (applyOnlyOp) Apply(doc any) (internal.OpResult[any], error) {
 func (applyOnlyOp) Validate() error {
 	return nil
 }
+
+func TestDecoderMatcherAndPatternLimit(t *testing.T) {
+	t.Parallel()
+
+	var compiled []string
+	matcher := WithMatcher(func(pattern string, _ bool) internal.RegexMatcher {
+		compiled = append(compiled, pattern)
+		return func(string) bool { return true }
+	})
+	nested := Op{CodeAnd, []string{"user"}, []any{[]any{CodeMatches, []string{"name"}, "^a+$"}}}
+
+	decoded, err := NewDecoder(matcher).Decode(nested)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"^a+$"}, compiled)
+	ok, err := decoded.(internal.PredicateOp).Test(map[string]any{"user": map[string]any{"name": "b"}})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	compiled = nil
+	_, err = NewDecoder(matcher, WithLimits(Limits{MaxPatternLength: 3})).Decode(nested)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Empty(t, compiled)
+}
@@ -35,6 +35,7 @@ func TestDecodeOperationFamilies(t *testing.T) {
 		{name: "split", raw: Op{CodeSplit, []string{"nodes", "0"}, 1, map[string]any{"kind": "paragraph"}}, want: internal.Operation{Op: "split", Path: "/nodes/0", Pos: 1, Props: map[string]any{"kind": "paragraph"}}},
 		{name: "merge", raw: Op{CodeMerge, []string{"nodes", "1"}, 1, map[string]any{"merged": true}}, want: internal.Operation{Op: "merge", Path: "/nodes/1", Pos: 1, Props: map[string]any{"merged": true}}},
 		{name: "extend", raw: Op{CodeExtend, []string{"profile"}, map[string]any{"name": "Ada"}, true}, want: internal.Operation{Op: "extend", Path: "/profile", Props: map[string]any{"name": "Ada"}, DeleteNull: true}},
+		{name: "merge_patch", raw: Op{CodeMergePatch, []string{"profile"}, map[string]any{"name": "Ada", "age": nil}}, want: internal.Operation{Op: "merge_patch", Path: "/profile", Value: map[string]any{"name": "Ada", "age": nil}}},
 		{name: "defined", raw: Op{CodeDefined, []string{"profile", "name"}}, want: internal.Operation{Op: "defined", Path: "/profile/name"}},
 		{name: "undefined", raw: Op{CodeUndefined, []string{"profile", "deleted"}}, want: internal.Operation{Op: "undefined", Path: "/profile/deleted"}},
 		{name: "contains", raw: Op{CodeContains, []string{"profile", "name"}, "Ad", true}, want: internal.Operation{Op: "contains", Path: "/profile/name", Value: "Ad", IgnoreCase: true}},
@@ -265,6 +266,7 @@ func TestDecodeRejectsInvalidOperationPayloads(t *testing.T) {
 		{name: "merge pos not number", raw: Op{CodeMerge, []string{"x"}, "1"}, wantErr: ErrMergePosNotNumber},
 		{name: "extend missing props", raw: Op{CodeExtend, []string{"x"}}, wantErr: ErrExtendMissingProps},
 		{name: "extend props not object", raw: Op{CodeExtend, []string{"x"}, "props"}, wantErr: ErrExtendPropsNotObject},
+		{name: "merge_patch missing value", raw: Op{CodeMergePatch, []string{"x"}}, wantErr: ErrMergePatchMissingValue},
 		{name: "contains missing value", raw: Op{CodeContains, []string{"x"}}, wantErr: ErrContainsMissingValue},
 		{name: "contains value not string", raw: Op{CodeContains, []string{"x"}, 1}, wantErr: ErrContainsValueNotString},
 		{name: "starts missing value", raw: Op{CodeStarts, []string{"x"}}, wantErr: ErrStartsMissingValue},
@@ -29,6 +29,9 @@ const (
 	CodeMerge  Code = Code(internal.OpMergeCode)
 	CodeExtend Code = Code(internal.OpExtendCode)
 
+	// JSON Merge Patch
+	CodeMergePatch Code = Code(internal.OpMergePatchCode)
+
 	// JSON Predicate
 	CodeContains      Code = Code(internal.OpContainsCode)
 	CodeDefined       Code = Code(internal.OpDefinedCode)
@@ -51,13 +54,24 @@ const (
 // Op represents a compact format operation as an array.
 type Op []any
 
-// Options configures the compact encoder behavior.
+// Limits bounds untrusted payloads during decoding. A zero field is
+// unlimited.
+type Limits = internal.Limits
+
+// Options configures the compact encoder and decoder.
 type Options struct {
 	// StringOpcode uses string opcodes instead of numeric ones.
 	StringOpcode bool
+	// CreateMatcher overrides regex compilation for decoded matches
+	// predicates.
+	CreateMatcher internal.CreateRegexMatcher
+	// Limits bounds decoded payloads. The decoder checks the pattern length
+	// of matches predicates before compiling them; callers check the other
+	// limits on the decoded operations.
+	Limits Limits
 }
 
-// Option is a functional option for configuring the encoder.
+// Option is a functional option for configuring the encoder or decoder.
 type Option func(*Options)
 
 // WithStringOpcode configures the encoder to use string opcodes.
@@ -67,5 +81,20 @@ func WithStringOpcode(useString bool) Option {
 	}
 }
 
+// WithMatcher sets the regex matcher factory the decoder builds matches
+// predicates with.
+func WithMatcher(createMatcher internal.CreateRegexMatcher) Option {
+	return func(o *Options) {
+		o.CreateMatcher = createMatcher
+	}
+}
+
+// WithLimits sets the limits the decoder enforces.
+func WithLimits(limits Limits) Option {
+	return func(o *Options) {
+		o.Limits = limits
+	}
+}
+
 // Operation represents a compact format operation.
 type Operation = internal.CompactOperation
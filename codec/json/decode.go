@@ -92,9 +92,17 @@ func decodeOp(m map[string]any, opts internal.JSONPatchOptions) (internal.Op, er
 		return decodeCoreOp(opType, path, m)
 	case "flip", "inc", "str_ins", "str_del", "split", "merge", "extend":
 		return decodeExtendedOp(opType, path, m)
+	case "merge_patch":
+		if _, ok := m["value"]; !ok {
+			return nil, ErrMergePatchOpMissingValue
+		}
+		return op.NewMergePatch(path, m["value"]), nil
 	case "not":
 		return decodeNotOp(path, m, opts)
 	default:
+		if spec, ok := internal.LookupOperation(internal.OpType(opType)); ok && spec.DecodeJSON != nil {
+			return spec.DecodeJSON(path, m)
+		}
 		return decodePredicateOp(opType, path, m, opts)
 	}
 }
@@ -635,7 +643,7 @@ func setValueField(m map[string]any, o *internal.Operation, _ bool) {
 
 func operationAllowsNullValue(op string) bool {
 	switch op {
-	case "add", "replace", "test":
+	case "add", "replace", "test", "merge_patch":
 		return true
 	default:
 		return false
@@ -35,6 +35,7 @@ func TestDecodeOperationFamilies(t *testing.T) {
 		{name: "split", raw: map[string]any{"op": "split", "path": "/nodes/0", "pos": 1, "props": map[string]any{"kind": "paragraph"}}, want: internal.Operation{Op: "split", Path: "/nodes/0", Pos: 1, Props: map[string]any{"kind": "paragraph"}}},
 		{name: "merge", raw: map[string]any{"op": "merge", "path": "/nodes/1", "pos": 1, "props": map[string]any{"merged": true}}, want: internal.Operation{Op: "merge", Path: "/nodes/1", Pos: 1, Props: map[string]any{"merged": true}}},
 		{name: "extend", raw: map[string]any{"op": "extend", "path": "/profile", "props": map[string]any{"name": "Ada"}, "deleteNull": true}, want: internal.Operation{Op: "extend", Path: "/profile", Props: map[string]any{"name": "Ada"}, DeleteNull: true}},
+		{name: "merge_patch", raw: map[string]any{"op": "merge_patch", "path": "", "value": map[string]any{"name": nil}}, want: internal.Operation{Op: "merge_patch", Value: map[string]any{"name": nil}}},
 		{name: "defined", raw: map[string]any{"op": "defined", "path": "/profile/name"}, want: internal.Operation{Op: "defined", Path: "/profile/name"}},
 		{name: "undefined", raw: map[string]any{"op": "undefined", "path": "/profile/deleted"}, want: internal.Operation{Op: "undefined", Path: "/profile/deleted"}},
 		{name: "type", raw: map[string]any{"op": "type", "path": "/profile/name", "value": "string"}, want: internal.Operation{Op: "type", Path: "/profile/name", Value: "string"}},
@@ -213,6 +214,7 @@ func TestDecodeRejectsInvalidOperationPayloads(t *testing.T) {
 		{name: "str_del missing pos", raw: map[string]any{"op": "str_del", "path": "/x", "len": 1}, wantErr: ErrStrDelOpMissingPos},
 		{name: "str_del missing len or str", raw: map[string]any{"op": "str_del", "path": "/x", "pos": 1, "len": struct{}{}}, wantErr: ErrStrDelOpMissingFields},
 		{name: "split missing pos", raw: map[string]any{"op": "split", "path": "/x"}, wantErr: ErrSplitOpMissingPos},
+		{name: "merge_patch missing value", raw: map[string]any{"op": "merge_patch", "path": ""}, wantErr: ErrMergePatchOpMissingValue},
 		{name: "extend props not object", raw: map[string]any{"op": "extend", "path": "/x", "props": "props"}, wantErr: ErrValueNotObject},
 		{name: "test missing value", raw: map[string]any{"op": "test", "path": "/x"}, wantErr: ErrMissingValueField},
 		{name: "test null value", raw: map[string]any{"op": "test", "path": "/x", "value": nil}},
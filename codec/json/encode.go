@@ -2,10 +2,14 @@ package json
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/go-json-experiment/json"
 
+	"github.com/kaptinlin/jsonpointer"
+
 	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/op"
 )
 
 // Encode converts Op instances to Operation structs.
@@ -33,3 +37,35 @@ func EncodeJSON(ops []internal.Op) ([]byte, error) {
 	}
 	return json.Marshal(result)
 }
+
+// MarshalOperations converts Operation values to JSON bytes that DecodeJSON
+// reads back to the same operations. Each operation carries only the members
+// its op defines. Operand paths of composite predicates must already be
+// relative to their own path, as RelativizeOperands leaves them.
+func MarshalOperations(operations []Operation) ([]byte, error) {
+	maps := make([]map[string]any, len(operations))
+	for i := range operations {
+		maps[i] = operationToMap(&operations[i], false)
+	}
+	return json.Marshal(maps)
+}
+
+// RelativizeOperands rewrites the absolute operand paths of a composite
+// predicate, as Encode projects them, relative to the composite path, which
+// is how DecodeJSON reads them. An operand outside its composite fails with
+// op.ErrPredicatePathOutsideParent.
+func RelativizeOperands(operation *Operation) error {
+	base := jsonpointer.Parse(operation.Path)
+	for i := range operation.Apply {
+		operand := &operation.Apply[i]
+		if err := RelativizeOperands(operand); err != nil {
+			return err
+		}
+		path := jsonpointer.Parse(operand.Path)
+		if len(path) < len(base) || !slices.Equal(path[:len(base)], base) {
+			return fmt.Errorf("%w: %q under %q", op.ErrPredicatePathOutsideParent, operand.Path, operation.Path)
+		}
+		operand.Path = jsonpointer.Format(path[len(base):]...)
+	}
+	return nil
+}
@@ -34,6 +34,11 @@ var (
 	ErrInvalidBooleanField   = errors.New("boolean field has invalid type")
 )
 
+// Errors for merge patch operation decoding.
+var (
+	ErrMergePatchOpMissingValue = errors.New("merge_patch operation missing 'value' field")
+)
+
 // Errors for predicate operation decoding.
 var (
 	ErrTypeOpMissingValue        = errors.New("type operation missing string 'value' field")
@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/op"
 )
 
 func TestDecodeTestTypeStringArray(t *testing.T) {
@@ -64,6 +65,39 @@ func TestDecodeJSONRejectsInvalidPayload(t *testing.T) {
 	assert.Nil(t, ops)
 }
 
+func TestMarshalOperationsKeepsOnlyOperationMembers(t *testing.T) {
+	t.Parallel()
+
+	input := `[{"op":"add","path":"/name","value":""},` +
+		`{"op":"and","path":"/a","apply":[{"op":"test_type","path":"/a/b","type":"string"}]},` +
+		`{"op":"inc","path":"/count","inc":0}]`
+	ops, err := DecodeJSON([]byte(input), PatchOptions{})
+	require.NoError(t, err)
+
+	operations, err := Encode(ops)
+	require.NoError(t, err)
+	assert.Equal(t, "/a/a/b", operations[1].Apply[0].Path)
+	for i := range operations {
+		require.NoError(t, RelativizeOperands(&operations[i]))
+	}
+	assert.Equal(t, "/a/b", operations[1].Apply[0].Path)
+
+	data, err := MarshalOperations(operations)
+	require.NoError(t, err)
+	assert.JSONEq(t, input, string(data))
+
+	roundTrip, err := DecodeJSON(data, PatchOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, ops, roundTrip)
+}
+
+func TestRelativizeOperandsRejectsOutsidePath(t *testing.T) {
+	t.Parallel()
+
+	operation := Operation{Op: "and", Path: "/a", Apply: []Operation{{Op: "defined", Path: "/b"}}}
+	require.ErrorIs(t, RelativizeOperands(&operation), op.ErrPredicatePathOutsideParent)
+}
+
 func TestEncodeRejectsOperationWithoutJSONProjection(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,297 @@
+package jsonpatch
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/kaptinlin/deepclone"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// Compose returns one optimized patch with the effect of applying p1 and then
+// p2. Operations keep the vocabulary they were compiled with.
+func Compose(p1, p2 *Patch) (*Patch, error) {
+	if p1 == nil || p2 == nil {
+		return nil, newPayloadError("", errors.New("nil patch"))
+	}
+	return optimizeOps(slices.Concat(p1.ops, p2.ops)), nil
+}
+
+// Optimize returns an equivalent patch with redundant operations squashed.
+// Writes into a value added or replaced earlier in the patch are folded into
+// that value, consecutive inc operations on one path are summed, and adjacent
+// str_ins and str_del edits are merged. Operations on unrelated paths do not
+// block these rewrites.
+//
+// The optimized patch produces the same document as p on every document p
+// applies to. Step and error indexes refer to the optimized operations.
+func (p *Patch) Optimize() (*Patch, error) {
+	if p == nil {
+		return nil, newPayloadError("", errors.New("nil patch"))
+	}
+	return optimizeOps(p.ops), nil
+}
+
+func optimizeOps(ops []Op) *Patch {
+	var o optimizer
+	for _, operation := range ops {
+		o.push(operation)
+	}
+	return &Patch{ops: o.ops}
+}
+
+// optimizer squashes operations as they are pushed. Every operation it holds
+// is already compiled, so rewritten operations are built from cloned values
+// and the rest are shared with the source patch.
+type optimizer struct {
+	ops []Op
+}
+
+// push appends next, first trying to combine it with an earlier operation it
+// can be reordered with. A combined result is pushed again so rewrites
+// cascade.
+func (o *optimizer) push(next Op) {
+	for i := len(o.ops) - 1; i >= 0; i-- {
+		if combined, ok := o.combine(i, next); ok {
+			suffix := slices.Clone(o.ops[i+1:])
+			o.ops = o.ops[:i]
+			for _, operation := range combined {
+				o.push(operation)
+			}
+			o.ops = append(o.ops, suffix...)
+			return
+		}
+		if !commutes(o.ops[i], next) {
+			break
+		}
+	}
+	o.ops = append(o.ops, next)
+}
+
+// combine returns the operations equivalent to o.ops[i] followed by next.
+func (o *optimizer) combine(i int, next Op) ([]Op, bool) {
+	switch prev := o.ops[i].(type) {
+	case *oppkg.AddOperation:
+		path := prev.Path()
+		if remove, ok := next.(*oppkg.RemoveOperation); ok && slices.Equal(remove.Path(), path) {
+			// The add may have overwritten an existing member, so the pair
+			// only cancels when the member was just removed.
+			if i > 0 && isRemoveAt(o.ops[i-1], path) {
+				return nil, true
+			}
+			return nil, false
+		}
+		if value, ok := foldInto(path, prev.Value, next); ok {
+			return []Op{oppkg.NewAdd(path, value)}, true
+		}
+	case *oppkg.ReplaceOperation:
+		path := prev.Path()
+		if isRemoveAt(next, path) {
+			return []Op{next}, true
+		}
+		if value, ok := foldInto(path, prev.Value, next); ok {
+			return []Op{oppkg.NewReplaceWithOldValue(path, value, prev.OldValue)}, true
+		}
+	case *oppkg.IncOperation:
+		if inc, ok := next.(*oppkg.IncOperation); ok && slices.Equal(inc.Path(), prev.Path()) {
+			return []Op{oppkg.NewInc(prev.Path(), prev.Inc+inc.Inc)}, true
+		}
+	case *oppkg.StrInsOperation:
+		if insert, ok := next.(*oppkg.StrInsOperation); ok && slices.Equal(insert.Path(), prev.Path()) {
+			return mergeStrIns(prev, insert)
+		}
+	case *oppkg.StrDelOperation:
+		if del, ok := next.(*oppkg.StrDelOperation); ok && slices.Equal(del.Path(), prev.Path()) {
+			return mergeStrDel(prev, del)
+		}
+	}
+	return nil, false
+}
+
+func isRemoveAt(operation Op, path []string) bool {
+	remove, ok := operation.(*oppkg.RemoveOperation)
+	return ok && slices.Equal(remove.Path(), path)
+}
+
+// foldInto applies next to a copy of the value written at path and returns
+// the result. It fails when next reaches outside that value, changes the
+// shape of its parent, or does not apply.
+func foldInto(path []string, value any, next Op) (any, bool) {
+	if slices.Contains(path, "-") {
+		return nil, false
+	}
+	touched, ok := touches(next)
+	if !ok {
+		return nil, false
+	}
+	for _, t := range touched {
+		if !hasPrefix(t.path, path) {
+			return nil, false
+		}
+		if len(t.path) == len(path) && t.kind == touchReshape {
+			return nil, false
+		}
+	}
+
+	// Parents of path are stand-in objects, so next only sees the value.
+	var doc any = deepclone.Clone(value)
+	for _, key := range slices.Backward(path) {
+		doc = map[string]any{key: doc}
+	}
+	result, err := next.Apply(doc)
+	if err != nil {
+		return nil, false
+	}
+	folded := result.Doc
+	for _, key := range path {
+		parent, ok := folded.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		folded = parent[key]
+	}
+	return folded, true
+}
+
+// mergeStrIns merges an insertion that starts inside or right after the text
+// inserted by prev. Unless the new text continues prev, prev must insert at
+// the start of the string, because a position past the end is clamped.
+func mergeStrIns(prev, next *oppkg.StrInsOperation) ([]Op, bool) {
+	offset := next.Pos - prev.Pos
+	length := utf8.RuneCountInString(prev.Str)
+	if prev.Pos < 0 || next.Pos < 0 {
+		return nil, false
+	}
+	if offset != length && (prev.Pos != 0 || offset < 0 || offset > length) {
+		return nil, false
+	}
+
+	runes := []rune(prev.Str)
+	str := string(runes[:offset]) + next.Str + string(runes[offset:])
+	return []Op{oppkg.NewStrIns(prev.Path(), float64(prev.Pos), str)}, true
+}
+
+// mergeStrDel merges a deletion whose range touches the position where prev
+// deleted, such as repeated backspace or forward delete.
+func mergeStrDel(prev, next *oppkg.StrDelOperation) ([]Op, bool) {
+	prevLen, nextLen := deletionLength(prev), deletionLength(next)
+	if prev.Pos < 0 || next.Pos < 0 || prevLen <= 0 || nextLen <= 0 {
+		return nil, false
+	}
+	if next.Pos > prev.Pos || prev.Pos > next.Pos+nextLen {
+		return nil, false
+	}
+
+	if prev.HasStr && next.HasStr {
+		runes := []rune(next.Str)
+		offset := prev.Pos - next.Pos
+		str := string(runes[:offset]) + prev.Str + string(runes[offset:])
+		return []Op{oppkg.NewStrDelWithStr(prev.Path(), float64(next.Pos), str)}, true
+	}
+	return []Op{oppkg.NewStrDel(prev.Path(), float64(next.Pos), float64(prevLen+nextLen))}, true
+}
+
+func deletionLength(del *oppkg.StrDelOperation) int {
+	if del.HasStr {
+		return utf8.RuneCountInString(del.Str)
+	}
+	return del.Len
+}
+
+type touchKind int
+
+const (
+	// touchValue reads or updates an existing value in place.
+	touchValue touchKind = iota
+	// touchUpsert updates a value, or appends it when the target is missing.
+	touchUpsert
+	// touchReshape inserts or removes the target, shifting array siblings.
+	touchReshape
+)
+
+type touch struct {
+	path []string
+	kind touchKind
+}
+
+// touches lists the paths an operation reads or writes. Operations it does
+// not know, including second-order predicates and wildcard operations, report
+// false and are never reordered.
+func touches(operation Op) ([]touch, bool) {
+	switch typed := operation.(type) {
+	case *wildcardOp:
+		return nil, false
+	case *oppkg.AddOperation, *oppkg.RemoveOperation, *oppkg.SplitOperation:
+		return []touch{{operation.Path(), touchReshape}}, true
+	case *oppkg.MoveOperation:
+		return []touch{{typed.Path(), touchReshape}, {typed.From(), touchReshape}}, true
+	case *oppkg.CopyOperation:
+		return []touch{{typed.Path(), touchReshape}, {typed.From(), touchValue}}, true
+	case *oppkg.FlipOperation, *oppkg.MergePatchOperation:
+		return []touch{{operation.Path(), touchUpsert}}, true
+	case *oppkg.ReplaceOperation, *oppkg.IncOperation, *oppkg.StrInsOperation,
+		*oppkg.StrDelOperation, *oppkg.ExtendOperation, *oppkg.MergeOperation:
+		return []touch{{operation.Path(), touchValue}}, true
+	}
+
+	spec, ok := internal.LookupOperation(operation.Op())
+	if !ok || spec.Families&(internal.FamilyFirstOrderPredicate|internal.FamilyRegexPredicate) == 0 ||
+		spec.Families&internal.FamilySecondOrderPredicate != 0 {
+		return nil, false
+	}
+	return []touch{{operation.Path(), touchValue}}, true
+}
+
+// commutes reports whether a and b give the same result in either order. They
+// must not touch overlapping paths, and neither may shift array indexes in
+// the container where their paths diverge. A member name that is not an
+// array index proves that container is an object.
+func commutes(a, b Op) bool {
+	aTouched, ok := touches(a)
+	if !ok {
+		return false
+	}
+	bTouched, ok := touches(b)
+	if !ok {
+		return false
+	}
+	for _, x := range aTouched {
+		for _, y := range bTouched {
+			if hasPrefix(x.path, y.path) || hasPrefix(y.path, x.path) {
+				return false
+			}
+			depth := commonPrefixLen(x.path, y.path)
+			if !isIndexToken(x.path[depth]) || !isIndexToken(y.path[depth]) {
+				continue
+			}
+			if (len(x.path) == depth+1 && x.kind != touchValue) || (len(y.path) == depth+1 && y.kind != touchValue) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(prefix) <= len(path) && slices.Equal(path[:len(prefix)], prefix)
+}
+
+func isIndexToken(token string) bool {
+	if token == "-" {
+		return true
+	}
+	_, err := strconv.Atoi(token)
+	return err == nil
+}
+
+func commonPrefixLen(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
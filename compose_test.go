@@ -0,0 +1,230 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestPatchOptimizeSquashesOperations(t *testing.T) {
+	t.Parallel()
+
+	newDoc := func() map[string]any {
+		return map[string]any{
+			"name":  "Ada",
+			"count": float64(1),
+			"text":  "hello",
+			"list":  []any{"a", "b", "c"},
+			"items": []any{map[string]any{"id": float64(1), "name": "o"}},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		ops   []jsonpatch.Op
+		steps []string
+	}{
+		{
+			name: "add then replace",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"email"}, "ada@example.com"),
+				op.NewReplace([]string{"email"}, "ada@example.org"),
+			},
+			steps: []string{"add /email"},
+		},
+		{
+			name: "replace then replace",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"name"}, "Grace"),
+				op.NewReplace([]string{"name"}, "Hopper"),
+			},
+			steps: []string{"replace /name"},
+		},
+		{
+			name: "replace then remove",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"name"}, "Grace"),
+				op.NewRemove([]string{"name"}),
+			},
+			steps: []string{"remove /name"},
+		},
+		{
+			name: "add then remove after remove",
+			ops: []jsonpatch.Op{
+				op.NewRemove([]string{"name"}),
+				op.NewAdd([]string{"name"}, "Grace"),
+				op.NewRemove([]string{"name"}),
+			},
+			steps: []string{"remove /name"},
+		},
+		{
+			name: "add then remove may overwrite",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"name"}, "Grace"),
+				op.NewRemove([]string{"name"}),
+			},
+			steps: []string{"add /name", "remove /name"},
+		},
+		{
+			name: "writes into added value",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"user"}, map[string]any{}),
+				op.NewAdd([]string{"user", "name"}, "Ada"),
+				op.NewAdd([]string{"user", "tags"}, []any{}),
+				op.NewAdd([]string{"user", "tags", "-"}, "admin"),
+				op.NewInc([]string{"user", "visits"}, 1),
+				op.NewTest([]string{"user", "name"}, "Ada"),
+				op.NewAdd([]string{"user", "draft"}, true),
+				op.NewRemove([]string{"user", "draft"}),
+			},
+			steps: []string{"add /user"},
+		},
+		{
+			name: "consecutive inc across unrelated write",
+			ops: []jsonpatch.Op{
+				op.NewInc([]string{"count"}, 1),
+				op.NewReplace([]string{"name"}, "Grace"),
+				op.NewInc([]string{"count"}, 2),
+			},
+			steps: []string{"inc /count", "replace /name"},
+		},
+		{
+			name: "typed str_ins",
+			ops: []jsonpatch.Op{
+				op.NewStrIns([]string{"text"}, 5, " "),
+				op.NewStrIns([]string{"text"}, 6, "w"),
+				op.NewStrIns([]string{"text"}, 7, "orld"),
+			},
+			steps: []string{"str_ins /text"},
+		},
+		{
+			name: "backspace str_del",
+			ops: []jsonpatch.Op{
+				op.NewStrDel([]string{"text"}, 4, 1),
+				op.NewStrDel([]string{"text"}, 3, 1),
+				op.NewStrDel([]string{"text"}, 2, 1),
+			},
+			steps: []string{"str_del /text"},
+		},
+		{
+			name: "array shift blocks reordering",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"list", "1"}, "x"),
+				op.NewAdd([]string{"list", "0"}, "first"),
+				op.NewReplace([]string{"list", "1"}, "y"),
+			},
+			steps: []string{"replace /list/1", "add /list/0", "replace /list/1"},
+		},
+		{
+			name: "key segment blocks reordering",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"items", "[id=1]", "name"}, "a"),
+				op.NewReplace([]string{"items", "0", "name"}, "q"),
+				op.NewReplace([]string{"items", "[id=1]", "name"}, "b"),
+			},
+			steps: []string{"replace /items/0/name", "replace /items/0/name", "replace /items/0/name"},
+		},
+		{
+			name: "failing test is kept",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"flag"}, true),
+				op.NewTest([]string{"flag"}, true),
+			},
+			steps: []string{"add /flag"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileOps(tt.ops, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities, jsonpatch.KeySegments))
+			require.NoError(t, err)
+			optimized, err := patch.Optimize()
+			require.NoError(t, err)
+
+			expected, err := jsonpatch.Apply(patch, newDoc())
+			require.NoError(t, err)
+			result, err := jsonpatch.Apply(optimized, newDoc())
+			require.NoError(t, err)
+			assert.Equal(t, expected.Doc, result.Doc)
+
+			steps := make([]string, 0, len(result.Steps))
+			for _, step := range result.Steps {
+				steps = append(steps, step.Op()+" "+step.Path())
+			}
+			assert.Equal(t, tt.steps, steps)
+		})
+	}
+}
+
+func TestPatchOptimizeKeepsFailures(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewAdd([]string{"flag"}, true),
+		op.NewTest([]string{"flag"}, false),
+	)
+	require.NoError(t, err)
+
+	optimized, err := patch.Optimize()
+	require.NoError(t, err)
+	assert.Equal(t, 2, optimized.Len())
+
+	_, err = jsonpatch.Apply(optimized, map[string]any{})
+	require.ErrorIs(t, err, jsonpatch.ErrTestFailed)
+}
+
+func TestPatchOptimizeKeepsKeyMemberWrites(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewReplace([]string{"items", "[id=1]", "name"}, "a"),
+		op.NewReplace([]string{"items", "[id=1]", "id"}, float64(2)),
+		op.NewReplace([]string{"items", "[id=1]", "name"}, "b"),
+	}, keyCaps)
+	require.NoError(t, err)
+
+	optimized, err := patch.Optimize()
+	require.NoError(t, err)
+	assert.Equal(t, 3, optimized.Len())
+
+	_, err = jsonpatch.Apply(optimized, map[string]any{"items": []any{map[string]any{"id": float64(1)}}})
+	require.ErrorIs(t, err, op.ErrPathNotFound)
+}
+
+func TestCompose(t *testing.T) {
+	t.Parallel()
+
+	first, err := jsonpatch.Compile(
+		op.NewAdd([]string{"title"}, "Draft"),
+		op.NewAdd([]string{"tags"}, []any{"go"}),
+	)
+	require.NoError(t, err)
+	second, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewStrIns([]string{"title"}, 5, " 1"),
+		op.NewAdd([]string{"tags", "-"}, "json"),
+	}, jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Extended))
+	require.NoError(t, err)
+
+	composed, err := jsonpatch.Compose(first, second)
+	require.NoError(t, err)
+	assert.Equal(t, 2, composed.Len())
+	assert.Equal(t, 2, first.Len())
+	assert.Equal(t, 2, second.Len())
+
+	result, err := jsonpatch.Apply(composed, map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"title": "Draft 1", "tags": []any{"go", "json"}}, result.Doc)
+
+	_, err = jsonpatch.Compose(first, nil)
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+
+	var patch *jsonpatch.Patch
+	_, err = patch.Optimize()
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+}
@@ -0,0 +1,199 @@
+package jsonpatch
+
+import (
+	"strconv"
+
+	"github.com/kaptinlin/jsonpointer"
+)
+
+// ConflictKind classifies how two operations from concurrent patches
+// collide.
+type ConflictKind int
+
+const (
+	// ConflictWriteWrite reports two operations that write the same value,
+	// or a value and one of its ancestors.
+	ConflictWriteWrite ConflictKind = iota + 1
+	// ConflictWriteRead reports an operation that writes a value the other
+	// reads, such as the target of a test or the from of a copy.
+	ConflictWriteRead
+	// ConflictStructural reports an operation that inserts or removes an
+	// array element before an element the other addresses, shifting its
+	// index.
+	ConflictStructural
+)
+
+// String returns "write/write", "write/read", or "structural".
+func (k ConflictKind) String() string {
+	switch k {
+	case ConflictWriteWrite:
+		return "write/write"
+	case ConflictWriteRead:
+		return "write/read"
+	case ConflictStructural:
+		return "structural"
+	default:
+		return "ConflictKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Conflict reports one pair of colliding operations.
+type Conflict struct {
+	Kind ConflictKind
+	// A and B are the indexes of the operations in the two patches.
+	A, B int
+	// APath and BPath are the JSON Pointers through which the operations
+	// collide. For a structural conflict, one is the inserted or removed
+	// element and the other the element whose index it shifts.
+	APath, BPath string
+}
+
+// Conflicts reports the operations of a and b, two patches made against the
+// same document, that touch overlapping values. Each pair of operations is
+// reported at most once, as its first kind in declaration order, ordered by
+// the index in a and then in b. A nil patch has no operations.
+//
+// Pointers are compared as written, using the reads and writes reported by
+// Analyze. A segment that is a decimal number is taken to be an array index.
+// In a patch compiled with KeySegments, a [name=value] key segment is taken
+// to possibly select the same element as any index or other key segment in
+// its position.
+func Conflicts(a, b *Patch) []Conflict {
+	as, bs := conflictAccesses(a), conflictAccesses(b)
+	var conflicts []Conflict
+	for i, x := range as {
+		for j, y := range bs {
+			if conflict, ok := conflictBetween(x, y); ok {
+				conflict.A, conflict.B = i, j
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+	return conflicts
+}
+
+// conflictAccess holds what one operation reads, writes, and shifts.
+type conflictAccess struct {
+	reads, writes [][]string
+	// shifts are the array elements the operation inserts or removes.
+	shifts []arrayShift
+	// keys is set when the operation resolves key segments.
+	keys bool
+}
+
+type arrayShift struct {
+	path   []string
+	index  int
+	insert bool
+}
+
+func conflictAccesses(p *Patch) []conflictAccess {
+	if p == nil {
+		return nil
+	}
+	result := make([]conflictAccess, len(p.ops))
+	for i, operation := range p.ops {
+		access := &result[i]
+		access.reads, access.writes = accesses(operation)
+		if wildcard, ok := operation.(*wildcardOp); ok {
+			access.keys = wildcard.keys
+		}
+		switch operation.Op() {
+		case OpAddType, OpCopyType:
+			access.addShift(operation.Path(), true)
+		case OpRemoveType:
+			access.addShift(operation.Path(), false)
+		case OpMoveType:
+			access.addShift(operationFrom(operation), false)
+			access.addShift(operation.Path(), true)
+		}
+	}
+	return result
+}
+
+func (c *conflictAccess) addShift(path []string, insert bool) {
+	if len(path) == 0 {
+		return
+	}
+	index, err := strconv.Atoi(path[len(path)-1])
+	if err != nil || index < 0 {
+		return
+	}
+	c.shifts = append(c.shifts, arrayShift{path: path, index: index, insert: insert})
+}
+
+func conflictBetween(x, y conflictAccess) (Conflict, bool) {
+	keys := x.keys || y.keys
+	if xPath, yPath, ok := overlap(x.writes, y.writes, keys); ok {
+		return newConflict(ConflictWriteWrite, xPath, yPath), true
+	}
+	if xPath, yPath, ok := overlap(x.writes, y.reads, keys); ok {
+		return newConflict(ConflictWriteRead, xPath, yPath), true
+	}
+	if yPath, xPath, ok := overlap(y.writes, x.reads, keys); ok {
+		return newConflict(ConflictWriteRead, xPath, yPath), true
+	}
+	if xPath, yPath, ok := shifted(x.shifts, y, keys); ok {
+		return newConflict(ConflictStructural, xPath, yPath), true
+	}
+	if yPath, xPath, ok := shifted(y.shifts, x, keys); ok {
+		return newConflict(ConflictStructural, xPath, yPath), true
+	}
+	return Conflict{}, false
+}
+
+func newConflict(kind ConflictKind, aPath, bPath []string) Conflict {
+	return Conflict{Kind: kind, APath: jsonpointer.Format(aPath...), BPath: jsonpointer.Format(bPath...)}
+}
+
+// overlap returns the first pair of paths where one is the other or one of
+// its ancestors. With keys, a key segment may be any index.
+func overlap(xs, ys [][]string, keys bool) (x, y []string, ok bool) {
+	for _, x := range xs {
+		for _, y := range ys {
+			if mayHavePrefix(x, y, keys) || mayHavePrefix(y, x, keys) {
+				return x, y, true
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// mayHavePrefix reports whether path may lie at or below prefix. With keys,
+// a key segment is taken to select the element of any index or other key
+// segment.
+func mayHavePrefix(path, prefix []string, keys bool) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, segment := range prefix {
+		if segment != path[i] && !(keys && mayAlias(segment, path[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// shifted returns the first shift that moves an element addressed by other.
+// An insertion shifts the element at its index and after it; a removal
+// shifts the elements after it. An element addressed by a key segment is not
+// moved; overlap covers the chance that it is the element inserted or
+// removed.
+func shifted(shifts []arrayShift, other conflictAccess, keys bool) (shift, path []string, ok bool) {
+	for _, s := range shifts {
+		depth := len(s.path) - 1
+		for _, paths := range [][][]string{other.writes, other.reads} {
+			for _, path := range paths {
+				if len(path) <= depth || !mayHavePrefix(path, s.path[:depth], keys) {
+					continue
+				}
+				index, err := strconv.Atoi(path[depth])
+				if err != nil || index < s.index || (index == s.index && !s.insert) {
+					continue
+				}
+				return s.path, path, true
+			}
+		}
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,165 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+)
+
+func TestConflicts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a, b func(*jsonpatch.Builder) *jsonpatch.Builder
+		want []jsonpatch.Conflict
+	}{
+		{
+			name: "write inside removed subtree",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/3", "x") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/items") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteWrite, APath: "/items/3", BPath: "/items"}},
+		},
+		{
+			name: "same field",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/name", "Ada") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/name", "Grace") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteWrite, APath: "/name", BPath: "/name"}},
+		},
+		{
+			name: "write under test",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/user", map[string]any{}) },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Add("/user/name", "Ada") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteRead, APath: "/user", BPath: "/user/name"}},
+		},
+		{
+			name: "copy source written",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Inc("/count", 1) },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Copy("/backup", "/count") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteRead, APath: "/count", BPath: "/count"}},
+		},
+		{
+			name: "composite operand",
+			a: func(b *jsonpatch.Builder) *jsonpatch.Builder {
+				return b.And("/user", func(b *jsonpatch.Builder) { b.Defined("/name").Less("/age", 30) })
+			},
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/user/age") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteRead, APath: "/user/age", BPath: "/user/age"}},
+		},
+		{
+			name: "removal shifts later element",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/items/1") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/3/title", "x") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictStructural, APath: "/items/1", BPath: "/items/3/title"}},
+		},
+		{
+			name: "insertion shifts later element",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/items/3/id", 7) },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Copy("/items/2", "/template") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictStructural, APath: "/items/3/id", BPath: "/items/2"}},
+		},
+		{
+			name: "move source shifts",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Move("/archive", "/items/0") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/items/4") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictStructural, APath: "/items/0", BPath: "/items/4"}},
+		},
+		{
+			name: "earlier element is not shifted",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/items/3") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/1", "x") },
+		},
+		{
+			name: "append does not shift",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Add("/items/-", "x") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/1", "y") },
+		},
+		{
+			name: "object member name is not an index",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/user/1") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/user/name", "x") },
+		},
+		{
+			name: "key segment may be the indexed element",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/[id=1]/name", "a") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/0/name", "q") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteWrite, APath: "/items/[id=1]/name", BPath: "/items/0/name"}},
+		},
+		{
+			name: "removed element may be the keyed element",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/items/[sku=A1]/qty", 1) },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/items/[id=7]") },
+			want: []jsonpatch.Conflict{{Kind: jsonpatch.ConflictWriteRead, APath: "/items/[sku=A1]/qty", BPath: "/items/[id=7]"}},
+		},
+		{
+			name: "key segment beside an object member",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/user/[id=1]", "x") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/user/name", "y") },
+		},
+		{
+			name: "disjoint fields and shared reads",
+			a:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/version", 1).Replace("/name", "Ada") },
+			b:    func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/version", 1).Replace("/email", "a@x") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := tt.a(jsonpatch.NewBuilder()).Compile(keyCaps)
+			require.NoError(t, err)
+			b, err := tt.b(jsonpatch.NewBuilder()).Compile(keyCaps)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, jsonpatch.Conflicts(a, b))
+		})
+	}
+
+	t.Run("literal key segment", func(t *testing.T) {
+		t.Parallel()
+
+		a, err := jsonpatch.NewBuilder().Replace("/items/[id=1]/name", "a").Compile()
+		require.NoError(t, err)
+		b, err := jsonpatch.NewBuilder().Replace("/items/0/name", "q").Compile()
+		require.NoError(t, err)
+		assert.Empty(t, jsonpatch.Conflicts(a, b))
+	})
+}
+
+func TestConflictsReportsEachPair(t *testing.T) {
+	t.Parallel()
+
+	a, err := jsonpatch.NewBuilder().
+		Test("/status", "open").
+		Replace("/title", "A").
+		Replace("/status", "closed").
+		Compile()
+	require.NoError(t, err)
+	b, err := jsonpatch.NewBuilder().
+		Replace("/status", "archived").
+		Replace("/title", "B").
+		Compile()
+	require.NoError(t, err)
+
+	assert.Equal(t, []jsonpatch.Conflict{
+		{Kind: jsonpatch.ConflictWriteRead, A: 0, B: 0, APath: "/status", BPath: "/status"},
+		{Kind: jsonpatch.ConflictWriteWrite, A: 1, B: 1, APath: "/title", BPath: "/title"},
+		{Kind: jsonpatch.ConflictWriteWrite, A: 2, B: 0, APath: "/status", BPath: "/status"},
+	}, jsonpatch.Conflicts(a, b))
+
+	assert.Nil(t, jsonpatch.Conflicts(a, nil))
+	assert.Nil(t, jsonpatch.Conflicts(nil, b))
+}
+
+func TestConflictKindString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "write/write", jsonpatch.ConflictWriteWrite.String())
+	assert.Equal(t, "write/read", jsonpatch.ConflictWriteRead.String())
+	assert.Equal(t, "structural", jsonpatch.ConflictStructural.String())
+	assert.Equal(t, "ConflictKind(0)", jsonpatch.ConflictKind(0).String())
+}
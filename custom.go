@@ -0,0 +1,58 @@
+package jsonpatch
+
+import "github.com/kaptinlin/jsonpatch/internal"
+
+// Compact and binary codes reserved for operations added with
+// RegisterOperation.
+const (
+	MinCustomCode = internal.MinCustomCode
+	MaxCustomCode = internal.MaxCustomCode
+)
+
+// MinCustomCapability is the lowest capability bit a custom operation may
+// use. The bits below it are reserved for the built-in capabilities.
+const MinCustomCapability = Capability(internal.MinCustomCapability)
+
+// CustomOperation describes an operation type added to the vocabulary with
+// RegisterOperation.
+//
+// The operations its decoders return must implement Clone() (Op, error), as
+// the built-in operations do, so that they can be compiled. To be encoded they
+// implement ToJSON() (json.Operation, error) from codec/json, and Code() int
+// with ToCompact() ([]any, error), whose compact form is [code, path,
+// ...operands]. Operations that implement none of these still compile from
+// JSON and compact input.
+type CustomOperation struct {
+	// Name is the op member that selects the operation.
+	Name OpType
+	// Code is the opcode of the compact and binary forms, from MinCustomCode
+	// to MaxCustomCode.
+	Code int
+	// Capability enables the operation at compile time. It is a single bit
+	// chosen by the caller from MinCustomCapability up, such as
+	// MinCustomCapability << 1.
+	Capability Capability
+	// DecodeJSON builds the operation from its JSON object, whose path
+	// member has already been parsed into path.
+	DecodeJSON func(path []string, operation map[string]any) (Op, error)
+	// DecodeCompact builds the operation from the members of its compact
+	// or binary array that follow the opcode and path.
+	DecodeCompact func(path []string, operands []any) (Op, error)
+}
+
+// RegisterOperation adds operation to the vocabulary read by CompileJSON,
+// CompileCompact, CompileBinary, Decode, and the codec packages. A name or
+// code that is already in use, a code outside the reserved range, a
+// capability that is not a single bit at or above MinCustomCapability, or a
+// missing decoder fails with
+// ErrInvalidRegistration. Operations are usually registered from an init
+// function; registration is safe for concurrent use and cannot be undone.
+func RegisterOperation(operation CustomOperation) error {
+	return internal.RegisterOperation(internal.OperationSpec{
+		Type:             operation.Name,
+		Code:             operation.Code,
+		CustomCapability: uint64(operation.Capability),
+		DecodeJSON:       operation.DecodeJSON,
+		DecodeCompact:    operation.DecodeCompact,
+	})
+}
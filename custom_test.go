@@ -0,0 +1,294 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch"
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// workflow is a caller-defined capability outside AllCapabilities.
+const workflow = jsonpatch.MinCustomCapability
+
+const setStatusCode = 200
+
+func init() {
+	err := jsonpatch.RegisterOperation(jsonpatch.CustomOperation{
+		Name:       "set_status",
+		Code:       setStatusCode,
+		Capability: workflow,
+		DecodeJSON: func(path []string, operation map[string]any) (jsonpatch.Op, error) {
+			return newSetStatus(path, operation["value"], operation["oldValue"])
+		},
+		DecodeCompact: func(path []string, operands []any) (jsonpatch.Op, error) {
+			if len(operands) == 0 {
+				return nil, errors.New("set_status needs a status")
+			}
+			var from any
+			if len(operands) > 1 {
+				from = operands[1]
+			}
+			return newSetStatus(path, operands[0], from)
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// setStatus sets a top-level status member, when its current value is one of
+// the statuses it may move from.
+type setStatus struct {
+	path []string
+	to   string
+	from []string
+}
+
+func newSetStatus(path []string, to, from any) (*setStatus, error) {
+	status, ok := to.(string)
+	if !ok {
+		return nil, errors.New("set_status value must be a string")
+	}
+	o := &setStatus{path: path, to: status}
+	if from == nil {
+		return o, nil
+	}
+	list, ok := from.([]any)
+	if !ok {
+		return nil, errors.New("set_status oldValue must be an array")
+	}
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, errors.New("set_status oldValue must hold strings")
+		}
+		o.from = append(o.from, s)
+	}
+	return o, nil
+}
+
+func (s *setStatus) Op() jsonpatch.OpType { return "set_status" }
+func (s *setStatus) Path() []string       { return s.path }
+func (s *setStatus) Code() int            { return setStatusCode }
+
+func (s *setStatus) Validate() error {
+	if len(s.path) != 1 || s.to == "" {
+		return errors.New("set_status needs a top-level path and a status")
+	}
+	return nil
+}
+
+func (s *setStatus) Apply(doc any) (op.Result[any], error) {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return op.Result[any]{}, errors.New("document is not an object")
+	}
+	old := obj[s.path[0]]
+	if current, _ := old.(string); len(s.from) > 0 && !slices.Contains(s.from, current) {
+		return op.Result[any]{}, fmt.Errorf("cannot move status from %q to %q", current, s.to)
+	}
+	obj[s.path[0]] = s.to
+	return op.Result[any]{Doc: obj, Old: old}, nil
+}
+
+func (s *setStatus) Clone() (jsonpatch.Op, error) {
+	return &setStatus{path: slices.Clone(s.path), to: s.to, from: slices.Clone(s.from)}, nil
+}
+
+func (s *setStatus) ToJSON() (jsoncodec.Operation, error) {
+	operation := jsoncodec.Operation{Op: "set_status", Path: jsonpointer.Format(s.path...), Value: s.to}
+	if s.from != nil {
+		operation.OldValue = s.fromOperand()
+	}
+	return operation, nil
+}
+
+func (s *setStatus) ToCompact() ([]any, error) {
+	compact := []any{setStatusCode, s.path, s.to}
+	if s.from != nil {
+		compact = append(compact, s.fromOperand())
+	}
+	return compact, nil
+}
+
+func (s *setStatus) fromOperand() []any {
+	from := make([]any, len(s.from))
+	for i, status := range s.from {
+		from[i] = status
+	}
+	return from
+}
+
+func TestRegisterOperationCompile(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[
+		{"op": "test", "path": "/status", "value": "paid"},
+		{"op": "set_status", "path": "/status", "value": "shipped", "oldValue": ["paid", "packed"]}
+	]`)
+
+	_, err := jsonpatch.CompileJSON(data)
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+	_, err = jsonpatch.CompileJSON(data, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+
+	patch, err := jsonpatch.CompileJSON(data, jsonpatch.WithCapabilities(jsonpatch.RFC6902, workflow))
+	require.NoError(t, err)
+
+	result, err := jsonpatch.Apply(patch, map[string]any{"status": "paid"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"status": "shipped"}, result.Doc)
+
+	transition, err := jsonpatch.CompileJSON(
+		[]byte(`[{"op": "set_status", "path": "/status", "value": "shipped", "oldValue": ["paid"]}]`),
+		jsonpatch.WithCapabilities(workflow),
+	)
+	require.NoError(t, err)
+	_, err = jsonpatch.Apply(transition, map[string]any{"status": "cancelled"})
+	require.ErrorIs(t, err, jsonpatch.ErrRuntimeConflict)
+
+	_, err = jsonpatch.CompileJSON(
+		[]byte(`[{"op": "set_status", "path": "/status", "value": 3}]`),
+		jsonpatch.WithCapabilities(workflow),
+	)
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+
+	analysis := patch.Analyze()
+	assert.Equal(t, []string{"/status"}, analysis.Ops[1].Reads)
+	assert.Equal(t, []string{"/status"}, analysis.Ops[1].Writes)
+	assert.Equal(t, jsonpatch.RFC6902|workflow, analysis.Capabilities)
+}
+
+func TestRegisterOperationEncodings(t *testing.T) {
+	t.Parallel()
+
+	status, err := newSetStatus([]string{"status"}, "shipped", []any{"paid"})
+	require.NoError(t, err)
+	_, err = jsonpatch.Compile(status)
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+	patch, err := jsonpatch.CompileOps(
+		[]jsonpatch.Op{status, op.NewAdd([]string{"note"}, "sent")},
+		jsonpatch.WithCapabilities(jsonpatch.RFC6902, workflow),
+	)
+	require.NoError(t, err)
+
+	for _, encoding := range []jsonpatch.Encoding{jsonpatch.EncodingJSON, jsonpatch.EncodingCompact, jsonpatch.EncodingBinary} {
+		t.Run(string(encoding), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := patch.Encode(encoding)
+			require.NoError(t, err)
+
+			err = jsonpatch.NewPatch().Decode(encoding, data)
+			require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+
+			decoded := jsonpatch.NewPatch(jsonpatch.WithCapabilities(jsonpatch.RFC6902, workflow))
+			require.NoError(t, decoded.Decode(encoding, data))
+			assert.Equal(t, patch.Ops(), decoded.Ops())
+
+			result, err := jsonpatch.Apply(decoded, map[string]any{"status": "paid"})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]any{"status": "shipped", "note": "sent"}, result.Doc)
+		})
+	}
+
+	compiled, err := jsonpatch.CompileCompact(
+		[]byte(`[["set_status", ["status"], "shipped"]]`),
+		jsonpatch.WithCapabilities(workflow),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []jsonpatch.Op{&setStatus{path: []string{"status"}, to: "shipped"}}, compiled.Ops())
+}
+
+func TestRegisterOperationInvalid(t *testing.T) {
+	t.Parallel()
+
+	decodeJSON := func([]string, map[string]any) (jsonpatch.Op, error) { return nil, nil }
+	decodeCompact := func([]string, []any) (jsonpatch.Op, error) { return nil, nil }
+	valid := func(name jsonpatch.OpType, code int) jsonpatch.CustomOperation {
+		return jsonpatch.CustomOperation{
+			Name:          name,
+			Code:          code,
+			Capability:    workflow,
+			DecodeJSON:    decodeJSON,
+			DecodeCompact: decodeCompact,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		operation jsonpatch.CustomOperation
+	}{
+		{name: "empty name", operation: valid("", 250)},
+		{name: "built-in code", operation: valid("invalid_code", jsonpatch.MinCustomCode-1)},
+		{name: "code beyond one byte", operation: valid("invalid_code", jsonpatch.MaxCustomCode+1)},
+		{name: "built-in name", operation: valid(jsonpatch.OpAddType, 250)},
+		{name: "registered name", operation: valid("set_status", 250)},
+		{name: "registered code", operation: valid("set_state", setStatusCode)},
+		{
+			name: "no capability",
+			operation: func() jsonpatch.CustomOperation {
+				operation := valid("invalid_capability", 250)
+				operation.Capability = 0
+				return operation
+			}(),
+		},
+		{
+			name: "several capabilities",
+			operation: func() jsonpatch.CustomOperation {
+				operation := valid("invalid_capability", 250)
+				operation.Capability = jsonpatch.Extended | workflow
+				return operation
+			}(),
+		},
+		{
+			name: "built-in capability",
+			operation: func() jsonpatch.CustomOperation {
+				operation := valid("invalid_capability", 250)
+				operation.Capability = jsonpatch.Extended
+				return operation
+			}(),
+		},
+		{
+			name: "key segments capability",
+			operation: func() jsonpatch.CustomOperation {
+				operation := valid("invalid_capability", 250)
+				operation.Capability = jsonpatch.KeySegments
+				return operation
+			}(),
+		},
+		{
+			name: "reserved capability",
+			operation: func() jsonpatch.CustomOperation {
+				operation := valid("invalid_capability", 250)
+				operation.Capability = jsonpatch.MinCustomCapability >> 1
+				return operation
+			}(),
+		},
+		{
+			name: "missing decoder",
+			operation: func() jsonpatch.CustomOperation {
+				operation := valid("invalid_decoder", 250)
+				operation.DecodeCompact = nil
+				return operation
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.ErrorIs(t, jsonpatch.RegisterOperation(tt.operation), jsonpatch.ErrInvalidRegistration)
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package jsonpatch
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// DiffOption configures patch generation by Diff.
+type DiffOption func(*diffOptions)
+
+type diffOptions struct {
+	lcs    bool
+	moves  bool
+	copies bool
+}
+
+// WithLCSArrays diffs arrays by longest common subsequence instead of by
+// index, so an insertion or removal in the middle of an array does not
+// rewrite every element after it.
+func WithLCSArrays() DiffOption {
+	return func(o *diffOptions) {
+		o.lcs = true
+	}
+}
+
+// WithMoveDetection emits move operations when a value removed at one
+// location is added unchanged at another, including reordered array elements.
+func WithMoveDetection() DiffOption {
+	return func(o *diffOptions) {
+		o.moves = true
+	}
+}
+
+// WithCopyDetection emits copy operations when an added object or array
+// already exists unchanged elsewhere in the document.
+func WithCopyDetection() DiffOption {
+	return func(o *diffOptions) {
+		o.copies = true
+	}
+}
+
+// Diff generates a compiled RFC 6902 patch that turns before into after.
+// Both documents are classified the same way Apply classifies its input, so
+// maps, JSON bytes, JSONText, and structs are accepted.
+func Diff[T internal.Document](before, after T, opts ...DiffOption) (*Patch, error) {
+	var options diffOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	from, err := documentValue(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := documentValue(after)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &differ{options: options}
+	d.diff(nil, from, to, true)
+	return compileOps(d.finish(), defaultCompileOptions())
+}
+
+// differ accumulates operations in application order. Paths recorded for
+// move and copy detection are stable: they never cross an array, so no
+// emitted operation can shift them before the operation that uses them.
+type differ struct {
+	options  diffOptions
+	ops      []Op
+	removals []diffRemoval
+	sources  []diffSource
+}
+
+type diffRemoval struct {
+	index int
+	path  []string
+	value any
+	used  bool
+}
+
+type diffSource struct {
+	path  []string
+	value any
+}
+
+// arraySlot describes where one element of the target array comes from.
+// A negative source marks an inserted element.
+type arraySlot struct {
+	source int
+	equal  bool
+}
+
+func (d *differ) emit(operation Op) {
+	d.ops = append(d.ops, operation)
+}
+
+func (d *differ) diff(path []string, before, after any, stable bool) {
+	if oppkg.DeepEqual(before, after) {
+		if stable && d.options.copies {
+			d.recordSources(path, before)
+		}
+		return
+	}
+
+	switch beforeValue := before.(type) {
+	case map[string]any:
+		if afterValue, ok := after.(map[string]any); ok {
+			d.diffObject(path, beforeValue, afterValue, stable)
+			return
+		}
+	case []any:
+		if afterValue, ok := after.([]any); ok {
+			d.diffArray(path, beforeValue, afterValue)
+			return
+		}
+	}
+	d.emit(oppkg.NewReplace(path, after))
+}
+
+func (d *differ) diffObject(path []string, before, after map[string]any, stable bool) {
+	for _, key := range slices.Sorted(maps.Keys(before)) {
+		child := childPath(path, key)
+		afterValue, ok := after[key]
+		if !ok {
+			d.emit(oppkg.NewRemove(child))
+			if stable && d.options.moves {
+				d.removals = append(d.removals, diffRemoval{index: len(d.ops) - 1, path: child, value: before[key]})
+			}
+			continue
+		}
+		d.diff(child, before[key], afterValue, stable)
+	}
+	for _, key := range slices.Sorted(maps.Keys(after)) {
+		if _, ok := before[key]; !ok {
+			d.emit(oppkg.NewAdd(childPath(path, key), after[key]))
+		}
+	}
+}
+
+// diffArray plans where every target element comes from, then replays the
+// plan against a model of the working array so every emitted index is valid
+// at the moment its operation runs.
+func (d *differ) diffArray(path []string, before, after []any) {
+	slots, used := d.planArray(before, after)
+
+	current := make([]int, len(before))
+	for i := range current {
+		current[i] = i
+	}
+
+	for j := range after {
+		for j < len(current) && current[j] >= 0 && !used[current[j]] {
+			d.emit(oppkg.NewRemove(indexPath(path, j)))
+			current = slices.Delete(current, j, j+1)
+		}
+
+		slot := slots[j]
+		if slot.source < 0 {
+			d.insert(path, after, j)
+			current = slices.Insert(current, j, -1)
+			continue
+		}
+
+		if position := slices.Index(current, slot.source); position != j {
+			d.emit(oppkg.NewMove(indexPath(path, j), indexPath(path, position)))
+			current = slices.Delete(current, position, position+1)
+			current = slices.Insert(current, j, slot.source)
+		}
+		if !slot.equal {
+			d.diff(indexPath(path, j), before[slot.source], after[j], false)
+		}
+	}
+
+	for i := len(current) - 1; i >= len(after); i-- {
+		d.emit(oppkg.NewRemove(indexPath(path, i)))
+	}
+}
+
+func (d *differ) planArray(before, after []any) ([]arraySlot, []bool) {
+	slots := make([]arraySlot, len(after))
+	for j := range slots {
+		slots[j].source = -1
+	}
+	used := make([]bool, len(before))
+
+	var anchors [][2]int
+	if d.options.lcs {
+		anchors = lcsMatches(before, after)
+	}
+	for _, anchor := range anchors {
+		slots[anchor[1]] = arraySlot{source: anchor[0], equal: true}
+		used[anchor[0]] = true
+	}
+
+	if d.options.moves {
+		for j := range slots {
+			if slots[j].source >= 0 {
+				continue
+			}
+			for i := range before {
+				if !used[i] && oppkg.DeepEqual(before[i], after[j]) {
+					slots[j] = arraySlot{source: i, equal: true}
+					used[i] = true
+					break
+				}
+			}
+		}
+	}
+
+	// Pair the remaining elements between consecutive anchors so changed
+	// elements are patched in place instead of being removed and re-added.
+	anchors = append(anchors, [2]int{len(before), len(after)})
+	previousI, previousJ := -1, -1
+	for _, anchor := range anchors {
+		next := previousI + 1
+		for j := previousJ + 1; j < anchor[1]; j++ {
+			if slots[j].source >= 0 {
+				continue
+			}
+			for next < anchor[0] && used[next] {
+				next++
+			}
+			if next >= anchor[0] {
+				break
+			}
+			slots[j] = arraySlot{source: next}
+			used[next] = true
+		}
+		previousI, previousJ = anchor[0], anchor[1]
+	}
+	return slots, used
+}
+
+func (d *differ) insert(path []string, after []any, j int) {
+	value := after[j]
+	if d.options.copies && copyCandidate(value) {
+		for k := range j {
+			if oppkg.DeepEqual(after[k], value) {
+				d.emit(oppkg.NewCopy(indexPath(path, j), indexPath(path, k)))
+				return
+			}
+		}
+	}
+	d.emit(oppkg.NewAdd(indexPath(path, j), value))
+}
+
+func (d *differ) recordSources(path []string, value any) {
+	if !copyCandidate(value) || len(path) == 0 {
+		return
+	}
+	d.sources = append(d.sources, diffSource{path: path, value: value})
+	if object, ok := value.(map[string]any); ok {
+		for _, key := range slices.Sorted(maps.Keys(object)) {
+			d.recordSources(childPath(path, key), object[key])
+		}
+	}
+}
+
+// finish rewrites adds into moves and copies where detection is enabled and
+// returns the operation list without the removals absorbed by moves.
+func (d *differ) finish() []Op {
+	for i, operation := range d.ops {
+		add, ok := operation.(*oppkg.AddOperation)
+		if !ok {
+			continue
+		}
+		if d.options.moves {
+			if from, ok := d.takeRemoval(add.Value); ok {
+				d.ops[i] = oppkg.NewMove(add.Path(), from)
+				continue
+			}
+		}
+		if d.options.copies && copyCandidate(add.Value) {
+			for _, source := range d.sources {
+				if oppkg.DeepEqual(source.value, add.Value) {
+					d.ops[i] = oppkg.NewCopy(add.Path(), source.path)
+					break
+				}
+			}
+		}
+	}
+	return slices.DeleteFunc(d.ops, func(operation Op) bool {
+		return operation == nil
+	})
+}
+
+func (d *differ) takeRemoval(value any) ([]string, bool) {
+	for i := range d.removals {
+		removal := &d.removals[i]
+		if removal.used || !oppkg.DeepEqual(removal.value, value) {
+			continue
+		}
+		removal.used = true
+		d.ops[removal.index] = nil
+		return removal.path, true
+	}
+	return nil, false
+}
+
+// lcsMatches returns the index pairs of a longest common subsequence of
+// before and after, compared with DeepEqual.
+func lcsMatches(before, after []any) [][2]int {
+	prefix := 0
+	for prefix < len(before) && prefix < len(after) && oppkg.DeepEqual(before[prefix], after[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(before)-prefix && suffix < len(after)-prefix &&
+		oppkg.DeepEqual(before[len(before)-1-suffix], after[len(after)-1-suffix]) {
+		suffix++
+	}
+
+	matches := make([][2]int, 0, prefix+suffix)
+	for i := range prefix {
+		matches = append(matches, [2]int{i, i})
+	}
+
+	a := before[prefix : len(before)-suffix]
+	b := after[prefix : len(after)-suffix]
+	width := len(b) + 1
+	lengths := make([]int, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if oppkg.DeepEqual(a[i], b[j]) {
+				lengths[i*width+j] = lengths[(i+1)*width+j+1] + 1
+			} else {
+				lengths[i*width+j] = max(lengths[(i+1)*width+j], lengths[i*width+j+1])
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case oppkg.DeepEqual(a[i], b[j]):
+			matches = append(matches, [2]int{prefix + i, prefix + j})
+			i++
+			j++
+		case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	for k := range suffix {
+		matches = append(matches, [2]int{len(before) - suffix + k, len(after) - suffix + k})
+	}
+	return matches
+}
+
+func copyCandidate(value any) bool {
+	switch typed := value.(type) {
+	case map[string]any:
+		return len(typed) > 0
+	case []any:
+		return len(typed) > 0
+	default:
+		return false
+	}
+}
+
+func childPath(path []string, key string) []string {
+	return append(slices.Clip(path), key)
+}
+
+func indexPath(path []string, index int) []string {
+	return childPath(path, strconv.Itoa(index))
+}
@@ -0,0 +1,242 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+)
+
+func diffOps(t *testing.T, patch *jsonpatch.Patch, doc map[string]any) (map[string]any, []string) {
+	t.Helper()
+
+	result, err := jsonpatch.Apply(patch, doc)
+	require.NoError(t, err)
+	ops := make([]string, 0, len(result.Steps))
+	for _, step := range result.Steps {
+		ops = append(ops, step.Op())
+	}
+	return result.Doc, ops
+}
+
+func TestDiffRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	options := map[string][]jsonpatch.DiffOption{
+		"positional": nil,
+		"lcs":        {jsonpatch.WithLCSArrays()},
+		"moves":      {jsonpatch.WithMoveDetection()},
+		"copies":     {jsonpatch.WithCopyDetection()},
+		"all":        {jsonpatch.WithLCSArrays(), jsonpatch.WithMoveDetection(), jsonpatch.WithCopyDetection()},
+	}
+	tests := []struct {
+		name   string
+		before map[string]any
+		after  map[string]any
+	}{
+		{
+			name:   "equal",
+			before: map[string]any{"a": float64(1)},
+			after:  map[string]any{"a": float64(1)},
+		},
+		{
+			name:   "object members",
+			before: map[string]any{"a": float64(1), "b": "x", "c": map[string]any{"d": true}},
+			after:  map[string]any{"a": float64(2), "c": map[string]any{"d": false, "e": nil}, "f": []any{}},
+		},
+		{
+			name:   "type change",
+			before: map[string]any{"a": map[string]any{"b": float64(1)}},
+			after:  map[string]any{"a": []any{float64(1)}},
+		},
+		{
+			name:   "array insert and delete",
+			before: map[string]any{"list": []any{"a", "b", "c", "d"}},
+			after:  map[string]any{"list": []any{"x", "a", "c", "d", "y"}},
+		},
+		{
+			name:   "array reorder",
+			before: map[string]any{"list": []any{"a", "b", "c", "d", "e"}},
+			after:  map[string]any{"list": []any{"e", "c", "a", "d", "b"}},
+		},
+		{
+			name:   "array shrink",
+			before: map[string]any{"list": []any{"a", "b", "c", "d"}},
+			after:  map[string]any{"list": []any{"b"}},
+		},
+		{
+			name: "nested array elements",
+			before: map[string]any{"list": []any{
+				map[string]any{"id": float64(1), "tags": []any{"a"}},
+				map[string]any{"id": float64(2)},
+			}},
+			after: map[string]any{"list": []any{
+				map[string]any{"id": float64(0)},
+				map[string]any{"id": float64(1), "tags": []any{"a", "b"}},
+				map[string]any{"id": float64(2), "tags": []any{"a"}},
+			}},
+		},
+		{
+			name:   "moved and copied members",
+			before: map[string]any{"a": map[string]any{"x": float64(1)}, "b": map[string]any{"y": []any{float64(1), float64(2)}}},
+			after:  map[string]any{"c": map[string]any{"x": float64(1)}, "b": map[string]any{"y": []any{float64(1), float64(2)}}, "d": []any{float64(1), float64(2)}},
+		},
+	}
+
+	for _, tt := range tests {
+		for optionName, opts := range options {
+			t.Run(tt.name+"/"+optionName, func(t *testing.T) {
+				t.Parallel()
+
+				patch, err := jsonpatch.Diff(tt.before, tt.after, opts...)
+				require.NoError(t, err)
+
+				doc, _ := diffOps(t, patch, tt.before)
+				assert.Equal(t, tt.after, doc)
+			})
+		}
+	}
+}
+
+func TestDiffOptionsShapeOperations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		before map[string]any
+		after  map[string]any
+		opts   []jsonpatch.DiffOption
+		want   []string
+	}{
+		{
+			name:   "positional arrays rewrite shifted elements",
+			before: map[string]any{"list": []any{"a", "b", "c"}},
+			after:  map[string]any{"list": []any{"x", "a", "b", "c"}},
+			want:   []string{"replace", "replace", "replace", "add"},
+		},
+		{
+			name:   "lcs arrays insert once",
+			before: map[string]any{"list": []any{"a", "b", "c"}},
+			after:  map[string]any{"list": []any{"x", "a", "b", "c"}},
+			opts:   []jsonpatch.DiffOption{jsonpatch.WithLCSArrays()},
+			want:   []string{"add"},
+		},
+		{
+			name:   "move detection between members",
+			before: map[string]any{"a": map[string]any{"x": float64(1)}},
+			after:  map[string]any{"b": map[string]any{"x": float64(1)}},
+			opts:   []jsonpatch.DiffOption{jsonpatch.WithMoveDetection()},
+			want:   []string{"move"},
+		},
+		{
+			name:   "move detection within arrays",
+			before: map[string]any{"list": []any{"a", "b", "c"}},
+			after:  map[string]any{"list": []any{"c", "a", "b"}},
+			opts:   []jsonpatch.DiffOption{jsonpatch.WithLCSArrays(), jsonpatch.WithMoveDetection()},
+			want:   []string{"move"},
+		},
+		{
+			name:   "copy detection from unchanged member",
+			before: map[string]any{"a": map[string]any{"x": float64(1)}},
+			after:  map[string]any{"a": map[string]any{"x": float64(1)}, "b": map[string]any{"x": float64(1)}},
+			opts:   []jsonpatch.DiffOption{jsonpatch.WithCopyDetection()},
+			want:   []string{"copy"},
+		},
+		{
+			name:   "copy detection within arrays",
+			before: map[string]any{"list": []any{}},
+			after:  map[string]any{"list": []any{[]any{"a"}, []any{"a"}}},
+			opts:   []jsonpatch.DiffOption{jsonpatch.WithCopyDetection()},
+			want:   []string{"add", "copy"},
+		},
+		{
+			name:   "scalars are never copied",
+			before: map[string]any{"a": "x"},
+			after:  map[string]any{"a": "x", "b": "x"},
+			opts:   []jsonpatch.DiffOption{jsonpatch.WithCopyDetection()},
+			want:   []string{"add"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.Diff(tt.before, tt.after, tt.opts...)
+			require.NoError(t, err)
+
+			doc, ops := diffOps(t, patch, tt.before)
+			assert.Equal(t, tt.after, doc)
+			assert.Equal(t, tt.want, ops)
+		})
+	}
+}
+
+func TestDiffDocumentShapes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("json bytes", func(t *testing.T) {
+		t.Parallel()
+
+		before := []byte(`{"name":"Ada","tags":["a"]}`)
+		after := []byte(`{"name":"Grace","tags":["a","b"]}`)
+		patch, err := jsonpatch.Diff(before, after)
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, before)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(after), string(result.Doc))
+	})
+
+	t.Run("json text", func(t *testing.T) {
+		t.Parallel()
+
+		before := jsonpatch.JSONText(`{"count":1}`)
+		after := jsonpatch.JSONText(`{"count":2}`)
+		patch, err := jsonpatch.Diff(before, after)
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, before)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(after), string(result.Doc))
+	})
+
+	t.Run("struct", func(t *testing.T) {
+		t.Parallel()
+
+		type user struct {
+			Name string `json:"name"`
+			Age  int    `json:"age"`
+		}
+		patch, err := jsonpatch.Diff(user{Name: "Ada", Age: 36}, user{Name: "Ada", Age: 37})
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, user{Name: "Ada", Age: 36})
+		require.NoError(t, err)
+		assert.Equal(t, user{Name: "Ada", Age: 37}, result.Doc)
+		require.Len(t, result.Steps, 1)
+		assert.Equal(t, "/age", result.Steps[0].Path())
+	})
+
+	t.Run("root scalar", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Diff[any]("a", float64(1))
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply[any](patch, "a")
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), result.Doc)
+	})
+
+	t.Run("invalid json text", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Diff(jsonpatch.JSONText(`{`), jsonpatch.JSONText(`{}`))
+		require.Error(t, err)
+		assert.Nil(t, patch)
+		assert.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	})
+}
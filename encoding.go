@@ -0,0 +1,166 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-json-experiment/json"
+
+	"github.com/kaptinlin/jsonpatch/codec/binary"
+	"github.com/kaptinlin/jsonpatch/codec/compact"
+)
+
+// Encoding names a wire form of a patch.
+type Encoding string
+
+const (
+	// EncodingJSON is the RFC 6902 JSON array of operation objects.
+	EncodingJSON Encoding = "json"
+	// EncodingCompact is the codec/compact JSON array of operation arrays.
+	EncodingCompact Encoding = "compact"
+	// EncodingBinary is the codec/binary MessagePack form.
+	EncodingBinary Encoding = "binary"
+)
+
+// NewPatch returns an empty patch whose Decode and UnmarshalJSON compile
+// with opts, for example to accept more than the default RFC 6902
+// vocabulary when a patch is a field of a decoded message.
+func NewPatch(opts ...CompileOption) *Patch {
+	return &Patch{decode: opts}
+}
+
+// MarshalJSON encodes the patch as an RFC 6902 JSON array.
+func (p *Patch) MarshalJSON() ([]byte, error) {
+	return p.Encode(EncodingJSON)
+}
+
+// UnmarshalJSON compiles an RFC 6902 JSON array into p, replacing its
+// operations. See Decode for the options it compiles with.
+func (p *Patch) UnmarshalJSON(data []byte) error {
+	return p.Decode(EncodingJSON, data)
+}
+
+// Encode returns the patch in the given wire form. An operation the form
+// cannot express fails with ErrNotRepresentable.
+func (p *Patch) Encode(encoding Encoding) ([]byte, error) {
+	var ops []Op
+	if p != nil {
+		ops = make([]Op, len(p.ops))
+		for i, operation := range p.ops {
+			if wildcard, ok := operation.(*wildcardOp); ok && wildcard.query == nil {
+				operation = wildcard.template
+			}
+			ops[i] = operation
+		}
+	}
+	switch encoding {
+	case EncodingJSON:
+		return encodeOps(ops, string(encoding), encodeJSON)
+	case EncodingCompact:
+		return encodeOps(ops, string(encoding), func(ops []Op) ([]byte, error) {
+			return compact.EncodeJSON(ops)
+		})
+	case EncodingBinary:
+		return encodeOps(ops, string(encoding), binary.New().Encode)
+	default:
+		return nil, newPayloadError(string(encoding), fmt.Errorf("unknown encoding %q", encoding))
+	}
+}
+
+// encodeOps encodes ops and, when that fails, finds the operation to blame.
+func encodeOps(ops []Op, codec string, encode func([]Op) ([]byte, error)) ([]byte, error) {
+	data, err := encode(ops)
+	if err == nil {
+		return data, nil
+	}
+	for i, operation := range ops {
+		if _, opErr := encode([]Op{operation}); opErr != nil {
+			return nil, newError(ErrNotRepresentable, i, operation, codec, opErr)
+		}
+	}
+	return nil, newError(ErrNotRepresentable, -1, nil, codec, err)
+}
+
+// Decode compiles data in the given wire form into p, replacing its
+// operations. It compiles with the options p was created with by NewPatch,
+// followed by opts; a Patch not created by NewPatch accepts the default RFC
+// 6902 vocabulary. On failure p is left unchanged.
+func (p *Patch) Decode(encoding Encoding, data []byte, opts ...CompileOption) error {
+	options := buildCompileOptions(append(append([]CompileOption(nil), p.decode...), opts...))
+	options.codec = string(encoding)
+
+	var (
+		decoded *Patch
+		err     error
+	)
+	switch encoding {
+	case EncodingJSON:
+		decoded, err = compileJSON(data, options)
+	case EncodingCompact:
+		decoded, err = compileCompact(data, options)
+	case EncodingBinary:
+		decoded, err = compileBinary(data, options)
+	default:
+		return newPayloadError(options.codec, fmt.Errorf("unknown encoding %q", encoding))
+	}
+	if err != nil {
+		return err
+	}
+	p.ops = decoded.ops
+	return nil
+}
+
+// CompileCompact compiles a codec/compact JSON patch document. The operation
+// count is checked before the payload is decoded and the pattern length of
+// matches predicates before the pattern is compiled; other limits are checked
+// on each operation once it is decoded.
+func CompileCompact(data []byte, opts ...CompileOption) (*Patch, error) {
+	options := buildCompileOptions(opts)
+	options.codec = string(EncodingCompact)
+	return compileCompact(data, options)
+}
+
+func compileCompact(data []byte, options compileOptions) (*Patch, error) {
+	if err := options.limits.CheckOperationsJSON(data); err != nil {
+		return nil, newPayloadError(options.codec, err)
+	}
+	var operations []compact.Op
+	if err := json.Unmarshal(data, &operations); err != nil {
+		return nil, newPayloadError(options.codec, err)
+	}
+
+	decoder := compact.NewDecoder(compact.WithMatcher(options.createMatcher), compact.WithLimits(options.limits))
+	ops := make([]Op, len(operations))
+	for i, operation := range operations {
+		decoded, err := decoder.Decode(operation)
+		if err != nil {
+			return nil, newFieldError(ErrPayloadInvalid, i, "", "", "", options.codec, err)
+		}
+		ops[i] = decoded
+	}
+	if err := checkOpLimits(ops, options); err != nil {
+		return nil, err
+	}
+	return compileOps(ops, options)
+}
+
+// CompileBinary compiles a codec/binary MessagePack patch document. Limits
+// are checked while decoding, before the offending operation is built.
+func CompileBinary(data []byte, opts ...CompileOption) (*Patch, error) {
+	options := buildCompileOptions(opts)
+	options.codec = string(EncodingBinary)
+	return compileBinary(data, options)
+}
+
+func compileBinary(data []byte, options compileOptions) (*Patch, error) {
+	ops, err := binary.New(binary.WithLimits(options.limits), binary.WithMatcher(options.createMatcher)).Decode(data)
+	if err != nil {
+		index := -1
+		var opErr *binary.OperationError
+		if errors.As(err, &opErr) {
+			index = opErr.Index
+		}
+		return nil, newFieldError(ErrPayloadInvalid, index, "", "", "", options.codec, err)
+	}
+	return compileOps(ops, options)
+}
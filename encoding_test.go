@@ -0,0 +1,348 @@
+package jsonpatch_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/go-json-experiment/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tinylib/msgp/msgp"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func newEncodingPatch(t *testing.T) *jsonpatch.Patch {
+	t.Helper()
+
+	patch, err := jsonpatch.NewBuilder().
+		Test("/version", 1).
+		Replace("/version", 2).
+		Add("/tags/-", "beta").
+		Move("/owner", "/author").
+		And("/title", func(b *jsonpatch.Builder) {
+			b.Defined("").Matches("", "^draft", true)
+		}).
+		Inc("/views", 1).
+		StrIns("/title", 0, "[x] ").
+		Compile()
+	require.NoError(t, err)
+	return patch
+}
+
+func TestPatchEncodingRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	encodings := []jsonpatch.Encoding{jsonpatch.EncodingJSON, jsonpatch.EncodingCompact, jsonpatch.EncodingBinary}
+	for _, encoding := range encodings {
+		t.Run(string(encoding), func(t *testing.T) {
+			t.Parallel()
+
+			patch := newEncodingPatch(t)
+			data, err := patch.Encode(encoding)
+			require.NoError(t, err)
+
+			decoded := jsonpatch.NewPatch(jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, decoded.Decode(encoding, data))
+			wantJSON, err := patch.MarshalJSON()
+			require.NoError(t, err)
+			gotJSON, err := decoded.MarshalJSON()
+			require.NoError(t, err)
+			assert.JSONEq(t, string(wantJSON), string(gotJSON))
+
+			doc := []byte(`{"version":1,"tags":[],"author":"ada","title":"Draft","views":0}`)
+			want, err := jsonpatch.Apply(patch, doc)
+			require.NoError(t, err)
+			got, err := jsonpatch.Apply(decoded, doc)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(want.Doc), string(got.Doc))
+		})
+	}
+}
+
+func TestPatchMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewAdd([]string{"a/b"}, float64(1)),
+		op.NewMove([]string{"c"}, []string{"d"}),
+	)
+	require.NoError(t, err)
+
+	type message struct {
+		Patch *jsonpatch.Patch `json:"patch"`
+	}
+	data, err := json.Marshal(message{Patch: patch})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"patch":[{"op":"add","path":"/a~1b","value":1},{"op":"move","path":"/c","from":"/d"}]}`, string(data))
+
+	var decoded message
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.NotNil(t, decoded.Patch)
+	assert.Equal(t, patch.Ops(), decoded.Patch.Ops())
+
+	var empty *jsonpatch.Patch
+	data, err = empty.MarshalJSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(data))
+}
+
+func TestPatchUnmarshalJSONCapabilities(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[{"op":"inc","path":"/views","inc":1}]`)
+
+	var patch jsonpatch.Patch
+	err := json.Unmarshal(data, &patch)
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+	assert.Zero(t, patch.Len())
+
+	configured := jsonpatch.NewPatch(jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Extended))
+	require.NoError(t, json.Unmarshal(data, configured))
+	assert.Equal(t, 1, configured.Len())
+
+	require.NoError(t, configured.Decode(jsonpatch.EncodingJSON, []byte(`[]`)))
+	assert.Zero(t, configured.Len())
+	require.NoError(t, configured.Decode(jsonpatch.EncodingJSON, data))
+	assert.Equal(t, 1, configured.Len())
+}
+
+func TestPatchDecodeFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		encoding jsonpatch.Encoding
+		data     []byte
+		opts     []jsonpatch.CompileOption
+		wantErr  error
+	}{
+		{name: "invalid json", encoding: jsonpatch.EncodingJSON, data: []byte(`{`), wantErr: jsonpatch.ErrPayloadInvalid},
+		{name: "invalid compact", encoding: jsonpatch.EncodingCompact, data: []byte(`[[99,["a"]]]`), wantErr: jsonpatch.ErrPayloadInvalid},
+		{name: "invalid binary", encoding: jsonpatch.EncodingBinary, data: []byte{0xc1}, wantErr: jsonpatch.ErrPayloadInvalid},
+		{
+			name:     "compact capability",
+			encoding: jsonpatch.EncodingCompact,
+			data:     []byte(`[[9,["views"],1]]`),
+			wantErr:  jsonpatch.ErrUnsupportedCapability,
+		},
+		{
+			name:     "compact limits",
+			encoding: jsonpatch.EncodingCompact,
+			data:     []byte(`[[1,["a"]],[1,["b"]]]`),
+			opts:     []jsonpatch.CompileOption{jsonpatch.WithMaxOperations(1)},
+			wantErr:  jsonpatch.ErrLimitExceeded,
+		},
+		{name: "unknown encoding", encoding: "yaml", data: []byte(`[]`), wantErr: jsonpatch.ErrPayloadInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			original, err := jsonpatch.Compile(op.NewRemove([]string{"x"}))
+			require.NoError(t, err)
+			patch, err := jsonpatch.Compile(op.NewRemove([]string{"x"}))
+			require.NoError(t, err)
+
+			err = patch.Decode(tt.encoding, tt.data, tt.opts...)
+			require.ErrorIs(t, err, tt.wantErr)
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, string(tt.encoding), patchErr.Codec())
+			assert.Equal(t, original.Ops(), patch.Ops())
+		})
+	}
+}
+
+func TestPatchEncodeUnknownEncoding(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(op.NewRemove([]string{"x"}))
+	require.NoError(t, err)
+
+	_, err = patch.Encode("yaml")
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+}
+
+func TestCompileCompact(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		data      string
+		opts      []jsonpatch.CompileOption
+		wantErr   error
+		wantIndex int
+		wantOp    string
+	}{
+		{name: "valid", data: `[[1,["a"]],[0,["b"],1]]`},
+		{name: "string opcodes", data: `[["remove",["a"]],["add",["b"],1]]`},
+		{name: "invalid document", data: `{`, wantErr: jsonpatch.ErrPayloadInvalid, wantIndex: -1},
+		{name: "invalid operation", data: `[[1,["a"]],[0,["b"]]]`, wantErr: jsonpatch.ErrPayloadInvalid, wantIndex: 1},
+		{name: "unknown opcode", data: `[[1,["a"]],[99,["b"]]]`, wantErr: jsonpatch.ErrPayloadInvalid, wantIndex: 1},
+		{name: "capability", data: `[[1,["a"]],[9,["n"],1]]`, wantErr: jsonpatch.ErrUnsupportedCapability, wantIndex: 1, wantOp: "inc"},
+		{
+			name:      "operation count limit",
+			data:      `[[1,["a"]],[1,["b"]]]`,
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithMaxOperations(1)},
+			wantErr:   jsonpatch.ErrLimitExceeded,
+			wantIndex: -1,
+		},
+		{
+			name:      "operation count before decoding",
+			data:      `[[1,["a"]],[1,["b"]],[1,]`,
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithMaxOperations(1)},
+			wantErr:   jsonpatch.ErrLimitExceeded,
+			wantIndex: -1,
+		},
+		{
+			name:      "pointer limit",
+			data:      `[[1,["a"]],[1,["b","c","d"]]]`,
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithMaxPointerSegments(2)},
+			wantErr:   jsonpatch.ErrLimitExceeded,
+			wantIndex: 1,
+			wantOp:    "remove",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileCompact([]byte(tt.data), tt.opts...)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.Equal(t, 2, patch.Len())
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, patch)
+
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, "compact", patchErr.Codec())
+			assert.Equal(t, tt.wantIndex, patchErr.Index())
+			assert.Equal(t, tt.wantOp, patchErr.Op())
+		})
+	}
+}
+
+func TestCompileBinary(t *testing.T) {
+	t.Parallel()
+
+	// encodeRaw writes each operation as a MessagePack array of an opcode
+	// and a path, the header every binary operation starts with.
+	encodeRaw := func(t *testing.T, ops ...[]any) []byte {
+		t.Helper()
+
+		var buf bytes.Buffer
+		w := msgp.NewWriter(&buf)
+		require.NoError(t, w.WriteArrayHeader(uint32(len(ops))))
+		for _, operation := range ops {
+			require.NoError(t, w.WriteArrayHeader(uint32(len(operation))))
+			for _, field := range operation {
+				require.NoError(t, w.WriteIntf(field))
+			}
+		}
+		require.NoError(t, w.Flush())
+		return buf.Bytes()
+	}
+
+	valid, err := jsonpatch.NewBuilder().Remove("/a").Add("/b", 1).Compile()
+	require.NoError(t, err)
+	validData, err := valid.Encode(jsonpatch.EncodingBinary)
+	require.NoError(t, err)
+	extended, err := jsonpatch.NewBuilder().Remove("/a").Inc("/n", 1).Compile()
+	require.NoError(t, err)
+	extendedData, err := extended.Encode(jsonpatch.EncodingBinary)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		data      []byte
+		opts      []jsonpatch.CompileOption
+		wantErr   error
+		wantIndex int
+		wantOp    string
+	}{
+		{name: "valid", data: validData},
+		{name: "truncated document", data: validData[:1], wantErr: jsonpatch.ErrPayloadInvalid, wantIndex: -1},
+		{
+			name:      "unknown opcode",
+			data:      encodeRaw(t, []any{1, []string{"a"}}, []any{99, []string{"b"}}),
+			wantErr:   jsonpatch.ErrPayloadInvalid,
+			wantIndex: 1,
+		},
+		{name: "capability", data: extendedData, wantErr: jsonpatch.ErrUnsupportedCapability, wantIndex: 1, wantOp: "inc"},
+		{
+			name:      "operation count limit",
+			data:      validData,
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithMaxOperations(1)},
+			wantErr:   jsonpatch.ErrLimitExceeded,
+			wantIndex: -1,
+		},
+		{
+			name:      "pointer limit",
+			data:      encodeRaw(t, []any{1, []string{"a"}}, []any{1, []string{"b", "c", "d"}}),
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithMaxPointerSegments(2)},
+			wantErr:   jsonpatch.ErrLimitExceeded,
+			wantIndex: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileBinary(tt.data, tt.opts...)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.Equal(t, 2, patch.Len())
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			assert.Nil(t, patch)
+
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, "binary", patchErr.Codec())
+			assert.Equal(t, tt.wantIndex, patchErr.Index())
+			assert.Equal(t, tt.wantOp, patchErr.Op())
+		})
+	}
+}
+
+func TestDecodeUsesCompileMatcher(t *testing.T) {
+	t.Parallel()
+
+	source, err := jsonpatch.NewBuilder().Matches("/name", "^never$", false).Compile()
+	require.NoError(t, err)
+
+	for _, encoding := range []jsonpatch.Encoding{jsonpatch.EncodingJSON, jsonpatch.EncodingCompact, jsonpatch.EncodingBinary} {
+		t.Run(string(encoding), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := source.Encode(encoding)
+			require.NoError(t, err)
+
+			var compiled int
+			matchAll := jsonpatch.WithCompileMatcher(func(string, bool) jsonpatch.RegexMatcher {
+				compiled++
+				return func(string) bool { return true }
+			})
+
+			patch := jsonpatch.NewPatch(jsonpatch.WithCapabilities(jsonpatch.AllCapabilities), matchAll)
+			require.NoError(t, patch.Decode(encoding, data))
+			_, err = jsonpatch.Apply(patch, map[string]any{"name": "Ada"})
+			require.NoError(t, err)
+
+			compiled = 0
+			err = patch.Decode(encoding, data, jsonpatch.WithMaxPatternLength(4))
+			require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+			assert.Zero(t, compiled, "pattern compiled before the limit was checked")
+		})
+	}
+}
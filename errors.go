@@ -14,6 +14,9 @@ var (
 	ErrPayloadInvalid = errors.New("payload invalid")
 	// ErrUnsupportedCapability reports an operation outside the enabled vocabulary.
 	ErrUnsupportedCapability = errors.New("unsupported capability")
+	// ErrPathDenied reports an operation that points where WithPathPolicy
+	// or WithRestrictions does not allow it.
+	ErrPathDenied = errors.New("path denied")
 	// ErrRuntimeConflict reports a valid operation that cannot apply to the document state.
 	ErrRuntimeConflict = errors.New("runtime conflict")
 	// ErrTestFailed reports a failed predicate or test operation.
@@ -22,6 +25,24 @@ var (
 	ErrTypeMismatch = errors.New("type mismatch")
 	// ErrConversionFailed reports that a patched result could not be converted back.
 	ErrConversionFailed = errors.New("failed to convert result back to original type")
+	// ErrNotReversible reports an operation that cannot be inverted.
+	ErrNotReversible = errors.New("operation not reversible")
+	// ErrNotRepresentable reports a change that the target patch format cannot express.
+	ErrNotRepresentable = errors.New("not representable")
+	// ErrLimitExceeded reports a patch payload that exceeds a compile limit. It
+	// is wrapped by ErrPayloadInvalid errors.
+	ErrLimitExceeded = internal.ErrLimitExceeded
+	// ErrBudgetExceeded reports an operation whose result exceeds an apply budget.
+	ErrBudgetExceeded = errors.New("budget exceeded")
+	// ErrValidationFailed reports a patched document rejected by a
+	// WithValidator validator.
+	ErrValidationFailed = errors.New("validation failed")
+	// ErrInvalidRegistration reports a RegisterOperation call that cannot add
+	// its operation to the vocabulary.
+	ErrInvalidRegistration = internal.ErrInvalidRegistration
+	// ErrUnknownField reports a PathOf accessor that does not select a JSON
+	// field.
+	ErrUnknownField = errors.New("unknown field")
 )
 
 // Error carries stable patch failure context for programmatic inspection.
@@ -33,6 +54,9 @@ type Error struct {
 	from  string
 	codec string
 	cause error
+
+	schemaPointer   string
+	instancePointer string
 }
 
 func newError(kind error, index int, operation internal.Op, codec string, cause error) *Error {
@@ -135,6 +159,18 @@ func (e *Error) Codec() string {
 	return e.codec
 }
 
+// SchemaPointer returns the JSON Pointer of the schema rule that rejected the
+// document when the validator reported one.
+func (e *Error) SchemaPointer() string {
+	return e.schemaPointer
+}
+
+// InstancePointer returns the JSON Pointer of the rejected document value
+// when the validator reported one.
+func (e *Error) InstancePointer() string {
+	return e.instancePointer
+}
+
 // Cause returns the original wrapped error.
 func (e *Error) Cause() error {
 	return e.cause
@@ -49,6 +49,9 @@ const (
 	OpSplitType  = internal.OpSplitType
 	OpMergeType  = internal.OpMergeType
 	OpExtendType = internal.OpExtendType
+
+	// JSON Merge Patch operation
+	OpMergePatchType = internal.OpMergePatchType
 )
 
 // RegexMatcher tests if a value matches a pattern.
@@ -56,3 +59,7 @@ type RegexMatcher = internal.RegexMatcher
 
 // CreateRegexMatcher creates a RegexMatcher from a pattern.
 type CreateRegexMatcher = internal.CreateRegexMatcher
+
+// Number is a JSON number kept as its literal text. Documents decoded with
+// WithExactNumbers hold their numbers as Number values.
+type Number = internal.Number
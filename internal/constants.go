@@ -49,6 +49,11 @@ const (
 	OpExtendType OpType = "extend"
 )
 
+// JSON Merge Patch (RFC 7386) operation type.
+const (
+	OpMergePatchType OpType = "merge_patch"
+)
+
 // Operation codes for binary serialization.
 const (
 	// JSON Patch (RFC 6902).
@@ -72,6 +77,9 @@ const (
 	OpMergeCode  = 11
 	OpExtendCode = 12
 
+	// JSON Merge Patch (RFC 7386).
+	OpMergePatchCode = 13
+
 	// JSON Predicate operations.
 	OpContainsCode      = 30
 	OpDefinedCode       = 31
@@ -62,6 +62,11 @@ func GetJSONPatchType(value any) JSONPatchType {
 			return JSONPatchTypeInteger
 		}
 		return JSONPatchTypeNumber
+	case Number:
+		if number, ok := ExactValue(v); ok && number.IsInt() {
+			return JSONPatchTypeInteger
+		}
+		return JSONPatchTypeNumber
 	default:
 		switch reflect.TypeOf(value).Kind() {
 		case reflect.Slice, reflect.Array:
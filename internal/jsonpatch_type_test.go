@@ -83,6 +83,10 @@ func TestGetJSONPatchType(t *testing.T) {
 		{"float64 NaN", math.NaN(), JSONPatchTypeNumber},
 		{"float64 +Inf", math.Inf(1), JSONPatchTypeNumber},
 		{"float64 -Inf", math.Inf(-1), JSONPatchTypeNumber},
+		// Exact numbers.
+		{"Number integer", Number("12345678901234567890"), JSONPatchTypeInteger},
+		{"Number whole decimal", Number("2.0"), JSONPatchTypeInteger},
+		{"Number fraction", Number("19.99"), JSONPatchTypeNumber},
 		{"float32 NaN", float32(math.NaN()), JSONPatchTypeNumber},
 		{"float32 +Inf", float32(math.Inf(1)), JSONPatchTypeNumber},
 	}
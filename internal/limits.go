@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// ErrLimitExceeded reports a patch payload that exceeds a decode limit.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bounds untrusted patch payloads while they are decoded. A zero
+// field is unlimited.
+type Limits struct {
+	// MaxOperations limits the number of top-level operations.
+	MaxOperations int
+	// MaxPredicateDepth limits the nesting of and, or, and not. A composite
+	// whose operands are all leaf predicates has depth 1.
+	MaxPredicateDepth int
+	// MaxPointerLength limits the length in bytes of each JSON Pointer.
+	MaxPointerLength int
+	// MaxPointerSegments limits the number of segments in each JSON Pointer.
+	MaxPointerSegments int
+	// MaxPatternLength limits the length in bytes of matches patterns.
+	MaxPatternLength int
+	// MaxValueSize limits the approximate JSON-encoded size in bytes of each
+	// embedded value, string operand, or props object.
+	MaxValueSize int
+}
+
+// CheckOperations checks the number of top-level operations.
+func (l Limits) CheckOperations(count int) error {
+	return checkLimit("operation count", count, l.MaxOperations)
+}
+
+// CheckOperationsJSON counts the top-level elements of a JSON array without
+// decoding them, so an oversized payload is rejected before it is held in
+// memory. Text that is not a well-formed array is left to the decoder.
+func (l Limits) CheckOperationsJSON(data []byte) error {
+	if l.MaxOperations <= 0 {
+		return nil
+	}
+	dec := jsontext.NewDecoder(bytes.NewReader(data))
+	if token, err := dec.ReadToken(); err != nil || token.Kind() != '[' {
+		return nil
+	}
+	for count := 1; dec.PeekKind() != ']'; count++ {
+		if err := dec.SkipValue(); err != nil {
+			return nil
+		}
+		if err := l.CheckOperations(count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckDepth checks the nesting depth of a composite predicate.
+func (l Limits) CheckDepth(depth int) error {
+	return checkLimit("predicate depth", depth, l.MaxPredicateDepth)
+}
+
+// CheckPointer checks a JSON Pointer in string form.
+func (l Limits) CheckPointer(pointer string) error {
+	if err := checkLimit("pointer length", len(pointer), l.MaxPointerLength); err != nil {
+		return err
+	}
+	return checkLimit("pointer segments", strings.Count(pointer, "/"), l.MaxPointerSegments)
+}
+
+// CheckPath checks a JSON Pointer in segment form.
+func (l Limits) CheckPath(path []string) error {
+	if err := checkLimit("pointer segments", len(path), l.MaxPointerSegments); err != nil {
+		return err
+	}
+	if l.MaxPointerLength <= 0 {
+		return nil
+	}
+	length := 0
+	for _, segment := range path {
+		length += 1 + len(segment) + strings.Count(segment, "~") + strings.Count(segment, "/")
+	}
+	return checkLimit("pointer length", length, l.MaxPointerLength)
+}
+
+// CheckPattern checks a matches pattern.
+func (l Limits) CheckPattern(pattern string) error {
+	return checkLimit("pattern length", len(pattern), l.MaxPatternLength)
+}
+
+// CheckValue checks an embedded value.
+func (l Limits) CheckValue(value any) error {
+	if l.MaxValueSize <= 0 || value == nil {
+		return nil
+	}
+	return checkLimit("value size", EncodedSize(value), l.MaxValueSize)
+}
+
+// CheckOperation checks a JSON-shaped operation and its nested predicates.
+func (l Limits) CheckOperation(operation Operation) error {
+	return l.checkOperation(operation, 0)
+}
+
+func (l Limits) checkOperation(operation Operation, depth int) error {
+	for _, pointer := range []string{operation.Path, operation.From} {
+		if err := l.CheckPointer(pointer); err != nil {
+			return err
+		}
+	}
+	if pattern, ok := operation.Value.(string); ok && operation.Op == string(OpMatchesType) {
+		if err := l.CheckPattern(pattern); err != nil {
+			return err
+		}
+	}
+	for _, value := range []any{operation.Value, operation.OldValue, operation.Str, operation.Props} {
+		if err := l.CheckValue(value); err != nil {
+			return err
+		}
+	}
+	if len(operation.Apply) == 0 {
+		return nil
+	}
+	if err := l.CheckDepth(depth + 1); err != nil {
+		return err
+	}
+	for _, child := range operation.Apply {
+		if err := l.checkOperation(child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckOperationMap checks an operation in JSON object form and its nested
+// predicates. Fields of the wrong type are left to the decoder.
+func (l Limits) CheckOperationMap(operation map[string]any) error {
+	return l.checkOperationMap(operation, 0)
+}
+
+func (l Limits) checkOperationMap(operation map[string]any, depth int) error {
+	for _, field := range []string{"path", "from"} {
+		if pointer, ok := operation[field].(string); ok {
+			if err := l.CheckPointer(pointer); err != nil {
+				return err
+			}
+		}
+	}
+	if pattern, ok := operation["value"].(string); ok && operation["op"] == string(OpMatchesType) {
+		if err := l.CheckPattern(pattern); err != nil {
+			return err
+		}
+	}
+	for _, field := range []string{"value", "oldValue", "str", "props"} {
+		if err := l.CheckValue(operation[field]); err != nil {
+			return err
+		}
+	}
+	apply, ok := operation["apply"].([]any)
+	if !ok {
+		return nil
+	}
+	if err := l.CheckDepth(depth + 1); err != nil {
+		return err
+	}
+	for _, raw := range apply {
+		if child, ok := raw.(map[string]any); ok {
+			if err := l.checkOperationMap(child, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkLimit(name string, value, limit int) error {
+	if limit > 0 && value > limit {
+		return fmt.Errorf("%w: %s %d exceeds %d", ErrLimitExceeded, name, value, limit)
+	}
+	return nil
+}
+
+// EncodedSize approximates the size of value encoded as JSON. String escapes
+// are not counted.
+func EncodedSize(value any) int {
+	var buf [32]byte
+	switch typed := value.(type) {
+	case nil:
+		return 4
+	case bool:
+		if typed {
+			return 4
+		}
+		return 5
+	case string:
+		return len(typed) + 2
+	case Number:
+		return max(len(typed), 1)
+	case float64:
+		return len(strconv.AppendFloat(buf[:0], typed, 'g', -1, 64))
+	case int:
+		return len(strconv.AppendInt(buf[:0], int64(typed), 10))
+	case int64:
+		return len(strconv.AppendInt(buf[:0], typed, 10))
+	case map[string]any:
+		size := 2 + max(len(typed)-1, 0)
+		for key, member := range typed {
+			size += len(key) + 3 + EncodedSize(member)
+		}
+		return size
+	case []any:
+		size := 2 + max(len(typed)-1, 0)
+		for _, element := range typed {
+			size += EncodedSize(element)
+		}
+		return size
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return 0
+		}
+		return len(data)
+	}
+}
@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// MaxNumberExponent bounds the exponent of a Number whose exact value is
+// computed. The exact value of 1e-999999 takes a million digits to write, so
+// decoding rejects such numbers and arithmetic does not treat them as exact.
+const MaxNumberExponent = 1000
+
+// ErrNumberRange reports a number whose exponent exceeds MaxNumberExponent.
+var ErrNumberRange = errors.New("number exponent out of range")
+
+// Number is a JSON number kept as the literal text it was written with, so
+// integers beyond 2^53 and decimal fractions survive decoding and encoding
+// unchanged.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string {
+	return string(n)
+}
+
+// Float64 returns the number as the nearest float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 10, 64)
+}
+
+// CheckNumber checks that the exponent of n is within MaxNumberExponent.
+func CheckNumber(n Number) error {
+	index := strings.IndexAny(string(n), "eE")
+	if index < 0 {
+		return nil
+	}
+	exponent, err := strconv.Atoi(string(n[index+1:]))
+	if err != nil || exponent > MaxNumberExponent || exponent < -MaxNumberExponent {
+		return ErrNumberRange
+	}
+	return nil
+}
+
+// ExactValue returns the exact value of n. It fails on text that is not a
+// number and on exponents beyond MaxNumberExponent.
+func ExactValue(n Number) (*big.Rat, bool) {
+	if CheckNumber(n) != nil {
+		return nil, false
+	}
+	return new(big.Rat).SetString(string(n))
+}
+
+// MarshalJSON writes the number as its literal text. The empty Number is
+// written as 0.
+func (n Number) MarshalJSON() ([]byte, error) {
+	if n == "" {
+		return []byte("0"), nil
+	}
+	return []byte(n), nil
+}
+
+// NumericValue returns the value of a Go number of any numeric kind, including
+// named types such as time.Duration, or of a Number.
+func NumericValue(value any) (float64, bool) {
+	if number, ok := value.(Number); ok {
+		f, err := number.Float64()
+		return f, err == nil
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
@@ -1,5 +1,12 @@
 package internal
 
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
 // OperationFamily describes how an operation participates in the patch
 // vocabulary. Families are flags because "test" is both an RFC 6902 operation
 // and a first-order predicate.
@@ -16,6 +23,10 @@ const (
 	FamilyRegexPredicate
 	// FamilyExtended marks JSON Patch+ extended operations.
 	FamilyExtended
+	// FamilyMergePatch marks JSON Merge Patch (RFC 7386) operations.
+	FamilyMergePatch
+	// FamilyCustom marks operations added with RegisterOperation.
+	FamilyCustom
 )
 
 // OperationCapability is the compile-time capability required by an operation.
@@ -30,8 +41,28 @@ const (
 	CapabilityRegexPredicate
 	// CapabilityExtended identifies the extended operation compile capability.
 	CapabilityExtended
+	// CapabilityMergePatch identifies the JSON Merge Patch compile capability.
+	CapabilityMergePatch
+	// CapabilityCustom identifies a capability bit chosen by the registrant of
+	// a custom operation, held in OperationSpec.CustomCapability.
+	CapabilityCustom
+)
+
+// Compact and binary codes reserved for custom operations. Binary codes are
+// one byte.
+const (
+	MinCustomCode = 128
+	MaxCustomCode = 255
 )
 
+// MinCustomCapability is the lowest capability bit of a custom operation.
+// The bits below it are reserved for the built-in capabilities.
+const MinCustomCapability uint64 = 1 << 32
+
+// ErrInvalidRegistration reports a custom operation that cannot be added to
+// the vocabulary.
+var ErrInvalidRegistration = errors.New("invalid operation registration")
+
 // OperationSpec is the small executable spine shared by compile policy and
 // compact/binary opcode resolution.
 type OperationSpec struct {
@@ -39,6 +70,14 @@ type OperationSpec struct {
 	Families   OperationFamily
 	Capability OperationCapability
 	Code       int
+
+	// CustomCapability is the single capability bit of a custom operation.
+	CustomCapability uint64
+	// DecodeJSON builds a custom operation from its JSON object.
+	DecodeJSON func(path []string, operation map[string]any) (Op, error)
+	// DecodeCompact builds a custom operation from the compact array members
+	// after the opcode and path.
+	DecodeCompact func(path []string, operands []any) (Op, error)
 }
 
 var operationSpecs = []OperationSpec{
@@ -74,6 +113,8 @@ var operationSpecs = []OperationSpec{
 	{Type: OpSplitType, Families: FamilyExtended, Capability: CapabilityExtended, Code: OpSplitCode},
 	{Type: OpMergeType, Families: FamilyExtended, Capability: CapabilityExtended, Code: OpMergeCode},
 	{Type: OpExtendType, Families: FamilyExtended, Capability: CapabilityExtended, Code: OpExtendCode},
+
+	{Type: OpMergePatchType, Families: FamilyMergePatch, Capability: CapabilityMergePatch, Code: OpMergePatchCode},
 }
 
 var (
@@ -81,6 +122,14 @@ var (
 	operationByCode = make(map[int]OperationSpec, len(operationSpecs))
 )
 
+// Custom operations are kept apart from the built-in spine, which is never
+// written after init and so is read without locking.
+var (
+	customMu     sync.RWMutex
+	customByType = map[OpType]OperationSpec{}
+	customByCode = map[int]OperationSpec{}
+)
+
 func init() {
 	for _, spec := range operationSpecs {
 		operationByType[spec.Type] = spec
@@ -88,21 +137,67 @@ func init() {
 	}
 }
 
-// OperationSpecs returns the operation vocabulary spine.
+// OperationSpecs returns the built-in operation vocabulary spine.
 func OperationSpecs() []OperationSpec {
 	specs := make([]OperationSpec, len(operationSpecs))
 	copy(specs, operationSpecs)
 	return specs
 }
 
-// LookupOperation returns the vocabulary entry for opType.
+// LookupOperation returns the vocabulary entry for opType, built-in or custom.
 func LookupOperation(opType OpType) (OperationSpec, bool) {
-	spec, ok := operationByType[opType]
+	if spec, ok := operationByType[opType]; ok {
+		return spec, true
+	}
+	customMu.RLock()
+	defer customMu.RUnlock()
+	spec, ok := customByType[opType]
 	return spec, ok
 }
 
-// LookupOperationCode returns the vocabulary entry for code.
+// LookupOperationCode returns the vocabulary entry for code, built-in or
+// custom.
 func LookupOperationCode(code int) (OperationSpec, bool) {
-	spec, ok := operationByCode[code]
+	if spec, ok := operationByCode[code]; ok {
+		return spec, true
+	}
+	customMu.RLock()
+	defer customMu.RUnlock()
+	spec, ok := customByCode[code]
 	return spec, ok
 }
+
+// RegisterOperation adds a custom operation to the vocabulary. Its name and
+// code must be unused, its code within MinCustomCode and MaxCustomCode, its
+// capability a single bit, and both decoders set.
+func RegisterOperation(spec OperationSpec) error {
+	switch {
+	case spec.Type == "":
+		return fmt.Errorf("%w: empty name", ErrInvalidRegistration)
+	case spec.Code < MinCustomCode || spec.Code > MaxCustomCode:
+		return fmt.Errorf("%w: %s code %d outside %d-%d", ErrInvalidRegistration, spec.Type, spec.Code, MinCustomCode, MaxCustomCode)
+	case bits.OnesCount64(spec.CustomCapability) != 1:
+		return fmt.Errorf("%w: %s capability must be a single bit", ErrInvalidRegistration, spec.Type)
+	case spec.CustomCapability < MinCustomCapability:
+		return fmt.Errorf("%w: %s capability %#x is reserved for built-in capabilities", ErrInvalidRegistration, spec.Type, spec.CustomCapability)
+	case spec.DecodeJSON == nil || spec.DecodeCompact == nil:
+		return fmt.Errorf("%w: %s needs JSON and compact decoders", ErrInvalidRegistration, spec.Type)
+	}
+	spec.Families = FamilyCustom
+	spec.Capability = CapabilityCustom
+
+	customMu.Lock()
+	defer customMu.Unlock()
+	if _, ok := operationByType[spec.Type]; ok {
+		return fmt.Errorf("%w: %s is already registered", ErrInvalidRegistration, spec.Type)
+	}
+	if _, ok := customByType[spec.Type]; ok {
+		return fmt.Errorf("%w: %s is already registered", ErrInvalidRegistration, spec.Type)
+	}
+	if existing, ok := customByCode[spec.Code]; ok {
+		return fmt.Errorf("%w: code %d is already registered to %s", ErrInvalidRegistration, spec.Code, existing.Type)
+	}
+	customByType[spec.Type] = spec
+	customByCode[spec.Code] = spec
+	return nil
+}
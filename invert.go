@@ -0,0 +1,266 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/kaptinlin/deepclone"
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// undoFunc builds the operations that reverse one applied operation. It
+// receives the document as it was right after that operation ran.
+type undoFunc func(after any) []Op
+
+// Invert returns a compiled patch that restores before after p has been
+// applied to it. The inverse uses only RFC 6902 operations at concrete
+// pointers: wildcard, JSONPath, and key segment operations are inverted as
+// the operations they expand to against before. Predicates add nothing to
+// the inverse, and operations without a known inverse fail with
+// ErrNotReversible.
+func (p *Patch) Invert(before any) (*Patch, error) {
+	if p == nil {
+		return nil, newPayloadError("", errors.New("nil patch"))
+	}
+	doc, err := documentValue(before)
+	if err != nil {
+		return nil, err
+	}
+
+	working := deepclone.Clone(doc)
+	groups := make([][]Op, len(p.ops))
+	for i, operation := range p.ops {
+		if operation == nil {
+			return nil, newError(ErrPayloadInvalid, i, nil, "", errNilOperation)
+		}
+		targets := []Op{operation}
+		if wildcard, ok := operation.(*wildcardOp); ok {
+			if targets, err = wildcard.expand(working); err != nil {
+				return nil, newError(kindForApplyError(err), i, operation, "", err)
+			}
+		}
+		for _, target := range targets {
+			undo, err := invertOperation(target, working)
+			if err != nil {
+				return nil, newError(ErrNotReversible, i, target, "", err)
+			}
+			result, err := target.Apply(working)
+			if err != nil {
+				return nil, newError(kindForApplyError(err), i, target, "", err)
+			}
+			working = result.Doc
+			groups[i] = append(undo(working), groups[i]...)
+		}
+	}
+
+	var inverse []Op
+	for _, group := range slices.Backward(groups) {
+		inverse = append(inverse, group...)
+	}
+	return compileOps(inverse, defaultCompileOptions())
+}
+
+// invertOperation captures the state operation is about to change. Captured
+// values are cloned because operations may mutate the working document.
+func invertOperation(operation Op, before any) (undoFunc, error) {
+	switch typed := operation.(type) {
+	case *oppkg.AddOperation:
+		return invertInsert(typed.Path(), before), nil
+	case *oppkg.CopyOperation:
+		return invertInsert(typed.Path(), before), nil
+	case *oppkg.RemoveOperation:
+		path := typed.Path()
+		old := cloneAt(before, path)
+		return func(any) []Op {
+			return []Op{oppkg.NewAdd(path, old)}
+		}, nil
+	case *oppkg.MoveOperation:
+		return invertMove(typed.Path(), typed.From(), before), nil
+	case *oppkg.ReplaceOperation, *oppkg.IncOperation, *oppkg.FlipOperation,
+		*oppkg.StrInsOperation, *oppkg.StrDelOperation, *oppkg.ExtendOperation,
+		*oppkg.MergePatchOperation:
+		return invertUpdate(operation.Path(), before), nil
+	case *oppkg.SplitOperation:
+		return invertSplit(typed.Path(), before), nil
+	case *oppkg.MergeOperation:
+		return invertMerge(typed.Path(), int(typed.Pos), before), nil
+	}
+
+	if spec, ok := internal.LookupOperation(operation.Op()); ok &&
+		spec.Families&(internal.FamilyFirstOrderPredicate|internal.FamilySecondOrderPredicate) != 0 {
+		return func(any) []Op { return nil }, nil
+	}
+	return nil, fmt.Errorf("no inverse for operation %T", operation)
+}
+
+// invertInsert reverses add-like operations: an overwritten object member
+// is restored, anything else is removed again.
+func invertInsert(path []string, before any) undoFunc {
+	if old, ok := memberAt(before, path); ok {
+		old = deepclone.Clone(old)
+		return func(any) []Op {
+			return []Op{oppkg.NewReplace(path, old)}
+		}
+	}
+	return func(after any) []Op {
+		return []Op{oppkg.NewRemove(appendedPath(path, after))}
+	}
+}
+
+// invertUpdate restores the value at path, or removes it when the operation
+// created it.
+func invertUpdate(path []string, before any) undoFunc {
+	old, ok := lookup(before, path)
+	if !ok {
+		return func(any) []Op {
+			return []Op{oppkg.NewRemove(path)}
+		}
+	}
+	old = deepclone.Clone(old)
+	return func(any) []Op {
+		return []Op{oppkg.NewReplace(path, old)}
+	}
+}
+
+func invertMove(path, from []string, before any) undoFunc {
+	if slices.Equal(path, from) {
+		return func(any) []Op { return nil }
+	}
+	// Moving a value onto one of its ancestors discards the rest of the
+	// ancestor, so only restoring the whole ancestor undoes it.
+	if isAncestor(path, from) {
+		return invertUpdate(path, before)
+	}
+
+	old, overwritten := memberAt(before, pathBeforeRemoval(path, from, before))
+	old = deepclone.Clone(old)
+	return func(after any) []Op {
+		ops := []Op{oppkg.NewMove(from, appendedPath(path, after))}
+		if overwritten {
+			ops = append(ops, oppkg.NewAdd(path, old))
+		}
+		return ops
+	}
+}
+
+func invertSplit(path []string, before any) undoFunc {
+	if len(path) == 0 {
+		return invertUpdate(path, before)
+	}
+	parentPath := path[:len(path)-1]
+	parent, _ := lookup(before, parentPath)
+	index, err := strconv.Atoi(path[len(path)-1])
+	if _, ok := parent.([]any); !ok || err != nil {
+		return invertUpdate(path, before)
+	}
+
+	// Splitting an array element stores the second part as a new sibling.
+	old := cloneAt(before, path)
+	return func(any) []Op {
+		return []Op{
+			oppkg.NewRemove(indexPath(parentPath, index+1)),
+			oppkg.NewReplace(path, old),
+		}
+	}
+}
+
+func invertMerge(path []string, pos int, before any) undoFunc {
+	target, _ := lookup(before, path)
+	array, ok := target.([]any)
+	if !ok || pos <= 0 || pos > len(array) {
+		return invertUpdate(path, before)
+	}
+
+	first := deepclone.Clone(array[pos-1])
+	if pos == len(array) {
+		return func(any) []Op {
+			return []Op{oppkg.NewReplace(indexPath(path, pos-1), first)}
+		}
+	}
+	second := deepclone.Clone(array[pos])
+	return func(any) []Op {
+		return []Op{
+			oppkg.NewReplace(indexPath(path, pos-1), first),
+			oppkg.NewAdd(indexPath(path, pos), second),
+		}
+	}
+}
+
+func lookup(doc any, path []string) (any, bool) {
+	if len(path) == 0 {
+		return doc, true
+	}
+	value, err := jsonpointer.Get(doc, path...)
+	return value, err == nil
+}
+
+func cloneAt(doc any, path []string) any {
+	value, _ := lookup(doc, path)
+	return deepclone.Clone(value)
+}
+
+// memberAt returns the value an add at path would overwrite. Only the root
+// and object members are overwritten; array adds insert.
+func memberAt(doc any, path []string) (any, bool) {
+	if len(path) == 0 {
+		return doc, true
+	}
+	parent, ok := lookup(doc, path[:len(path)-1])
+	if !ok {
+		return nil, false
+	}
+	object, ok := parent.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	value, ok := object[path[len(path)-1]]
+	return value, ok
+}
+
+// appendedPath resolves a trailing "-" to the index of the element it
+// appended, read from the document after the append.
+func appendedPath(path []string, after any) []string {
+	if len(path) == 0 || path[len(path)-1] != "-" {
+		return path
+	}
+	parentPath := path[:len(path)-1]
+	parent, _ := lookup(after, parentPath)
+	array, ok := parent.([]any)
+	if !ok || len(array) == 0 {
+		return path
+	}
+	return indexPath(parentPath, len(array)-1)
+}
+
+// pathBeforeRemoval maps path, addressed after the array element at from was
+// removed, back to the same location before the removal.
+func pathBeforeRemoval(path, from []string, before any) []string {
+	if len(from) == 0 || len(path) <= len(from) || !isAncestor(from[:len(from)-1], path) {
+		return path
+	}
+	parent, _ := lookup(before, from[:len(from)-1])
+	if _, ok := parent.([]any); !ok {
+		return path
+	}
+	removed, err := strconv.Atoi(from[len(from)-1])
+	if err != nil {
+		return path
+	}
+	position := len(from) - 1
+	index, err := strconv.Atoi(path[position])
+	if err != nil || index < removed {
+		return path
+	}
+	shifted := slices.Clone(path)
+	shifted[position] = strconv.Itoa(index + 1)
+	return shifted
+}
+
+func isAncestor(ancestor, path []string) bool {
+	return len(ancestor) < len(path) && slices.Equal(path[:len(ancestor)], ancestor)
+}
@@ -0,0 +1,270 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestPatchInvertRestoresDocument(t *testing.T) {
+	t.Parallel()
+
+	newDoc := func() map[string]any {
+		return map[string]any{
+			"name":   "Ada",
+			"nick":   nil,
+			"count":  float64(1),
+			"active": true,
+			"tags":   []any{"a", "b", "c"},
+			"meta":   map[string]any{"x": float64(1), "y": map[string]any{"z": "deep"}},
+			"text":   "hello",
+			"lines":  []any{"ab", "cd"},
+		}
+	}
+
+	tests := []struct {
+		name string
+		ops  []jsonpatch.Op
+	}{
+		{name: "add new member", ops: []jsonpatch.Op{op.NewAdd([]string{"email"}, "ada@example.com")}},
+		{name: "add over member", ops: []jsonpatch.Op{op.NewAdd([]string{"name"}, "Grace")}},
+		{name: "add over null member", ops: []jsonpatch.Op{op.NewAdd([]string{"nick"}, "Countess")}},
+		{name: "add array element", ops: []jsonpatch.Op{op.NewAdd([]string{"tags", "1"}, "x")}},
+		{name: "append array element", ops: []jsonpatch.Op{op.NewAdd([]string{"tags", "-"}, "x")}},
+		{name: "add root", ops: []jsonpatch.Op{op.NewAdd(nil, []any{float64(1)})}},
+		{name: "remove member", ops: []jsonpatch.Op{op.NewRemove([]string{"meta"})}},
+		{name: "remove array element", ops: []jsonpatch.Op{op.NewRemove([]string{"tags", "0"})}},
+		{name: "replace", ops: []jsonpatch.Op{op.NewReplace([]string{"meta", "y"}, "flat")}},
+		{name: "replace root", ops: []jsonpatch.Op{op.NewReplace(nil, "scalar")}},
+		{name: "move member", ops: []jsonpatch.Op{op.NewMove([]string{"renamed"}, []string{"name"})}},
+		{name: "move over member", ops: []jsonpatch.Op{op.NewMove([]string{"text"}, []string{"name"})}},
+		{name: "move within array", ops: []jsonpatch.Op{op.NewMove([]string{"tags", "2"}, []string{"tags", "0"})}},
+		{name: "move to array end", ops: []jsonpatch.Op{op.NewMove([]string{"tags", "-"}, []string{"name"})}},
+		{name: "move onto ancestor", ops: []jsonpatch.Op{op.NewMove([]string{"meta"}, []string{"meta", "y"})}},
+		{name: "copy member", ops: []jsonpatch.Op{op.NewCopy([]string{"meta", "copy"}, []string{"tags"})}},
+		{name: "copy over member", ops: []jsonpatch.Op{op.NewCopy([]string{"text"}, []string{"name"})}},
+		{name: "copy into array", ops: []jsonpatch.Op{op.NewCopy([]string{"tags", "0"}, []string{"name"})}},
+		{name: "test", ops: []jsonpatch.Op{op.NewTest([]string{"name"}, "Ada"), op.NewRemove([]string{"name"})}},
+		{name: "inc existing", ops: []jsonpatch.Op{op.NewInc([]string{"count"}, 0.1)}},
+		{name: "inc missing", ops: []jsonpatch.Op{op.NewInc([]string{"visits"}, 1)}},
+		{name: "flip", ops: []jsonpatch.Op{op.NewFlip([]string{"active"})}},
+		{name: "str_ins", ops: []jsonpatch.Op{op.NewStrIns([]string{"text"}, 5, " world")}},
+		{name: "str_del", ops: []jsonpatch.Op{op.NewStrDel([]string{"text"}, 1, 3)}},
+		{name: "split array element", ops: []jsonpatch.Op{op.NewSplit([]string{"lines", "0"}, 1, nil)}},
+		{name: "split member", ops: []jsonpatch.Op{op.NewSplit([]string{"text"}, 2, nil)}},
+		{name: "merge", ops: []jsonpatch.Op{op.NewMerge([]string{"lines"}, 1, nil)}},
+		{name: "extend", ops: []jsonpatch.Op{op.NewExtend([]string{"meta"}, map[string]any{"x": nil, "w": "new"}, true)}},
+		{name: "merge_patch", ops: []jsonpatch.Op{op.NewMergePatch(nil, map[string]any{"name": nil, "meta": map[string]any{"y": "flat"}})}},
+		{
+			name: "sequence",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"tags", "0"}, "first"),
+				op.NewMove([]string{"tags", "-"}, []string{"tags", "1"}),
+				op.NewReplace([]string{"meta", "x"}, float64(2)),
+				op.NewRemove([]string{"tags", "0"}),
+				op.NewCopy([]string{"backup"}, []string{"meta"}),
+				op.NewStrIns([]string{"backup", "y", "z"}, 0, "very "),
+				op.NewInc([]string{"count"}, 5),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileOps(tt.ops, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+
+			before := newDoc()
+			inverse, err := patch.Invert(before)
+			require.NoError(t, err)
+			assert.Equal(t, newDoc(), before)
+
+			forward, err := jsonpatch.Apply[any](patch, before)
+			require.NoError(t, err)
+
+			restored, err := jsonpatch.Apply(inverse, forward.Doc)
+			require.NoError(t, err)
+			assert.Equal(t, any(newDoc()), restored.Doc)
+		})
+	}
+}
+
+func TestPatchInvertKeySegments(t *testing.T) {
+	t.Parallel()
+
+	newDoc := func() map[string]any {
+		return map[string]any{
+			"items": []any{
+				map[string]any{"id": float64(7), "name": "washer"},
+				map[string]any{"id": float64(42), "name": "bolt", "tags": []any{"steel"}},
+				map[string]any{"id": float64(99)},
+			},
+			"bin": []any{},
+		}
+	}
+
+	tests := []struct {
+		name string
+		ops  []jsonpatch.Op
+	}{
+		{name: "replace member", ops: []jsonpatch.Op{op.NewReplace([]string{"items", "[id=42]", "name"}, "nut")}},
+		{name: "replace key", ops: []jsonpatch.Op{op.NewReplace([]string{"items", "[id=42]", "id"}, float64(43))}},
+		{name: "remove element", ops: []jsonpatch.Op{op.NewRemove([]string{"items", "[id=42]"})}},
+		{name: "remove member", ops: []jsonpatch.Op{op.NewRemove([]string{"items", "[id=42]", "name"})}},
+		{name: "append below element", ops: []jsonpatch.Op{op.NewAdd([]string{"items", "[id=42]", "tags", "-"}, "zinc")}},
+		{name: "insert before element", ops: []jsonpatch.Op{op.NewAdd([]string{"items", "[id=42]"}, map[string]any{"id": float64(1)})}},
+		{name: "move element out", ops: []jsonpatch.Op{op.NewMove([]string{"bin", "-"}, []string{"items", "[id=42]"})}},
+		{name: "move member across elements", ops: []jsonpatch.Op{op.NewMove([]string{"items", "[id=7]", "name"}, []string{"items", "[id=42]", "name"})}},
+		{name: "move into shifted element", ops: []jsonpatch.Op{op.NewMove([]string{"items", "[id=99]", "old"}, []string{"items", "0"})}},
+		{name: "copy element", ops: []jsonpatch.Op{op.NewCopy([]string{"items", "[id=7]", "copy"}, []string{"items", "[id=42]"})}},
+		{
+			name: "sequence",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"items", "[id=42]", "id"}, float64(43)),
+				op.NewRemove([]string{"items", "[id=43]", "tags"}),
+				op.NewRemove([]string{"items", "[id=7]"}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileOps(tt.ops, keyCaps)
+			require.NoError(t, err)
+
+			inverse, err := patch.Invert(newDoc())
+			require.NoError(t, err)
+			for _, operation := range inverse.Ops() {
+				assert.NotContains(t, operation.Path(), "[id=42]")
+			}
+
+			forward, err := jsonpatch.Apply[any](patch, newDoc())
+			require.NoError(t, err)
+			restored, err := jsonpatch.Apply(inverse, forward.Doc)
+			require.NoError(t, err)
+			assert.Equal(t, any(newDoc()), restored.Doc)
+		})
+	}
+}
+
+func TestPatchInvertUsesRFC6902Operations(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewInc([]string{"count"}, 1),
+		op.NewAdd([]string{"tags", "-"}, "c"),
+	}, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+	require.NoError(t, err)
+
+	before := []byte(`{"count":1,"tags":["a","b"]}`)
+	inverse, err := patch.Invert(before)
+	require.NoError(t, err)
+
+	forward, err := jsonpatch.Apply(patch, before)
+	require.NoError(t, err)
+	restored, err := jsonpatch.Apply(inverse, forward.Doc)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(restored.Doc))
+
+	require.Len(t, restored.Steps, 2)
+	assert.Equal(t, "remove", restored.Steps[0].Op())
+	assert.Equal(t, "/tags/2", restored.Steps[0].Path())
+	assert.Equal(t, "replace", restored.Steps[1].Op())
+	assert.Equal(t, "/count", restored.Steps[1].Path())
+}
+
+func TestPatchInvertWildcard(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileJSON([]byte(`[
+		{"op": "remove", "path": "/items/*/tmp"},
+		{"op": "add", "path": "/items/*", "value": 0}
+	]`), jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Wildcard))
+	require.NoError(t, err)
+
+	before := []byte(`{"items":[{"tmp":1},{"tmp":2}]}`)
+	inverse, err := patch.Invert(before)
+	require.NoError(t, err)
+
+	forward, err := jsonpatch.Apply(patch, before)
+	require.NoError(t, err)
+	restored, err := jsonpatch.Apply(inverse, forward.Doc)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(restored.Doc))
+}
+
+func TestPatchInvertErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("operation without inverse", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(op.NewAdd([]string{"a"}, "x"), opaqueAddOp{})
+		require.NoError(t, err)
+
+		inverse, err := patch.Invert(map[string]any{})
+		require.Error(t, err)
+		assert.Nil(t, inverse)
+		assert.ErrorIs(t, err, jsonpatch.ErrNotReversible)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, 1, patchErr.Index())
+		assert.Equal(t, "add", patchErr.Op())
+		assert.Equal(t, "/name", patchErr.Path())
+	})
+
+	t.Run("patch does not apply to before", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(op.NewTest([]string{"name"}, "Ada"))
+		require.NoError(t, err)
+
+		inverse, err := patch.Invert(map[string]any{"name": "Grace"})
+		require.Error(t, err)
+		assert.Nil(t, inverse)
+		assert.ErrorIs(t, err, jsonpatch.ErrTestFailed)
+	})
+
+	t.Run("nil patch", func(t *testing.T) {
+		t.Parallel()
+
+		var patch *jsonpatch.Patch
+		inverse, err := patch.Invert(map[string]any{})
+		require.Error(t, err)
+		assert.Nil(t, inverse)
+		assert.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	})
+}
+
+type opaqueAddOp struct{}
+
+func (opaqueAddOp) Op() jsonpatch.OpType {
+	return jsonpatch.OpAddType
+}
+
+func (opaqueAddOp) Path() []string {
+	return []string{"name"}
+}
+
+func (opaqueAddOp) Apply(doc any) (internal.OpResult[any], error) {
+	return internal.OpResult[any]{Doc: doc}, nil
+}
+
+func (opaqueAddOp) Validate() error {
+	return nil
+}
+
+func (o opaqueAddOp) Clone() (internal.Op, error) {
+	return o, nil
+}
@@ -0,0 +1,66 @@
+package jsonpatch
+
+import (
+	"errors"
+	"strings"
+
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/jsonpath"
+)
+
+var errJSONPathDisabled = errors.New("JSONPath paths need the JSONPath capability")
+
+// parseQueryPath parses path as a JSONPath query when it starts with $, and
+// returns nil for a JSON Pointer. A failure comes with its error kind.
+func parseQueryPath(path string, options compileOptions) (*jsonpath.Query, error, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, nil, nil
+	}
+	if options.capabilities&JSONPath == 0 {
+		return nil, ErrUnsupportedCapability, errJSONPathDisabled
+	}
+	parseOpts := []jsonpath.Option{jsonpath.WithMatcher(options.createMatcher), jsonpath.WithLimits(options.limits)}
+	if options.capabilities&RegexPredicate == 0 {
+		parseOpts = append(parseOpts, jsonpath.WithoutRegex())
+	}
+	query, err := jsonpath.Parse(path, parseOpts...)
+	if errors.Is(err, jsonpath.ErrRegexDisabled) {
+		return nil, ErrUnsupportedCapability, err
+	}
+	if err != nil {
+		return nil, ErrPayloadInvalid, err
+	}
+	return query, nil, nil
+}
+
+// queryOperation wraps operation, decoded at the pattern of query, so that it
+// is applied at the pointers the query locates. Without a query it returns
+// operation unchanged.
+func queryOperation(operation Op, query *jsonpath.Query, options compileOptions) (Op, error) {
+	if query == nil {
+		return operation, nil
+	}
+	return newWildcardOp(operation, query, options)
+}
+
+// encodeJSON encodes ops with codec/json, writing the query of an operation
+// targeted by JSONPath as its path.
+func encodeJSON(ops []Op) ([]byte, error) {
+	operations := make([]jsoncodec.Operation, len(ops))
+	for i, operation := range ops {
+		if target, ok := operation.(*wildcardOp); ok && target.query != nil {
+			operations[i] = target.operation
+			operations[i].Path = target.query.String()
+			continue
+		}
+		encoded, err := jsoncodec.Encode([]Op{operation})
+		if err != nil {
+			return nil, err
+		}
+		if err := jsoncodec.RelativizeOperands(&encoded[0]); err != nil {
+			return nil, err
+		}
+		operations[i] = encoded[0]
+	}
+	return jsoncodec.MarshalOperations(operations)
+}
@@ -0,0 +1,275 @@
+package jsonpath
+
+import (
+	"reflect"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// logical is a filter expression that is either true or false for the node
+// being filtered.
+type logical interface {
+	test(root, current any) bool
+}
+
+type orExpr []logical
+
+func (e orExpr) test(root, current any) bool {
+	for _, operand := range e {
+		if operand.test(root, current) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []logical
+
+func (e andExpr) test(root, current any) bool {
+	for _, operand := range e {
+		if !operand.test(root, current) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct {
+	operand logical
+}
+
+func (e notExpr) test(root, current any) bool {
+	return !e.operand.test(root, current)
+}
+
+// existsExpr is true when its query selects at least one node.
+type existsExpr struct {
+	query *query
+}
+
+func (e existsExpr) test(root, current any) bool {
+	return len(e.query.evaluate(root, current)) > 0
+}
+
+// functionTest is a function that returns a logical result, used as a test.
+type functionTest struct {
+	call *functionCall
+}
+
+func (e functionTest) test(root, current any) bool {
+	result, _ := e.call.evaluate(root, current)
+	return result == true
+}
+
+type comparison struct {
+	op          string
+	left, right comparable
+}
+
+func (e comparison) test(root, current any) bool {
+	left, leftOK := e.left.value(root, current)
+	right, rightOK := e.right.value(root, current)
+	switch e.op {
+	case "==":
+		return equal(left, leftOK, right, rightOK)
+	case "!=":
+		return !equal(left, leftOK, right, rightOK)
+	case "<":
+		return less(left, leftOK, right, rightOK)
+	case "<=":
+		return less(left, leftOK, right, rightOK) || equal(left, leftOK, right, rightOK)
+	case ">":
+		return less(right, rightOK, left, leftOK)
+	default:
+		return less(right, rightOK, left, leftOK) || equal(left, leftOK, right, rightOK)
+	}
+}
+
+// equal compares two values, either of which may be Nothing (ok false).
+func equal(a any, aOK bool, b any, bOK bool) bool {
+	if !aOK || !bOK {
+		return aOK == bOK
+	}
+	return op.DeepEqual(a, b)
+}
+
+// less orders two numbers or two strings; other values are unordered.
+func less(a any, aOK bool, b any, bOK bool) bool {
+	if !aOK || !bOK {
+		return false
+	}
+	if x, ok := internal.NumericValue(a); ok {
+		y, ok := internal.NumericValue(b)
+		return ok && x < y
+	}
+	if x, ok := a.(string); ok {
+		y, ok := b.(string)
+		return ok && x < y
+	}
+	return false
+}
+
+// comparable produces a value, or Nothing (ok false), for a comparison or a
+// function argument.
+type comparable interface {
+	value(root, current any) (any, bool)
+}
+
+type literal struct {
+	v any
+}
+
+func (l literal) value(any, any) (any, bool) {
+	return l.v, true
+}
+
+// singularQuery yields the value of the node its query selects, if any.
+type singularQuery struct {
+	query *query
+}
+
+func (q singularQuery) value(root, current any) (any, bool) {
+	nodes := q.query.evaluate(root, current)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].value, true
+}
+
+// resultType is the declared type of a function result or parameter.
+type resultType int
+
+const (
+	valueType resultType = iota
+	logicalType
+	nodesType
+)
+
+type function struct {
+	params []resultType
+	result resultType
+}
+
+var functions = map[string]function{
+	"length": {params: []resultType{valueType}, result: valueType},
+	"count":  {params: []resultType{nodesType}, result: valueType},
+	"match":  {params: []resultType{valueType, valueType}, result: logicalType},
+	"search": {params: []resultType{valueType, valueType}, result: logicalType},
+	"value":  {params: []resultType{nodesType}, result: valueType},
+}
+
+// maxCachedPatterns bounds the patterns a match or search call caches when
+// its pattern comes from the document.
+const maxCachedPatterns = 64
+
+// functionCall is a call of one of the functions RFC 9535 defines. Its
+// arguments are comparables for value parameters and queries for nodes
+// parameters. A match or search call with a literal pattern holds its
+// matcher; one whose pattern is computed caches a matcher per pattern.
+type functionCall struct {
+	name          string
+	args          []argument
+	createMatcher internal.CreateRegexMatcher
+	limits        internal.Limits
+	matcher       internal.RegexMatcher
+
+	mu       sync.Mutex
+	matchers map[string]internal.RegexMatcher
+}
+
+type argument struct {
+	value comparable
+	nodes *query
+}
+
+func (c *functionCall) value(root, current any) (any, bool) {
+	return c.evaluate(root, current)
+}
+
+func (c *functionCall) evaluate(root, current any) (any, bool) {
+	switch c.name {
+	case "length":
+		v, ok := c.args[0].value.value(root, current)
+		if !ok {
+			return nil, false
+		}
+		if s, ok := v.(string); ok {
+			return float64(utf8.RuneCountInString(s)), true
+		}
+		if length, ok := arrayLength(v); ok {
+			return float64(length), true
+		}
+		if object := reflect.ValueOf(v); object.Kind() == reflect.Map {
+			return float64(object.Len()), true
+		}
+		return nil, false
+	case "count":
+		return float64(len(c.args[0].nodes.evaluate(root, current))), true
+	case "value":
+		nodes := c.args[0].nodes.evaluate(root, current)
+		if len(nodes) != 1 {
+			return nil, false
+		}
+		return nodes[0].value, true
+	default:
+		return c.matches(root, current), true
+	}
+}
+
+// matches evaluates match, which matches the whole string, and search, which
+// matches any substring.
+func (c *functionCall) matches(root, current any) bool {
+	v, ok := c.args[0].value.value(root, current)
+	s, isString := v.(string)
+	if !ok || !isString {
+		return false
+	}
+	matcher := c.matcher
+	if matcher == nil {
+		pattern, ok := c.args[1].value.value(root, current)
+		source, isString := pattern.(string)
+		if !ok || !isString || c.limits.CheckPattern(source) != nil {
+			return false
+		}
+		matcher = c.cached(source)
+	}
+	return matcher(s)
+}
+
+// cached returns the matcher of a pattern computed from the document,
+// compiling each pattern once up to maxCachedPatterns.
+func (c *functionCall) cached(pattern string) internal.RegexMatcher {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if matcher, ok := c.matchers[pattern]; ok {
+		return matcher
+	}
+	matcher := c.compile(pattern)
+	if c.matchers == nil {
+		c.matchers = map[string]internal.RegexMatcher{}
+	}
+	if len(c.matchers) < maxCachedPatterns {
+		c.matchers[pattern] = matcher
+	}
+	return matcher
+}
+
+// compile builds the matcher of pattern with createMatcher, or with Go
+// regexp when it is nil. An invalid pattern matches nothing.
+func (c *functionCall) compile(pattern string) internal.RegexMatcher {
+	if c.name == "match" {
+		pattern = `^(?:` + pattern + `)$`
+	}
+	if c.createMatcher != nil {
+		return c.createMatcher(pattern, false)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return func(string) bool { return false }
+	}
+	return re.MatchString
+}
@@ -0,0 +1,417 @@
+// Package jsonpath parses and evaluates JSONPath expressions (RFC 9535), such
+// as $.items[?@.sku=='A1'].qty, for jsonpatch operations compiled with the
+// JSONPath capability.
+//
+// The whole RFC 9535 syntax is supported: child and descendant segments;
+// name, wildcard, index, slice, and filter selectors; comparisons, logical
+// operators, and the length, count, match, search, and value functions.
+// Regular expressions of match and search use Go regexp syntax unless Parse
+// is given WithMatcher. In a filter, == and != compare values deeply and <,
+// <=, >, and >= order only two numbers or two strings; a number may be any Go
+// numeric type or a jsonpatch.Number, so 3, int64(3), and 3.0 are all equal.
+// Object members are visited in sorted key order, so a query selects the same
+// nodes in the same order every time.
+package jsonpath
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// ErrSyntax reports an expression that is not a valid JSONPath query.
+var ErrSyntax = errors.New("invalid JSONPath expression")
+
+// ErrRegexDisabled reports a call of match or search in a query parsed with
+// DisableRegex.
+var ErrRegexDisabled = errors.New("match and search are disabled")
+
+// Limits bounds the patterns of match and search. Only MaxPatternLength
+// applies. A zero field is unlimited.
+type Limits = internal.Limits
+
+// Options configures how Parse handles the regular expressions of match and
+// search.
+type Options struct {
+	// CreateMatcher overrides regex compilation. The pattern of match is
+	// passed anchored at both ends.
+	CreateMatcher internal.CreateRegexMatcher
+	// Limits bounds the patterns. A literal pattern over MaxPatternLength
+	// fails Parse; a pattern taken from the document matches nothing.
+	Limits Limits
+	// DisableRegex rejects queries that call match or search.
+	DisableRegex bool
+}
+
+// Option is a functional option for Parse.
+type Option func(*Options)
+
+// WithMatcher sets the function that compiles match and search patterns.
+func WithMatcher(createMatcher internal.CreateRegexMatcher) Option {
+	return func(o *Options) {
+		o.CreateMatcher = createMatcher
+	}
+}
+
+// WithLimits sets the limits on match and search patterns.
+func WithLimits(limits Limits) Option {
+	return func(o *Options) {
+		o.Limits = limits
+	}
+}
+
+// WithoutRegex rejects queries that call match or search with
+// ErrRegexDisabled.
+func WithoutRegex() Option {
+	return func(o *Options) {
+		o.DisableRegex = true
+	}
+}
+
+// Query is a parsed JSONPath query. It is safe for concurrent use.
+type Query struct {
+	expression string
+	query      *query
+}
+
+// query is a sequence of segments applied to the root node ($) or, inside a
+// filter, to the current node (@).
+type query struct {
+	relative bool
+	segments []segment
+}
+
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// selector selects children of a node and passes each one to emit.
+type selector interface {
+	selectFrom(n node, root any, emit func(node))
+}
+
+// node is a value in the document with its location.
+type node struct {
+	value any
+	path  []string
+}
+
+// String returns the expression the query was parsed from.
+func (q *Query) String() string {
+	return q.expression
+}
+
+// Select returns the normalized paths of the nodes the query selects from
+// doc, as JSON Pointer segments, in the order RFC 9535 defines. A node
+// selected twice appears twice.
+func (q *Query) Select(doc any) [][]string {
+	nodes := q.query.evaluate(doc, doc)
+	paths := make([][]string, len(nodes))
+	for i, n := range nodes {
+		paths[i] = n.path
+	}
+	return paths
+}
+
+// Locate returns the paths an operation targeted by the query applies to in
+// doc. Unlike Select, the trailing name and non-negative index segments of
+// the query are appended to the selected paths without being looked up, so
+// that they can name members the operation creates, and each path appears
+// once.
+func (q *Query) Locate(doc any) [][]string {
+	head, tail := q.query.splitLiteralTail()
+	var paths [][]string
+	for _, n := range head.evaluate(doc, doc) {
+		path := slices.Concat(n.path, tail)
+		if !slices.ContainsFunc(paths, func(located []string) bool { return slices.Equal(located, path) }) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Pattern returns a JSON Pointer pattern covering every path Locate can
+// return, as segments. A "*" segment stands for any one segment; after a
+// descendant segment, the pattern ends with "*" and covers the paths below
+// it at any depth.
+func (q *Query) Pattern() []string {
+	pattern := []string{}
+	for _, s := range q.query.segments {
+		if s.descendant {
+			return append(pattern, "*")
+		}
+		if segment, ok := s.literal(); ok {
+			pattern = append(pattern, segment)
+		} else {
+			pattern = append(pattern, "*")
+		}
+	}
+	return pattern
+}
+
+// splitLiteralTail splits q before its trailing literal segments, returning
+// those as path segments.
+func (q *query) splitLiteralTail() (*query, []string) {
+	end := len(q.segments)
+	for end > 0 {
+		if _, ok := q.segments[end-1].literal(); !ok {
+			break
+		}
+		end--
+	}
+	tail := make([]string, 0, len(q.segments)-end)
+	for _, s := range q.segments[end:] {
+		segment, _ := s.literal()
+		tail = append(tail, segment)
+	}
+	return &query{relative: q.relative, segments: q.segments[:end]}, tail
+}
+
+// literal returns the path segment of a child segment with a single name or
+// non-negative index selector.
+func (s segment) literal() (string, bool) {
+	if s.descendant || len(s.selectors) != 1 {
+		return "", false
+	}
+	switch sel := s.selectors[0].(type) {
+	case nameSelector:
+		return string(sel), true
+	case indexSelector:
+		if sel >= 0 {
+			return strconv.Itoa(int(sel)), true
+		}
+	}
+	return "", false
+}
+
+// singular reports whether q selects at most one node: every segment is a
+// child segment with a single name or index selector.
+func (q *query) singular() bool {
+	for _, s := range q.segments {
+		if s.descendant || len(s.selectors) != 1 {
+			return false
+		}
+		switch s.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// evaluate applies q to root, or to current for a relative query.
+func (q *query) evaluate(root, current any) []node {
+	start := node{value: root, path: []string{}}
+	if q.relative {
+		start.value = current
+	}
+	nodes := []node{start}
+	for _, s := range q.segments {
+		var next []node
+		emit := func(n node) { next = append(next, n) }
+		for _, n := range nodes {
+			if s.descendant {
+				visitDescendants(n, func(visited node) {
+					for _, sel := range s.selectors {
+						sel.selectFrom(visited, root, emit)
+					}
+				})
+				continue
+			}
+			for _, sel := range s.selectors {
+				sel.selectFrom(n, root, emit)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// visitDescendants calls visit with n and then with each of its descendants,
+// parents before their children.
+func visitDescendants(n node, visit func(node)) {
+	visit(n)
+	for _, child := range children(n) {
+		visitDescendants(child, visit)
+	}
+}
+
+type nameSelector string
+
+func (s nameSelector) selectFrom(n node, _ any, emit func(node)) {
+	if value, ok := member(n.value, string(s)); ok {
+		emit(n.child(string(s), value))
+	}
+}
+
+type wildcardSelector struct{}
+
+func (wildcardSelector) selectFrom(n node, _ any, emit func(node)) {
+	for _, child := range children(n) {
+		emit(child)
+	}
+}
+
+type indexSelector int
+
+func (s indexSelector) selectFrom(n node, _ any, emit func(node)) {
+	length, ok := arrayLength(n.value)
+	if !ok {
+		return
+	}
+	index := int(s)
+	if index < 0 {
+		index += length
+	}
+	if index >= 0 && index < length {
+		emit(n.element(index))
+	}
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) selectFrom(n node, _ any, emit func(node)) {
+	length, ok := arrayLength(n.value)
+	if !ok || s.step == 0 {
+		return
+	}
+	normalize := func(bound *int, fallback int) int {
+		switch {
+		case bound == nil:
+			return fallback
+		case *bound < 0:
+			return length + *bound
+		default:
+			return *bound
+		}
+	}
+	if s.step > 0 {
+		lower := min(max(normalize(s.start, 0), 0), length)
+		upper := min(max(normalize(s.end, length), 0), length)
+		for i := lower; i < upper; i += s.step {
+			emit(n.element(i))
+		}
+		return
+	}
+	upper := min(max(normalize(s.start, length-1), -1), length-1)
+	lower := min(max(normalize(s.end, -length-1), -1), length-1)
+	for i := upper; lower < i; i += s.step {
+		emit(n.element(i))
+	}
+}
+
+type filterSelector struct {
+	expr logical
+}
+
+func (s filterSelector) selectFrom(n node, root any, emit func(node)) {
+	for _, child := range children(n) {
+		if s.expr.test(root, child.value) {
+			emit(child)
+		}
+	}
+}
+
+func (n node) child(name string, value any) node {
+	return node{value: value, path: append(slices.Clip(n.path), name)}
+}
+
+func (n node) element(index int) node {
+	value, _ := elementAt(n.value, index)
+	return n.child(strconv.Itoa(index), value)
+}
+
+// children returns the elements of an array or the members of an object, in
+// sorted key order. Other values have no children.
+func children(n node) []node {
+	if length, ok := arrayLength(n.value); ok {
+		nodes := make([]node, length)
+		for i := range nodes {
+			nodes[i] = n.element(i)
+		}
+		return nodes
+	}
+	switch object := n.value.(type) {
+	case map[string]any:
+		nodes := make([]node, 0, len(object))
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			nodes = append(nodes, n.child(name, object[name]))
+		}
+		return nodes
+	}
+	v := reflect.ValueOf(n.value)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil
+	}
+	names := make([]string, 0, v.Len())
+	for _, key := range v.MapKeys() {
+		names = append(names, key.String())
+	}
+	slices.Sort(names)
+	nodes := make([]node, len(names))
+	for i, name := range names {
+		value, _ := member(n.value, name)
+		nodes[i] = n.child(name, value)
+	}
+	return nodes
+}
+
+// member returns the member name of an object.
+func member(value any, name string) (any, bool) {
+	if object, ok := value.(map[string]any); ok {
+		member, ok := object[name]
+		return member, ok
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	member := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+	if !member.IsValid() {
+		return nil, false
+	}
+	return member.Interface(), true
+}
+
+// arrayLength returns the length of an array.
+func arrayLength(value any) (int, bool) {
+	if array, ok := value.([]any); ok {
+		return len(array), true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return 0, false
+		}
+		return v.Len(), true
+	case reflect.Array:
+		return v.Len(), true
+	default:
+		return 0, false
+	}
+}
+
+// elementAt returns the element index of an array.
+func elementAt(value any, index int) (any, bool) {
+	if array, ok := value.([]any); ok {
+		if index < 0 || index >= len(array) {
+			return nil, false
+		}
+		return array[index], true
+	}
+	length, ok := arrayLength(value)
+	if !ok || index < 0 || index >= length {
+		return nil, false
+	}
+	return reflect.ValueOf(value).Index(index).Interface(), true
+}
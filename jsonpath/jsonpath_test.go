@@ -0,0 +1,208 @@
+package jsonpath
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/go-json-experiment/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// store is the example document of RFC 9535, section 1.5.
+const store = `{
+	"store": {
+		"book": [
+			{"category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95},
+			{"category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99},
+			{"category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99},
+			{"category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99}
+		],
+		"bicycle": {"color": "red", "price": 399}
+	}
+}`
+
+func decode(t *testing.T, data string) any {
+	t.Helper()
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(data), &doc))
+	return doc
+}
+
+func pointers(paths [][]string) []string {
+	formatted := make([]string, len(paths))
+	for i, path := range paths {
+		formatted[i] = jsonpointer.Format(path...)
+	}
+	return formatted
+}
+
+func TestSelect(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		doc   string
+		want  []string
+	}{
+		{name: "root", query: "$", doc: store, want: []string{""}},
+		{name: "authors", query: "$.store.book[*].author", doc: store, want: []string{
+			"/store/book/0/author", "/store/book/1/author", "/store/book/2/author", "/store/book/3/author",
+		}},
+		{name: "all authors", query: "$..author", doc: store, want: []string{
+			"/store/book/0/author", "/store/book/1/author", "/store/book/2/author", "/store/book/3/author",
+		}},
+		{name: "store members in key order", query: "$.store.*", doc: store, want: []string{"/store/bicycle", "/store/book"}},
+		{name: "all prices", query: "$.store..price", doc: store, want: []string{
+			"/store/bicycle/price", "/store/book/0/price", "/store/book/1/price", "/store/book/2/price", "/store/book/3/price",
+		}},
+		{name: "third book", query: "$..book[2]", doc: store, want: []string{"/store/book/2"}},
+		{name: "last book", query: "$..book[-1]", doc: store, want: []string{"/store/book/3"}},
+		{name: "union", query: "$..book[0,1]", doc: store, want: []string{"/store/book/0", "/store/book/1"}},
+		{name: "slice", query: "$..book[:2]", doc: store, want: []string{"/store/book/0", "/store/book/1"}},
+		{name: "books with isbn", query: "$..book[?@.isbn]", doc: store, want: []string{"/store/book/2", "/store/book/3"}},
+		{name: "cheap books", query: "$..book[?@.price<10]", doc: store, want: []string{"/store/book/0", "/store/book/2"}},
+		{name: "bracketed names", query: `$['store']["bicycle"]`, doc: store, want: []string{"/store/bicycle"}},
+		{name: "missing member", query: "$.store.car", doc: store, want: []string{}},
+		{name: "reverse slice", query: "$[::-1]", doc: `[0, 1, 2]`, want: []string{"/2", "/1", "/0"}},
+		{name: "stepped slice", query: "$[1:5:2]", doc: `[0, 1, 2, 3, 4, 5]`, want: []string{"/1", "/3"}},
+		{name: "zero step", query: "$[::0]", doc: `[0, 1]`, want: []string{}},
+		{name: "duplicates kept", query: "$[0, 0]", doc: `["a"]`, want: []string{"/0", "/0"}},
+		{name: "string comparison", query: "$[?@.sku == 'A1']", doc: `[{"sku": "A1"}, {"sku": "B2"}]`, want: []string{"/0"}},
+		{name: "and or not", query: "$[?@.a == 1 && (@.b == 2 || !@.c)]", doc: `[{"a": 1, "b": 2, "c": 0}, {"a": 1}, {"a": 1, "c": 0}]`, want: []string{"/0", "/1"}},
+		{name: "absolute query in filter", query: "$.items[?@.qty > $.min]", doc: `{"min": 2, "items": [{"qty": 1}, {"qty": 3}]}`, want: []string{"/items/1"}},
+		{name: "missing compares equal only to missing", query: "$[?@.a == @.b]", doc: `[{}, {"a": null}, {"a": 1, "b": 1}]`, want: []string{"/0", "/2"}},
+		{name: "length", query: "$[?length(@.name) > 3]", doc: `[{"name": "Ada"}, {"name": "Grace"}]`, want: []string{"/1"}},
+		{name: "count", query: "$[?count(@.*) == 2]", doc: `[{"a": 1}, {"a": 1, "b": 2}]`, want: []string{"/1"}},
+		{name: "match", query: "$[?match(@.code, '[A-Z][0-9]')]", doc: `[{"code": "A1"}, {"code": "A12"}]`, want: []string{"/0"}},
+		{name: "search", query: "$[?search(@.code, '[0-9]')]", doc: `[{"code": "A1"}, {"code": "AB"}]`, want: []string{"/0"}},
+		{name: "value", query: "$[?value(@..id) == 7]", doc: `[{"x": {"id": 7}}, {"id": 8}]`, want: []string{"/0"}},
+		{name: "escaped name", query: `$['a\'b', "é"]`, doc: `{"a'b": 1, "é": 2}`, want: []string{"/a'b", "/é"}},
+		{name: "blank between segments", query: "$ .a [0]", doc: `{"a": [1]}`, want: []string{"/a/0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := Parse(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.query, query.String())
+			assert.Equal(t, tt.want, pointers(query.Select(decode(t, tt.doc))))
+		})
+	}
+}
+
+func TestSelectGoValues(t *testing.T) {
+	t.Parallel()
+
+	query, err := Parse("$.items[?@.price >= 10].name")
+	require.NoError(t, err)
+	doc := map[string]any{"items": []map[string]any{
+		{"name": "a", "price": 5},
+		{"name": "b", "price": int64(10)},
+		{"name": "c", "price": internal.Number("10.50")},
+	}}
+	assert.Equal(t, []string{"/items/1/name", "/items/2/name"}, pointers(query.Select(doc)))
+}
+
+func TestLocate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		query       string
+		wantPattern string
+		want        []string
+	}{
+		{name: "literal tail", query: "$.items[?@.sku=='A1'].qty", wantPattern: "/items/*/qty", want: []string{"/items/0/qty", "/items/2/qty"}},
+		{name: "new member", query: "$.meta.owner", wantPattern: "/meta/owner", want: []string{"/meta/owner"}},
+		{name: "negative index resolved", query: "$.items[-1]", wantPattern: "/items/*", want: []string{"/items/2"}},
+		{name: "duplicates dropped", query: "$.items[0,0,1]", wantPattern: "/items/*", want: []string{"/items/0", "/items/1"}},
+		{name: "descendant", query: "$..sku", wantPattern: "/*", want: []string{"/items/0/sku", "/items/1/sku", "/items/2/sku"}},
+		{name: "no match", query: "$.missing[*].qty", wantPattern: "/missing/*/qty", want: []string{}},
+	}
+
+	doc := decode(t, `{"items": [{"sku": "A1"}, {"sku": "B2", "qty": 1}, {"sku": "A1"}]}`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			query, err := Parse(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPattern, jsonpointer.Format(query.Pattern()...))
+			assert.Equal(t, tt.want, pointers(query.Locate(doc)))
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, expression := range []string{
+		"",
+		"@.a",
+		"$.",
+		"$a",
+		"$ ",
+		"$.1a",
+		"$[01]",
+		"$[-0]",
+		"$[9007199254740992]",
+		"$['a]",
+		`$['\x']`,
+		`$["\ud800"]`,
+		"$[?@.a == 01]",
+		"$[?@.* == 1]",
+		"$[?1]",
+		"$[?length(@.a)]",
+		"$[?match(@.a, 'x') == true]",
+		"$[?count(1) == 1]",
+		"$[?unknown(@.a)]",
+		"$[?(@.a]",
+		"$[1,]",
+	} {
+		t.Run(expression, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(expression)
+			require.ErrorIs(t, err, ErrSyntax)
+		})
+	}
+}
+
+func TestParseRegexOptions(t *testing.T) {
+	t.Parallel()
+
+	_, err := Parse("$[?search(@.code, 'A')]", WithoutRegex())
+	require.ErrorIs(t, err, ErrRegexDisabled)
+
+	_, err = Parse("$[?search(@.code, 'ABCD')]", WithLimits(Limits{MaxPatternLength: 3}))
+	require.ErrorIs(t, err, internal.ErrLimitExceeded)
+
+	var compiled []string
+	var mu sync.Mutex
+	createMatcher := func(pattern string, _ bool) internal.RegexMatcher {
+		mu.Lock()
+		compiled = append(compiled, pattern)
+		mu.Unlock()
+		return func(value string) bool { return value == "A1" }
+	}
+	q, err := Parse("$[?match(@.code, @.pattern)]", WithMatcher(createMatcher), WithLimits(Limits{MaxPatternLength: 3}))
+	require.NoError(t, err)
+
+	var doc any
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"code": "A1", "pattern": "A."},
+		{"code": "B2", "pattern": "A."},
+		{"code": "A1", "pattern": "A.*."},
+		{"code": "A1", "pattern": "A."}
+	]`), &doc))
+	assert.Equal(t, [][]string{{"0"}, {"3"}}, q.Select(doc))
+	assert.Equal(t, []string{"^(?:A.)$"}, compiled, "a computed pattern is compiled once and one over the limit not at all")
+}
@@ -0,0 +1,645 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxInt is the largest index, slice bound, or step RFC 9535 allows: the
+// largest integer an IEEE 754 double holds exactly.
+const maxInt = 1<<53 - 1
+
+// Parse parses a JSONPath query, which starts with the root identifier $.
+func Parse(expression string, opts ...Option) (*Query, error) {
+	p := &parser{input: expression}
+	for _, opt := range opts {
+		opt(&p.options)
+	}
+	if p.peek() != '$' {
+		return nil, p.errorf("query must start with $")
+	}
+	q, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, p.errorf("unexpected %q", p.input[p.pos:])
+	}
+	return &Query{expression: expression, query: q}, nil
+}
+
+type parser struct {
+	input   string
+	pos     int
+	options Options
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrSyntax, fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(token string) error {
+	if !p.consume(token) {
+		return p.errorf("expected %q", token)
+	}
+	return nil
+}
+
+// skipBlank skips the blank space RFC 9535 allows between tokens.
+func (p *parser) skipBlank() {
+	for p.pos < len(p.input) {
+		switch p.input[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// parseQuery parses a query starting with $ or, inside a filter, @.
+func (p *parser) parseQuery() (*query, error) {
+	q := &query{}
+	switch p.peek() {
+	case '$':
+	case '@':
+		q.relative = true
+	default:
+		return nil, p.errorf("expected $ or @")
+	}
+	p.pos++
+	for {
+		start := p.pos
+		p.skipBlank()
+		if c := p.peek(); c != '.' && c != '[' {
+			p.pos = start
+			return q, nil
+		}
+		s, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, s)
+	}
+}
+
+func (p *parser) parseSegment() (segment, error) {
+	var s segment
+	switch {
+	case p.consume(".."):
+		s.descendant = true
+		if p.peek() == '[' {
+			selectors, err := p.parseBracketed()
+			s.selectors = selectors
+			return s, err
+		}
+	case p.consume("."):
+	default:
+		selectors, err := p.parseBracketed()
+		s.selectors = selectors
+		return s, err
+	}
+	if p.consume("*") {
+		s.selectors = []selector{wildcardSelector{}}
+		return s, nil
+	}
+	name, err := p.parseMemberName()
+	s.selectors = []selector{nameSelector(name)}
+	return s, err
+}
+
+// parseMemberName parses the member name of a shorthand such as .name.
+func (p *parser) parseMemberName() (string, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		r, size := utf8.DecodeRuneInString(p.input[p.pos:])
+		first := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(r >= 0x80 && !(r == utf8.RuneError && size == 1))
+		if !first && (p.pos == start || r < '0' || r > '9') {
+			break
+		}
+		p.pos += size
+	}
+	if p.pos == start {
+		return "", p.errorf("expected a member name")
+	}
+	return p.input[start:p.pos], nil
+}
+
+func (p *parser) parseBracketed() ([]selector, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var selectors []selector
+	for {
+		p.skipBlank()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, sel)
+		p.skipBlank()
+		if p.consume("]") {
+			return selectors, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, err := p.parseString()
+		return nameSelector(name), err
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+	case c == '?':
+		p.pos++
+		p.skipBlank()
+		expr, err := p.parseLogicalOr()
+		return filterSelector{expr: expr}, err
+	}
+
+	start, hasStart, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if !p.consume(":") {
+		if !hasStart {
+			return nil, p.errorf("expected a selector")
+		}
+		return indexSelector(start), nil
+	}
+	slice := sliceSelector{step: 1}
+	if hasStart {
+		slice.start = &start
+	}
+	p.skipBlank()
+	end, hasEnd, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+	if hasEnd {
+		slice.end = &end
+	}
+	p.skipBlank()
+	if p.consume(":") {
+		p.skipBlank()
+		step, hasStep, err := p.parseInt()
+		if err != nil {
+			return nil, err
+		}
+		if hasStep {
+			slice.step = step
+		}
+	}
+	return slice, nil
+}
+
+// parseInt parses an optional integer: 0, or a non-zero integer without
+// leading zeros.
+func (p *parser) parseInt() (int, bool, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+		p.pos++
+	}
+	text := p.input[start:p.pos]
+	switch {
+	case p.pos == digits && p.pos == start:
+		return 0, false, nil
+	case p.pos == digits:
+		return 0, false, p.errorf("expected digits")
+	case p.input[digits] == '0' && (p.pos > digits+1 || digits > start):
+		return 0, false, p.errorf("invalid integer %q", text)
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n > maxInt || n < -maxInt {
+		return 0, false, p.errorf("integer %s out of range", text)
+	}
+	return n, true, nil
+}
+
+// parseString parses a single- or double-quoted string literal.
+func (p *parser) parseString() (string, error) {
+	quote := p.input[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return b.String(), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		p.pos++
+		escaped := p.peek()
+		p.pos++
+		switch escaped {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '/', '\\':
+			b.WriteByte(escaped)
+		case 'u':
+			r, err := p.parseUnicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+		default:
+			if escaped != quote {
+				return "", p.errorf("invalid escape")
+			}
+			b.WriteByte(escaped)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// parseUnicodeEscape parses the hex digits of a \u escape, and the low
+// surrogate escape that must follow a high surrogate.
+func (p *parser) parseUnicodeEscape() (rune, error) {
+	r, err := p.parseHex4()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case r >= 0xDC00 && r <= 0xDFFF:
+		return 0, p.errorf("unpaired surrogate")
+	case r >= 0xD800 && r <= 0xDBFF:
+		if !p.consume(`\u`) {
+			return 0, p.errorf("unpaired surrogate")
+		}
+		low, err := p.parseHex4()
+		if err != nil {
+			return 0, err
+		}
+		if low < 0xDC00 || low > 0xDFFF {
+			return 0, p.errorf("unpaired surrogate")
+		}
+		return utf16.DecodeRune(r, low), nil
+	default:
+		return r, nil
+	}
+}
+
+func (p *parser) parseHex4() (rune, error) {
+	if p.pos+4 > len(p.input) {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	n, err := strconv.ParseUint(p.input[p.pos:p.pos+4], 16, 32)
+	if err != nil {
+		return 0, p.errorf("invalid unicode escape")
+	}
+	p.pos += 4
+	return rune(n), nil
+}
+
+func (p *parser) parseLogicalOr() (logical, error) {
+	var operands orExpr
+	for {
+		operand, err := p.parseLogicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		start := p.pos
+		p.skipBlank()
+		if !p.consume("||") {
+			p.pos = start
+			break
+		}
+		p.skipBlank()
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *parser) parseLogicalAnd() (logical, error) {
+	var operands andExpr
+	for {
+		operand, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		start := p.pos
+		p.skipBlank()
+		if !p.consume("&&") {
+			p.pos = start
+			break
+		}
+		p.skipBlank()
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+// parseBasic parses a parenthesized expression, a comparison, or a test,
+// each optionally negated.
+func (p *parser) parseBasic() (logical, error) {
+	if p.consume("!") {
+		p.skipBlank()
+		if p.peek() == '(' {
+			expr, err := p.parseParen()
+			return notExpr{operand: expr}, err
+		}
+		expr, err := p.parseTest()
+		return notExpr{operand: expr}, err
+	}
+	if p.peek() == '(' {
+		return p.parseParen()
+	}
+
+	left, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	afterLeft := p.pos
+	p.skipBlank()
+	operator := p.parseComparisonOp()
+	if operator == "" {
+		p.pos = afterLeft
+		return p.test(left)
+	}
+	p.skipBlank()
+	right, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	for _, operand := range []comparable{left, right} {
+		if err := checkComparable(operand); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+	}
+	return comparison{op: operator, left: left, right: right}, nil
+}
+
+func (p *parser) parseParen() (logical, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	expr, err := p.parseLogicalOr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	return expr, p.expect(")")
+}
+
+// parseTest parses a query, true when it selects a node, or a function with
+// a logical result.
+func (p *parser) parseTest() (logical, error) {
+	operand, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	return p.test(operand)
+}
+
+func (p *parser) test(operand comparable) (logical, error) {
+	switch typed := operand.(type) {
+	case singularQuery:
+		return existsExpr{query: typed.query}, nil
+	case nodesQuery:
+		return existsExpr{query: typed.query}, nil
+	case *functionCall:
+		if functions[typed.name].result == valueType {
+			return nil, p.errorf("%s() result is not a test", typed.name)
+		}
+		return functionTest{call: typed}, nil
+	default:
+		return nil, p.errorf("literal is not a test")
+	}
+}
+
+func (p *parser) parseComparisonOp() string {
+	for _, operator := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(operator) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// nodesQuery is a query that may select several nodes. It is not
+// comparable, but can be a test or a count or value argument.
+type nodesQuery struct {
+	query *query
+}
+
+func (q nodesQuery) value(any, any) (any, bool) {
+	return nil, false
+}
+
+// checkComparable rejects operands that do not produce a single value.
+func checkComparable(operand comparable) error {
+	switch typed := operand.(type) {
+	case nodesQuery:
+		return fmt.Errorf("query compared is not singular")
+	case *functionCall:
+		if functions[typed.name].result != valueType {
+			return fmt.Errorf("%s() result is not comparable", typed.name)
+		}
+	}
+	return nil
+}
+
+// parseComparable parses a literal, a query, or a function call. A query
+// that is not singular is returned as a nodesQuery.
+func (p *parser) parseComparable() (comparable, error) {
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		q, err := p.parseQuery()
+		if err != nil {
+			return nil, err
+		}
+		if q.singular() {
+			return singularQuery{query: q}, nil
+		}
+		return nodesQuery{query: q}, nil
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		return literal{v: s}, err
+	case c == '-' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	}
+	for _, keyword := range keywords {
+		if p.consume(keyword.name) {
+			if isFunctionNameChar(p.peek()) {
+				p.pos -= len(keyword.name)
+				break
+			}
+			return literal{v: keyword.value}, nil
+		}
+	}
+	if c >= 'a' && c <= 'z' {
+		return p.parseFunction()
+	}
+	return nil, p.errorf("expected a literal, query, or function")
+}
+
+var keywords = []struct {
+	name  string
+	value any
+}{{"true", true}, {"false", false}, {"null", nil}}
+
+func isFunctionNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// parseNumber parses a JSON number.
+func (p *parser) parseNumber() (comparable, error) {
+	start := p.pos
+	p.consume("-")
+	digits := func() int {
+		from := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] >= '0' && p.input[p.pos] <= '9' {
+			p.pos++
+		}
+		return p.pos - from
+	}
+	intStart := p.pos
+	if n := digits(); n == 0 || (n > 1 && p.input[intStart] == '0') {
+		return nil, p.errorf("invalid number")
+	}
+	if p.consume(".") && digits() == 0 {
+		return nil, p.errorf("invalid number")
+	}
+	if p.consume("e") || p.consume("E") {
+		if !p.consume("+") {
+			p.consume("-")
+		}
+		if digits() == 0 {
+			return nil, p.errorf("invalid number")
+		}
+	}
+	n, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return nil, p.errorf("invalid number")
+	}
+	return literal{v: n}, nil
+}
+
+func (p *parser) parseFunction() (*functionCall, error) {
+	start := p.pos
+	for isFunctionNameChar(p.peek()) {
+		p.pos++
+	}
+	name := p.input[start:p.pos]
+	fn, ok := functions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %q", name)
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if (name == "match" || name == "search") && p.options.DisableRegex {
+		return nil, fmt.Errorf("%w: %s() at offset %d", ErrRegexDisabled, name, start)
+	}
+	call := &functionCall{name: name, createMatcher: p.options.CreateMatcher, limits: p.options.Limits}
+	for i, param := range fn.params {
+		p.skipBlank()
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			p.skipBlank()
+		}
+		operand, err := p.parseComparable()
+		if err != nil {
+			return nil, err
+		}
+		arg, err := functionArgument(name, param, operand)
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		call.args = append(call.args, arg)
+	}
+	p.skipBlank()
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if pattern, ok := call.regexLiteral(); ok {
+		if err := call.limits.CheckPattern(pattern); err != nil {
+			return nil, fmt.Errorf("%s() pattern at offset %d: %w", name, start, err)
+		}
+		call.matcher = call.compile(pattern)
+	}
+	return call, nil
+}
+
+// functionArgument checks operand against the declared parameter type.
+func functionArgument(name string, param resultType, operand comparable) (argument, error) {
+	if param == nodesType {
+		switch typed := operand.(type) {
+		case singularQuery:
+			return argument{nodes: typed.query}, nil
+		case nodesQuery:
+			return argument{nodes: typed.query}, nil
+		default:
+			return argument{}, fmt.Errorf("%s() takes a query", name)
+		}
+	}
+	if err := checkComparable(operand); err != nil {
+		return argument{}, fmt.Errorf("%s() argument: %w", name, err)
+	}
+	return argument{value: operand}, nil
+}
+
+// regexLiteral returns the pattern of a match or search call when it is a
+// string literal, so that it is compiled once.
+func (c *functionCall) regexLiteral() (string, bool) {
+	if c.name != "match" && c.name != "search" {
+		return "", false
+	}
+	lit, ok := c.args[1].value.(literal)
+	if !ok {
+		return "", false
+	}
+	pattern, ok := lit.v.(string)
+	return pattern, ok
+}
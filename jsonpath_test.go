@@ -0,0 +1,209 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/jsonpath"
+)
+
+// jsonPathCaps enables the core, predicate, and extended vocabularies with
+// JSONPath paths.
+var jsonPathCaps = jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Predicate, jsonpatch.Extended, jsonpatch.JSONPath)
+
+func jsonPathCart() map[string]any {
+	return map[string]any{
+		"items": []any{
+			map[string]any{"sku": "A1", "qty": 1.0},
+			map[string]any{"sku": "B2", "qty": 5.0},
+			map[string]any{"sku": "A1", "qty": 2.0},
+		},
+	}
+}
+
+func TestJSONPathApply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		patch     string
+		want      map[string]any
+		wantPaths []string
+	}{
+		{
+			name:  "replace filtered elements",
+			patch: `[{"op": "replace", "path": "$.items[?@.sku=='A1'].qty", "value": 9}]`,
+			want: map[string]any{"items": []any{
+				map[string]any{"sku": "A1", "qty": 9.0},
+				map[string]any{"sku": "B2", "qty": 5.0},
+				map[string]any{"sku": "A1", "qty": 9.0},
+			}},
+			wantPaths: []string{"/items/0/qty", "/items/2/qty"},
+		},
+		{
+			name:  "inc and test",
+			patch: `[{"op": "test", "path": "$.items[?@.qty > 4].sku", "value": "B2"}, {"op": "inc", "path": "$.items[*].qty", "inc": 1}]`,
+			want: map[string]any{"items": []any{
+				map[string]any{"sku": "A1", "qty": 2.0},
+				map[string]any{"sku": "B2", "qty": 6.0},
+				map[string]any{"sku": "A1", "qty": 3.0},
+			}},
+			wantPaths: []string{"/items/1/sku", "/items/0/qty", "/items/1/qty", "/items/2/qty"},
+		},
+		{
+			name:  "add a member to filtered elements",
+			patch: `[{"op": "add", "path": "$.items[?@.qty < 2].note", "value": "low"}]`,
+			want: map[string]any{"items": []any{
+				map[string]any{"sku": "A1", "qty": 1.0, "note": "low"},
+				map[string]any{"sku": "B2", "qty": 5.0},
+				map[string]any{"sku": "A1", "qty": 2.0},
+			}},
+			wantPaths: []string{"/items/0/note"},
+		},
+		{
+			name:  "remove filtered elements from the last back",
+			patch: `[{"op": "remove", "path": "$.items[?@.sku=='A1']"}]`,
+			want: map[string]any{"items": []any{
+				map[string]any{"sku": "B2", "qty": 5.0},
+			}},
+			wantPaths: []string{"/items/2", "/items/0"},
+		},
+		{
+			name:      "predicate operands relative to each node",
+			patch:     `[{"op": "and", "path": "$.items[?@.sku=='A1']", "apply": [{"op": "less", "path": "/qty", "value": 3}]}]`,
+			want:      jsonPathCart(),
+			wantPaths: []string{"/items/0", "/items/2"},
+		},
+		{
+			name:  "no match",
+			patch: `[{"op": "replace", "path": "$.items[?@.sku=='C3'].qty", "value": 0}]`,
+			want:  jsonPathCart(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), jsonPathCaps)
+			require.NoError(t, err)
+
+			result, err := jsonpatch.Apply(patch, jsonPathCart())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result.Doc)
+
+			var paths []string
+			for _, step := range result.Steps {
+				assert.True(t, step.Applied())
+				paths = append(paths, step.Path())
+			}
+			assert.Equal(t, tt.wantPaths, paths)
+		})
+	}
+}
+
+func TestJSONPathFailures(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[{"op": "replace", "path": "$.items[?@.sku=='A1'].qty", "value": 9}]`)
+	_, err := jsonpatch.CompileJSON(data)
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+	_, err = jsonpatch.CompileJSON(data, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+
+	_, err = jsonpatch.CompileJSON([]byte(`[{"op": "replace", "path": "$.items[?@.sku==]", "value": 9}]`), jsonPathCaps)
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	require.ErrorIs(t, err, jsonpath.ErrSyntax)
+
+	_, err = jsonpatch.CompileOperations(
+		[]jsoncodec.Operation{{Op: "remove", Path: "$"}},
+		jsonPathCaps, jsonpatch.WithRestrictions(jsonpatch.NoRootWrites),
+	)
+	require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+
+	_, err = jsonpatch.CompileJSON(data, jsonPathCaps, jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/items/1/qty")))
+	require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+
+	patch, err := jsonpatch.CompileJSON([]byte(`[{"op": "test", "path": "$.items[*].sku", "value": "A1"}]`), jsonPathCaps)
+	require.NoError(t, err)
+	_, err = jsonpatch.Apply(patch, jsonPathCart())
+	require.ErrorIs(t, err, jsonpatch.ErrTestFailed)
+	var patchErr *jsonpatch.Error
+	require.ErrorAs(t, err, &patchErr)
+	assert.Equal(t, "/items/1/sku", patchErr.Path())
+}
+
+func TestJSONPathRegexFunctions(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[{"op": "replace", "path": "$.items[?match(@.sku, 'A[0-9]')].qty", "value": 9}]`)
+	_, err := jsonpatch.CompileJSON(data, jsonPathCaps)
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+	require.ErrorIs(t, err, jsonpath.ErrRegexDisabled)
+
+	regexCaps := jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.RegexPredicate, jsonpatch.JSONPath)
+	_, err = jsonpatch.CompileJSON(data, regexCaps, jsonpatch.WithMaxPatternLength(3))
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+
+	patch, err := jsonpatch.CompileJSON(data, regexCaps)
+	require.NoError(t, err)
+	result, err := jsonpatch.Apply(patch, jsonPathCart())
+	require.NoError(t, err)
+	assert.Equal(t, []any{9.0, 5.0, 9.0}, qtys(result.Doc))
+
+	matchAll := func(string, bool) jsonpatch.RegexMatcher { return func(string) bool { return true } }
+	patch, err = jsonpatch.CompileJSON(data, regexCaps, jsonpatch.WithCompileMatcher(matchAll))
+	require.NoError(t, err)
+	result, err = jsonpatch.Apply(patch, jsonPathCart())
+	require.NoError(t, err)
+	assert.Equal(t, []any{9.0, 9.0, 9.0}, qtys(result.Doc))
+}
+
+func qtys(doc map[string]any) []any {
+	var values []any
+	for _, item := range doc["items"].([]any) {
+		values = append(values, item.(map[string]any)["qty"])
+	}
+	return values
+}
+
+func TestJSONPathEncodingAndAnalysis(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileJSON(
+		[]byte(`[{"op": "replace", "path": "$.items[?@.sku=='A1'].qty", "value": 9}, {"op": "add", "path": "/total", "value": 0}]`),
+		jsonPathCaps,
+	)
+	require.NoError(t, err)
+
+	analysis := patch.Analyze()
+	assert.Equal(t, []string{"/items/*/qty", "/total"}, analysis.Writes)
+	assert.Equal(t, jsonpatch.RFC6902|jsonpatch.JSONPath, analysis.Capabilities)
+
+	data, err := patch.Encode(jsonpatch.EncodingJSON)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"op": "replace", "path": "$.items[?@.sku=='A1'].qty", "value": 9},
+		{"op": "add", "path": "/total", "value": 0}
+	]`, string(data))
+
+	decoded := jsonpatch.NewPatch(jsonPathCaps)
+	require.NoError(t, decoded.Decode(jsonpatch.EncodingJSON, data))
+	result, err := jsonpatch.Apply(decoded, jsonPathCart())
+	require.NoError(t, err)
+	assert.Len(t, result.Steps, 3)
+
+	recompiled, err := jsonpatch.CompileOps(patch.Ops(), jsonpatch.WithCapabilities(analysis.Capabilities))
+	require.NoError(t, err)
+	result, err = jsonpatch.Apply(recompiled, jsonPathCart())
+	require.NoError(t, err)
+	assert.Len(t, result.Steps, 3)
+
+	_, err = patch.Encode(jsonpatch.EncodingCompact)
+	require.ErrorIs(t, err, jsonpatch.ErrNotRepresentable)
+}
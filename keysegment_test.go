@@ -0,0 +1,194 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// keyCaps enables the core and extended vocabularies with key segments.
+var keyCaps = jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Predicate, jsonpatch.Extended, jsonpatch.KeySegments)
+
+func TestKeySegmentPaths(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[
+		{"op": "test", "path": "/items/[id=42]/name", "value": "bolt"},
+		{"op": "replace", "path": "/items/[id=42]/name", "value": "nut"},
+		{"op": "remove", "path": "/items/[sku=A1]"}
+	]`)
+	doc := []byte(`{"items": [{"id": 7, "sku": "A1"}, {"id": 42, "name": "bolt"}]}`)
+
+	encodings := []jsonpatch.Encoding{jsonpatch.EncodingJSON, jsonpatch.EncodingCompact, jsonpatch.EncodingBinary}
+	for _, encoding := range encodings {
+		t.Run(string(encoding), func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON(data, keyCaps)
+			require.NoError(t, err)
+			encoded, err := patch.Encode(encoding)
+			require.NoError(t, err)
+
+			decoded := jsonpatch.NewPatch(keyCaps)
+			require.NoError(t, decoded.Decode(encoding, encoded))
+			gotJSON, err := decoded.MarshalJSON()
+			require.NoError(t, err)
+			assert.JSONEq(t, string(data), string(gotJSON))
+
+			result, err := jsonpatch.Apply(decoded, doc)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"items": [{"id": 42, "name": "nut"}]}`, string(result.Doc))
+			assert.Equal(t, "/items/1/name", result.Steps[1].Path())
+		})
+	}
+}
+
+func TestKeySegmentFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{name: "no element matches", doc: `{"items": [{"id": 7}]}`},
+		{name: "several elements match", doc: `{"items": [{"id": 42}, {"id": "42"}]}`},
+	}
+
+	patch, err := jsonpatch.CompileJSON([]byte(`[{"op": "replace", "path": "/items/[id=42]/name", "value": "nut"}]`), keyCaps)
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := jsonpatch.Apply(patch, []byte(tt.doc))
+			require.ErrorIs(t, err, op.ErrPathNotFound)
+		})
+	}
+
+	result, err := jsonpatch.Apply(patch, []byte(`{"items": {"[id=42]": {"name": "bolt"}}}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"items": {"[id=42]": {"name": "nut"}}}`, string(result.Doc))
+}
+
+func TestKeySegmentCapability(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[{"op": "replace", "path": "/items/[id=42]/name", "value": "nut"}]`)
+	doc := []byte(`{"items": [{"id": 42, "name": "bolt"}]}`)
+
+	for _, opts := range [][]jsonpatch.CompileOption{nil, {jsonpatch.WithCapabilities(jsonpatch.AllCapabilities)}} {
+		patch, err := jsonpatch.CompileJSON(data, opts...)
+		require.NoError(t, err)
+		assert.Equal(t, jsonpatch.RFC6902, patch.Analyze().Capabilities)
+		_, err = jsonpatch.Apply(patch, doc)
+		require.ErrorIs(t, err, op.ErrPathNotFound)
+	}
+
+	patch, err := jsonpatch.CompileJSON(data, keyCaps)
+	require.NoError(t, err)
+	assert.Equal(t, jsonpatch.RFC6902|jsonpatch.KeySegments, patch.Analyze().Capabilities)
+	_, err = jsonpatch.Apply(patch, doc)
+	require.NoError(t, err)
+}
+
+func TestKeySegmentOperations(t *testing.T) {
+	t.Parallel()
+
+	items := func() map[string]any {
+		return map[string]any{
+			"items": []any{
+				map[string]any{"id": 7.0, "name": "seven", "children": []any{"a", "b"}},
+				map[string]any{"id": "42", "name": "forty-two"},
+				map[string]any{"id": true, "name": "yes"},
+			},
+		}
+	}
+	item := func(doc map[string]any, index int) map[string]any {
+		return doc["items"].([]any)[index].(map[string]any)
+	}
+
+	tests := []struct {
+		name    string
+		ops     []jsonpatch.Op
+		want    func(doc map[string]any)
+		wantErr error
+	}{
+		{
+			name: "replace",
+			ops:  []jsonpatch.Op{op.NewReplace([]string{"items", "[id=42]", "name"}, "answer")},
+			want: func(doc map[string]any) { item(doc, 1)["name"] = "answer" },
+		},
+		{
+			name: "remove",
+			ops:  []jsonpatch.Op{op.NewRemove([]string{"items", "[id=7]"})},
+			want: func(doc map[string]any) { doc["items"] = doc["items"].([]any)[1:] },
+		},
+		{
+			name: "add below",
+			ops:  []jsonpatch.Op{op.NewAdd([]string{"items", "[id=true]", "tag"}, "new")},
+			want: func(doc map[string]any) { item(doc, 2)["tag"] = "new" },
+		},
+		{
+			name: "inc the key member",
+			ops:  []jsonpatch.Op{op.NewInc([]string{"items", "[id=7]", "id"}, 1)},
+			want: func(doc map[string]any) { item(doc, 0)["id"] = 8.0 },
+		},
+		{
+			name: "move from",
+			ops:  []jsonpatch.Op{op.NewMove([]string{"first"}, []string{"items", "[id=42]", "name"})},
+			want: func(doc map[string]any) {
+				delete(item(doc, 1), "name")
+				doc["first"] = "forty-two"
+			},
+		},
+		{
+			name: "move to an element after the removal",
+			ops:  []jsonpatch.Op{op.NewMove([]string{"items", "[id=true]", "old"}, []string{"items", "0"})},
+			want: func(doc map[string]any) {
+				first := item(doc, 0)
+				doc["items"] = doc["items"].([]any)[1:]
+				item(doc, 1)["old"] = first
+			},
+		},
+		{
+			name: "merge",
+			ops:  []jsonpatch.Op{op.NewMerge([]string{"items", "[id=7]", "children"}, 1, nil)},
+			want: func(doc map[string]any) { item(doc, 0)["children"] = []any{"ab"} },
+		},
+		{
+			name: "composite operands",
+			ops: []jsonpatch.Op{op.NewAnd([]string{"items"}, []any{
+				op.NewTest([]string{"items", "[id=42]", "name"}, "forty-two"),
+				op.NewUndefined([]string{"items", "[id=9]"}),
+			})},
+			want: func(map[string]any) {},
+		},
+		{
+			name:    "failing composite operand",
+			ops:     []jsonpatch.Op{op.NewAnd([]string{"items"}, []any{op.NewTest([]string{"items", "[id=42]", "name"}, "seven")})},
+			wantErr: jsonpatch.ErrTestFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileOps(tt.ops, keyCaps)
+			require.NoError(t, err)
+			result, err := jsonpatch.Apply(patch, items())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			want := items()
+			tt.want(want)
+			assert.Equal(t, want, result.Doc)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestCompileJSONLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		patch  string
+		option jsonpatch.CompileOption
+		index  int
+	}{
+		{
+			name:   "operation count",
+			patch:  `[{"op":"remove","path":"/a"},{"op":"remove","path":"/b"}]`,
+			option: jsonpatch.WithMaxOperations(1),
+			index:  -1,
+		},
+		{
+			name:   "predicate depth",
+			patch:  `[{"op":"and","path":"","apply":[{"op":"not","path":"","apply":[{"op":"defined","path":"/a"}]}]}]`,
+			option: jsonpatch.WithMaxPredicateDepth(1),
+		},
+		{
+			name:   "pointer length",
+			patch:  `[{"op":"remove","path":"/a"},{"op":"move","path":"/b","from":"/abcdef"}]`,
+			option: jsonpatch.WithMaxPointerLength(4),
+			index:  1,
+		},
+		{
+			name:   "nested pointer segments",
+			patch:  `[{"op":"or","path":"","apply":[{"op":"defined","path":"/a/b/c"}]}]`,
+			option: jsonpatch.WithMaxPointerSegments(2),
+		},
+		{
+			name:   "pattern length",
+			patch:  `[{"op":"matches","path":"/name","value":"^(a+)+$"}]`,
+			option: jsonpatch.WithMaxPatternLength(4),
+		},
+		{
+			name:   "value size",
+			patch:  `[{"op":"add","path":"/list","value":["aaaa","bbbb"]}]`,
+			option: jsonpatch.WithMaxValueSize(8),
+		},
+		{
+			name:   "props size",
+			patch:  `[{"op":"extend","path":"","props":{"name":"Grace Hopper"}}]`,
+			option: jsonpatch.WithMaxValueSize(8),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := jsonpatch.CompileJSON([]byte(tt.patch), jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), jsonpatch.WithCapabilities(jsonpatch.AllCapabilities), tt.option)
+			require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+			assert.Nil(t, patch)
+
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, jsonpatch.ErrPayloadInvalid, patchErr.Kind())
+			assert.Equal(t, tt.index, patchErr.Index())
+			assert.Equal(t, "json", patchErr.Codec())
+		})
+	}
+}
+
+func TestCompileJSONCountsBeforeDecoding(t *testing.T) {
+	t.Parallel()
+
+	// The third element is malformed, so the count must fail before the
+	// payload is decoded.
+	patch, err := jsonpatch.CompileJSON([]byte(`[{"op":"remove","path":"/a"},{"op":"remove","path":"/b"},{"op":]`),
+		jsonpatch.WithMaxOperations(1))
+	require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+	assert.Nil(t, patch)
+
+	_, err = jsonpatch.CompileJSON([]byte(`{"op":"remove","path":"/a"}`), jsonpatch.WithMaxOperations(1))
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	assert.NotErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+}
+
+func TestCompileOperationsLimits(t *testing.T) {
+	t.Parallel()
+
+	operations := []jsoncodec.Operation{
+		{Op: "add", Path: "/name", Value: "Ada"},
+		{Op: "matches", Path: "/name", Value: "^(a|aa)+$"},
+	}
+
+	patch, err := jsonpatch.CompileOperations(operations,
+		jsonpatch.WithCapabilities(jsonpatch.AllCapabilities),
+		jsonpatch.WithMaxPatternLength(4),
+	)
+	require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+	assert.Nil(t, patch)
+
+	var patchErr *jsonpatch.Error
+	require.True(t, errors.As(err, &patchErr))
+	assert.Equal(t, 1, patchErr.Index())
+	assert.Equal(t, "matches", patchErr.Op())
+}
+
+func TestCompileOpsLimits(t *testing.T) {
+	t.Parallel()
+
+	ops := []jsonpatch.Op{op.NewAdd([]string{"a", "b", "c"}, "x")}
+
+	_, err := jsonpatch.CompileOps(ops, jsonpatch.WithMaxPointerSegments(3))
+	require.NoError(t, err)
+
+	_, err = jsonpatch.CompileOps(ops, jsonpatch.WithMaxPointerSegments(2))
+	require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+
+	_, err = jsonpatch.CompileOps(ops, jsonpatch.WithMaxOperations(0))
+	require.NoError(t, err)
+}
@@ -0,0 +1,120 @@
+package jsonpatch
+
+import (
+	"errors"
+	"maps"
+	"slices"
+
+	"github.com/go-json-experiment/json"
+	"github.com/kaptinlin/jsonpointer"
+
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+const mergePatchCodec = "merge-patch"
+
+var errMergePatchNull = errors.New("merge patch cannot set an object member to null")
+
+// CompileMergePatch compiles a JSON Merge Patch (RFC 7386) document into a
+// patch holding one merge_patch operation at the document root. The
+// MergePatch capability is enabled by default; WithCapabilities replaces it.
+// Compile limits apply to the operation as they do in CompileOps.
+func CompileMergePatch(data []byte, opts ...CompileOption) (*Patch, error) {
+	options := compileOptions{capabilities: MergePatch}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	options.codec = mergePatchCodec
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, newPayloadError(options.codec, err)
+	}
+	ops := []Op{oppkg.NewMergePatch(nil, value)}
+	if err := checkOpLimits(ops, options); err != nil {
+		return nil, err
+	}
+	return compileOps(ops, options)
+}
+
+// MergePatchToJSONPatch converts a JSON Merge Patch into a compiled RFC 6902
+// patch with the same effect on before. Merge patch semantics depend on the
+// shape of the target, so the conversion is relative to that document.
+func MergePatchToJSONPatch(before any, data []byte, opts ...DiffOption) (*Patch, error) {
+	doc, err := documentValue(before)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, newPayloadError(mergePatchCodec, err)
+	}
+	merged, err := oppkg.NewMergePatch(nil, value).Apply(doc)
+	if err != nil {
+		return nil, newError(kindForApplyError(err), 0, nil, mergePatchCodec, err)
+	}
+	return Diff(doc, merged.Doc, opts...)
+}
+
+// ToMergePatch returns the JSON Merge Patch with the same effect as p on
+// before. It fails with ErrNotRepresentable when the patched document sets an
+// object member to null, which a merge patch reads as a removal.
+func (p *Patch) ToMergePatch(before any) ([]byte, error) {
+	if p == nil {
+		return nil, newPayloadError("", errors.New("nil patch"))
+	}
+	doc, err := documentValue(before)
+	if err != nil {
+		return nil, err
+	}
+
+	after, _, err := p.apply(doc, &applyOptions{})
+	if err != nil {
+		return nil, err
+	}
+	patch, err := createMergePatch(doc, after, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, newPayloadError(mergePatchCodec, err)
+	}
+	return data, nil
+}
+
+// createMergePatch returns the merge patch that turns before into after.
+func createMergePatch(before, after any, path []string) (any, error) {
+	afterObject, ok := after.(map[string]any)
+	if !ok {
+		return after, nil
+	}
+	beforeObject, _ := before.(map[string]any)
+
+	patch := make(map[string]any)
+	for key := range beforeObject {
+		if _, ok := afterObject[key]; !ok {
+			patch[key] = nil
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(afterObject)) {
+		value := afterObject[key]
+		old, existed := beforeObject[key]
+		if existed && oppkg.DeepEqual(old, value) {
+			continue
+		}
+
+		child := childPath(path, key)
+		if value == nil {
+			return nil, newFieldError(ErrNotRepresentable, -1, "", jsonpointer.Format(child...), "", mergePatchCodec, errMergePatchNull)
+		}
+		member, err := createMergePatch(old, value, child)
+		if err != nil {
+			return nil, err
+		}
+		patch[key] = member
+	}
+	return patch, nil
+}
@@ -0,0 +1,209 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestCompileMergePatchAppliesThroughApply(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileMergePatch([]byte(`{"title":"Hello!","author":{"familyName":null},"phoneNumber":"+01-123-456-7890","tags":["example"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, patch.Len())
+
+	doc := []byte(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`)
+	result, err := jsonpatch.Apply(patch, doc)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`, string(result.Doc))
+
+	require.Len(t, result.Steps, 1)
+	assert.Equal(t, "merge_patch", result.Steps[0].Op())
+	assert.Empty(t, result.Steps[0].Path())
+
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email,omitempty"`
+	}
+	structDoc := user{Name: "Ada", Email: "ada@example.com"}
+	patch, err = jsonpatch.CompileMergePatch([]byte(`{"email":null}`))
+	require.NoError(t, err)
+	require.NoError(t, jsonpatch.ApplyInPlace(patch, &structDoc))
+	assert.Equal(t, user{Name: "Ada"}, structDoc)
+}
+
+func TestCompileMergePatchErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid json", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileMergePatch([]byte(`{`))
+		require.Error(t, err)
+		assert.Nil(t, patch)
+		assert.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, "merge-patch", patchErr.Codec())
+	})
+
+	t.Run("compile limits", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte(`{"name":"a value well beyond the five byte limit"}`)
+		patch, err := jsonpatch.CompileMergePatch(data, jsonpatch.WithMaxValueSize(5))
+		require.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+		assert.Nil(t, patch)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, "merge-patch", patchErr.Codec())
+		assert.Equal(t, 0, patchErr.Index())
+
+		_, err = jsonpatch.CompileMergePatch(data, jsonpatch.WithMaxValueSize(64))
+		require.NoError(t, err)
+	})
+
+	t.Run("capability disabled", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileMergePatch([]byte(`{}`), jsonpatch.WithCapabilities(jsonpatch.RFC6902))
+		require.Error(t, err)
+		assert.Nil(t, patch)
+		assert.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, 0, patchErr.Index())
+		assert.Equal(t, "merge_patch", patchErr.Op())
+	})
+
+	t.Run("json patch documents need the capability", func(t *testing.T) {
+		t.Parallel()
+
+		data := []byte(`[{"op":"merge_patch","path":"","value":{"a":1}}]`)
+		_, err := jsonpatch.CompileJSON(data)
+		require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+
+		patch, err := jsonpatch.CompileJSON(data, jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.MergePatch))
+		require.NoError(t, err)
+		result, err := jsonpatch.Apply(patch, map[string]any{"b": float64(2)})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"a": float64(1), "b": float64(2)}, result.Doc)
+	})
+}
+
+func TestMergePatchToJSONPatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		before map[string]any
+		patch  string
+		want   map[string]any
+	}{
+		{
+			name:   "members",
+			before: map[string]any{"a": "b", "c": map[string]any{"d": "e", "f": "g"}},
+			patch:  `{"a":"z","c":{"f":null}}`,
+			want:   map[string]any{"a": "z", "c": map[string]any{"d": "e"}},
+		},
+		{
+			name:   "null for missing member is ignored",
+			before: map[string]any{"a": "b"},
+			patch:  `{"missing":null}`,
+			want:   map[string]any{"a": "b"},
+		},
+		{
+			name:   "object replaces scalar",
+			before: map[string]any{"a": "b"},
+			patch:  `{"a":{"x":1,"y":null}}`,
+			want:   map[string]any{"a": map[string]any{"x": float64(1)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.MergePatchToJSONPatch(tt.before, []byte(tt.patch))
+			require.NoError(t, err)
+
+			result, err := jsonpatch.Apply(patch, tt.before)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result.Doc)
+			for _, step := range result.Steps {
+				assert.NotEqual(t, "merge_patch", step.Op())
+			}
+		})
+	}
+
+	_, err := jsonpatch.MergePatchToJSONPatch(map[string]any{}, []byte(`{`))
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+}
+
+func TestPatchToMergePatch(t *testing.T) {
+	t.Parallel()
+
+	before := map[string]any{
+		"name":  "Ada",
+		"email": "ada@example.com",
+		"tags":  []any{"a"},
+		"meta":  map[string]any{"x": float64(1), "y": float64(2)},
+	}
+	patch, err := jsonpatch.Compile(
+		op.NewReplace([]string{"name"}, "Grace"),
+		op.NewRemove([]string{"email"}),
+		op.NewAdd([]string{"tags", "-"}, "b"),
+		op.NewRemove([]string{"meta", "y"}),
+		op.NewAdd([]string{"meta", "z"}, []any{nil}),
+	)
+	require.NoError(t, err)
+
+	data, err := patch.ToMergePatch(before)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"Grace","email":null,"tags":["a","b"],"meta":{"y":null,"z":[null]}}`, string(data))
+
+	expected, err := jsonpatch.Apply(patch, before)
+	require.NoError(t, err)
+	mergePatch, err := jsonpatch.CompileMergePatch(data)
+	require.NoError(t, err)
+	merged, err := jsonpatch.Apply(mergePatch, before)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Doc, merged.Doc)
+
+	t.Run("null member is not representable", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(op.NewAdd([]string{"meta", "deleted"}, nil))
+		require.NoError(t, err)
+
+		data, err := patch.ToMergePatch(map[string]any{"meta": map[string]any{}})
+		require.Error(t, err)
+		assert.Nil(t, data)
+		assert.ErrorIs(t, err, jsonpatch.ErrNotRepresentable)
+
+		var patchErr *jsonpatch.Error
+		require.True(t, errors.As(err, &patchErr))
+		assert.Equal(t, "/meta/deleted", patchErr.Path())
+		assert.Equal(t, "merge-patch", patchErr.Codec())
+	})
+
+	t.Run("non-object root replaces document", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(op.NewReplace(nil, []any{"x"}))
+		require.NoError(t, err)
+
+		data, err := patch.ToMergePatch(map[string]any{"a": "b"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `["x"]`, string(data))
+	})
+}
@@ -0,0 +1,906 @@
+package jsonpatch
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-json-experiment/json"
+	"github.com/kaptinlin/deepclone"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// errNotNative reports a document or operation the native path cannot patch
+// with the same result as the JSON round-trip. The caller falls back to the
+// round-trip, so a native result, its steps, and its errors always match what
+// the round-trip would produce.
+var errNotNative = errors.New("not patchable natively")
+
+// native reports whether every operation is one the native path supports.
+func (p *Patch) native() bool {
+	for _, operation := range p.ops {
+		switch operation.(type) {
+		case *oppkg.AddOperation, *oppkg.RemoveOperation, *oppkg.ReplaceOperation,
+			*oppkg.MoveOperation, *oppkg.CopyOperation, *oppkg.TestOperation, nil:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// applyNativeDocument applies patch to a Go value without converting it to
+// JSON. Pointers resolve against struct fields by their json tags, map
+// entries, and slice and array elements, and every write copies the
+// containers along its path, so a failed operation leaves the document as it
+// was. It returns errNotNative when the document or patch needs the
+// round-trip.
+//
+// A struct field cannot be deleted, so removing one that its JSON form shows
+// when zero leaves it in place, zeroed. The round-trip deletes the member
+// instead, so such fields are tracked in cleared, and a later operation that
+// reaches one needs the round-trip.
+func applyNativeDocument[T internal.Document](patch *Patch, doc T, options *applyOptions) (*Result[T], error) {
+	if reflect.TypeFor[T]().Kind() == reflect.Interface {
+		return nil, errNotNative
+	}
+	root := reflect.ValueOf(any(doc))
+	if !root.IsValid() || (root.Kind() == reflect.Pointer && root.IsNil()) {
+		return nil, errNotNative
+	}
+
+	steps := make([]Step, 0, len(patch.ops))
+	var cleared [][]string
+	for i, operation := range patch.ops {
+		if options.ctx != nil && options.ctx.Err() != nil {
+			return nil, contextError(options.ctx, i, operation)
+		}
+		if operation != nil && nativeReachesCleared(operation, cleared) {
+			return nil, errNotNative
+		}
+		if operation == nil {
+			err := newError(ErrPayloadInvalid, i, nil, "", errNilOperation)
+			if !options.continueOnError {
+				return nil, err
+			}
+			steps = append(steps, Step{index: i, err: err})
+			continue
+		}
+		observed := options.observe(i, operation)
+		next, old, err := applyNativeOperation(operation, root)
+		if errors.Is(err, errNotNative) {
+			return nil, errNotNative
+		}
+		if err != nil {
+			patchErr := newError(kindForApplyError(err), i, operation, "", err)
+			observed(patchErr)
+			if !options.continueOnError {
+				return nil, patchErr
+			}
+			step := newStep(i, operation, nil)
+			step.err = patchErr
+			steps = append(steps, step)
+			continue
+		}
+		observed(nil)
+		if path, ok := nativeCleared(operation, next); ok {
+			cleared = append(cleared, path)
+		}
+		if old, err = nativeJSON(old, options.exactNumbers); err != nil {
+			return nil, err
+		}
+		root = next
+		step := newStep(i, operation, old)
+		step.applied = true
+		steps = append(steps, step)
+	}
+	if options.dryRun {
+		return &Result[T]{Steps: steps}, nil
+	}
+
+	result, _ := root.Interface().(T)
+	if !options.mutate {
+		// Unchanged containers are still shared with doc.
+		result = deepclone.Clone(result)
+	}
+	return &Result[T]{Doc: result, Steps: steps}, nil
+}
+
+// applyNativeOperation applies one operation to root and returns the new
+// root and the value the operation replaced or removed.
+func applyNativeOperation(operation Op, root reflect.Value) (reflect.Value, any, error) {
+	path := operation.Path()
+	switch typed := operation.(type) {
+	case *oppkg.AddOperation:
+		return nativeAdd(root, path, typed.Value, true)
+	case *oppkg.ReplaceOperation:
+		if len(path) == 0 {
+			return nativeAdd(root, path, typed.Value, true)
+		}
+		return nativeUpdate(root, path, nativeReplaceEdit(typed.Value))
+	case *oppkg.RemoveOperation:
+		if len(path) == 0 {
+			return root, nil, errNotNative
+		}
+		return nativeUpdate(root, path, nativeRemoveEdit)
+	case *oppkg.MoveOperation:
+		from := typed.From()
+		if slices.Equal(path, from) {
+			return root, nil, nil
+		}
+		if len(path) == 0 || len(from) == 0 {
+			return root, nil, errNotNative
+		}
+		if isAncestor(from, path) {
+			return root, nil, oppkg.ErrCannotMoveIntoChildren
+		}
+		removed, value, err := nativeUpdate(root, from, nativeRemoveEdit)
+		if err != nil {
+			return root, nil, err
+		}
+		return nativeAdd(removed, path, value, false)
+	case *oppkg.CopyOperation:
+		value, err := nativeGet(root, typed.From())
+		if err != nil {
+			return root, nil, err
+		}
+		return nativeAdd(root, path, value.Interface(), true)
+	case *oppkg.TestOperation:
+		return root, nil, nativeTest(root, typed)
+	default:
+		return root, nil, errNotNative
+	}
+}
+
+// nativeJSON returns the JSON form of a value an operation replaced or
+// removed, so steps report the values the round-trip would report.
+func nativeJSON(value any, exact bool) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, errNotNative
+	}
+	lifted, err := unmarshalDocument(data, exact)
+	if err != nil {
+		return nil, errNotNative
+	}
+	return lifted, nil
+}
+
+// nativeCleared returns the location a remove or move took its value from
+// when that location still resolves in root afterwards: a struct field left
+// zeroed in place.
+func nativeCleared(operation Op, root reflect.Value) ([]string, bool) {
+	var path []string
+	switch typed := operation.(type) {
+	case *oppkg.RemoveOperation:
+		path = typed.Path()
+	case *oppkg.MoveOperation:
+		if slices.Equal(typed.Path(), typed.From()) {
+			return nil, false
+		}
+		path = typed.From()
+	default:
+		return nil, false
+	}
+	if _, err := nativeGet(root, path); err != nil && !errors.Is(err, errNotNative) {
+		return nil, false
+	}
+	return path, true
+}
+
+// nativeReachesCleared reports whether operation reads or writes a cleared
+// field, a value inside or around one, or inserts or removes an array
+// element that shifts one to another index.
+func nativeReachesCleared(operation Op, cleared [][]string) bool {
+	if len(cleared) == 0 {
+		return false
+	}
+	paths := [][]string{operation.Path()}
+	if from, ok := operation.(interface{ From() []string }); ok {
+		paths = append(paths, from.From())
+	}
+	for _, path := range paths {
+		for _, field := range cleared {
+			if hasPrefix(path, field) || hasPrefix(field, path) {
+				return true
+			}
+			if len(path) > 0 && isIndexToken(path[len(path)-1]) && hasPrefix(field, path[:len(path)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nativeAdd(root reflect.Value, path []string, value any, clone bool) (reflect.Value, any, error) {
+	if len(path) == 0 {
+		converted, err := nativeAssign(root.Type(), value, clone)
+		if err != nil {
+			return root, nil, err
+		}
+		return converted, root.Interface(), nil
+	}
+	return nativeUpdate(root, path, nativeAddEdit(value, clone))
+}
+
+// nativeTest runs a test against the JSON form of its target, so values
+// compare exactly as they do in the round-trip.
+func nativeTest(root reflect.Value, operation *oppkg.TestOperation) error {
+	target, err := nativeGet(root, operation.Path())
+	if errors.Is(err, errNotNative) {
+		return err
+	}
+	if err != nil {
+		if operation.NotFlag {
+			return nil
+		}
+		return fmt.Errorf("%w: path not found", oppkg.ErrTestOperationFailed)
+	}
+	data, err := json.Marshal(target.Interface())
+	if err != nil {
+		return errNotNative
+	}
+	var lifted any
+	if err := json.Unmarshal(data, &lifted); err != nil {
+		return errNotNative
+	}
+	_, err = oppkg.NewTestWithNot(nil, operation.Value, operation.NotFlag).Apply(lifted)
+	return err
+}
+
+// nativeEdit changes the container that holds the last token of a path. It
+// receives a copy of the container it may modify and returns the container
+// to store in its place with the value it replaced.
+type nativeEdit func(container reflect.Value, token string) (reflect.Value, any, error)
+
+// nativeUpdate applies edit at path and returns a new v. The containers along
+// path are copied and v itself is never modified.
+func nativeUpdate(v reflect.Value, path []string, edit nativeEdit) (reflect.Value, any, error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return v, nil, errNotNative
+		}
+		inner, old, err := nativeUpdate(v.Elem(), path, edit)
+		if err != nil {
+			return v, nil, err
+		}
+		if v.Kind() == reflect.Pointer {
+			out := reflect.New(v.Type().Elem())
+			out.Elem().Set(inner)
+			return out, old, nil
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(inner)
+		return out, old, nil
+	}
+	if nativeOpaque(v.Type()) {
+		return v, nil, errNotNative
+	}
+
+	container := nativeCopy(v)
+	if len(path) == 1 {
+		return edit(container, path[0])
+	}
+	child, err := nativeMember(v, path[0])
+	if err != nil {
+		return v, nil, err
+	}
+	updated, old, err := nativeUpdate(child, path[1:], edit)
+	if err != nil {
+		return v, nil, err
+	}
+	if err := nativeSetMember(container, path[0], updated); err != nil {
+		return v, nil, err
+	}
+	return container, old, nil
+}
+
+// nativeGet returns the value at path.
+func nativeGet(v reflect.Value, path []string) (reflect.Value, error) {
+	for _, token := range path {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return v, oppkg.ErrPathNotFound
+			}
+			v = v.Elem()
+		}
+		if nativeOpaque(v.Type()) {
+			return v, errNotNative
+		}
+		var err error
+		if v, err = nativeMember(v, token); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// nativeMember returns the member of container named by token as the JSON
+// form of container would show it.
+func nativeMember(container reflect.Value, token string) (reflect.Value, error) {
+	switch container.Kind() {
+	case reflect.Struct:
+		field, err := nativeStructField(container.Type(), token)
+		if err != nil {
+			return container, err
+		}
+		value, ok := nativeFieldValue(container, field.index)
+		if !ok {
+			return container, oppkg.ErrPathNotFound
+		}
+		omitted, err := field.omitted(value)
+		if err != nil {
+			return container, err
+		}
+		if omitted {
+			return container, oppkg.ErrPathNotFound
+		}
+		return value, nil
+	case reflect.Map:
+		key, err := nativeMapKey(container.Type().Key(), token)
+		if err != nil {
+			return container, err
+		}
+		value := container.MapIndex(key)
+		if !value.IsValid() {
+			return container, oppkg.ErrPathNotFound
+		}
+		return value, nil
+	case reflect.Slice, reflect.Array:
+		index, err := nativeIndex(token, container.Len())
+		if err != nil {
+			return container, err
+		}
+		return container.Index(index), nil
+	default:
+		return container, oppkg.ErrPathNotFound
+	}
+}
+
+// nativeSetMember stores value as the member of container named by token.
+func nativeSetMember(container reflect.Value, token string, value reflect.Value) error {
+	switch container.Kind() {
+	case reflect.Struct:
+		field, err := nativeStructField(container.Type(), token)
+		if err != nil {
+			return err
+		}
+		target, err := nativeFieldForWrite(container, field.index)
+		if err != nil {
+			return err
+		}
+		target.Set(value)
+	case reflect.Map:
+		key, err := nativeMapKey(container.Type().Key(), token)
+		if err != nil {
+			return err
+		}
+		container.SetMapIndex(key, value)
+	default:
+		index, err := nativeIndex(token, container.Len())
+		if err != nil {
+			return err
+		}
+		container.Index(index).Set(value)
+	}
+	return nil
+}
+
+func nativeAddEdit(value any, clone bool) nativeEdit {
+	return func(container reflect.Value, token string) (reflect.Value, any, error) {
+		switch container.Kind() {
+		case reflect.Struct:
+			field, err := nativeStructField(container.Type(), token)
+			if errors.Is(err, oppkg.ErrPathNotFound) {
+				// The round-trip adds the member and then drops it.
+				return container, nil, errNotNative
+			}
+			if err != nil {
+				return container, nil, err
+			}
+			target, err := nativeFieldForWrite(container, field.index)
+			if err != nil {
+				return container, nil, err
+			}
+			var old any
+			if omitted, err := field.omitted(target); err != nil {
+				return container, nil, err
+			} else if !omitted {
+				old = target.Interface()
+			}
+			converted, err := nativeAssign(target.Type(), value, clone)
+			if err != nil {
+				return container, nil, err
+			}
+			target.Set(converted)
+			return container, old, nil
+		case reflect.Map:
+			key, err := nativeMapKey(container.Type().Key(), token)
+			if errors.Is(err, oppkg.ErrPathNotFound) {
+				return container, nil, errNotNative
+			}
+			if err != nil {
+				return container, nil, err
+			}
+			var old any
+			if prior := container.MapIndex(key); prior.IsValid() {
+				old = prior.Interface()
+			}
+			converted, err := nativeAssign(container.Type().Elem(), value, clone)
+			if err != nil {
+				return container, nil, err
+			}
+			container.SetMapIndex(key, converted)
+			return container, old, nil
+		case reflect.Slice:
+			length := container.Len()
+			index := length
+			if token != "-" {
+				var err error
+				if index, err = nativeIndex(token, length+1); err != nil {
+					return container, nil, err
+				}
+			}
+			converted, err := nativeAssign(container.Type().Elem(), value, clone)
+			if err != nil {
+				return container, nil, err
+			}
+			// Like the round-trip, report the element the insert displaced.
+			var old any
+			if index < length {
+				old = container.Index(index).Interface()
+			}
+			grown := reflect.MakeSlice(container.Type(), length+1, length+1)
+			reflect.Copy(grown, container.Slice(0, index))
+			grown.Index(index).Set(converted)
+			reflect.Copy(grown.Slice(index+1, length+1), container.Slice(index, length))
+			return grown, old, nil
+		case reflect.Array:
+			return container, nil, errNotNative
+		default:
+			return container, nil, oppkg.ErrCannotAddToValue
+		}
+	}
+}
+
+func nativeReplaceEdit(value any) nativeEdit {
+	return func(container reflect.Value, token string) (reflect.Value, any, error) {
+		switch container.Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		default:
+			return container, nil, oppkg.ErrCannotReplace
+		}
+		prior, err := nativeMember(container, token)
+		if err != nil {
+			return container, nil, err
+		}
+		converted, err := nativeAssign(prior.Type(), value, true)
+		if err != nil {
+			return container, nil, err
+		}
+		old := prior.Interface()
+		if err := nativeSetMember(container, token, converted); err != nil {
+			return container, nil, err
+		}
+		return container, old, nil
+	}
+}
+
+// nativeRemoveEdit deletes a map entry or slice element. A struct field
+// cannot be deleted, so it is reset to its zero value; applyNativeDocument
+// tracks it when its JSON form still shows it.
+func nativeRemoveEdit(container reflect.Value, token string) (reflect.Value, any, error) {
+	switch container.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice:
+	case reflect.Array:
+		return container, nil, errNotNative
+	default:
+		return container, nil, oppkg.ErrCannotRemoveFromValue
+	}
+	prior, err := nativeMember(container, token)
+	if err != nil {
+		return container, nil, err
+	}
+	old := prior.Interface()
+	switch container.Kind() {
+	case reflect.Struct:
+		field, _ := nativeStructField(container.Type(), token)
+		target, err := nativeFieldForWrite(container, field.index)
+		if err != nil {
+			return container, nil, err
+		}
+		target.Set(reflect.Zero(target.Type()))
+	case reflect.Map:
+		key, _ := nativeMapKey(container.Type().Key(), token)
+		container.SetMapIndex(key, reflect.Value{})
+	default:
+		index, _ := strconv.Atoi(token)
+		length := container.Len()
+		shrunk := reflect.MakeSlice(container.Type(), length-1, length-1)
+		reflect.Copy(shrunk, container.Slice(0, index))
+		reflect.Copy(shrunk.Slice(index, length-1), container.Slice(index+1, length))
+		container = shrunk
+	}
+	return container, old, nil
+}
+
+// nativeAssign converts value to t. Values already of an assignable type are
+// used directly, deep-cloned when clone is set; other values are converted
+// through JSON. A value that does not convert is left to the round-trip,
+// because a later operation may replace it before the result is decoded.
+func nativeAssign(t reflect.Type, value any, clone bool) (reflect.Value, error) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+			return reflect.Zero(t), nil
+		default:
+			// The round-trip keeps the null until the end of the patch.
+			return reflect.Value{}, errNotNative
+		}
+	}
+	if reflect.TypeOf(value).AssignableTo(t) {
+		if clone {
+			value = deepclone.Clone(value)
+		}
+		converted := reflect.New(t).Elem()
+		converted.Set(reflect.ValueOf(value))
+		return converted, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return reflect.Value{}, errNotNative
+	}
+	converted := reflect.New(t)
+	if err := json.Unmarshal(data, converted.Interface()); err != nil {
+		return reflect.Value{}, errNotNative
+	}
+	return converted.Elem(), nil
+}
+
+// nativeCopy returns a modifiable shallow copy of v. A nil map is copied as
+// an empty map because its JSON form is an empty object.
+func nativeCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			copied.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return copied
+	case reflect.Slice:
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(copied, v)
+		return copied
+	default:
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(v)
+		return copied
+	}
+}
+
+// nativeIndex parses an array index the way the op package does.
+func nativeIndex(token string, length int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, oppkg.ErrPathNotFound
+	}
+	if index < 0 || index >= length {
+		return 0, oppkg.ErrIndexOutOfRange
+	}
+	return index, nil
+}
+
+// nativeMapKey parses token as a key of type t. Keys that do not format
+// back to token are reported as not found.
+func nativeMapKey(t reflect.Type, token string) (reflect.Value, error) {
+	if nativeOpaque(t) {
+		return reflect.Value{}, errNotNative
+	}
+	key := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		key.SetString(token)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(token, 10, t.Bits())
+		if err != nil || strconv.FormatInt(n, 10) != token {
+			return key, oppkg.ErrPathNotFound
+		}
+		key.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(token, 10, t.Bits())
+		if err != nil || strconv.FormatUint(n, 10) != token {
+			return key, oppkg.ErrPathNotFound
+		}
+		key.SetUint(n)
+	default:
+		return key, errNotNative
+	}
+	return key, nil
+}
+
+// opaqueInterfaces are the interfaces through which a type chooses its own
+// JSON form.
+var opaqueInterfaces = []reflect.Type{
+	reflect.TypeFor[json.Marshaler](),
+	reflect.TypeFor[json.MarshalerTo](),
+	reflect.TypeFor[json.Unmarshaler](),
+	reflect.TypeFor[json.UnmarshalerFrom](),
+	reflect.TypeFor[encoding.TextMarshaler](),
+	reflect.TypeFor[encoding.TextAppender](),
+	reflect.TypeFor[encoding.TextUnmarshaler](),
+}
+
+// nativeFieldCache holds a *nativeFields per struct type.
+var nativeFieldCache sync.Map
+
+// nativeOpaque reports whether t chooses its own JSON form or is encoded as
+// a string, so its members cannot be addressed directly.
+func nativeOpaque(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	for _, opaque := range opaqueInterfaces {
+		if t.Implements(opaque) || reflect.PointerTo(t).Implements(opaque) {
+			return true
+		}
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Struct, reflect.Map:
+		return false
+	default:
+		return !nativeKind(t.Kind())
+	}
+}
+
+// nativeKind reports whether values of kind k have a JSON form.
+func nativeKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return false
+	default:
+		return true
+	}
+}
+
+// nativeField is a struct field with a JSON name.
+type nativeField struct {
+	index     []int
+	omitEmpty bool
+	omitZero  bool
+}
+
+// omitted reports whether the field is left out of the JSON form of its
+// struct.
+func (f nativeField) omitted(value reflect.Value) (bool, error) {
+	if f.omitZero {
+		if zeroer, ok := value.Interface().(interface{ IsZero() bool }); ok {
+			if value.Kind() != reflect.Pointer || !value.IsNil() {
+				return zeroer.IsZero(), nil
+			}
+		}
+		if value.IsZero() {
+			return true, nil
+		}
+	}
+	if !f.omitEmpty {
+		return false, nil
+	}
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return true, nil
+		}
+		value = value.Elem()
+	}
+	return nativeEmpty(value)
+}
+
+// nativeEmpty reports whether value is encoded as an empty JSON string,
+// array, or object.
+func nativeEmpty(value reflect.Value) (bool, error) {
+	if nativeOpaque(value.Type()) && value.Kind() != reflect.String {
+		return false, errNotNative
+	}
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0, nil
+	case reflect.Struct:
+		fields := nativeFieldsOf(value.Type())
+		if !fields.native {
+			return false, errNotNative
+		}
+		for _, field := range fields.byName {
+			member, ok := nativeFieldValue(value, field.index)
+			if !ok {
+				continue
+			}
+			if omitted, err := field.omitted(member); err != nil || !omitted {
+				return false, err
+			}
+		}
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// nativeFields maps the JSON names of a struct type to its fields. native is
+// false when the JSON form of the type uses features the native path does
+// not model; byName still lists the names it could resolve.
+type nativeFields struct {
+	byName map[string]nativeField
+	native bool
+}
+
+// nativeStructField returns the field of t with the JSON name token.
+func nativeStructField(t reflect.Type, token string) (nativeField, error) {
+	fields := nativeFieldsOf(t)
+	if !fields.native {
+		return nativeField{}, errNotNative
+	}
+	field, ok := fields.byName[token]
+	if !ok {
+		return nativeField{}, oppkg.ErrPathNotFound
+	}
+	return field, nil
+}
+
+func nativeFieldsOf(t reflect.Type) *nativeFields {
+	cached, ok := nativeFieldCache.Load(t)
+	if !ok {
+		cached, _ = nativeFieldCache.LoadOrStore(t, buildNativeFields(t))
+	}
+	return cached.(*nativeFields)
+}
+
+// buildNativeFields lists the JSON fields of t by a breadth-first search
+// through embedded structs. A name at a shallower depth hides deeper ones.
+// Tag options other than omitempty and omitzero, and names that conflict at
+// the same depth, leave the type to the round-trip.
+func buildNativeFields(t reflect.Type) *nativeFields {
+	type level struct {
+		t     reflect.Type
+		index []int
+	}
+	type candidate struct {
+		field  nativeField
+		tagged bool
+	}
+	fields := &nativeFields{byName: make(map[string]nativeField), native: true}
+	visited := map[reflect.Type]bool{t: true}
+	queue := []level{{t: t}}
+	for len(queue) > 0 {
+		var next []level
+		found := make(map[string][]candidate)
+		for _, current := range queue {
+			for i := range current.t.NumField() {
+				sf := current.t.Field(i)
+				tag, hasTag := sf.Tag.Lookup("json")
+				if tag == "-" {
+					continue
+				}
+				name, options, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(current.index), i)
+
+				if sf.Anonymous && name == "" {
+					embedded := sf.Type
+					if embedded.Kind() == reflect.Pointer {
+						embedded = embedded.Elem()
+					}
+					switch {
+					case options != "" || (sf.Type.Kind() == reflect.Pointer && !sf.IsExported()):
+						fields.native = false
+					case embedded.Kind() != reflect.Struct || nativeOpaque(embedded):
+						fields.native = fields.native && !sf.IsExported() && !hasTag
+					case !visited[embedded]:
+						visited[embedded] = true
+						next = append(next, level{t: embedded, index: index})
+					}
+					continue
+				}
+				if !sf.IsExported() || strings.HasPrefix(name, "'") {
+					fields.native = fields.native && !hasTag
+					continue
+				}
+				if !nativeKind(sf.Type.Kind()) {
+					fields.native = false
+				}
+				opts := strings.Split(options, ",")
+				if slices.Contains(opts, "inline") || slices.Contains(opts, "unknown") {
+					// The members of the field are promoted into its parent.
+					fields.native = false
+					continue
+				}
+				explicit := name != ""
+				if !explicit {
+					name = sf.Name
+				}
+				field := nativeField{index: index}
+				for _, option := range opts {
+					switch option {
+					case "":
+					case "omitempty":
+						field.omitEmpty = true
+					case "omitzero":
+						field.omitZero = true
+					default:
+						fields.native = false
+					}
+				}
+				if _, shadowed := fields.byName[name]; shadowed {
+					continue
+				}
+				found[name] = append(found[name], candidate{field: field, tagged: explicit})
+			}
+		}
+		for name, candidates := range found {
+			if len(candidates) == 1 {
+				fields.byName[name] = candidates[0].field
+				continue
+			}
+			var dominant []nativeField
+			for _, c := range candidates {
+				if c.tagged {
+					dominant = append(dominant, c.field)
+				}
+			}
+			if len(dominant) != 1 {
+				fields.native = false
+				continue
+			}
+			fields.byName[name] = dominant[0]
+		}
+		queue = next
+	}
+	return fields
+}
+
+// nativeFieldValue returns the field at index. It reports false when an
+// embedded pointer on the way is nil, because the JSON form then omits the
+// embedded fields.
+func nativeFieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, position := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(position)
+	}
+	return v, true
+}
+
+// nativeFieldForWrite returns the settable field at index of a copied
+// struct. Embedded pointers on the way are copied so the write does not
+// reach the original.
+func nativeFieldForWrite(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, position := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() || !v.CanSet() {
+				return v, errNotNative
+			}
+			copied := reflect.New(v.Type().Elem())
+			copied.Elem().Set(v.Elem())
+			v.Set(copied)
+			v = copied.Elem()
+		}
+		v = v.Field(position)
+	}
+	if !v.CanSet() {
+		return v, errNotNative
+	}
+	return v, nil
+}
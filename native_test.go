@@ -0,0 +1,430 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+type nativeAudit struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Author    string    `json:"author,omitempty"`
+}
+
+type nativeService struct {
+	Name  string `json:"name"`
+	Ports []int  `json:"ports"`
+}
+
+type nativeConfig struct {
+	nativeAudit
+	Version  int                       `json:"version"`
+	Labels   map[string]string         `json:"labels"`
+	Services []nativeService           `json:"services"`
+	Limits   *nativeLimits             `json:"limits,omitempty"`
+	Extra    map[string]any            `json:"extra,omitzero"`
+	Weights  map[int]float64           `json:"weights"`
+	Secret   string                    `json:"-"`
+	Backends map[string]*nativeService `json:"backends,omitempty"`
+}
+
+type nativeLimits struct {
+	CPU    float64 `json:"cpu"`
+	Memory int     `json:"memory"`
+}
+
+func newNativeConfig() nativeConfig {
+	return nativeConfig{
+		nativeAudit: nativeAudit{CreatedAt: time.Date(2024, 5, 1, 9, 30, 0, 123456789, time.FixedZone("CEST", 2*60*60))},
+		Version:     1,
+		Labels:      map[string]string{"team": "core"},
+		Services:    []nativeService{{Name: "api", Ports: []int{80}}},
+		Limits:      &nativeLimits{CPU: 0.5, Memory: 256},
+		Secret:      "hunter2",
+	}
+}
+
+func TestApplyPatchesStructsNatively(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ops     []jsonpatch.Op
+		want    func(*nativeConfig)
+		wantOld []any
+	}{
+		{
+			name:    "replace field",
+			ops:     []jsonpatch.Op{op.NewReplace([]string{"version"}, 2)},
+			want:    func(c *nativeConfig) { c.Version = 2 },
+			wantOld: []any{float64(1)},
+		},
+		{
+			name:    "replace through pointer",
+			ops:     []jsonpatch.Op{op.NewReplace([]string{"limits", "memory"}, float64(512))},
+			want:    func(c *nativeConfig) { c.Limits = &nativeLimits{CPU: 0.5, Memory: 512} },
+			wantOld: []any{float64(256)},
+		},
+		{
+			name:    "add map entry",
+			ops:     []jsonpatch.Op{op.NewAdd([]string{"labels", "tier"}, "gold")},
+			want:    func(c *nativeConfig) { c.Labels = map[string]string{"team": "core", "tier": "gold"} },
+			wantOld: []any{nil},
+		},
+		{
+			name: "append and insert slice elements",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"services", "-"}, map[string]any{"name": "worker", "ports": []any{}}),
+				op.NewAdd([]string{"services", "0", "ports", "0"}, 443),
+			},
+			want: func(c *nativeConfig) {
+				c.Services = []nativeService{{Name: "api", Ports: []int{443, 80}}, {Name: "worker", Ports: []int{}}}
+			},
+			wantOld: []any{nil, float64(80)},
+		},
+		{
+			name:    "remove map entry and slice element",
+			ops:     []jsonpatch.Op{op.NewRemove([]string{"labels", "team"}), op.NewRemove([]string{"services", "0"})},
+			want:    func(c *nativeConfig) { c.Labels = map[string]string{}; c.Services = []nativeService{} },
+			wantOld: []any{"core", map[string]any{"name": "api", "ports": []any{float64(80)}}},
+		},
+		{
+			name:    "remove field resets it",
+			ops:     []jsonpatch.Op{op.NewRemove([]string{"version"})},
+			want:    func(c *nativeConfig) { c.Version = 0 },
+			wantOld: []any{float64(1)},
+		},
+		{
+			name: "move and copy between fields",
+			ops: []jsonpatch.Op{
+				op.NewCopy([]string{"labels", "owner"}, []string{"services", "0", "name"}),
+				op.NewMove([]string{"author"}, []string{"labels", "team"}),
+			},
+			want: func(c *nativeConfig) {
+				c.Labels = map[string]string{"owner": "api"}
+				c.Author = "core"
+			},
+			wantOld: []any{nil, nil},
+		},
+		{
+			name:    "embedded field",
+			ops:     []jsonpatch.Op{op.NewReplace([]string{"createdAt"}, "2025-01-02T03:04:05Z")},
+			want:    func(c *nativeConfig) { c.CreatedAt = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) },
+			wantOld: []any{"2024-05-01T09:30:00.123456789+02:00"},
+		},
+		{
+			name:    "integer map keys",
+			ops:     []jsonpatch.Op{op.NewAdd([]string{"weights", "7"}, 0.25)},
+			want:    func(c *nativeConfig) { c.Weights = map[int]float64{7: 0.25} },
+			wantOld: []any{nil},
+		},
+		{
+			name:    "test compares JSON form",
+			ops:     []jsonpatch.Op{op.NewTest([]string{"limits"}, map[string]any{"cpu": 0.5, "memory": 256})},
+			want:    func(*nativeConfig) {},
+			wantOld: []any{nil},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.Compile(tt.ops...)
+			require.NoError(t, err)
+
+			doc := newNativeConfig()
+			result, err := jsonpatch.Apply(patch, doc)
+			require.NoError(t, err)
+
+			want := newNativeConfig()
+			tt.want(&want)
+			if diff := cmp.Diff(want, result.Doc, cmp.AllowUnexported(nativeConfig{})); diff != "" {
+				t.Errorf("Apply() document mismatch (-want +got):\n%s", diff)
+			}
+			require.Len(t, result.Steps, len(tt.wantOld))
+			for i, old := range tt.wantOld {
+				assert.Equal(t, old, result.Steps[i].Old())
+			}
+			if diff := cmp.Diff(newNativeConfig(), doc, cmp.AllowUnexported(nativeConfig{})); diff != "" {
+				t.Errorf("Apply() modified its input (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyNativeKeepsGoValues(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(op.NewReplace([]string{"version"}, 2))
+	require.NoError(t, err)
+
+	doc := newNativeConfig()
+	result, err := jsonpatch.Apply(patch, doc)
+	require.NoError(t, err)
+	assert.Equal(t, "CEST", result.Doc.CreatedAt.Location().String())
+	assert.Equal(t, 123456789, result.Doc.CreatedAt.Nanosecond())
+	assert.Equal(t, "hunter2", result.Doc.Secret)
+
+	result.Doc.Services[0].Ports[0] = 8080
+	result.Doc.Labels["team"] = "edge"
+	assert.Equal(t, 80, doc.Services[0].Ports[0])
+	assert.Equal(t, "core", doc.Labels["team"])
+}
+
+func TestApplyNativeFailures(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ops     []jsonpatch.Op
+		wantErr error
+		index   int
+	}{
+		{
+			name:    "missing map entry",
+			ops:     []jsonpatch.Op{op.NewRemove([]string{"labels", "owner"})},
+			wantErr: jsonpatch.ErrRuntimeConflict,
+		},
+		{
+			name:    "omitted field is absent",
+			ops:     []jsonpatch.Op{op.NewReplace([]string{"author"}, "Ada")},
+			wantErr: jsonpatch.ErrRuntimeConflict,
+		},
+		{
+			name:    "index past end",
+			ops:     []jsonpatch.Op{op.NewAdd([]string{"services", "2"}, map[string]any{})},
+			wantErr: jsonpatch.ErrRuntimeConflict,
+		},
+		{
+			name:    "value of wrong type",
+			ops:     []jsonpatch.Op{op.NewReplace([]string{"labels", "team"}, "infra"), op.NewReplace([]string{"version"}, "two")},
+			wantErr: jsonpatch.ErrConversionFailed,
+			index:   -1,
+		},
+		{
+			name:    "failed test",
+			ops:     []jsonpatch.Op{op.NewTest([]string{"services", "0", "name"}, "web")},
+			wantErr: jsonpatch.ErrTestFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.Compile(tt.ops...)
+			require.NoError(t, err)
+
+			doc := newNativeConfig()
+			err = jsonpatch.ApplyInPlace(patch, &doc)
+			require.ErrorIs(t, err, tt.wantErr)
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, tt.index, patchErr.Index())
+			if diff := cmp.Diff(newNativeConfig(), doc, cmp.AllowUnexported(nativeConfig{})); diff != "" {
+				t.Errorf("ApplyInPlace() modified a failed document (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestApplyNativeContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewAdd([]string{"labels", "tier"}, "gold"),
+		op.NewMove([]string{"services", "-"}, []string{"labels", "owner"}),
+		op.NewReplace([]string{"version"}, 3),
+	)
+	require.NoError(t, err)
+
+	doc := newNativeConfig()
+	require.NoError(t, jsonpatch.ApplyInPlace(patch, &doc, jsonpatch.WithContinueOnError()))
+
+	want := newNativeConfig()
+	want.Labels["tier"] = "gold"
+	want.Version = 3
+	if diff := cmp.Diff(want, doc, cmp.AllowUnexported(nativeConfig{})); diff != "" {
+		t.Errorf("ApplyInPlace() document mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestApplyNativeFallsBackToJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		ops  []jsonpatch.Op
+		want func(*nativeConfig)
+	}{
+		{
+			name: "extended operation",
+			ops:  []jsonpatch.Op{op.NewInc([]string{"version"}, 1)},
+			want: func(c *nativeConfig) { c.Version = 2 },
+		},
+		{
+			name: "member the struct does not declare",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"draft"}, "1.1"),
+				op.NewMove([]string{"labels", "draft"}, []string{"draft"}),
+			},
+			want: func(c *nativeConfig) { c.Labels["draft"] = "1.1" },
+		},
+		{
+			name: "null kept until the end of the patch",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"version"}, nil),
+				op.NewTest([]string{"version"}, nil),
+			},
+			want: func(c *nativeConfig) { c.Version = 0 },
+		},
+		{
+			name: "key segment",
+			ops:  []jsonpatch.Op{op.NewReplace([]string{"services", "[name=api]", "ports"}, []int{8080})},
+			want: func(c *nativeConfig) { c.Services[0].Ports = []int{8080} },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileOps(tt.ops, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities, jsonpatch.KeySegments))
+			require.NoError(t, err)
+
+			result, err := jsonpatch.Apply(patch, newNativeConfig())
+			require.NoError(t, err)
+
+			want := newNativeConfig()
+			want.Secret = ""
+			want.Weights = map[int]float64{}
+			want.CreatedAt = want.CreatedAt.In(time.FixedZone("", 2*60*60))
+			tt.want(&want)
+			assert.True(t, want.CreatedAt.Equal(result.Doc.CreatedAt))
+			want.CreatedAt = result.Doc.CreatedAt
+			if diff := cmp.Diff(want, result.Doc, cmp.AllowUnexported(nativeConfig{})); diff != "" {
+				t.Errorf("Apply() document mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type nativeRecord struct {
+	Name    string        `json:"name"`
+	Flag    bool          `json:"flag"`
+	Tags    []string      `json:"tags"`
+	Notes   []string      `json:"notes,omitempty"`
+	Address nativeAddress `json:"address"`
+}
+
+type nativeAddress struct {
+	City string `json:"city"`
+}
+
+// TestApplyNativeMatchesRoundTrip compares the native path with the JSON
+// round-trip, which a validator forces.
+func TestApplyNativeMatchesRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	roundTrip := jsonpatch.WithValidator(jsonpatch.ValidatorFunc(func(any) error { return nil }))
+	tests := []struct {
+		name string
+		ops  []jsonpatch.Op
+	}{
+		{
+			name: "test after removing a field",
+			ops:  []jsonpatch.Op{op.NewRemove([]string{"name"}), op.NewTest([]string{"name"}, "")},
+		},
+		{
+			name: "field removed twice",
+			ops:  []jsonpatch.Op{op.NewRemove([]string{"name"}), op.NewRemove([]string{"name"})},
+		},
+		{
+			name: "field re-added after removal",
+			ops:  []jsonpatch.Op{op.NewRemove([]string{"name"}), op.NewAdd([]string{"name"}, "b")},
+		},
+		{
+			name: "removal unrelated to later operations",
+			ops:  []jsonpatch.Op{op.NewRemove([]string{"name"}), op.NewReplace([]string{"flag"}, true)},
+		},
+		{
+			name: "field moved away and back",
+			ops: []jsonpatch.Op{
+				op.NewMove([]string{"tags", "0"}, []string{"name"}),
+				op.NewCopy([]string{"notes"}, []string{"name"}),
+			},
+		},
+		{
+			name: "intermediate value of another type",
+			ops:  []jsonpatch.Op{op.NewReplace([]string{"flag"}, 1), op.NewReplace([]string{"flag"}, true)},
+		},
+		{
+			name: "final value of another type",
+			ops:  []jsonpatch.Op{op.NewReplace([]string{"flag"}, "yes")},
+		},
+		{
+			name: "insert reports displaced element",
+			ops: []jsonpatch.Op{
+				op.NewAdd([]string{"tags", "0"}, "x"),
+				op.NewCopy([]string{"tags", "1"}, []string{"name"}),
+			},
+		},
+		{
+			name: "old values in JSON form",
+			ops: []jsonpatch.Op{
+				op.NewReplace([]string{"address"}, map[string]any{"city": "b"}),
+				op.NewRemove([]string{"tags"}),
+				op.NewMove([]string{"flag"}, []string{"address"}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.Compile(tt.ops...)
+			require.NoError(t, err)
+
+			doc := nativeRecord{Name: "a", Tags: []string{"go", "json"}, Address: nativeAddress{City: "a"}}
+			native, nativeErr := jsonpatch.Apply(patch, doc)
+			want, wantErr := jsonpatch.Apply(patch, doc, roundTrip)
+
+			if wantErr != nil {
+				var want, got *jsonpatch.Error
+				require.ErrorAs(t, wantErr, &want)
+				require.ErrorAs(t, nativeErr, &got)
+				assert.Equal(t, want.Kind(), got.Kind())
+				assert.Equal(t, want.Index(), got.Index())
+				assert.Equal(t, wantErr.Error(), nativeErr.Error())
+				return
+			}
+			require.NoError(t, nativeErr)
+			assert.Equal(t, want.Doc, native.Doc)
+			require.Len(t, native.Steps, len(want.Steps))
+			for i := range want.Steps {
+				assert.Equal(t, want.Steps[i].Old(), native.Steps[i].Old())
+			}
+		})
+	}
+}
+
+func TestApplyNativeOldExactNumbers(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(op.NewReplace([]string{"limits"}, nil))
+	require.NoError(t, err)
+
+	result, err := jsonpatch.Apply(patch, newNativeConfig(), jsonpatch.WithExactNumbers())
+	require.NoError(t, err)
+	require.Len(t, result.Steps, 1)
+	assert.Equal(t, map[string]any{"cpu": jsonpatch.Number("0.5"), "memory": jsonpatch.Number("256")}, result.Steps[0].Old())
+}
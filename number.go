@@ -0,0 +1,69 @@
+package jsonpatch
+
+import (
+	"errors"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// WithExactNumbers decodes the numbers of JSON text, byte, and round-tripped
+// struct documents as Number values instead of float64, so integers beyond
+// 2^53 and decimal fractions are written back exactly as they were read.
+// Comparisons, test, less, more, and inc use the exact values. A number with
+// an exponent beyond ±1000 fails to decode with ErrPayloadInvalid. The values of
+// the operations are exact only when the patch is compiled with
+// WithExactOperands.
+func WithExactNumbers() ApplyOption {
+	return func(o *applyOptions) {
+		o.exactNumbers = true
+	}
+}
+
+// WithExactOperands decodes the numbers in the values of JSON operations, as
+// CompileJSON, Decode with EncodingJSON, and UnmarshalJSON read them, as Number
+// values instead of float64, so test compares, and add and replace write,
+// integers beyond 2^53 and decimal fractions exactly as they were written.
+// Other encodings decode numbers as they do without it.
+func WithExactOperands() CompileOption {
+	return func(o *compileOptions) {
+		o.exactNumbers = true
+	}
+}
+
+// exactNumbers decodes JSON numbers held in any as Number values. Numbers
+// with an exponent beyond internal.MaxNumberExponent are rejected.
+var exactNumbers = json.WithUnmarshalers(json.UnmarshalFromFunc(func(dec *jsontext.Decoder, value *any) error {
+	if dec.PeekKind() != '0' {
+		return errors.ErrUnsupported
+	}
+	token, err := dec.ReadToken()
+	if err != nil {
+		return err
+	}
+	number := Number(token.String())
+	if err := internal.CheckNumber(number); err != nil {
+		return err
+	}
+	*value = number
+	return nil
+}))
+
+// unmarshalDocument decodes a JSON document for patching, with Number values
+// for its numbers when exact is set.
+func unmarshalDocument(data []byte, exact bool) (any, error) {
+	var parsed any
+	err := unmarshalJSON(data, &parsed, exact)
+	return parsed, err
+}
+
+// unmarshalJSON decodes data into target, with Number values for the numbers
+// held in any when exact is set.
+func unmarshalJSON(data []byte, target any, exact bool) error {
+	if exact {
+		return json.Unmarshal(data, target, exactNumbers)
+	}
+	return json.Unmarshal(data, target)
+}
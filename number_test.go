@@ -0,0 +1,179 @@
+package jsonpatch_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// numberLiteral matches a member value that is a number.
+var numberLiteral = regexp.MustCompile(`:\s*([-0-9][-+.0-9eE]*)`)
+
+func TestExactNumbers(t *testing.T) {
+	t.Parallel()
+
+	const doc = `{"id": 12345678901234567891, "price": 19.90, "count": 1}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "unrelated write keeps numbers",
+			patch: `[{"op": "add", "path": "/note", "value": "x"}]`,
+			want:  `{"id": 12345678901234567891, "price": 19.90, "count": 1, "note": "x"}`,
+		},
+		{
+			name:  "test compares exactly",
+			patch: `[{"op": "test", "path": "/price", "value": 19.9}, {"op": "replace", "path": "/count", "value": 2}]`,
+			want:  `{"id": 12345678901234567891, "price": 19.90, "count": 2}`,
+		},
+		{
+			name:  "inc keeps decimal places",
+			patch: `[{"op": "inc", "path": "/price", "inc": 0.1}, {"op": "inc", "path": "/id", "inc": 1}]`,
+			want:  `{"id": 12345678901234567892, "price": 20.00, "count": 1}`,
+		},
+		{
+			name:  "less and more",
+			patch: `[{"op": "more", "path": "/id", "value": 1e19}, {"op": "less", "path": "/price", "value": 20}, {"op": "remove", "path": "/count"}]`,
+			want:  `{"id": 12345678901234567891, "price": 19.90}`,
+		},
+		{
+			name:  "copy keeps the literal",
+			patch: `[{"op": "copy", "from": "/id", "path": "/parent"}]`,
+			want:  `{"id": 12345678901234567891, "parent": 12345678901234567891, "price": 19.90, "count": 1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+
+			result, err := jsonpatch.Apply(patch, []byte(doc), jsonpatch.WithExactNumbers())
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(result.Doc))
+			// JSONEq compares float64 values, so check the literals too.
+			for _, match := range numberLiteral.FindAllStringSubmatch(tt.want, -1) {
+				assert.Contains(t, string(result.Doc), ":"+match[1])
+			}
+
+			text, err := jsonpatch.Apply(patch, jsonpatch.JSONText(doc), jsonpatch.WithExactNumbers())
+			require.NoError(t, err)
+			assert.JSONEq(t, string(result.Doc), string(text.Doc))
+		})
+	}
+}
+
+func TestExactOperands(t *testing.T) {
+	t.Parallel()
+
+	const doc = `{"id": 12345678901234567891, "price": 19.90}`
+	tests := []struct {
+		name  string
+		patch string
+		// want are the number literals the patched document and the encoded
+		// patch both hold.
+		want []string
+	}{
+		{
+			name:  "test compares a big operand exactly",
+			patch: `[{"op": "test", "path": "/id", "value": 12345678901234567891}, {"op": "remove", "path": "/price"}]`,
+			want:  []string{"12345678901234567891"},
+		},
+		{
+			name:  "replace writes a big operand",
+			patch: `[{"op": "replace", "path": "/id", "value": 12345678901234567893}]`,
+			want:  []string{"12345678901234567893"},
+		},
+		{
+			name:  "add writes nested operands",
+			patch: `[{"op": "add", "path": "/meta", "value": {"parent": 12345678901234567895, "rates": [0.10, 1e400]}}]`,
+			want:  []string{"12345678901234567895", "0.10", "1e400"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), jsonpatch.WithExactOperands())
+			require.NoError(t, err)
+			encoded, err := patch.MarshalJSON()
+			require.NoError(t, err)
+
+			result, err := jsonpatch.Apply(patch, []byte(doc), jsonpatch.WithExactNumbers())
+			require.NoError(t, err)
+			for _, literal := range tt.want {
+				assert.Contains(t, string(encoded), literal)
+				assert.Contains(t, string(result.Doc), literal)
+			}
+		})
+	}
+}
+
+func TestExactNumbersStructRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	type account struct {
+		ID      uint64 `json:"id"`
+		Balance int64  `json:"balance"`
+	}
+	patch, err := jsonpatch.CompileJSON(
+		[]byte(`[{"op": "inc", "path": "/balance", "inc": 5}]`),
+		jsonpatch.WithCapabilities(jsonpatch.AllCapabilities),
+	)
+	require.NoError(t, err)
+
+	doc := account{ID: 1<<63 + 1, Balance: 1<<62 + 1}
+	result, err := jsonpatch.Apply(patch, doc, jsonpatch.WithExactNumbers())
+	require.NoError(t, err)
+	assert.Equal(t, account{ID: 1<<63 + 1, Balance: 1<<62 + 6}, result.Doc)
+}
+
+func TestExactNumbersOffByDefault(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileJSON([]byte(`[{"op": "add", "path": "/note", "value": "x"}]`))
+	require.NoError(t, err)
+
+	result, err := jsonpatch.Apply(patch, []byte(`{"id": 12345678901234567891}`))
+	require.NoError(t, err)
+	assert.NotContains(t, string(result.Doc), "12345678901234567891")
+
+	value, err := jsonpatch.Apply(patch, map[string]any{"id": jsonpatch.Number("12345678901234567891")})
+	require.NoError(t, err)
+	assert.Equal(t, jsonpatch.Number("12345678901234567891"), value.Doc["id"])
+}
+
+func TestExactNumbersExponentRange(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileJSON([]byte(`[{"op": "inc", "path": "/a", "inc": 1}]`),
+		jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+	require.NoError(t, err)
+
+	_, err = jsonpatch.Apply(patch, []byte(`{"a": 1e-999999}`), jsonpatch.WithExactNumbers())
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+
+	_, err = jsonpatch.CompileJSON([]byte(`[{"op": "add", "path": "/a", "value": 1e999999}]`), jsonpatch.WithExactOperands())
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+
+	// A Number built in Go is not read exactly, so inc rejects it instead of
+	// writing out a million digits.
+	_, err = jsonpatch.Apply(patch, map[string]any{"a": jsonpatch.Number("1e-999999")})
+	require.ErrorIs(t, err, op.ErrNotNumber)
+
+	// Equality compares the written digits and exponent.
+	test, err := jsonpatch.Compile(op.NewTest([]string{"a"}, jsonpatch.Number("10e-1000000")))
+	require.NoError(t, err)
+	_, err = jsonpatch.Apply(test, map[string]any{"a": jsonpatch.Number("1e-999999")})
+	require.NoError(t, err)
+}
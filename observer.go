@@ -0,0 +1,146 @@
+package jsonpatch
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// OpEvent describes one operation of an apply call. It carries no document
+// values, so an Observer cannot change the document being patched.
+type OpEvent struct {
+	// Index is the operation index in the patch.
+	Index int
+	// Op is the operation name.
+	Op OpType
+	// Path and From are the operation's JSON Pointers. From is empty for
+	// operations without a source.
+	Path, From string
+	// Duration is how long the operation took. It is zero in BeforeOp.
+	Duration time.Duration
+	// Err is the *Error the operation failed with. It is nil in BeforeOp and
+	// after an operation that applied.
+	Err error
+}
+
+// Observer is notified around each operation, for example to record
+// latency or tracing spans. Calls happen on the goroutine applying the patch.
+//
+// For a struct document patched natively, the calls are not made as each
+// operation runs: they are replayed in order once the whole patch has run,
+// each event keeping the Duration measured around its operation. A struct the
+// native path cannot finish is patched again through the JSON round-trip,
+// and only that run is reported, so no operation is reported twice. An
+// observer that must see operations as they run, such as one that opens a
+// span in BeforeOp, should not rely on the time of the call for such
+// documents.
+type Observer interface {
+	BeforeOp(event OpEvent)
+	AfterOp(event OpEvent)
+}
+
+// ObserverFuncs adapts functions to the Observer interface. A nil function
+// is skipped.
+type ObserverFuncs struct {
+	Before func(event OpEvent)
+	After  func(event OpEvent)
+}
+
+// BeforeOp calls f.Before.
+func (f ObserverFuncs) BeforeOp(event OpEvent) {
+	if f.Before != nil {
+		f.Before(event)
+	}
+}
+
+// AfterOp calls f.After.
+func (f ObserverFuncs) AfterOp(event OpEvent) {
+	if f.After != nil {
+		f.After(event)
+	}
+}
+
+// WithObserver notifies observer before and after each operation, with the
+// outcome and duration of the operation afterwards. Repeated options add
+// observers, which are called in order. Operations of struct documents
+// patched natively are reported together once the patch has run; see
+// Observer.
+func WithObserver(observer Observer) ApplyOption {
+	return func(o *applyOptions) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// observe calls BeforeOp for operation and returns the function that calls
+// AfterOp with its outcome.
+func (o *applyOptions) observe(index int, operation Op) func(err error) {
+	if len(o.observers) == 0 {
+		return func(error) {}
+	}
+	event := OpEvent{
+		Index: index,
+		Op:    operation.Op(),
+		Path:  jsonpointer.Format(operation.Path()...),
+	}
+	if from := operationFrom(operation); from != nil {
+		event.From = jsonpointer.Format(from...)
+	}
+	for _, observer := range o.observers {
+		observer.BeforeOp(event)
+	}
+	start := time.Now()
+	return func(err error) {
+		event.Duration = time.Since(start)
+		event.Err = err
+		for _, observer := range o.observers {
+			observer.AfterOp(event)
+		}
+	}
+}
+
+// eventRecorder holds observer events back until they are replayed.
+type eventRecorder struct {
+	events []recordedEvent
+}
+
+type recordedEvent struct {
+	event OpEvent
+	after bool
+}
+
+func (r *eventRecorder) BeforeOp(event OpEvent) {
+	r.events = append(r.events, recordedEvent{event: event})
+}
+
+func (r *eventRecorder) AfterOp(event OpEvent) {
+	r.events = append(r.events, recordedEvent{event: event, after: true})
+}
+
+// applyNativeObserved runs applyNativeDocument and reports its operations to
+// the observers only when it did not fall back to the round-trip.
+func applyNativeObserved[T internal.Document](patch *Patch, doc T, options *applyOptions) (*Result[T], error) {
+	if len(options.observers) == 0 {
+		return applyNativeDocument(patch, doc, options)
+	}
+	observers := options.observers
+	recorder := &eventRecorder{}
+	options.observers = []Observer{recorder}
+	result, err := applyNativeDocument(patch, doc, options)
+	options.observers = observers
+	if errors.Is(err, errNotNative) {
+		return result, err
+	}
+	for _, recorded := range recorder.events {
+		for _, observer := range observers {
+			if recorded.after {
+				observer.AfterOp(recorded.event)
+			} else {
+				observer.BeforeOp(recorded.event)
+			}
+		}
+	}
+	return result, err
+}
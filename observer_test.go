@@ -0,0 +1,200 @@
+package jsonpatch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// observedEvent is an OpEvent with its phase, without the duration.
+type observedEvent struct {
+	after bool
+	index int
+	op    jsonpatch.OpType
+	path  string
+	from  string
+	err   bool
+}
+
+type eventLog struct {
+	events []observedEvent
+}
+
+func (l *eventLog) BeforeOp(event jsonpatch.OpEvent) {
+	l.events = append(l.events, l.record(event, false))
+}
+
+func (l *eventLog) AfterOp(event jsonpatch.OpEvent) {
+	l.events = append(l.events, l.record(event, true))
+}
+
+func (l *eventLog) record(event jsonpatch.OpEvent, after bool) observedEvent {
+	return observedEvent{
+		after: after,
+		index: event.Index,
+		op:    event.Op,
+		path:  event.Path,
+		from:  event.From,
+		err:   event.Err != nil,
+	}
+}
+
+type observedPost struct {
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+func TestWithObserver(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewReplace([]string{"title"}, "B"),
+		op.NewMove([]string{"tags", "0"}, []string{"tags", "1"}),
+		op.NewRemove([]string{"missing"}),
+	)
+	require.NoError(t, err)
+
+	want := []observedEvent{
+		{index: 0, op: jsonpatch.OpReplaceType, path: "/title"},
+		{after: true, index: 0, op: jsonpatch.OpReplaceType, path: "/title"},
+		{index: 1, op: jsonpatch.OpMoveType, path: "/tags/0", from: "/tags/1"},
+		{after: true, index: 1, op: jsonpatch.OpMoveType, path: "/tags/0", from: "/tags/1"},
+		{index: 2, op: jsonpatch.OpRemoveType, path: "/missing"},
+		{after: true, index: 2, op: jsonpatch.OpRemoveType, path: "/missing", err: true},
+	}
+
+	tests := []struct {
+		name  string
+		apply func(opts ...jsonpatch.ApplyOption) error
+	}{
+		{
+			name: "map",
+			apply: func(opts ...jsonpatch.ApplyOption) error {
+				_, err := jsonpatch.Apply(patch, map[string]any{"title": "A", "tags": []any{"a", "b"}}, opts...)
+				return err
+			},
+		},
+		{
+			name: "native struct",
+			apply: func(opts ...jsonpatch.ApplyOption) error {
+				_, err := jsonpatch.Apply(patch, observedPost{Title: "A", Tags: []string{"a", "b"}}, opts...)
+				return err
+			},
+		},
+		{
+			name: "in place",
+			apply: func(opts ...jsonpatch.ApplyOption) error {
+				doc := map[string]any{"title": "A", "tags": []any{"a", "b"}}
+				return jsonpatch.ApplyInPlace(patch, &doc, opts...)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			log := &eventLog{}
+			err := tt.apply(jsonpatch.WithObserver(log))
+			require.ErrorIs(t, err, jsonpatch.ErrRuntimeConflict)
+			assert.Equal(t, want, log.events)
+		})
+	}
+}
+
+func TestWithObserverContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewRemove([]string{"missing"}),
+		op.NewAdd([]string{"a"}, 1),
+	)
+	require.NoError(t, err)
+
+	var durations []time.Duration
+	var outcomes []error
+	first, second := &eventLog{}, &eventLog{}
+	result, err := jsonpatch.Apply(patch, map[string]any{},
+		jsonpatch.WithContinueOnError(),
+		jsonpatch.WithObserver(first),
+		jsonpatch.WithObserver(jsonpatch.ObserverFuncs{After: func(event jsonpatch.OpEvent) {
+			durations = append(durations, event.Duration)
+			outcomes = append(outcomes, event.Err)
+		}}),
+		jsonpatch.WithObserver(second),
+	)
+	require.NoError(t, err)
+
+	require.Len(t, outcomes, 2)
+	require.ErrorIs(t, outcomes[0], jsonpatch.ErrRuntimeConflict)
+	assert.Equal(t, result.Steps[0].Err(), outcomes[0])
+	assert.NoError(t, outcomes[1])
+	for _, duration := range durations {
+		assert.GreaterOrEqual(t, duration, time.Duration(0))
+	}
+	assert.Len(t, first.events, 4)
+	assert.Equal(t, first.events, second.events)
+}
+
+// observedNested is patched natively until a move replaces the whole
+// document, which needs the JSON round-trip.
+type observedNested struct {
+	Title string         `json:"title"`
+	Inner map[string]any `json:"inner,omitempty"`
+}
+
+func TestWithObserverNativeFallback(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewReplace([]string{"title"}, "B"),
+		op.NewMove([]string{}, []string{"inner"}),
+	)
+	require.NoError(t, err)
+
+	log := &eventLog{}
+	result, err := jsonpatch.Apply(patch, observedNested{Title: "A", Inner: map[string]any{"title": "C"}},
+		jsonpatch.WithObserver(log))
+	require.NoError(t, err)
+	assert.Equal(t, observedNested{Title: "C"}, result.Doc)
+
+	assert.Equal(t, []observedEvent{
+		{index: 0, op: jsonpatch.OpReplaceType, path: "/title"},
+		{after: true, index: 0, op: jsonpatch.OpReplaceType, path: "/title"},
+		{index: 1, op: jsonpatch.OpMoveType, path: "", from: "/inner"},
+		{after: true, index: 1, op: jsonpatch.OpMoveType, path: "", from: "/inner"},
+	}, log.events)
+}
+
+func TestWithObserverNativeReplay(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewReplace([]string{"title"}, "B"),
+		op.NewRemove([]string{"tags", "0"}),
+	)
+	require.NoError(t, err)
+
+	// The observer cancels the context from the first event. The round-trip
+	// sees it before the second operation; the native path has already run
+	// every operation when its events are replayed.
+	observe := func() (context.Context, jsonpatch.ApplyOption) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return ctx, jsonpatch.WithObserver(jsonpatch.ObserverFuncs{Before: func(jsonpatch.OpEvent) { cancel() }})
+	}
+
+	ctx, observer := observe()
+	_, err = jsonpatch.ApplyContext(ctx, patch, map[string]any{"title": "A", "tags": []any{"a"}}, observer)
+	require.ErrorIs(t, err, context.Canceled)
+
+	ctx, observer = observe()
+	result, err := jsonpatch.ApplyContext(ctx, patch, observedPost{Title: "A", Tags: []string{"a"}}, observer)
+	require.NoError(t, err)
+	assert.Equal(t, observedPost{Title: "B", Tags: []string{}}, result.Doc)
+}
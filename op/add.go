@@ -30,6 +30,11 @@ func (a *AddOperation) Op() internal.OpType {
 
 // Apply applies the add operation.
 func (a *AddOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return a.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the add operation and records its writes in undo.
+func (a *AddOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	newValue := deepclone.Clone(a.Value)
 
 	// Handle empty path (root replacement) - only for truly empty path, not empty string key
@@ -38,7 +43,7 @@ func (a *AddOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{Doc: newValue, Old: doc}, nil
 	}
 
-	newDoc, oldValue, err := addAtPath(doc, a.path, newValue)
+	newDoc, oldValue, err := addAtPath(undo, doc, a.path, newValue)
 	if err != nil {
 		return internal.OpResult[any]{}, err
 	}
@@ -46,23 +51,23 @@ func (a *AddOperation) Apply(doc any) (internal.OpResult[any], error) {
 }
 
 // addAtPath recursively inserts value at the given path, returns new doc and old value if replaced.
-func addAtPath(doc any, path []string, value any) (any, any, error) {
+func addAtPath(undo *UndoLog, doc any, path []string, value any) (any, any, error) {
 	switch v := doc.(type) {
 	case map[string]any:
-		return addToMap(v, path, value)
+		return addToMap(undo, v, path, value)
 	case []any:
-		return addToSlice(v, path, value)
+		return addToSlice(undo, v, path, value)
 	default:
 		return nil, nil, ErrCannotAddToValue
 	}
 }
 
-func addToMap(doc map[string]any, path []string, value any) (any, any, error) {
+func addToMap(undo *UndoLog, doc map[string]any, path []string, value any) (any, any, error) {
 	key := path[0]
 
 	if len(path) == 1 {
 		oldValue := doc[key]
-		doc[key] = value
+		undo.setKey(doc, key, value)
 		return doc, oldValue, nil
 	}
 
@@ -72,15 +77,15 @@ func addToMap(doc map[string]any, path []string, value any) (any, any, error) {
 		// According to JSON Patch spec, missing objects are not created recursively
 		return nil, nil, ErrCannotReplace
 	}
-	newChild, oldValue, err := addAtPath(child, path[1:], value)
+	newChild, oldValue, err := addAtPath(undo, child, path[1:], value)
 	if err != nil {
 		return nil, nil, err
 	}
-	doc[key] = newChild
+	undo.setKey(doc, key, newChild)
 	return doc, oldValue, nil
 }
 
-func addToSlice(doc []any, path []string, value any) (any, any, error) {
+func addToSlice(undo *UndoLog, doc []any, path []string, value any) (any, any, error) {
 	key := path[0]
 
 	if len(path) == 1 {
@@ -115,11 +120,11 @@ func addToSlice(doc []any, path []string, value any) (any, any, error) {
 	if index < 0 || index >= len(doc) {
 		return nil, nil, ErrIndexOutOfRange
 	}
-	newChild, oldValue, err := addAtPath(doc[index], path[1:], value)
+	newChild, oldValue, err := addAtPath(undo, doc[index], path[1:], value)
 	if err != nil {
 		return nil, nil, err
 	}
-	doc[index] = newChild
+	undo.setIndex(doc, index, newChild)
 	return doc, oldValue, nil
 }
 
@@ -225,3 +225,8 @@ func (ex *ExtendOperation) Clone() (internal.Op, error) {
 		DeleteNull: ex.DeleteNull,
 	}, nil
 }
+
+// Clone implements internal.CloneOp.
+func (mp *MergePatchOperation) Clone() (internal.Op, error) {
+	return &MergePatchOperation{BaseOp: cloneBaseOp(mp.BaseOp), Value: cloneValue(mp.Value)}, nil
+}
@@ -27,6 +27,11 @@ func (c *CopyOperation) Op() internal.OpType {
 
 // Apply applies the copy operation.
 func (c *CopyOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return c.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the copy operation and records its writes in undo.
+func (c *CopyOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	val, err := value(doc, c.from)
 	if err != nil {
 		return internal.OpResult[any]{}, err
@@ -40,7 +45,7 @@ func (c *CopyOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{Doc: clonedValue, Old: doc}, nil
 	}
 
-	newDoc, oldValue, err := addAtPath(doc, c.path, clonedValue)
+	newDoc, oldValue, err := addAtPath(undo, doc, c.path, clonedValue)
 	if err != nil {
 		return internal.OpResult[any]{}, err
 	}
@@ -35,6 +35,11 @@ func (ex *ExtendOperation) Op() internal.OpType {
 
 // Apply applies the object extend operation.
 func (ex *ExtendOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return ex.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the object extend operation and records its writes in undo.
+func (ex *ExtendOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	path := ex.Path()
 	target, err := value(doc, path)
 	if err != nil {
@@ -51,7 +56,7 @@ func (ex *ExtendOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{Doc: extendedObj}, nil
 	}
 
-	if err := setValueAtPath(doc, path, extendedObj); err != nil {
+	if err := setValueAtPath(undo, doc, path, extendedObj); err != nil {
 		return internal.OpResult[any]{}, err
 	}
 
@@ -24,6 +24,11 @@ func (f *FlipOperation) Op() internal.OpType {
 
 // Apply applies the flip operation to the document.
 func (f *FlipOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return f.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the flip operation and records its writes in undo.
+func (f *FlipOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	if len(f.Path()) == 0 {
 		flipped := flipValue(doc)
 		return internal.OpResult[any]{Doc: flipped, Old: doc}, nil
@@ -36,7 +41,7 @@ func (f *FlipOperation) Apply(doc any) (internal.OpResult[any], error) {
 	oldValue := value
 	flipped := flipValue(value)
 
-	err = setValueAtPath(doc, f.Path(), flipped)
+	err = setValueAtPath(undo, doc, f.Path(), flipped)
 	if err != nil {
 		return internal.OpResult[any]{}, err
 	}
@@ -55,6 +60,9 @@ func flipValue(value any) any {
 		return v == 0
 	case int:
 		return v == 0
+	case internal.Number:
+		decimal, ok := canonicalDecimal(string(v))
+		return ok && decimal == "0"
 	case string:
 		return v == ""
 	default:
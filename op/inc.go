@@ -23,8 +23,20 @@ func (ic *IncOperation) Op() internal.OpType {
 
 // Apply applies the increment operation to the document.
 func (ic *IncOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return ic.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the increment operation and records its writes in undo.
+func (ic *IncOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	if len(ic.path) == 0 {
 		// Root level increment
+		if number, ok := doc.(internal.Number); ok {
+			result, ok := addToNumber(number, ic.Inc)
+			if !ok {
+				return internal.OpResult[any]{}, ErrNotNumber
+			}
+			return internal.OpResult[any]{Doc: result, Old: doc}, nil
+		}
 		oldValue, ok := ToFloat64(doc)
 		if !ok {
 			return internal.OpResult[any]{}, ErrNotNumber
@@ -39,19 +51,25 @@ func (ic *IncOperation) Apply(doc any) (internal.OpResult[any], error) {
 	}
 
 	// Missing final targets are treated as 0, creating the field with the incremented value.
+	// Number values are incremented exactly and stay Number values.
 	var currentValue any
-	var oldValue float64
+	var result any = ic.Inc
 	if pathExists(doc, ic.path) {
 		currentValue = valueFromParent(parent, key)
-		var ok bool
-		oldValue, ok = ToFloat64(currentValue)
-		if !ok {
-			return internal.OpResult[any]{}, ErrNotNumber
+		if number, ok := currentValue.(internal.Number); ok {
+			if result, ok = addToNumber(number, ic.Inc); !ok {
+				return internal.OpResult[any]{}, ErrNotNumber
+			}
+		} else {
+			oldValue, ok := ToFloat64(currentValue)
+			if !ok {
+				return internal.OpResult[any]{}, ErrNotNumber
+			}
+			result = oldValue + ic.Inc
 		}
 	}
-	result := oldValue + ic.Inc
 
-	if err := updateParent(parent, key, result); err != nil {
+	if err := updateParent(undo, parent, key, result); err != nil {
 		return internal.OpResult[any]{}, err
 	}
 
@@ -84,6 +84,37 @@ func TestInc_Apply(t *testing.T) {
 			expected: map[string]any{"count": 1, "notfound": 5.0},
 			oldValue: nil,
 		},
+		{
+			name:     "inc exact integer beyond float64",
+			path:     []string{"id"},
+			doc:      map[string]any{"id": internal.Number("9007199254740993")},
+			inc:      1,
+			expected: map[string]any{"id": internal.Number("9007199254740994")},
+			oldValue: internal.Number("9007199254740993"),
+		},
+		{
+			name:     "inc exact decimal keeps its places",
+			path:     []string{"price"},
+			doc:      map[string]any{"price": internal.Number("19.90")},
+			inc:      0.1,
+			expected: map[string]any{"price": internal.Number("20.00")},
+			oldValue: internal.Number("19.90"),
+		},
+		{
+			name:     "inc root exact number",
+			path:     []string{},
+			doc:      internal.Number("1e2"),
+			inc:      0.25,
+			expected: internal.Number("100.25"),
+			oldValue: internal.Number("1e2"),
+		},
+		{
+			name:    "inc exact number beyond the exponent bound",
+			path:    []string{"a"},
+			doc:     map[string]any{"a": internal.Number("1e-999999")},
+			inc:     1,
+			wantErr: true,
+		},
 		{
 			name:    "not a number",
 			path:    []string{"str"},
@@ -13,3 +13,10 @@ type PredicateOp = internal.PredicateOp
 
 // SecondOrderPredicateOp represents operations that combine multiple predicate operations.
 type SecondOrderPredicateOp = internal.SecondOrderPredicateOp
+
+// UndoableOp represents operations that record their in-place writes in an
+// UndoLog, so a failed patch can be reverted without cloning the document.
+type UndoableOp interface {
+	Op
+	ApplyWithUndo(doc any, undo *UndoLog) (Result[any], error)
+}
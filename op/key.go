@@ -0,0 +1,212 @@
+package op
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// Key segments select an array element by the value of one of its members
+// rather than by position: /items/[id=42]/name names the name member of the
+// only element of items whose id is 42. Operations address elements by index
+// only; ResolveKeySegments rewrites a path for them. A key segment is
+// interpreted only where the container is an array, so object members named
+// like one keep their literal meaning.
+
+// IsKeySegment reports whether a path segment has the form [name=value].
+func IsKeySegment(segment string) bool {
+	_, _, ok := keySegment(segment)
+	return ok
+}
+
+// ResolveKeySegments returns path with each key segment that addresses an
+// array of doc replaced by the index of the element it selects, so that the
+// path keeps naming that element after its key changes or it is removed.
+// Resolution stops at the first segment doc does not have, including a key
+// segment that selects no element or several, leaving it and the segments
+// after it unchanged. A path without key segments is returned as is.
+func ResolveKeySegments(doc any, path []string) []string {
+	if !slices.ContainsFunc(path, IsKeySegment) {
+		return path
+	}
+	resolved := slices.Clone(path)
+	current := doc
+	for i, segment := range path {
+		if array, ok := current.([]any); ok {
+			if index, ok := keyIndex(array, segment); ok {
+				resolved[i] = strconv.Itoa(index)
+				current = array[index]
+				continue
+			}
+		}
+		next, err := jsonpointer.Get(current, segment)
+		if err != nil {
+			return resolved
+		}
+		current = next
+	}
+	return resolved
+}
+
+// keySegment splits a segment of the form [name=value], which selects the
+// element of an array whose member name matches value, at its first "=".
+func keySegment(segment string) (string, string, bool) {
+	if len(segment) < 3 || segment[0] != '[' || segment[len(segment)-1] != ']' {
+		return "", "", false
+	}
+	name, want, ok := strings.Cut(segment[1:len(segment)-1], "=")
+	return name, want, ok && name != ""
+}
+
+// keyIndex returns the index of the only element of array a key segment
+// selects. It fails for other segments and when the segment selects no
+// element or several.
+func keyIndex(array []any, segment string) (int, bool) {
+	name, want, ok := keySegment(segment)
+	if !ok {
+		return 0, false
+	}
+	key := parseKeyValue(want)
+	index := -1
+	for i, element := range array {
+		object, ok := element.(map[string]any)
+		if !ok {
+			continue
+		}
+		member, exists := object[name]
+		if !exists || !key.matches(member) {
+			continue
+		}
+		if index >= 0 {
+			return 0, false
+		}
+		index = i
+	}
+	if index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// keyValue is the value text of a key segment, parsed once per array so
+// that matching it against each element stays cheap.
+type keyValue struct {
+	text    string
+	number  bool    // text follows the JSON number grammar
+	float   float64 // nearest float64, ±Inf when out of range
+	decimal string  // canonical form, "" when the exponent is out of range
+}
+
+func parseKeyValue(text string) keyValue {
+	key := keyValue{text: text}
+	if _, _, _, _, ok := jsonNumber(text); !ok {
+		return key
+	}
+	key.number = true
+	key.float, _ = strconv.ParseFloat(text, 64)
+	key.decimal, _ = canonicalDecimal(text)
+	return key
+}
+
+// matches reports whether a member value matches the key: a string equal to
+// the text, a boolean spelled the same way, or a number equal to the number
+// the text spells. A Number is compared exactly and any other number as a
+// float64.
+func (k keyValue) matches(member any) bool {
+	switch m := member.(type) {
+	case string:
+		return m == k.text
+	case bool:
+		return strconv.FormatBool(m) == k.text
+	case internal.Number:
+		if !k.number {
+			return false
+		}
+		if string(m) == k.text {
+			return true
+		}
+		decimal, ok := canonicalDecimal(string(m))
+		return ok && k.decimal != "" && decimal == k.decimal
+	}
+	if !k.number {
+		return false
+	}
+	f, ok := toNumericValue(member)
+	return ok && f == k.float
+}
+
+// jsonNumber splits text that follows the JSON number grammar into its sign,
+// integer digits, fraction digits, and signed exponent digits.
+func jsonNumber(text string) (neg bool, integer, fraction, exponent string, ok bool) {
+	s := text
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	}
+	n := digitRun(s)
+	if n == 0 || (s[0] == '0' && n > 1) {
+		return false, "", "", "", false
+	}
+	integer, s = s[:n], s[n:]
+	if strings.HasPrefix(s, ".") {
+		n = digitRun(s[1:])
+		if n == 0 {
+			return false, "", "", "", false
+		}
+		fraction, s = s[1:1+n], s[1+n:]
+	}
+	if strings.HasPrefix(s, "e") || strings.HasPrefix(s, "E") {
+		sign := ""
+		s = s[1:]
+		if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+			sign, s = s[:1], s[1:]
+		}
+		n = digitRun(s)
+		if n == 0 {
+			return false, "", "", "", false
+		}
+		exponent, s = sign+s[:n], s[n:]
+	}
+	return neg, integer, fraction, exponent, s == ""
+}
+
+func digitRun(s string) int {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	return n
+}
+
+// canonicalDecimal rewrites a JSON number as its significant digits and a
+// power of ten, so that two spellings of the same value compare equal as
+// strings without big.Rat arithmetic. It fails on text outside the grammar
+// and on exponents beyond the range of int.
+func canonicalDecimal(text string) (string, bool) {
+	neg, integer, fraction, exponent, ok := jsonNumber(text)
+	if !ok {
+		return "", false
+	}
+	exp := 0
+	if exponent != "" {
+		e, err := strconv.Atoi(exponent)
+		if err != nil || e > math.MaxInt/2 || e < math.MinInt/2 {
+			return "", false
+		}
+		exp = e
+	}
+	digits := strings.TrimLeft(integer+fraction, "0")
+	if digits == "" {
+		return "0", true
+	}
+	significant := strings.TrimRight(digits, "0")
+	exp += len(digits) - len(significant) - len(fraction)
+	if neg {
+		significant = "-" + significant
+	}
+	return significant + "e" + strconv.Itoa(exp), true
+}
@@ -0,0 +1,141 @@
+package op
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+func keyedItems() map[string]any {
+	return map[string]any{
+		"items": []any{
+			map[string]any{"id": 7.0, "name": "seven"},
+			map[string]any{"id": "42", "name": "forty-two"},
+			map[string]any{"id": true, "name": "yes"},
+			map[string]any{"id": internal.Number("12345678901234567891"), "name": "big"},
+			"loose",
+		},
+	}
+}
+
+func TestKeyIndex(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		segment string
+		want    int
+		ok      bool
+	}{
+		{name: "number", segment: "[id=7]", want: 0, ok: true},
+		{name: "number spelled differently", segment: "[id=7.0]", want: 0, ok: true},
+		{name: "string", segment: "[id=42]", want: 1, ok: true},
+		{name: "boolean", segment: "[id=true]", want: 2, ok: true},
+		{name: "exact number", segment: "[id=12345678901234567891]", want: 3, ok: true},
+		{name: "exact number spelled differently", segment: "[id=1.2345678901234567891e19]", want: 3, ok: true},
+		{name: "number with exponent", segment: "[id=70e-1]", want: 0, ok: true},
+		{name: "other member", segment: "[name=seven]", want: 0, ok: true},
+		{name: "fraction is not a number", segment: "[id=7/1]"},
+		{name: "hex is not a number", segment: "[id=0x7]"},
+		{name: "huge exponent", segment: "[id=1e999999]"},
+		{name: "no match", segment: "[id=8]"},
+		{name: "empty name is not a key segment", segment: "[=7]"},
+		{name: "index is not a key segment", segment: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			items := keyedItems()["items"].([]any)
+			got, ok := keyIndex(items, tt.segment)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCanonicalDecimal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{text: "42", want: "42e0", ok: true},
+		{text: "4.20e1", want: "42e0", ok: true},
+		{text: "4200", want: "42e2", ok: true},
+		{text: "-0.0042", want: "-42e-4", ok: true},
+		{text: "-0", want: "0", ok: true},
+		{text: "1e999999", want: "1e999999", ok: true},
+		{text: "1e99999999999999999999", ok: false},
+		{text: "042", ok: false},
+		{text: "84/2", ok: false},
+		{text: "0x2A", ok: false},
+		{text: "1.", ok: false},
+		{text: ".5", ok: false},
+		{text: "1e", ok: false},
+		{text: "+1", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := canonicalDecimal(tt.text)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeySegmentAmbiguous(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{"items": []any{
+		map[string]any{"id": 1.0},
+		map[string]any{"id": "1"},
+	}}
+	assert.Equal(t, []string{"items", "[id=1]"}, ResolveKeySegments(doc, []string{"items", "[id=1]"}))
+}
+
+func TestKeySegmentLiteral(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewReplace([]string{"items", "[id=7]", "name"}, "answer").Apply(keyedItems())
+	require.ErrorIs(t, err, ErrPathNotFound)
+	assert.False(t, pathExists(keyedItems(), []string{"items", "[id=7]"}))
+
+	doc := map[string]any{"[id=1]": "member"}
+	got, err := value(doc, []string{"[id=1]"})
+	require.NoError(t, err)
+	assert.Equal(t, "member", got)
+}
+
+func TestResolveKeySegments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path []string
+		want []string
+	}{
+		{name: "key segments", path: []string{"items", "[id=42]", "name"}, want: []string{"items", "1", "name"}},
+		{name: "no key segments", path: []string{"items", "0", "name"}, want: []string{"items", "0", "name"}},
+		{name: "missing member stops resolution", path: []string{"items", "[id=7]", "tags", "[id=1]"}, want: []string{"items", "0", "tags", "[id=1]"}},
+		{name: "no match stays a key segment", path: []string{"items", "[id=8]", "name"}, want: []string{"items", "[id=8]", "name"}},
+		{name: "key segment on an object", path: []string{"[id=1]"}, want: []string{"[id=1]"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, ResolveKeySegments(keyedItems(), tt.path))
+		})
+	}
+}
@@ -27,12 +27,12 @@ func (l *LessOperation) Op() internal.OpType {
 
 // Test evaluates the less predicate condition.
 func (l *LessOperation) Test(doc any) (bool, error) {
-	_, actualValue, err := numericValue(doc, l.Path())
+	value, actualValue, err := numericValue(doc, l.Path())
 	if err != nil {
 		//nolint:nilerr // intentional: path not found means test fails
 		return false, nil
 	}
-	return actualValue < l.Value, nil
+	return compareToOperand(value, actualValue, l.Value) < 0, nil
 }
 
 // Apply applies the less test operation to the document.
@@ -42,7 +42,7 @@ func (l *LessOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{}, err
 	}
 
-	if actualValue >= l.Value {
+	if compareToOperand(value, actualValue, l.Value) >= 0 {
 		return internal.OpResult[any]{}, fmt.Errorf("%w: value %f is not less than %f", ErrComparisonFailed, actualValue, l.Value)
 	}
 	return internal.OpResult[any]{Doc: doc, Old: value}, nil
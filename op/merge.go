@@ -34,6 +34,11 @@ func (mg *MergeOperation) Op() internal.OpType {
 
 // Apply applies the merge operation to the document.
 func (mg *MergeOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return mg.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the merge operation and records its writes in undo.
+func (mg *MergeOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	var targetArray []any
 
 	if len(mg.Path()) == 0 {
@@ -85,7 +90,7 @@ func (mg *MergeOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{Doc: newSlice, Old: []any{one, two}}, nil
 	}
 
-	err := setValueAtPath(doc, mg.Path(), newSlice)
+	err := setValueAtPath(undo, doc, mg.Path(), newSlice)
 	if err != nil {
 		return internal.OpResult[any]{}, err
 	}
@@ -0,0 +1,83 @@
+package op
+
+import (
+	"maps"
+
+	"github.com/kaptinlin/deepclone"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+// MergePatchOperation represents a JSON Merge Patch (RFC 7386) applied at a path.
+// path: target path, usually the root document
+// value: merge patch document
+// Object members set to null are removed; other object members are merged recursively.
+type MergePatchOperation struct {
+	BaseOp
+	Value any `json:"value"` // Merge patch document
+}
+
+// NewMergePatch creates a new JSON Merge Patch operation.
+func NewMergePatch(path []string, value any) *MergePatchOperation {
+	return &MergePatchOperation{
+		BaseOp: NewBaseOp(path),
+		Value:  value,
+	}
+}
+
+// Op returns the operation type.
+func (mp *MergePatchOperation) Op() internal.OpType {
+	return internal.OpMergePatchType
+}
+
+// Apply applies the merge patch to the value at the operation path.
+// A missing target is merged as if it were undefined, as RFC 7386 specifies.
+func (mp *MergePatchOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return mp.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the merge patch and records its writes in undo.
+func (mp *MergePatchOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
+	if len(mp.path) == 0 {
+		return internal.OpResult[any]{Doc: mergePatch(doc, mp.Value), Old: doc}, nil
+	}
+
+	var target any
+	if pathExists(doc, mp.path) {
+		target, _ = value(doc, mp.path)
+	}
+
+	if err := setValueAtPath(undo, doc, mp.path, mergePatch(target, mp.Value)); err != nil {
+		return internal.OpResult[any]{}, err
+	}
+	return internal.OpResult[any]{Doc: doc, Old: target}, nil
+}
+
+// Validate validates the merge patch operation.
+func (mp *MergePatchOperation) Validate() error {
+	return nil
+}
+
+// mergePatch implements the RFC 7386 MergePatch function without mutating target.
+func mergePatch(target, patch any) any {
+	patchObj, ok := patch.(map[string]any)
+	if !ok {
+		return deepclone.Clone(patch)
+	}
+
+	targetObj, ok := target.(map[string]any)
+	if ok {
+		targetObj = maps.Clone(targetObj)
+	} else {
+		targetObj = make(map[string]any, len(patchObj))
+	}
+
+	for k, v := range patchObj {
+		if v == nil {
+			delete(targetObj, k)
+			continue
+		}
+		targetObj[k] = mergePatch(targetObj[k], v)
+	}
+	return targetObj
+}
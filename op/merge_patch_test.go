@@ -0,0 +1,109 @@
+package op
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+)
+
+func TestMergePatch_Apply(t *testing.T) {
+	t.Parallel()
+
+	// Cases from RFC 7386 Appendix A.
+	tests := []struct {
+		name     string
+		doc      any
+		patch    any
+		expected any
+	}{
+		{name: "replace member", doc: map[string]any{"a": "b"}, patch: map[string]any{"a": "c"}, expected: map[string]any{"a": "c"}},
+		{name: "add member", doc: map[string]any{"a": "b"}, patch: map[string]any{"b": "c"}, expected: map[string]any{"a": "b", "b": "c"}},
+		{name: "remove member", doc: map[string]any{"a": "b"}, patch: map[string]any{"a": nil}, expected: map[string]any{}},
+		{name: "remove one of two", doc: map[string]any{"a": "b", "b": "c"}, patch: map[string]any{"a": nil}, expected: map[string]any{"b": "c"}},
+		{name: "array to scalar", doc: map[string]any{"a": []any{"b"}}, patch: map[string]any{"a": "c"}, expected: map[string]any{"a": "c"}},
+		{name: "scalar to array", doc: map[string]any{"a": "c"}, patch: map[string]any{"a": []any{"b"}}, expected: map[string]any{"a": []any{"b"}}},
+		{
+			name:     "nested merge",
+			doc:      map[string]any{"a": map[string]any{"b": "c"}},
+			patch:    map[string]any{"a": map[string]any{"b": "d", "c": nil}},
+			expected: map[string]any{"a": map[string]any{"b": "d"}},
+		},
+		{
+			name:     "arrays replace whole",
+			doc:      map[string]any{"a": []any{map[string]any{"b": "c"}}},
+			patch:    map[string]any{"a": []any{float64(1)}},
+			expected: map[string]any{"a": []any{float64(1)}},
+		},
+		{name: "root array", doc: []any{"a", "b"}, patch: []any{"c", "d"}, expected: []any{"c", "d"}},
+		{name: "object to array", doc: map[string]any{"a": "b"}, patch: []any{"c"}, expected: []any{"c"}},
+		{name: "object to scalar", doc: map[string]any{"a": "foo"}, patch: nil, expected: nil},
+		{name: "object to string", doc: map[string]any{"a": "foo"}, patch: "bar", expected: "bar"},
+		{name: "null members stay", doc: map[string]any{"e": nil}, patch: map[string]any{"a": float64(1)}, expected: map[string]any{"e": nil, "a": float64(1)}},
+		{name: "array to object", doc: []any{float64(1), float64(2)}, patch: map[string]any{"a": "b", "c": nil}, expected: map[string]any{"a": "b"}},
+		{
+			name:     "deep null inside new member",
+			doc:      map[string]any{},
+			patch:    map[string]any{"a": map[string]any{"bb": map[string]any{"ccc": nil}}},
+			expected: map[string]any{"a": map[string]any{"bb": map[string]any{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := NewMergePatch(nil, tt.patch).Apply(tt.doc)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Doc)
+			assert.Equal(t, tt.doc, result.Old)
+		})
+	}
+}
+
+func TestMergePatch_ApplyAtPath(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{"user": map[string]any{"name": "John", "age": float64(30)}}
+	result, err := NewMergePatch([]string{"user"}, map[string]any{"age": nil, "city": "NYC"}).Apply(doc)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"user": map[string]any{"name": "John", "city": "NYC"}}, result.Doc)
+	assert.Equal(t, map[string]any{"name": "John", "age": float64(30)}, result.Old)
+
+	result, err = NewMergePatch([]string{"settings"}, map[string]any{"theme": "dark"}).Apply(map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"settings": map[string]any{"theme": "dark"}}, result.Doc)
+	assert.Nil(t, result.Old)
+
+	_, err = NewMergePatch([]string{"missing", "child"}, map[string]any{}).Apply(map[string]any{})
+	assert.Error(t, err)
+}
+
+func TestMergePatch_DoesNotShareValue(t *testing.T) {
+	t.Parallel()
+
+	value := map[string]any{"tags": []any{"a"}}
+	result, err := NewMergePatch(nil, value).Apply(map[string]any{})
+	require.NoError(t, err)
+
+	value["tags"].([]any)[0] = "changed"
+	assert.Equal(t, map[string]any{"tags": []any{"a"}}, result.Doc)
+}
+
+func TestMergePatch_Projection(t *testing.T) {
+	t.Parallel()
+
+	mergePatch := NewMergePatch([]string{"a"}, map[string]any{"b": nil})
+	assert.Equal(t, internal.OpMergePatchType, mergePatch.Op())
+	assert.Equal(t, internal.OpMergePatchCode, mergePatch.Code())
+
+	operation, err := mergePatch.ToJSON()
+	require.NoError(t, err)
+	assert.Equal(t, internal.Operation{Op: "merge_patch", Path: "/a", Value: map[string]any{"b": nil}}, operation)
+
+	compact, err := mergePatch.ToCompact()
+	require.NoError(t, err)
+	assert.Equal(t, internal.CompactOperation{internal.OpMergePatchCode, []string{"a"}, map[string]any{"b": nil}}, compact)
+}
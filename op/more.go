@@ -27,12 +27,12 @@ func (mo *MoreOperation) Op() internal.OpType {
 
 // Test evaluates the more predicate condition.
 func (mo *MoreOperation) Test(doc any) (bool, error) {
-	_, num, err := numericValue(doc, mo.Path())
+	val, num, err := numericValue(doc, mo.Path())
 	if err != nil {
 		//nolint:nilerr // intentional: path not found or wrong type means test fails
 		return false, nil
 	}
-	return num > mo.Value, nil
+	return compareToOperand(val, num, mo.Value) > 0, nil
 }
 
 // Apply applies the more operation.
@@ -42,7 +42,7 @@ func (mo *MoreOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{}, err
 	}
 
-	if num <= mo.Value {
+	if compareToOperand(val, num, mo.Value) <= 0 {
 		return internal.OpResult[any]{}, fmt.Errorf("%w: value %f is not greater than %f", ErrComparisonFailed, num, mo.Value)
 	}
 
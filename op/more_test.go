@@ -49,6 +49,20 @@ func TestMore_Basic(t *testing.T) {
 			value:       25.0,
 			expectError: false,
 		},
+		{
+			name:        "exact_number_beyond_float64",
+			doc:         map[string]any{"id": internal.Number("9007199254740993")},
+			path:        []string{"id"},
+			value:       9007199254740992.0,
+			expectError: false,
+		},
+		{
+			name:        "exact_decimal_equal_failure",
+			doc:         map[string]any{"price": internal.Number("0.10")},
+			path:        []string{"price"},
+			value:       0.1,
+			expectError: true,
+		},
 		{
 			name:          "non_numeric_value",
 			doc:           map[string]any{"name": "John"},
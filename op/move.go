@@ -1,6 +1,7 @@
 package op
 
 import (
+	"errors"
 	"slices"
 
 	"github.com/kaptinlin/jsonpatch/internal"
@@ -25,6 +26,11 @@ func (m *MoveOperation) Op() internal.OpType {
 
 // Apply applies the move operation following RFC 6902: remove then add.
 func (m *MoveOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return m.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the move operation and records its writes in undo.
+func (m *MoveOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	if slices.Equal(m.path, m.from) {
 		return internal.OpResult[any]{Doc: doc, Old: nil}, nil
 	}
@@ -35,13 +41,21 @@ func (m *MoveOperation) Apply(doc any) (internal.OpResult[any], error) {
 
 	// Move = remove + add (RFC 6902)
 	removeOp := NewRemove(m.from)
-	removeResult, err := removeOp.Apply(doc)
+	removeResult, err := removeOp.ApplyWithUndo(doc, undo)
 	if err != nil {
 		return internal.OpResult[any]{}, err
 	}
 
 	addOp := NewAdd(m.path, removeResult.Old)
-	return addOp.Apply(removeResult.Doc)
+	result, err := addOp.ApplyWithUndo(removeResult.Doc, undo)
+	if err != nil {
+		// Put the value back so a failed move leaves the document unchanged.
+		if _, _, restoreErr := addAtPath(undo, removeResult.Doc, m.from, removeResult.Old); restoreErr != nil {
+			return internal.OpResult[any]{}, errors.Join(err, restoreErr)
+		}
+		return internal.OpResult[any]{}, err
+	}
+	return result, nil
 }
 
 // isPrefix checks if prefix is a prefix of path.
@@ -90,6 +90,21 @@ func TestMove_FromNonExistent(t *testing.T) {
 	}
 }
 
+func TestMove_FailedAddRestoresSource(t *testing.T) {
+	t.Parallel()
+	doc := map[string]any{
+		"foo":  "bar",
+		"list": []any{"a", "b"},
+	}
+
+	_, err := NewMove([]string{"missing", "target"}, []string{"foo"}).Apply(doc)
+	require.Error(t, err)
+	_, err = NewMove([]string{"list", "5"}, []string{"list", "0"}).Apply(doc)
+	require.ErrorIs(t, err, ErrIndexOutOfRange)
+
+	assert.Equal(t, map[string]any{"foo": "bar", "list": []any{"a", "b"}}, doc)
+}
+
 func TestMove_SamePath(t *testing.T) {
 	t.Parallel()
 	doc := map[string]any{"foo": 1}
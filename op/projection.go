@@ -291,6 +291,25 @@ func (mg *MergeOperation) ToCompact() (internal.CompactOperation, error) {
 	return compact, nil
 }
 
+// Code returns the operation code.
+func (mp *MergePatchOperation) Code() int {
+	return codeFor(mp.Op())
+}
+
+// ToJSON serializes the operation to JSON format.
+func (mp *MergePatchOperation) ToJSON() (internal.Operation, error) {
+	return internal.Operation{
+		Op:    string(internal.OpMergePatchType),
+		Path:  formatPath(mp.path),
+		Value: mp.Value,
+	}, nil
+}
+
+// ToCompact serializes the operation to compact format.
+func (mp *MergePatchOperation) ToCompact() (internal.CompactOperation, error) {
+	return internal.CompactOperation{codeFor(internal.OpMergePatchType), mp.path, mp.Value}, nil
+}
+
 // Code returns the operation code.
 func (mo *MoreOperation) Code() int {
 	return codeFor(mo.Op())
@@ -36,6 +36,11 @@ func (r *RemoveOperation) Op() internal.OpType {
 
 // Apply applies the remove operation to the document.
 func (r *RemoveOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return r.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the remove operation and records its writes in undo.
+func (r *RemoveOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	if len(r.path) == 0 {
 		return internal.OpResult[any]{Doc: nil, Old: doc}, nil
 	}
@@ -46,7 +51,7 @@ func (r *RemoveOperation) Apply(doc any) (internal.OpResult[any], error) {
 			if !exists {
 				return internal.OpResult[any]{}, ErrPathNotFound
 			}
-			delete(v, r.path[0])
+			undo.deleteKey(v, r.path[0])
 			return internal.OpResult[any]{Doc: doc, Old: oldValue}, nil
 		case []any:
 			index, err := parseArrayIndex(r.path[0])
@@ -79,7 +84,7 @@ func (r *RemoveOperation) Apply(doc any) (internal.OpResult[any], error) {
 		if !exists {
 			return internal.OpResult[any]{}, ErrPathNotFound
 		}
-		delete(p, k)
+		undo.deleteKey(p, k)
 		return internal.OpResult[any]{Doc: doc, Old: oldValue}, nil
 	case []any:
 		k, ok := key.(int)
@@ -91,7 +96,7 @@ func (r *RemoveOperation) Apply(doc any) (internal.OpResult[any], error) {
 		}
 		oldValue := p[k]
 		newSlice := slices.Delete(slices.Clone(p), k, k+1)
-		if err := setValueAtPath(doc, r.path[:len(r.path)-1], newSlice); err != nil {
+		if err := setValueAtPath(undo, doc, r.path[:len(r.path)-1], newSlice); err != nil {
 			return internal.OpResult[any]{}, err
 		}
 		return internal.OpResult[any]{Doc: doc, Old: oldValue}, nil
@@ -37,6 +37,11 @@ func (rp *ReplaceOperation) Op() internal.OpType {
 
 // Apply applies the replace operation to the document.
 func (rp *ReplaceOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return rp.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the replace operation and records its writes in undo.
+func (rp *ReplaceOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	newValue := deepclone.Clone(rp.Value)
 
 	if len(rp.path) == 0 {
@@ -49,7 +54,7 @@ func (rp *ReplaceOperation) Apply(doc any) (internal.OpResult[any], error) {
 		switch v := doc.(type) {
 		case map[string]any:
 			oldValue := v[""]
-			v[""] = newValue
+			undo.setKey(v, "", newValue)
 			return internal.OpResult[any]{Doc: doc, Old: oldValue}, nil
 		default:
 			return internal.OpResult[any]{}, ErrCannotReplace
@@ -68,7 +73,7 @@ func (rp *ReplaceOperation) Apply(doc any) (internal.OpResult[any], error) {
 			return internal.OpResult[any]{}, ErrInvalidKeyTypeMap
 		}
 		if oldValue, exists := p[k]; exists {
-			p[k] = newValue
+			undo.setKey(p, k, newValue)
 			return internal.OpResult[any]{Doc: doc, Old: oldValue}, nil
 		}
 		return internal.OpResult[any]{}, ErrPathNotFound
@@ -80,7 +85,7 @@ func (rp *ReplaceOperation) Apply(doc any) (internal.OpResult[any], error) {
 		}
 		if k >= 0 && k < len(p) {
 			oldValue := p[k]
-			p[k] = newValue
+			undo.setIndex(p, k, newValue)
 			return internal.OpResult[any]{Doc: doc, Old: oldValue}, nil
 		}
 		return internal.OpResult[any]{}, ErrPathNotFound
@@ -4,6 +4,8 @@ import (
 	"maps"
 	"slices"
 
+	"github.com/kaptinlin/deepclone"
+
 	"github.com/kaptinlin/jsonpatch/internal"
 )
 
@@ -34,6 +36,11 @@ func (sp *SplitOperation) Op() internal.OpType {
 
 // Apply applies the split operation to the document.
 func (sp *SplitOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return sp.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the split operation and records its writes in undo.
+func (sp *SplitOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	var target any
 	var err error
 
@@ -68,13 +75,13 @@ func (sp *SplitOperation) Apply(doc any) (internal.OpResult[any], error) {
 			if len(parentPath) == 0 {
 				return internal.OpResult[any]{Doc: newSlice, Old: target}, nil
 			}
-			err = setValueAtPath(doc, parentPath, newSlice)
+			err = setValueAtPath(undo, doc, parentPath, newSlice)
 			if err != nil {
 				return internal.OpResult[any]{}, err
 			}
 		}
 	} else {
-		err = setValueAtPath(doc, sp.Path(), parts)
+		err = setValueAtPath(undo, doc, sp.Path(), parts)
 		if err != nil {
 			return internal.OpResult[any]{}, err
 		}
@@ -108,9 +115,9 @@ func (sp *SplitOperation) splitValue(value any) any {
 				return []any{results[0], results[1]}
 			}
 		}
-		return []any{v, v}
+		return []any{v, deepclone.Clone(v)}
 	default:
-		return []any{value, value}
+		return []any{value, deepclone.Clone(value)}
 	}
 }
 
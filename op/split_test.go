@@ -205,6 +205,20 @@ func TestSplit_ArrayElementCreatesNewSlice(t *testing.T) {
 	assert.NotEqual(t, originalPointer, fmt.Sprintf("%p", resultLines))
 }
 
+func TestSplit_UnsplittableValueIsNotShared(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{"items": []any{map[string]any{"done": false}}}
+
+	result, err := NewSplit([]string{"items", "0"}, 1, nil).Apply(doc)
+	require.NoError(t, err)
+
+	items := result.Doc.(map[string]any)["items"].([]any)
+	require.Len(t, items, 2)
+	items[1].(map[string]any)["done"] = true
+	assert.Equal(t, map[string]any{"done": false}, items[0])
+}
+
 func TestSplitReferenceBehavior(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
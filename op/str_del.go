@@ -48,6 +48,11 @@ func (sd *StrDelOperation) Op() internal.OpType {
 
 // Apply applies the string delete operation.
 func (sd *StrDelOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return sd.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the string delete operation and records its writes in undo.
+func (sd *StrDelOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	path := sd.Path()
 	target, err := value(doc, path)
 	if err != nil {
@@ -64,7 +69,7 @@ func (sd *StrDelOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{Doc: result, Old: target}, nil
 	}
 
-	if err := setValueAtPath(doc, path, result); err != nil {
+	if err := setValueAtPath(undo, doc, path, result); err != nil {
 		return internal.OpResult[any]{}, err
 	}
 
@@ -33,6 +33,11 @@ func (si *StrInsOperation) Op() internal.OpType {
 
 // Apply applies the string insert operation.
 func (si *StrInsOperation) Apply(doc any) (internal.OpResult[any], error) {
+	return si.ApplyWithUndo(doc, nil)
+}
+
+// ApplyWithUndo applies the string insert operation and records its writes in undo.
+func (si *StrInsOperation) ApplyWithUndo(doc any, undo *UndoLog) (internal.OpResult[any], error) {
 	path := si.Path()
 	target, err := value(doc, path)
 	if err != nil {
@@ -53,7 +58,7 @@ func (si *StrInsOperation) Apply(doc any) (internal.OpResult[any], error) {
 		return internal.OpResult[any]{Doc: result, Old: target}, nil
 	}
 
-	if err := setValueAtPath(doc, path, result); err != nil {
+	if err := setValueAtPath(undo, doc, path, result); err != nil {
 		return internal.OpResult[any]{}, err
 	}
 
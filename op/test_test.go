@@ -38,6 +38,33 @@ func TestTest_Basic(t *testing.T) {
 	}
 }
 
+func TestTest_ExactNumbers(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		doc   any
+		value any
+		want  bool
+	}{
+		{name: "same literal", doc: internal.Number("9007199254740993"), value: internal.Number("9007199254740993"), want: true},
+		{name: "differ beyond float64", doc: internal.Number("9007199254740993"), value: internal.Number("9007199254740992"), want: false},
+		{name: "different spelling", doc: internal.Number("1.50"), value: internal.Number("15e-1"), want: true},
+		{name: "float by its shortest decimal", doc: internal.Number("0.1"), value: 0.1, want: true},
+		{name: "int", doc: internal.Number("42"), value: 42, want: true},
+		{name: "int64 beyond float64", doc: internal.Number("9007199254740993"), value: int64(9007199254740993), want: true},
+		{name: "not a string", doc: internal.Number("42"), value: "42", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ok, err := NewTest(nil, tt.value).Test(tt.doc)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ok)
+		})
+	}
+}
+
 func TestTest_Apply(t *testing.T) {
 	t.Parallel()
 	doc := map[string]any{
@@ -98,7 +98,7 @@ func getTypeName(val any) string {
 		return "boolean"
 	case float64, float32:
 		return "number"
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, internal.Number:
 		return "number"
 	case string:
 		return "string"
@@ -120,6 +120,9 @@ func isWholeNumber(val any) bool {
 		return v == float64(int64(v))
 	case float32:
 		return v == float32(int32(v))
+	case internal.Number:
+		number, ok := exactNumber(v)
+		return ok && number.IsInt()
 	default:
 		rt := reflect.TypeOf(val)
 		if rt == nil {
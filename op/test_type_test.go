@@ -176,6 +176,7 @@ func TestGetTypeName(t *testing.T) {
 		{"int32", int32(42), "number"},
 		{"uint64", uint64(42), "number"},
 		{"float32", float32(3.14), "number"},
+		{"exact number", internal.Number("12345678901234567890"), "number"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,80 @@
+package op
+
+// UndoLog records the writes operations make to existing objects and arrays
+// so they can be reverted without cloning the document. The zero value is
+// ready to use, and a nil *UndoLog records nothing.
+type UndoLog struct {
+	entries []undoEntry
+}
+
+// undoEntry restores one object member or array element.
+type undoEntry struct {
+	object  map[string]any
+	array   []any
+	key     string
+	index   int
+	old     any
+	existed bool
+}
+
+// Len returns the number of recorded writes. Pass it to RollbackTo to revert
+// only the writes recorded after this point.
+func (l *UndoLog) Len() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.entries)
+}
+
+// Rollback reverts every recorded write, newest first, and empties the log.
+func (l *UndoLog) Rollback() {
+	l.RollbackTo(0)
+}
+
+// RollbackTo reverts the writes recorded after the first n, newest first.
+func (l *UndoLog) RollbackTo(n int) {
+	if l == nil {
+		return
+	}
+	for i := len(l.entries) - 1; i >= n; i-- {
+		entry := l.entries[i]
+		switch {
+		case entry.array != nil:
+			entry.array[entry.index] = entry.old
+		case entry.existed:
+			entry.object[entry.key] = entry.old
+		default:
+			delete(entry.object, entry.key)
+		}
+		l.entries[i] = undoEntry{}
+	}
+	l.entries = l.entries[:min(n, len(l.entries))]
+}
+
+// setKey sets an object member and records its previous state.
+func (l *UndoLog) setKey(object map[string]any, key string, value any) {
+	l.recordKey(object, key)
+	object[key] = value
+}
+
+// deleteKey removes an object member and records its previous state.
+func (l *UndoLog) deleteKey(object map[string]any, key string) {
+	l.recordKey(object, key)
+	delete(object, key)
+}
+
+// setIndex sets an array element in place and records its previous value.
+func (l *UndoLog) setIndex(array []any, index int, value any) {
+	if l != nil {
+		l.entries = append(l.entries, undoEntry{array: array, index: index, old: array[index]})
+	}
+	array[index] = value
+}
+
+func (l *UndoLog) recordKey(object map[string]any, key string) {
+	if l == nil {
+		return
+	}
+	old, existed := object[key]
+	l.entries = append(l.entries, undoEntry{object: object, key: key, old: old, existed: existed})
+}
@@ -0,0 +1,91 @@
+package op
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUndoLog_RollbackRestoresWrites(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		op   UndoableOp
+	}{
+		{name: "add member", op: NewAdd([]string{"user", "email"}, "ada@example.com")},
+		{name: "add over member", op: NewAdd([]string{"user", "name"}, "Grace")},
+		{name: "add nested array element", op: NewAdd([]string{"user", "tags", "0"}, "first")},
+		{name: "remove member", op: NewRemove([]string{"user", "name"})},
+		{name: "remove array element", op: NewRemove([]string{"user", "tags", "1"})},
+		{name: "replace array element", op: NewReplace([]string{"user", "tags", "0"}, "x")},
+		{name: "move", op: NewMove([]string{"user", "tags", "-"}, []string{"user", "name"})},
+		{name: "copy", op: NewCopy([]string{"backup"}, []string{"user"})},
+		{name: "inc", op: NewInc([]string{"count"}, 2)},
+		{name: "flip", op: NewFlip([]string{"user", "active"})},
+		{name: "str_ins", op: NewStrIns([]string{"user", "name"}, 3, "!")},
+		{name: "str_del", op: NewStrDel([]string{"user", "name"}, 0, 1)},
+		{name: "extend", op: NewExtend([]string{"user"}, map[string]any{"role": "admin"}, false)},
+		{name: "merge_patch", op: NewMergePatch([]string{"user"}, map[string]any{"name": nil})},
+		{name: "split", op: NewSplit([]string{"user", "tags", "0"}, 1, nil)},
+		{name: "merge", op: NewMerge([]string{"user", "tags"}, 1, nil)},
+	}
+
+	newDoc := func() map[string]any {
+		return map[string]any{
+			"count": float64(1),
+			"user": map[string]any{
+				"name":   "Ada",
+				"active": true,
+				"tags":   []any{"go", "json"},
+			},
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc := newDoc()
+			var undo UndoLog
+			_, err := tt.op.ApplyWithUndo(doc, &undo)
+			require.NoError(t, err)
+			assert.NotEqual(t, newDoc(), doc)
+			assert.Positive(t, undo.Len())
+
+			undo.Rollback()
+			assert.Equal(t, newDoc(), doc)
+			assert.Zero(t, undo.Len())
+		})
+	}
+}
+
+func TestUndoLog_RollbackTo(t *testing.T) {
+	t.Parallel()
+
+	doc := map[string]any{"a": float64(1)}
+	var undo UndoLog
+
+	_, err := NewReplace([]string{"a"}, float64(2)).ApplyWithUndo(doc, &undo)
+	require.NoError(t, err)
+	mark := undo.Len()
+	_, err = NewAdd([]string{"b"}, "x").ApplyWithUndo(doc, &undo)
+	require.NoError(t, err)
+
+	undo.RollbackTo(mark)
+	assert.Equal(t, map[string]any{"a": float64(2)}, doc)
+	assert.Equal(t, mark, undo.Len())
+}
+
+func TestUndoLog_NilRecordsNothing(t *testing.T) {
+	t.Parallel()
+
+	var undo *UndoLog
+	doc := map[string]any{"a": float64(1)}
+	_, err := NewRemove([]string{"a"}).ApplyWithUndo(doc, undo)
+	require.NoError(t, err)
+	assert.Zero(t, undo.Len())
+	undo.Rollback()
+	assert.Empty(t, doc)
+}
@@ -1,7 +1,10 @@
 package op
 
 import (
+	"cmp"
 	"fmt"
+	"math"
+	"math/big"
 	"reflect"
 	"slices"
 	"strconv"
@@ -100,6 +103,14 @@ func deepEqual(a, b any) bool {
 		return false
 	}
 	if aIsNum {
+		if isExactNumber(a) || isExactNumber(b) {
+			x, ok := numberDecimal(a)
+			if !ok {
+				return false
+			}
+			y, ok := numberDecimal(b)
+			return ok && x == y
+		}
 		return aFloat == bFloat
 	}
 
@@ -168,11 +179,130 @@ func toNumericValue(val any) (float64, bool) {
 		return float64(v), true
 	case uint64:
 		return float64(v), true
+	case internal.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	default:
 		return 0, false
 	}
 }
 
+// isExactNumber reports whether val is a Number decoded as literal text.
+func isExactNumber(val any) bool {
+	_, ok := val.(internal.Number)
+	return ok
+}
+
+// exactNumber returns the exact value of a number. A Number has the value
+// of its text and a float that of the shortest decimal that reads back as
+// it, so 0.1 is one tenth rather than the binary value nearest to it. A
+// Number with an exponent beyond internal.MaxNumberExponent has none.
+func exactNumber(val any) (*big.Rat, bool) {
+	switch v := val.(type) {
+	case internal.Number:
+		return internal.ExactValue(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint())), true
+	default:
+		return nil, false
+	}
+}
+
+// numberDecimal returns the canonical decimal form of a number, which is
+// equal for two numbers exactly when their exact values are. Unlike
+// exactNumber it holds no more digits than the number is written with.
+func numberDecimal(val any) (string, bool) {
+	switch v := val.(type) {
+	case internal.Number:
+		return canonicalDecimal(string(v))
+	case float64:
+		return canonicalDecimal(strconv.FormatFloat(v, 'g', -1, 64))
+	case float32:
+		return canonicalDecimal(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return canonicalDecimal(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return canonicalDecimal(strconv.FormatUint(v.Uint(), 10))
+	default:
+		return "", false
+	}
+}
+
+// compareNumbers compares the exact values of two numbers, returning -1, 0,
+// or +1.
+func compareNumbers(a, b any) (int, bool) {
+	x, ok := exactNumber(a)
+	if !ok {
+		return 0, false
+	}
+	y, ok := exactNumber(b)
+	if !ok {
+		return 0, false
+	}
+	return x.Cmp(y), true
+}
+
+// compareToOperand compares the number val, whose float64 value is number,
+// with a numeric operand. A Number is compared exactly.
+func compareToOperand(val any, number, operand float64) int {
+	if isExactNumber(val) {
+		if order, ok := compareNumbers(val, operand); ok {
+			return order
+		}
+	}
+	return cmp.Compare(number, operand)
+}
+
+// addToNumber adds delta to n exactly. The result has as many decimal places
+// as the more precise of the two, so 19.90 plus 0.1 is 20.00. It fails when n
+// has no exact value.
+func addToNumber(n internal.Number, delta float64) (internal.Number, bool) {
+	x, ok := exactNumber(n)
+	if !ok {
+		return "", false
+	}
+	text := strconv.FormatFloat(delta, 'g', -1, 64)
+	y, ok := new(big.Rat).SetString(text)
+	if !ok {
+		return "", false
+	}
+	places := max(decimalPlaces(string(n)), decimalPlaces(text))
+	return internal.Number(x.Add(x, y).FloatString(places)), true
+}
+
+// decimalPlaces returns the number of digits after the decimal point of a
+// JSON number written without its exponent.
+func decimalPlaces(text string) int {
+	mantissa, exponent, _ := strings.Cut(strings.ToLower(text), "e")
+	_, fraction, _ := strings.Cut(mantissa, ".")
+	shift, _ := strconv.Atoi(exponent)
+	return max(len(fraction)-shift, 0)
+}
+
+// DeepEqual reports whether two document values are equal under the same
+// comparison rules used by test and other value predicates.
+func DeepEqual(a, b any) bool {
+	return deepEqual(a, b)
+}
+
 // DeepClone performs a deep clone of a value.
 func DeepClone(value any) (any, error) {
 	cloned := deepclone.Clone(value)
@@ -241,7 +371,7 @@ func valueFromParent(parent any, key any) any {
 }
 
 // setValueAtPath sets a value at a specific path in the document
-func setValueAtPath(doc any, path []string, value any) error {
+func setValueAtPath(undo *UndoLog, doc any, path []string, value any) error {
 	if len(path) == 0 {
 		// Root level set - this should be handled by the caller
 		return ErrPathNotFound
@@ -256,23 +386,23 @@ func setValueAtPath(doc any, path []string, value any) error {
 	if slice, ok := parent.([]any); ok {
 		if index, ok := key.(int); ok && index >= 0 && index <= len(slice) {
 			if index < len(slice) {
-				return updateParent(parent, key, value)
+				return updateParent(undo, parent, key, value)
 			}
 			newSlice := slices.Insert(slices.Clone(slice), len(slice), value)
 
 			if len(path) == 1 {
 				return ErrCannotModifyRootArray
 			}
-			return updateGrandparent(doc, path, newSlice)
+			return updateGrandparent(undo, doc, path, newSlice)
 		}
 	}
 
-	return updateParent(parent, key, value)
+	return updateParent(undo, parent, key, value)
 }
 
 // updateGrandparent updates a grandparent container with a new value for the given key.
 // It handles both root-level parents and nested grandparents.
-func updateGrandparent(doc any, path []string, newSlice []any) error {
+func updateGrandparent(undo *UndoLog, doc any, path []string, newSlice []any) error {
 	grandParentPath := path[:len(path)-2]
 	grandParentKey := path[len(path)-2]
 
@@ -281,7 +411,7 @@ func updateGrandparent(doc any, path []string, newSlice []any) error {
 		if !ok {
 			return ErrCannotUpdateParent
 		}
-		docMap[grandParentKey] = newSlice
+		undo.setKey(docMap, grandParentKey, newSlice)
 		return nil
 	}
 
@@ -293,19 +423,19 @@ func updateGrandparent(doc any, path []string, newSlice []any) error {
 	if !ok {
 		return ErrCannotUpdateGrandparent
 	}
-	grandParentMap[grandParentKey] = newSlice
+	undo.setKey(grandParentMap, grandParentKey, newSlice)
 	return nil
 }
 
 // updateParent updates the parent container with a new value
-func updateParent(parent any, key any, value any) error {
+func updateParent(undo *UndoLog, parent any, key any, value any) error {
 	switch p := parent.(type) {
 	case map[string]any:
 		k, ok := key.(string)
 		if !ok {
 			return ErrInvalidKeyTypeMap
 		}
-		p[k] = value
+		undo.setKey(p, k, value)
 		return nil
 	case []any:
 		k, ok := key.(int)
@@ -313,7 +443,7 @@ func updateParent(parent any, key any, value any) error {
 			return ErrInvalidKeyTypeSlice
 		}
 		if k >= 0 && k < len(p) {
-			p[k] = value
+			undo.setIndex(p, k, value)
 			return nil
 		}
 		return ErrIndexOutOfRange
@@ -372,6 +502,9 @@ func ToFloat64(val any) (float64, bool) {
 		return float64(v), true
 	case uint64:
 		return float64(v), true
+	case internal.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	case string:
 		return parseStringToFloat(v)
 	default:
@@ -3,9 +3,12 @@
 package jsonpatch
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 
 	"github.com/go-json-experiment/json"
 
@@ -27,22 +30,57 @@ const (
 	RFC6902 Capability = 1 << iota
 	// Predicate enables non-regex predicate operations.
 	Predicate
-	// RegexPredicate enables the matches predicate operation.
+	// RegexPredicate enables the matches predicate operation and the match
+	// and search functions of JSONPath queries.
 	RegexPredicate
 	// Extended enables JSON Patch Extended operations.
 	Extended
+	// MergePatch enables JSON Merge Patch (RFC 7386) operations.
+	MergePatch
+	// Wildcard makes a "*" segment of an operation path match every element
+	// of an array and every member of an object. It is not part of
+	// AllCapabilities, because it changes the meaning of literal "*" keys.
+	Wildcard
+	// JSONPath lets the path of an operation in a JSON patch be a JSONPath
+	// query (RFC 9535), such as $.items[?@.sku=='A1'].qty, resolved against
+	// the document when the patch is applied. It is not part of
+	// AllCapabilities, because the pointers such a patch writes are only
+	// known once it runs.
+	JSONPath
+	// KeySegments makes a [name=value] segment of an operation's path, from,
+	// or operand paths select the only element of an array whose name member
+	// matches value, resolved to its index against the document when the
+	// patch is applied. It is not part of AllCapabilities, because it changes
+	// the meaning of literal keys spelled that way.
+	KeySegments
 )
 
 // AllCapabilities enables every operation vocabulary implemented by the package.
-const AllCapabilities = RFC6902 | Predicate | RegexPredicate | Extended
+const AllCapabilities = RFC6902 | Predicate | RegexPredicate | Extended | MergePatch
+
+// Restriction forbids a structural form of operation regardless of the
+// vocabulary that enables it.
+type Restriction uint64
+
+const (
+	// NoRootWrites rejects operations that write the whole document, such as
+	// add, replace, or remove at the empty path, or copy and move into it.
+	NoRootWrites Restriction = 1 << iota
+)
 
 // CompileOption configures patch compilation.
 type CompileOption func(*compileOptions)
 
 type compileOptions struct {
 	capabilities  Capability
+	explicitCaps  bool
 	createMatcher internal.CreateRegexMatcher
 	codec         string
+	limits        internal.Limits
+	pathRules     []PathRule
+	operations    map[OpType]bool
+	exactNumbers  bool
+	restrictions  Restriction
 }
 
 func defaultCompileOptions() compileOptions {
@@ -57,6 +95,33 @@ func WithCapabilities(capabilities ...Capability) CompileOption {
 			enabled |= capability
 		}
 		o.capabilities = enabled
+		o.explicitCaps = true
+	}
+}
+
+// WithOperations allows only the listed operation types, including the
+// operands of and, or, and not. Unless WithCapabilities is also given, the
+// list takes the place of the capability vocabularies, so listing inc enables
+// it without the rest of Extended. Operations outside the list fail with
+// ErrUnsupportedCapability. Repeated options add types.
+func WithOperations(types ...OpType) CompileOption {
+	return func(o *compileOptions) {
+		if o.operations == nil {
+			o.operations = make(map[OpType]bool, len(types))
+		}
+		for _, opType := range types {
+			o.operations[opType] = true
+		}
+	}
+}
+
+// WithRestrictions rejects operations of the given structural forms with
+// ErrPathDenied. Repeated options add restrictions.
+func WithRestrictions(restrictions ...Restriction) CompileOption {
+	return func(o *compileOptions) {
+		for _, restriction := range restrictions {
+			o.restrictions |= restriction
+		}
 	}
 }
 
@@ -67,6 +132,53 @@ func WithCompileMatcher(createMatcher CreateRegexMatcher) CompileOption {
 	}
 }
 
+// WithMaxOperations limits the number of top-level operations in a patch.
+func WithMaxOperations(n int) CompileOption {
+	return func(o *compileOptions) {
+		o.limits.MaxOperations = n
+	}
+}
+
+// WithMaxPredicateDepth limits the nesting of and, or, and not predicates. A
+// composite whose operands are all leaf predicates has depth 1.
+func WithMaxPredicateDepth(depth int) CompileOption {
+	return func(o *compileOptions) {
+		o.limits.MaxPredicateDepth = depth
+	}
+}
+
+// WithMaxPointerLength limits the length in bytes of each path and from JSON
+// Pointer.
+func WithMaxPointerLength(length int) CompileOption {
+	return func(o *compileOptions) {
+		o.limits.MaxPointerLength = length
+	}
+}
+
+// WithMaxPointerSegments limits the number of segments in each path and from
+// JSON Pointer.
+func WithMaxPointerSegments(n int) CompileOption {
+	return func(o *compileOptions) {
+		o.limits.MaxPointerSegments = n
+	}
+}
+
+// WithMaxPatternLength limits the length in bytes of matches patterns. The
+// limit is checked before the pattern is compiled.
+func WithMaxPatternLength(length int) CompileOption {
+	return func(o *compileOptions) {
+		o.limits.MaxPatternLength = length
+	}
+}
+
+// WithMaxValueSize limits the approximate JSON-encoded size in bytes of each
+// embedded value, string operand, and props object.
+func WithMaxValueSize(size int) CompileOption {
+	return func(o *compileOptions) {
+		o.limits.MaxValueSize = size
+	}
+}
+
 func buildCompileOptions(opts []CompileOption) compileOptions {
 	options := defaultCompileOptions()
 	for _, opt := range opts {
@@ -81,10 +193,50 @@ type JSONText string
 // Patch is a compiled, reusable operation sequence.
 type Patch struct {
 	ops []Op
+	// decode holds the NewPatch options used by Decode and UnmarshalJSON.
+	decode []CompileOption
 }
 
+// ApplyOption configures patch application.
+type ApplyOption func(*applyOptions)
+
 type applyOptions struct {
-	mutate bool
+	mutate          bool
+	continueOnError bool
+	dryRun          bool
+	ctx             context.Context
+	budget          *budget
+	validator       Validator
+	observers       []Observer
+	exactNumbers    bool
+}
+
+// WithContinueOnError skips operations that fail instead of stopping. Each
+// skipped operation is reported in Result.Steps with Applied false and its
+// error, and later operations see the document without its changes.
+func WithContinueOnError() ApplyOption {
+	return func(o *applyOptions) {
+		o.continueOnError = true
+	}
+}
+
+// WithDryRun checks the patch against the document without producing output.
+// Every operation is attempted as with WithContinueOnError, Result.Doc is the
+// zero value, and the input document is never modified.
+func WithDryRun() ApplyOption {
+	return func(o *applyOptions) {
+		o.continueOnError = true
+		o.dryRun = true
+	}
+}
+
+func buildApplyOptions(opts []ApplyOption, mutate bool) *applyOptions {
+	options := &applyOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	options.mutate = mutate && !options.dryRun
+	return options
 }
 
 type documentKind uint8
@@ -109,6 +261,9 @@ func Compile(ops ...Op) (*Patch, error) {
 // CompileOps compiles Go-built operations.
 func CompileOps(ops []Op, opts ...CompileOption) (*Patch, error) {
 	options := buildCompileOptions(opts)
+	if err := checkOpLimits(ops, options); err != nil {
+		return nil, err
+	}
 	return compileOps(ops, options)
 }
 
@@ -116,12 +271,29 @@ func CompileOps(ops []Op, opts ...CompileOption) (*Patch, error) {
 func CompileOperations(operations []jsoncodec.Operation, opts ...CompileOption) (*Patch, error) {
 	options := buildCompileOptions(opts)
 	options.codec = "json"
+	if err := options.limits.CheckOperations(len(operations)); err != nil {
+		return nil, newPayloadError(options.codec, err)
+	}
 
 	ops := make([]Op, len(operations))
 	for i := range operations {
-		decoded, err := jsoncodec.DecodeOperations([]internal.Operation{operations[i]}, internal.JSONPatchOptions{
+		if err := options.limits.CheckOperation(operations[i]); err != nil {
+			return nil, newFieldError(ErrPayloadInvalid, i, operations[i].Op, operations[i].Path, operations[i].From, options.codec, err)
+		}
+		operation := operations[i]
+		query, kind, err := parseQueryPath(operation.Path, options)
+		if err != nil {
+			return nil, newFieldError(kind, i, operation.Op, operation.Path, operation.From, options.codec, err)
+		}
+		if query != nil {
+			operation.Path = jsonpointer.Format(query.Pattern()...)
+		}
+		decoded, err := jsoncodec.DecodeOperations([]internal.Operation{operation}, internal.JSONPatchOptions{
 			CreateMatcher: options.createMatcher,
 		})
+		if err == nil {
+			ops[i], err = queryOperation(decoded[0], query, options)
+		}
 		if err != nil {
 			return nil, newFieldError(
 				ErrPayloadInvalid,
@@ -133,7 +305,6 @@ func CompileOperations(operations []jsoncodec.Operation, opts ...CompileOption)
 				err,
 			)
 		}
-		ops[i] = decoded[0]
 	}
 	return compileOps(ops, options)
 }
@@ -142,17 +313,54 @@ func CompileOperations(operations []jsoncodec.Operation, opts ...CompileOption)
 func CompileJSON(data []byte, opts ...CompileOption) (*Patch, error) {
 	options := buildCompileOptions(opts)
 	options.codec = "json"
+	return compileJSON(data, options)
+}
 
+func compileJSON(data []byte, options compileOptions) (*Patch, error) {
+	if err := options.limits.CheckOperationsJSON(data); err != nil {
+		return nil, newPayloadError(options.codec, err)
+	}
 	var operations []map[string]any
-	if err := json.Unmarshal(data, &operations); err != nil {
+	if err := unmarshalJSON(data, &operations, options.exactNumbers); err != nil {
 		return nil, newPayloadError(options.codec, err)
 	}
 
 	ops := make([]Op, len(operations))
 	for i := range operations {
-		decoded, err := jsoncodec.Decode([]map[string]any{operations[i]}, internal.JSONPatchOptions{
+		if err := options.limits.CheckOperationMap(operations[i]); err != nil {
+			return nil, newFieldError(
+				ErrPayloadInvalid,
+				i,
+				stringMapValue(operations[i], "op"),
+				stringMapValue(operations[i], "path"),
+				stringMapValue(operations[i], "from"),
+				options.codec,
+				err,
+			)
+		}
+		operation := operations[i]
+		query, kind, err := parseQueryPath(stringMapValue(operation, "path"), options)
+		if err != nil {
+			return nil, newFieldError(
+				kind,
+				i,
+				stringMapValue(operation, "op"),
+				stringMapValue(operation, "path"),
+				stringMapValue(operation, "from"),
+				options.codec,
+				err,
+			)
+		}
+		if query != nil {
+			operation = maps.Clone(operation)
+			operation["path"] = jsonpointer.Format(query.Pattern()...)
+		}
+		decoded, err := jsoncodec.Decode([]map[string]any{operation}, internal.JSONPatchOptions{
 			CreateMatcher: options.createMatcher,
 		})
+		if err == nil {
+			ops[i], err = queryOperation(decoded[0], query, options)
+		}
 		if err != nil {
 			return nil, newFieldError(
 				ErrPayloadInvalid,
@@ -164,7 +372,6 @@ func CompileJSON(data []byte, opts ...CompileOption) (*Patch, error) {
 				err,
 			)
 		}
-		ops[i] = decoded[0]
 	}
 	return compileOps(ops, options)
 }
@@ -174,7 +381,35 @@ func stringMapValue(values map[string]any, key string) string {
 	return value
 }
 
+// checkOpLimits checks Go-built operations against the compile limits through
+// their JSON projection.
+func checkOpLimits(ops []Op, options compileOptions) error {
+	if options.limits == (internal.Limits{}) {
+		return nil
+	}
+	if err := options.limits.CheckOperations(len(ops)); err != nil {
+		return newPayloadError(options.codec, err)
+	}
+	for i, operation := range ops {
+		jsonOp, ok := operation.(internal.JSONOp)
+		if !ok {
+			continue
+		}
+		projected, err := jsonOp.ToJSON()
+		if err != nil {
+			continue
+		}
+		if err := options.limits.CheckOperation(projected); err != nil {
+			return newError(ErrPayloadInvalid, i, operation, options.codec, err)
+		}
+	}
+	return nil
+}
+
 func compileOps(ops []Op, options compileOptions) (*Patch, error) {
+	if err := checkPathRuleErrors(options.pathRules); err != nil {
+		return nil, newPayloadError(options.codec, err)
+	}
 	compiled := make([]Op, len(ops))
 	for i, operation := range ops {
 		if operation == nil {
@@ -183,10 +418,19 @@ func compileOps(ops []Op, options compileOptions) (*Patch, error) {
 		if err := operation.Validate(); err != nil {
 			return nil, newError(ErrPayloadInvalid, i, operation, options.codec, err)
 		}
-		if !operationAllowed(operation, options.capabilities) {
-			return nil, newError(ErrUnsupportedCapability, i, operation, options.codec, nil)
+		if kind, cause := operationAllowed(operation, options); kind != nil {
+			return nil, newError(kind, i, operation, options.codec, cause)
+		}
+		wrapped, _ := operation.(*wildcardOp)
+		wildcard := options.capabilities&Wildcard != 0 || wrapped != nil
+		keys := options.capabilities&KeySegments != 0 || wrapped != nil && wrapped.keys
+		if err := checkPathPolicy(operation, options.pathRules, wildcard, keys); err != nil {
+			return nil, newError(ErrPathDenied, i, operation, options.codec, err)
 		}
 		cloned, err := cloneCompiledOperation(operation)
+		if err == nil {
+			cloned, err = wrapWildcard(cloned, options)
+		}
 		if err != nil {
 			return nil, newError(ErrPayloadInvalid, i, operation, options.codec, err)
 		}
@@ -203,22 +447,77 @@ func cloneCompiledOperation(operation Op) (Op, error) {
 	return cloneOp.Clone()
 }
 
-func operationAllowed(operation Op, capabilities Capability) bool {
+// operationAllowed returns the error kind and cause that reject operation
+// under the capability, operation type, and structural options, or nil kind
+// when it may be compiled.
+func operationAllowed(operation Op, options compileOptions) (error, error) {
+	if options.operations == nil || options.explicitCaps {
+		required := requiredCapability(operation)
+		if required == 0 || options.capabilities&required != required {
+			return ErrUnsupportedCapability, nil
+		}
+	}
+	if options.operations != nil {
+		var denied Op
+		WalkPredicates(operation, func(predicate Op, _ int) bool {
+			if !options.operations[predicate.Op()] {
+				denied = predicate
+			}
+			return denied == nil
+		})
+		if denied != nil {
+			return ErrUnsupportedCapability, fmt.Errorf("operation %q not allowed", denied.Op())
+		}
+	}
+	if options.restrictions&NoRootWrites != 0 {
+		_, writes := accesses(operation)
+		for _, path := range writes {
+			if len(path) == 0 {
+				return ErrPathDenied, fmt.Errorf("%s writes the document root", operation.Op())
+			}
+		}
+	}
+	return nil, nil
+}
+
+// requiredCapability returns the capabilities that enable operation, or zero
+// for an operation outside the vocabulary.
+func requiredCapability(operation Op) Capability {
+	if wildcard, ok := operation.(*wildcardOp); ok {
+		required := requiredCapability(wildcard.template)
+		if required == 0 {
+			return 0
+		}
+		if wildcard.query != nil {
+			required |= JSONPath
+		}
+		if wildcard.wildcards {
+			required |= Wildcard
+		}
+		if wildcard.keys {
+			required |= KeySegments
+		}
+		return required
+	}
 	spec, ok := internal.LookupOperation(operation.Op())
 	if !ok {
-		return false
+		return 0
 	}
 	switch spec.Capability {
 	case internal.CapabilityJSONPatch:
-		return capabilities&RFC6902 != 0
+		return RFC6902
 	case internal.CapabilityPredicate:
-		return capabilities&Predicate != 0
+		return Predicate
 	case internal.CapabilityRegexPredicate:
-		return capabilities&RegexPredicate != 0
+		return RegexPredicate
 	case internal.CapabilityExtended:
-		return capabilities&Extended != 0
+		return Extended
+	case internal.CapabilityMergePatch:
+		return MergePatch
+	case internal.CapabilityCustom:
+		return Capability(spec.CustomCapability)
 	default:
-		return false
+		return 0
 	}
 }
 
@@ -230,13 +529,34 @@ func (p *Patch) Len() int {
 	return len(p.ops)
 }
 
+// Ops returns the compiled operations in order. The slice is a copy, but the
+// operations are shared with the patch and must not be modified.
+func (p *Patch) Ops() []Op {
+	if p == nil {
+		return nil
+	}
+	return slices.Clone(p.ops)
+}
+
 // Result is the typed result of applying a compiled patch.
 type Result[T internal.Document] struct {
 	Doc   T
 	Steps []Step
 }
 
-// Step describes one applied operation.
+// Err joins the errors of skipped operations in order, or returns nil when
+// every operation was applied.
+func (r *Result[T]) Err() error {
+	var errs []error
+	for i := range r.Steps {
+		if r.Steps[i].err != nil {
+			errs = append(errs, r.Steps[i].err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Step describes one attempted operation.
 type Step struct {
 	index   int
 	op      string
@@ -244,6 +564,7 @@ type Step struct {
 	from    string
 	old     any
 	applied bool
+	err     error
 }
 
 // Index returns the operation index.
@@ -276,27 +597,49 @@ func (s *Step) Applied() bool {
 	return s.applied
 }
 
+// Err returns the structured *Error of a skipped operation, or nil when the
+// operation was applied.
+func (s *Step) Err() error {
+	return s.err
+}
+
 // Apply applies patch immutably to doc.
-func Apply[T internal.Document](patch *Patch, doc T) (*Result[T], error) {
+func Apply[T internal.Document](patch *Patch, doc T, opts ...ApplyOption) (*Result[T], error) {
 	if patch == nil {
 		return nil, newPayloadError("", errors.New("nil patch"))
 	}
-	return applyCompiledByDocumentType(patch, doc, &applyOptions{})
+	return applyCompiledByDocumentType(patch, doc, buildApplyOptions(opts, false))
 }
 
-// ApplyInPlace applies patch and stores the result back in doc.
-func ApplyInPlace[T internal.Document](patch *Patch, doc *T) error {
+// ApplyContext applies patch immutably to doc and checks ctx before each
+// operation. When ctx is done, the patch stops with an *Error whose kind is
+// ctx.Err(), even with WithContinueOnError.
+func ApplyContext[T internal.Document](ctx context.Context, patch *Patch, doc T, opts ...ApplyOption) (*Result[T], error) {
+	if patch == nil {
+		return nil, newPayloadError("", errors.New("nil patch"))
+	}
+	options := buildApplyOptions(opts, false)
+	options.ctx = ctx
+	return applyCompiledByDocumentType(patch, doc, options)
+}
+
+// ApplyInPlace applies patch and stores the result back in doc. With
+// WithDryRun, doc is left unchanged.
+func ApplyInPlace[T internal.Document](patch *Patch, doc *T, opts ...ApplyOption) error {
 	if patch == nil {
 		return newPayloadError("", errors.New("nil patch"))
 	}
 	if doc == nil {
 		return newPayloadError("", errors.New("nil document pointer"))
 	}
-	result, err := applyCompiledByDocumentType(patch, *doc, &applyOptions{mutate: true})
+	options := buildApplyOptions(opts, true)
+	result, err := applyCompiledByDocumentType(patch, *doc, options)
 	if err != nil {
 		return err
 	}
-	*doc = result.Doc
+	if !options.dryRun {
+		*doc = result.Doc
+	}
 	return nil
 }
 
@@ -363,57 +706,108 @@ func classifyDocumentByReflection[T internal.Document](doc T) documentClass {
 	}
 }
 
+// documentValue returns the JSON-shaped value of doc using the same shape
+// classification as Apply. Direct documents are returned without cloning.
+func documentValue[T internal.Document](doc T) (any, error) {
+	class := classifyDocument(doc)
+	switch class.kind {
+	case documentJSONText:
+		var parsed any
+		if err := json.Unmarshal([]byte(class.working.(JSONText)), &parsed); err != nil {
+			return nil, newPayloadError("json", err)
+		}
+		return parsed, nil
+	case documentJSONBytes:
+		var parsed any
+		if err := json.Unmarshal(class.working.([]byte), &parsed); err != nil {
+			return nil, newPayloadError("json", err)
+		}
+		return parsed, nil
+	case documentDirect:
+		return class.working, nil
+	default:
+		data, err := json.Marshal(doc)
+		if err != nil {
+			return nil, conversionError(doc, err)
+		}
+		var parsed any
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, conversionError(doc, err)
+		}
+		return parsed, nil
+	}
+}
+
 func applyJSONTextDocument[T internal.Document](patch *Patch, doc JSONText, original T, options *applyOptions) (*Result[T], error) {
-	var parsed any
-	if err := json.Unmarshal([]byte(doc), &parsed); err != nil {
+	parsed, err := unmarshalDocument([]byte(doc), options.exactNumbers)
+	if err != nil {
 		return nil, newPayloadError("json", err)
 	}
 
-	resultDoc, opResults, err := patch.apply(parsed, options)
+	resultDoc, steps, err := patch.apply(parsed, options)
 	if err != nil {
 		return nil, err
 	}
+	if options.dryRun {
+		return &Result[T]{Steps: steps}, nil
+	}
 
 	resultBytes, err := json.Marshal(resultDoc)
 	if err != nil {
 		return nil, conversionError(original, err)
 	}
-	return resultFromRaw(patch, string(resultBytes), opResults, original)
+	return resultFromRaw(string(resultBytes), steps, original)
 }
 
 func applyJSONBytesDocument[T internal.Document](patch *Patch, doc []byte, original T, options *applyOptions) (*Result[T], error) {
-	var parsed any
-	if err := json.Unmarshal(doc, &parsed); err != nil {
+	parsed, err := unmarshalDocument(doc, options.exactNumbers)
+	if err != nil {
 		return nil, newPayloadError("json", err)
 	}
 
-	resultDoc, opResults, err := patch.apply(parsed, options)
+	resultDoc, steps, err := patch.apply(parsed, options)
 	if err != nil {
 		return nil, err
 	}
+	if options.dryRun {
+		return &Result[T]{Steps: steps}, nil
+	}
 
 	resultBytes, err := json.Marshal(resultDoc)
 	if err != nil {
 		return nil, conversionError(original, err)
 	}
-	return resultFromRaw(patch, resultBytes, opResults, original)
+	return resultFromRaw(resultBytes, steps, original)
 }
 
+// applyStructLikeDocument patches structs and other Go values natively when
+// it can, and otherwise through their JSON form. Budgets are measured and
+// validators run on the JSON form, so they always use the round-trip.
 func applyStructLikeDocument[T internal.Document](patch *Patch, doc T, options *applyOptions) (*Result[T], error) {
+	if options.budget == nil && options.validator == nil && patch.native() {
+		result, err := applyNativeObserved(patch, doc, options)
+		if !errors.Is(err, errNotNative) {
+			return result, err
+		}
+	}
+
 	data, err := json.Marshal(doc)
 	if err != nil {
 		return nil, conversionError(doc, err)
 	}
 
-	var parsed any
-	if err := json.Unmarshal(data, &parsed); err != nil {
+	parsed, err := unmarshalDocument(data, options.exactNumbers)
+	if err != nil {
 		return nil, conversionError(doc, err)
 	}
 
-	resultDoc, opResults, err := patch.apply(parsed, options)
+	resultDoc, steps, err := patch.apply(parsed, options)
 	if err != nil {
 		return nil, err
 	}
+	if options.dryRun {
+		return &Result[T]{Steps: steps}, nil
+	}
 
 	resultData, err := json.Marshal(resultDoc)
 	if err != nil {
@@ -424,23 +818,26 @@ func applyStructLikeDocument[T internal.Document](patch *Patch, doc T, options *
 	if err := json.Unmarshal(resultData, &result); err != nil {
 		return nil, conversionError(doc, err)
 	}
-	return &Result[T]{Doc: result, Steps: stepsFromResults(patch.ops, opResults)}, nil
+	return &Result[T]{Doc: result, Steps: steps}, nil
 }
 
 func applyDirectDocument[T internal.Document](patch *Patch, working any, original T, options *applyOptions) (*Result[T], error) {
-	resultDoc, opResults, err := patch.apply(working, options)
+	resultDoc, steps, err := patch.apply(working, options)
 	if err != nil {
 		return nil, err
 	}
-	return resultFromRaw(patch, resultDoc, opResults, original)
+	if options.dryRun {
+		return &Result[T]{Steps: steps}, nil
+	}
+	return resultFromRaw(resultDoc, steps, original)
 }
 
-func resultFromRaw[T internal.Document](patch *Patch, resultDoc any, opResults []internal.OpResult[any], original T) (*Result[T], error) {
+func resultFromRaw[T internal.Document](resultDoc any, steps []Step, original T) (*Result[T], error) {
 	result, err := convertResult(resultDoc, original)
 	if err != nil {
 		return nil, err
 	}
-	return &Result[T]{Doc: result, Steps: stepsFromResults(patch.ops, opResults)}, nil
+	return &Result[T]{Doc: result, Steps: steps}, nil
 }
 
 func convertResult[T internal.Document](resultDoc any, original T) (T, error) {
@@ -476,25 +873,139 @@ func conversionError(doc any, cause error) error {
 	}
 }
 
-func (p *Patch) apply(doc any, options *applyOptions) (any, []internal.OpResult[any], error) {
+// apply runs the operations in order. The writes of a failing operation are
+// reverted through an undo log, so the document is left as it was before the
+// operation; when mutating, a failed patch also reverts the earlier
+// operations. With continueOnError, a failing operation is recorded as a
+// skipped step instead. A wildcard operation runs as one operation per
+// pointer it expands to, each with its own step, and the key segments of an
+// operation are resolved to indexes just before it runs.
+func (p *Patch) apply(doc any, options *applyOptions) (any, []Step, error) {
 	workingDoc := doc
-	if !options.mutate {
+	if !options.mutate || !p.undoable() {
 		workingDoc = deepclone.Clone(doc)
 	}
+	var undo *oppkg.UndoLog
+	if options.mutate || options.continueOnError {
+		undo = &oppkg.UndoLog{}
+	}
+
+	options.budget.start(workingDoc)
 
-	results := make([]internal.OpResult[any], 0, len(p.ops))
+	steps := make([]Step, 0, len(p.ops))
 	for i, operation := range p.ops {
+		if options.ctx != nil && options.ctx.Err() != nil {
+			undo.Rollback()
+			return nil, nil, contextError(options.ctx, i, operation)
+		}
 		if operation == nil {
-			return nil, nil, newError(ErrPayloadInvalid, i, nil, "", errNilOperation)
+			err := newError(ErrPayloadInvalid, i, nil, "", errNilOperation)
+			if !options.continueOnError {
+				undo.Rollback()
+				return nil, nil, err
+			}
+			steps = append(steps, Step{index: i, err: err})
+			continue
 		}
-		opResult, err := operation.Apply(workingDoc)
-		if err != nil {
-			return nil, nil, newError(kindForApplyError(err), i, operation, "", err)
+		targets := []Op{operation}
+		if wildcard, ok := operation.(*wildcardOp); ok {
+			expanded, err := wildcard.expand(workingDoc)
+			if err != nil {
+				kind := ErrPayloadInvalid
+				if errors.Is(err, oppkg.ErrPathNotFound) {
+					kind = kindForApplyError(err)
+				}
+				patchErr := newError(kind, i, operation, "", err)
+				if !options.continueOnError {
+					undo.Rollback()
+					return nil, nil, patchErr
+				}
+				step := newStep(i, operation, nil)
+				step.err = patchErr
+				steps = append(steps, step)
+				continue
+			}
+			targets = expanded
+		}
+		for _, target := range targets {
+			mark := undo.Len()
+			observed := options.observe(i, target)
+			opResult, kind, err := applyWithinBudget(target, workingDoc, undo, options.budget)
+			if err != nil {
+				patchErr := newError(kind, i, target, "", err)
+				observed(patchErr)
+				if !options.continueOnError {
+					undo.Rollback()
+					return nil, nil, patchErr
+				}
+				undo.RollbackTo(mark)
+				step := newStep(i, target, nil)
+				step.err = patchErr
+				steps = append(steps, step)
+				continue
+			}
+			observed(nil)
+			workingDoc = opResult.Doc
+			step := newStep(i, target, opResult.Old)
+			step.applied = true
+			steps = append(steps, step)
+		}
+	}
+	if err := options.validateResult(workingDoc); err != nil {
+		undo.Rollback()
+		return nil, nil, err
+	}
+	return workingDoc, steps, nil
+}
+
+// undoable reports whether every operation either records its writes or
+// never writes. Other operations cannot be reverted, so mutating application
+// works on a copy instead.
+func (p *Patch) undoable() bool {
+	for _, operation := range p.ops {
+		if wildcard, ok := operation.(*wildcardOp); ok {
+			operation = wildcard.template
+		}
+		switch operation.(type) {
+		case oppkg.UndoableOp, internal.PredicateOp:
+		default:
+			return false
 		}
-		workingDoc = opResult.Doc
-		results = append(results, opResult)
 	}
-	return workingDoc, results, nil
+	return true
+}
+
+// applyWithinBudget applies operation and checks the result against the
+// budget. It returns the kind of a failure with the error: ErrBudgetExceeded
+// for a budget violation, or the class of the operation's own error.
+func applyWithinBudget(operation Op, doc any, undo *oppkg.UndoLog, limits *budget) (internal.OpResult[any], error, error) {
+	c, err := limits.reserve(operation, doc)
+	if err != nil {
+		return internal.OpResult[any]{}, ErrBudgetExceeded, err
+	}
+	result, err := applyOperation(operation, doc, undo)
+	if err != nil {
+		return internal.OpResult[any]{}, kindForApplyError(err), err
+	}
+	if err := limits.settle(c, operation, result.Doc); err != nil {
+		return internal.OpResult[any]{}, ErrBudgetExceeded, err
+	}
+	return result, nil, nil
+}
+
+func contextError(ctx context.Context, index int, operation Op) error {
+	var cause error
+	if err := context.Cause(ctx); !errors.Is(err, ctx.Err()) {
+		cause = err
+	}
+	return newError(ctx.Err(), index, operation, "", cause)
+}
+
+func applyOperation(operation Op, doc any, undo *oppkg.UndoLog) (internal.OpResult[any], error) {
+	if undoable, ok := operation.(oppkg.UndoableOp); ok {
+		return undoable.ApplyWithUndo(doc, undo)
+	}
+	return operation.Apply(doc)
 }
 
 func kindForApplyError(err error) error {
@@ -522,21 +1033,15 @@ func kindForApplyError(err error) error {
 	}
 }
 
-func stepsFromResults(ops []Op, results []internal.OpResult[any]) []Step {
-	steps := make([]Step, len(results))
-	for i := range results {
-		operation := ops[i]
-		step := Step{
-			index:   i,
-			op:      string(operation.Op()),
-			path:    jsonpointer.Format(operation.Path()...),
-			old:     results[i].Old,
-			applied: true,
-		}
-		if from, ok := operation.(interface{ From() []string }); ok {
-			step.from = jsonpointer.Format(from.From()...)
-		}
-		steps[i] = step
+func newStep(index int, operation Op, old any) Step {
+	step := Step{
+		index: index,
+		op:    string(operation.Op()),
+		path:  jsonpointer.Format(operation.Path()...),
+		old:   old,
+	}
+	if from, ok := operation.(interface{ From() []string }); ok {
+		step.from = jsonpointer.Format(from.From()...)
 	}
-	return steps
+	return step
 }
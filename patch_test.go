@@ -39,6 +39,108 @@ func TestCompileJSONCapabilityPolicy(t *testing.T) {
 	assert.Equal(t, float64(2), result.Doc["count"])
 }
 
+func TestCompileWithOperations(t *testing.T) {
+	t.Parallel()
+
+	allowed := jsonpatch.WithOperations(
+		jsonpatch.OpAddType, jsonpatch.OpReplaceType, jsonpatch.OpTestType,
+		jsonpatch.OpIncType, jsonpatch.OpExtendType, jsonpatch.OpAndType,
+	)
+
+	tests := []struct {
+		name      string
+		patch     string
+		opts      []jsonpatch.CompileOption
+		wantErr   error
+		wantIndex int
+		wantCause string
+	}{
+		{
+			name:  "listed types without their capabilities",
+			patch: `[{"op":"inc","path":"/count","inc":1},{"op":"extend","path":"/meta","props":{"a":1}}]`,
+			opts:  []jsonpatch.CompileOption{allowed},
+		},
+		{
+			name:      "unlisted type of an allowed family",
+			patch:     `[{"op":"inc","path":"/count","inc":1},{"op":"split","path":"/text","pos":1}]`,
+			opts:      []jsonpatch.CompileOption{allowed},
+			wantErr:   jsonpatch.ErrUnsupportedCapability,
+			wantIndex: 1,
+			wantCause: `operation "split" not allowed`,
+		},
+		{
+			name:      "unlisted operand",
+			patch:     `[{"op":"and","path":"","apply":[{"op":"test","path":"/a","value":1},{"op":"defined","path":"/b"}]}]`,
+			opts:      []jsonpatch.CompileOption{allowed},
+			wantErr:   jsonpatch.ErrUnsupportedCapability,
+			wantCause: `operation "defined" not allowed`,
+		},
+		{
+			name:  "listed operands",
+			patch: `[{"op":"and","path":"","apply":[{"op":"test","path":"/a","value":1}]}]`,
+			opts:  []jsonpatch.CompileOption{allowed},
+		},
+		{
+			name:    "explicit capabilities still apply",
+			patch:   `[{"op":"inc","path":"/count","inc":1}]`,
+			opts:    []jsonpatch.CompileOption{allowed, jsonpatch.WithCapabilities(jsonpatch.RFC6902)},
+			wantErr: jsonpatch.ErrUnsupportedCapability,
+		},
+		{
+			name:  "repeated options add types",
+			patch: `[{"op":"remove","path":"/a"}]`,
+			opts:  []jsonpatch.CompileOption{allowed, jsonpatch.WithOperations(jsonpatch.OpRemoveType)},
+		},
+		{
+			name:      "root replace",
+			patch:     `[{"op":"replace","path":"","value":{}}]`,
+			opts:      []jsonpatch.CompileOption{allowed, jsonpatch.WithRestrictions(jsonpatch.NoRootWrites)},
+			wantErr:   jsonpatch.ErrPathDenied,
+			wantCause: "replace writes the document root",
+		},
+		{
+			name:      "root add",
+			patch:     `[{"op":"add","path":"/a","value":1},{"op":"add","path":"","value":[]}]`,
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithRestrictions(jsonpatch.NoRootWrites)},
+			wantErr:   jsonpatch.ErrPathDenied,
+			wantIndex: 1,
+			wantCause: "add writes the document root",
+		},
+		{
+			name:      "move into the root",
+			patch:     `[{"op":"move","path":"","from":"/a"}]`,
+			opts:      []jsonpatch.CompileOption{jsonpatch.WithRestrictions(jsonpatch.NoRootWrites)},
+			wantErr:   jsonpatch.ErrPathDenied,
+			wantCause: "move writes the document root",
+		},
+		{
+			name:  "root test",
+			patch: `[{"op":"test","path":"","value":{}},{"op":"replace","path":"/a","value":1}]`,
+			opts:  []jsonpatch.CompileOption{jsonpatch.WithRestrictions(jsonpatch.NoRootWrites)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), tt.opts...)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				assert.NotNil(t, patch)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, tt.wantIndex, patchErr.Index())
+			if tt.wantCause != "" {
+				assert.EqualError(t, patchErr.Cause(), tt.wantCause)
+			}
+		})
+	}
+}
+
 func TestCompileJSONPayloadErrorIncludesOperationContext(t *testing.T) {
 	t.Parallel()
 
@@ -154,6 +256,46 @@ func TestPatchApplyInPlaceIsExplicit(t *testing.T) {
 	assert.Equal(t, "Grace", doc["name"])
 }
 
+func TestPatchApplyInPlaceRollsBackOnFailure(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileOps([]jsonpatch.Op{
+		op.NewReplace([]string{"user", "name"}, "Grace"),
+		op.NewRemove([]string{"user", "tags", "0"}),
+		op.NewAdd([]string{"user", "tags", "-"}, "admin"),
+		op.NewInc([]string{"visits"}, 1),
+		op.NewMove([]string{"archive", "user"}, []string{"user"}),
+	}, jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Extended))
+	require.NoError(t, err)
+
+	newDoc := func() map[string]any {
+		return map[string]any{"user": map[string]any{"name": "Ada", "tags": []any{"go", "json"}}}
+	}
+	doc := newDoc()
+	user := doc["user"].(map[string]any)
+
+	err = jsonpatch.ApplyInPlace(patch, &doc)
+	require.ErrorIs(t, err, jsonpatch.ErrRuntimeConflict)
+
+	var patchErr *jsonpatch.Error
+	require.True(t, errors.As(err, &patchErr))
+	assert.Equal(t, 4, patchErr.Index())
+	assert.Equal(t, newDoc(), doc)
+	assert.Equal(t, newDoc()["user"], user, "rollback restores the caller's containers")
+}
+
+func TestPatchApplyInPlaceMutatesCallerContainers(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(op.NewAdd([]string{"user", "role"}, "admin"))
+	require.NoError(t, err)
+
+	user := map[string]any{"name": "Ada"}
+	doc := map[string]any{"user": user}
+	require.NoError(t, jsonpatch.ApplyInPlace(patch, &doc))
+	assert.Equal(t, "admin", user["role"])
+}
+
 func TestPatchApplyDistinguishesJSONTextFromScalarString(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,101 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// PathOf returns the JSON Pointer segments of the field that field selects
+// in T, named through json tags the way encoding would name them:
+//
+//	path, err := jsonpatch.PathOf(func(u *User) any { return &u.Address.City })
+//
+// field receives a zero T whose nested struct pointers are allocated and
+// must return the address of a field reachable through JSON members. When it
+// does not, PathOf returns an error wrapping ErrUnknownField.
+func PathOf[T any](field func(*T) any) (path []string, err error) {
+	root := reflect.New(reflect.TypeFor[T]())
+	allocateStructPointers(root.Elem(), map[reflect.Type]bool{})
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			path, err = nil, fmt.Errorf("%w: PathOf[%s]: accessor panicked: %v", ErrUnknownField, root.Type().Elem(), recovered)
+		}
+	}()
+
+	selected := reflect.ValueOf(field(root.Interface().(*T)))
+	if !selected.IsValid() || selected.Kind() != reflect.Pointer || selected.IsNil() {
+		return nil, fmt.Errorf("%w: PathOf[%s]: accessor must return a field address", ErrUnknownField, root.Type().Elem())
+	}
+	if selected.Pointer() == root.Pointer() && selected.Type() == root.Type() {
+		return []string{}, nil
+	}
+	if path, ok := findFieldPath(root.Elem(), selected); ok {
+		return path, nil
+	}
+	return nil, fmt.Errorf("%w: PathOf[%s]: accessor does not select a JSON field", ErrUnknownField, root.Type().Elem())
+}
+
+// MustPathOf is like PathOf but panics when field does not select a JSON
+// field. It simplifies building operations from accessors known to be
+// valid:
+//
+//	operation := op.NewReplace(jsonpatch.MustPathOf(func(u *User) any { return &u.Address.City }), "Paris")
+func MustPathOf[T any](field func(*T) any) []string {
+	path, err := PathOf(field)
+	if err != nil {
+		panic(err)
+	}
+	return path
+}
+
+// allocateStructPointers points every nil settable pointer-to-struct field
+// of v at a new zero value, so accessors can reach nested fields. A type
+// already being allocated is left nil to stop recursive types.
+func allocateStructPointers(v reflect.Value, active map[reflect.Type]bool) {
+	if v.Kind() != reflect.Struct || active[v.Type()] {
+		return
+	}
+	active[v.Type()] = true
+	defer delete(active, v.Type())
+	for i := range v.NumField() {
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Struct:
+			allocateStructPointers(field, active)
+		case reflect.Pointer:
+			elem := field.Type().Elem()
+			if !field.CanSet() || elem.Kind() != reflect.Struct || active[elem] {
+				continue
+			}
+			field.Set(reflect.New(elem))
+			allocateStructPointers(field.Elem(), active)
+		}
+	}
+}
+
+// findFieldPath searches the JSON members of v for the field at the address
+// and of the type of selected.
+func findFieldPath(v reflect.Value, selected reflect.Value) ([]string, bool) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct || nativeOpaque(v.Type()) {
+		return nil, false
+	}
+	for name, field := range nativeFieldsOf(v.Type()).byName {
+		member, ok := nativeFieldValue(v, field.index)
+		if !ok {
+			continue
+		}
+		if member.Addr().Pointer() == selected.Pointer() && member.Type() == selected.Type().Elem() {
+			return []string{name}, true
+		}
+		if rest, ok := findFieldPath(member, selected); ok {
+			return append([]string{name}, rest...), true
+		}
+	}
+	return nil, false
+}
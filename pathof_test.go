@@ -0,0 +1,110 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+type pathOfAddress struct {
+	City    string `json:"city"`
+	ZIP     string `json:"zip,omitempty"`
+	Country string
+}
+
+// PathOfMeta is exported so embedding it through a pointer is settable.
+type PathOfMeta struct {
+	Revision int `json:"revision"`
+}
+
+type pathOfUser struct {
+	*PathOfMeta
+	Name     string            `json:"name"`
+	Address  pathOfAddress     `json:"address"`
+	Billing  *pathOfAddress    `json:"billing,omitempty"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels"`
+	Password string            `json:"-"`
+	internal string
+}
+
+func TestPathOf(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		field func(*pathOfUser) any
+		want  []string
+	}{
+		{name: "top-level field", field: func(u *pathOfUser) any { return &u.Name }, want: []string{"name"}},
+		{name: "nested field", field: func(u *pathOfUser) any { return &u.Address.City }, want: []string{"address", "city"}},
+		{name: "tag options", field: func(u *pathOfUser) any { return &u.Address.ZIP }, want: []string{"address", "zip"}},
+		{name: "untagged field", field: func(u *pathOfUser) any { return &u.Address.Country }, want: []string{"address", "Country"}},
+		{name: "struct field", field: func(u *pathOfUser) any { return &u.Address }, want: []string{"address"}},
+		{name: "through pointer", field: func(u *pathOfUser) any { return &u.Billing.City }, want: []string{"billing", "city"}},
+		{name: "pointer field", field: func(u *pathOfUser) any { return &u.Billing }, want: []string{"billing"}},
+		{name: "embedded pointer", field: func(u *pathOfUser) any { return &u.Revision }, want: []string{"revision"}},
+		{name: "slice field", field: func(u *pathOfUser) any { return &u.Tags }, want: []string{"tags"}},
+		{name: "root", field: func(u *pathOfUser) any { return u }, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := jsonpatch.PathOf(tt.field)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, path)
+
+			_, err = jsonpatch.Compile(op.NewTest(path, nil))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestPathOfErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		field func(*pathOfUser) any
+	}{
+		{name: "ignored field", field: func(u *pathOfUser) any { return &u.Password }},
+		{name: "unexported field", field: func(u *pathOfUser) any { return &u.internal }},
+		{name: "not an address", field: func(u *pathOfUser) any { return u.Name }},
+		{name: "nil", field: func(*pathOfUser) any { return nil }},
+		{name: "outside the value", field: func(*pathOfUser) any { return new(string) }},
+		{name: "slice element", field: func(u *pathOfUser) any { return &u.Tags[0] }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path, err := jsonpatch.PathOf(tt.field)
+			require.ErrorIs(t, err, jsonpatch.ErrUnknownField)
+			assert.Nil(t, path)
+
+			assert.PanicsWithError(t, err.Error(), func() { jsonpatch.MustPathOf(tt.field) })
+		})
+	}
+}
+
+func TestPathOfAppliesToStructs(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewReplace(jsonpatch.MustPathOf(func(u *pathOfUser) any { return &u.Address.City }), "Paris"),
+		op.NewAdd(jsonpatch.MustPathOf(func(u *pathOfUser) any { return &u.Tags }), []string{"admin"}),
+	)
+	require.NoError(t, err)
+
+	result, err := jsonpatch.Apply(patch, pathOfUser{Name: "Ada", Address: pathOfAddress{City: "London"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", result.Doc.Address.City)
+	assert.Equal(t, []string{"admin"}, result.Doc.Tags)
+}
@@ -0,0 +1,149 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch/internal"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// PathRule pairs a JSON Pointer pattern with the operations it allows or
+// denies. A "*" segment in the pattern matches any one segment.
+type PathRule struct {
+	deny    bool
+	pointer string
+	pattern []string
+	ops     []OpType
+	err     error
+}
+
+// AllowPath returns a rule that permits ops on the pattern and everything
+// below it. Without ops it permits every operation.
+func AllowPath(pattern string, ops ...OpType) PathRule {
+	return newPathRule(false, pattern, ops)
+}
+
+// DenyPath returns a rule that forbids ops from touching the pattern,
+// everything below it, and its ancestors, whose replacement would overwrite
+// it. Without ops it forbids every operation.
+func DenyPath(pattern string, ops ...OpType) PathRule {
+	return newPathRule(true, pattern, ops)
+}
+
+func newPathRule(deny bool, pattern string, ops []OpType) PathRule {
+	rule := PathRule{deny: deny, pointer: pattern, ops: slices.Clone(ops)}
+	if err := jsonpointer.Validate(pattern); err != nil {
+		rule.err = fmt.Errorf("path policy pattern %q: %w", pattern, err)
+		return rule
+	}
+	rule.pattern = jsonpointer.Parse(pattern)
+	return rule
+}
+
+// WithPathPolicy restricts where operations may point. Each path and from of
+// an operation, and the path of each operand of and, or, and not, is checked
+// against the rules. A pointer that a deny rule covers is rejected. When any
+// allow rule is given, a pointer that no allow rule covers is rejected too.
+// With KeySegments, a [name=value] key segment may select any element, so a
+// deny rule rejects it wherever the rule names an index or key segment, and
+// an index wherever the rule names a key segment; allow rules cover key
+// segments only as written or with "*". Rejections fail with ErrPathDenied. Repeated options
+// add rules.
+func WithPathPolicy(rules ...PathRule) CompileOption {
+	return func(o *compileOptions) {
+		o.pathRules = append(o.pathRules, rules...)
+	}
+}
+
+// checkPathRuleErrors reports the first rule with an invalid pattern.
+func checkPathRuleErrors(rules []PathRule) error {
+	for _, rule := range rules {
+		if rule.err != nil {
+			return rule.err
+		}
+	}
+	return nil
+}
+
+// checkPathPolicy checks operation against rules. With wildcard, a "*"
+// segment of a path may expand to any member, so deny rules treat it as
+// matching every segment while allow rules must cover it as written. With
+// keys, a key segment may select the element an index or another key segment
+// names, so deny rules treat them as matching each other, while allow rules
+// must again cover them as written.
+func checkPathPolicy(operation Op, rules []PathRule, wildcard, keys bool) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	var err error
+	WalkPredicates(operation, func(visited Op, _ int) bool {
+		if _, ok := visited.(internal.SecondOrderPredicateOp); ok {
+			return true
+		}
+		err = checkPathRules(visited.Op(), visited.Path(), rules, wildcard, keys)
+		if err == nil {
+			if from := operationFrom(visited); from != nil {
+				err = checkPathRules(visited.Op(), from, rules, false, keys)
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+func checkPathRules(opType OpType, path []string, rules []PathRule, wildcard, keys bool) error {
+	allowRules, allowed := false, false
+	for _, rule := range rules {
+		if len(rule.ops) > 0 && !slices.Contains(rule.ops, opType) {
+			if !rule.deny {
+				allowRules = true
+			}
+			continue
+		}
+		if rule.deny {
+			ancestor := len(path) < len(rule.pattern) && matchesPattern(path, rule.pattern[:len(path)], wildcard, keys)
+			if ancestor || matchesPattern(path, rule.pattern, wildcard, keys) {
+				return fmt.Errorf("%s %q denied by %q", opType, jsonpointer.Format(path...), rule.pointer)
+			}
+			continue
+		}
+		allowRules = true
+		if matchesPattern(path, rule.pattern, false, false) {
+			allowed = true
+		}
+	}
+	if allowRules && !allowed {
+		return fmt.Errorf("%s %q not allowed", opType, jsonpointer.Format(path...))
+	}
+	return nil
+}
+
+// matchesPattern reports whether path is pattern or lies below it. With
+// wildcard, a "*" segment of path matches any segment of pattern. With keys,
+// a segment of path matches a segment of pattern that may select the same
+// array element.
+func matchesPattern(path, pattern []string, wildcard, keys bool) bool {
+	if len(path) < len(pattern) {
+		return false
+	}
+	for i, segment := range pattern {
+		switch {
+		case segment == "*", segment == path[i]:
+		case wildcard && path[i] == wildcardSegment:
+		case keys && mayAlias(path[i], segment):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// mayAlias reports whether two different segments may select the same array
+// element: a key segment against an index or another key segment.
+func mayAlias(a, b string) bool {
+	aKey, bKey := oppkg.IsKeySegment(a), oppkg.IsKeySegment(b)
+	return (aKey || bKey) && (aKey || isIndexToken(a)) && (bKey || isIndexToken(b))
+}
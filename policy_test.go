@@ -0,0 +1,176 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+)
+
+func TestWithPathPolicy(t *testing.T) {
+	t.Parallel()
+
+	clientPolicy := jsonpatch.WithPathPolicy(
+		jsonpatch.AllowPath("/profile", jsonpatch.OpReplaceType, jsonpatch.OpTestType),
+		jsonpatch.AllowPath("/items/*/done", jsonpatch.OpReplaceType),
+		jsonpatch.DenyPath("/profile/roles"),
+	)
+
+	tests := []struct {
+		name      string
+		build     func(*jsonpatch.Builder) *jsonpatch.Builder
+		opts      []jsonpatch.CompileOption
+		wantIndex int
+		wantErr   bool
+	}{
+		{
+			name: "allowed replace and test",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder {
+				return b.Test("/profile/name", "Ada").Replace("/profile/name", "Grace")
+			},
+			opts: []jsonpatch.CompileOption{clientPolicy},
+		},
+		{
+			name:  "wildcard segment",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/3/done", true) },
+			opts:  []jsonpatch.CompileOption{clientPolicy},
+		},
+		{
+			name: "operation type not allowed",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder {
+				return b.Replace("/profile/name", "x").Remove("/profile/name")
+			},
+			opts:      []jsonpatch.CompileOption{clientPolicy},
+			wantIndex: 1,
+			wantErr:   true,
+		},
+		{
+			name:    "outside allowed paths",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/billing/plan", "pro") },
+			opts:    []jsonpatch.CompileOption{clientPolicy},
+			wantErr: true,
+		},
+		{
+			name:    "denied below an allowed path",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/profile/roles/0", "admin") },
+			opts:    []jsonpatch.CompileOption{clientPolicy},
+			wantErr: true,
+		},
+		{
+			name:    "write to an ancestor of a denied path",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/profile", map[string]any{}) },
+			opts:    []jsonpatch.CompileOption{clientPolicy},
+			wantErr: true,
+		},
+		{
+			name:    "move source",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Move("/profile/name", "/secrets/token") },
+			opts:    []jsonpatch.CompileOption{jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/secrets"))},
+			wantErr: true,
+		},
+		{
+			name:    "copy source",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Copy("/profile/name", "/secrets/token") },
+			opts:    []jsonpatch.CompileOption{jsonpatch.WithPathPolicy(jsonpatch.AllowPath("/profile"))},
+			wantErr: true,
+		},
+		{
+			name: "composite operand",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder {
+				return b.And("", func(b *jsonpatch.Builder) { b.Defined("/profile").Defined("/secrets/token") })
+			},
+			opts: []jsonpatch.CompileOption{
+				jsonpatch.WithCapabilities(jsonpatch.Predicate),
+				jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/secrets", jsonpatch.OpDefinedType)),
+			},
+			wantErr: true,
+		},
+		{
+			name:    "key segment denied by index",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/[id=1]/name", "x") },
+			opts:    []jsonpatch.CompileOption{keyCaps, jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/items/0"))},
+			wantErr: true,
+		},
+		{
+			name:    "index denied by key segment",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("/items/3") },
+			opts:    []jsonpatch.CompileOption{keyCaps, jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/items/[id=1]"))},
+			wantErr: true,
+		},
+		{
+			name:  "literal key segment without KeySegments",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/[id=1]/name", "x") },
+			opts:  []jsonpatch.CompileOption{jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/items/0"))},
+		},
+		{
+			name:    "key segment not allowed by index",
+			build:   func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/[id=1]/done", true) },
+			opts:    []jsonpatch.CompileOption{jsonpatch.WithPathPolicy(jsonpatch.AllowPath("/items/0"))},
+			wantErr: true,
+		},
+		{
+			name:  "key segment allowed by wildcard",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Replace("/items/[id=1]/done", true) },
+			opts:  []jsonpatch.CompileOption{clientPolicy, jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/profile/0"))},
+		},
+		{
+			name:  "deny scoped to other operations",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Test("/secrets/token", "x") },
+			opts:  []jsonpatch.CompileOption{jsonpatch.WithPathPolicy(jsonpatch.DenyPath("/secrets", jsonpatch.OpReplaceType))},
+		},
+		{
+			name:  "no policy",
+			build: func(b *jsonpatch.Builder) *jsonpatch.Builder { return b.Remove("") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := tt.build(jsonpatch.NewBuilder()).Compile(tt.opts...)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				assert.NotNil(t, patch)
+				return
+			}
+			require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+			assert.Nil(t, patch)
+
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, tt.wantIndex, patchErr.Index())
+		})
+	}
+}
+
+func TestWithPathPolicyCompileEntryPoints(t *testing.T) {
+	t.Parallel()
+
+	policy := jsonpatch.WithPathPolicy(jsonpatch.AllowPath("/profile"), jsonpatch.DenyPath("/profile/roles"))
+
+	_, err := jsonpatch.CompileJSON([]byte(`[{"op":"move","path":"/profile/name","from":"/profile/roles/0"}]`), policy)
+	require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+	var patchErr *jsonpatch.Error
+	require.True(t, errors.As(err, &patchErr))
+	assert.Equal(t, "move", patchErr.Op())
+	assert.Equal(t, "/profile/roles/0", patchErr.From())
+	assert.Contains(t, err.Error(), `"/profile/roles/0" denied by "/profile/roles"`)
+
+	_, err = jsonpatch.CompileCompact([]byte(`[[1,["roles"]]]`), policy)
+	require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+
+	_, err = jsonpatch.CompileJSON([]byte(`[{"op":"remove","path":"/profile/name"}]`), policy)
+	require.NoError(t, err)
+}
+
+func TestWithPathPolicyInvalidPattern(t *testing.T) {
+	t.Parallel()
+
+	_, err := jsonpatch.NewBuilder().Remove("/a").Compile(jsonpatch.WithPathPolicy(jsonpatch.DenyPath("roles")))
+	require.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+	assert.Contains(t, err.Error(), `path policy pattern "roles"`)
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/kaptinlin/jsonpatch/codec/compact"
 	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
 	"github.com/kaptinlin/jsonpatch/op"
+	"github.com/kaptinlin/jsonpatch/schema"
+	"github.com/kaptinlin/jsonpatch/transform"
 )
 
 type readmeUser struct {
@@ -20,6 +22,14 @@ type readmeUser struct {
 	Roles  []string `json:"roles"`
 }
 
+type readmeAddress struct {
+	City string `json:"city"`
+}
+
+type readmeAddressedUser struct {
+	Address readmeAddress `json:"address"`
+}
+
 func TestREADMEExamples(t *testing.T) {
 	t.Parallel()
 
@@ -82,6 +92,27 @@ func TestREADMEExamples(t *testing.T) {
 		assert.Equal(t, "Ada Lovelace", result.Doc["name"])
 	})
 
+	t.Run("WithOperations and WithRestrictions narrow the vocabulary", func(t *testing.T) {
+		t.Parallel()
+
+		opts := []jsonpatch.CompileOption{
+			jsonpatch.WithOperations(
+				jsonpatch.OpAddType, jsonpatch.OpReplaceType, jsonpatch.OpTestType,
+				jsonpatch.OpIncType, jsonpatch.OpExtendType,
+			),
+			jsonpatch.WithRestrictions(jsonpatch.NoRootWrites),
+		}
+
+		_, err := jsonpatch.CompileJSON([]byte(`[{"op":"inc","path":"/count","inc":1}]`), opts...)
+		require.NoError(t, err)
+
+		_, err = jsonpatch.CompileJSON([]byte(`[{"op":"split","path":"/text","pos":1}]`), opts...)
+		require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+
+		_, err = jsonpatch.CompileJSON([]byte(`[{"op":"replace","path":"","value":{}}]`), opts...)
+		require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+	})
+
 	t.Run("JSONText marks string documents as JSON", func(t *testing.T) {
 		t.Parallel()
 
@@ -96,6 +127,158 @@ func TestREADMEExamples(t *testing.T) {
 		assert.Equal(t, "Jane", got["name"])
 	})
 
+	t.Run("PathOf derives paths from struct fields", func(t *testing.T) {
+		t.Parallel()
+
+		city, err := jsonpatch.PathOf(func(u *readmeAddressedUser) any { return &u.Address.City })
+		require.NoError(t, err)
+		assert.Equal(t, []string{"address", "city"}, city)
+
+		patch, err := jsonpatch.Compile(op.NewReplace(city, "Paris"))
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, readmeAddressedUser{Address: readmeAddress{City: "London"}})
+		require.NoError(t, err)
+		assert.Equal(t, "Paris", result.Doc.Address.City)
+	})
+
+	t.Run("Builder compiles string paths", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.NewBuilder().
+			Test("/version", 3).
+			Add("/tags/-", "beta").
+			Inc("/count", 1).
+			Compile()
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, map[string]any{"version": 3, "tags": []any{}, "count": 1})
+		require.NoError(t, err)
+		assert.Equal(t, []any{"beta"}, result.Doc["tags"])
+		assert.InDelta(t, 2.0, result.Doc["count"], 0)
+	})
+
+	t.Run("Patch.Analyze reports reads, writes, and capabilities", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.NewBuilder().
+			Test("/version", 3).
+			Add("/tags/-", "beta").
+			Inc("/count", 1).
+			Compile()
+		require.NoError(t, err)
+
+		analysis := patch.Analyze()
+		assert.Equal(t, []string{"/version", "/count"}, analysis.Reads)
+		assert.Equal(t, []string{"/tags/-", "/count"}, analysis.Writes)
+
+		_, err = jsonpatch.CompileOps(patch.Ops(), jsonpatch.WithCapabilities(analysis.Capabilities))
+		require.NoError(t, err)
+	})
+
+	t.Run("RegisterOperation adds domain operations", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileJSON(
+			[]byte(`[{"op": "set_status", "path": "/status", "value": "shipped", "oldValue": ["paid"]}]`),
+			jsonpatch.WithCapabilities(jsonpatch.RFC6902, workflow),
+		)
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, map[string]any{"status": "paid"})
+		require.NoError(t, err)
+		assert.Equal(t, "shipped", result.Doc["status"])
+	})
+
+	t.Run("Wildcard paths expand to every element", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileJSON(
+			[]byte(`[{"op": "replace", "path": "/items/*/currency", "value": "EUR"}]`),
+			jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Wildcard),
+		)
+		require.NoError(t, err)
+
+		order := map[string]any{"items": []any{
+			map[string]any{"currency": "USD"},
+			map[string]any{"currency": "USD"},
+		}}
+		result, err := jsonpatch.Apply(patch, order)
+		require.NoError(t, err)
+
+		var paths []string
+		for _, step := range result.Steps {
+			paths = append(paths, step.Path())
+		}
+		assert.Equal(t, []string{"/items/0/currency", "/items/1/currency"}, paths)
+	})
+
+	t.Run("JSONPath queries select targets at apply time", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileJSON(
+			[]byte(`[{"op": "replace", "path": "$.items[?@.sku=='A1'].qty", "value": 3}]`),
+			jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.JSONPath),
+		)
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, map[string]any{"items": []any{
+			map[string]any{"sku": "B2", "qty": 1.0},
+			map[string]any{"sku": "A1", "qty": 1.0},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"items": []any{
+			map[string]any{"sku": "B2", "qty": 1.0},
+			map[string]any{"sku": "A1", "qty": 3.0},
+		}}, result.Doc)
+	})
+
+	t.Run("Exact numbers survive patching", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileJSON([]byte(`[{"op": "add", "path": "/note", "value": "paid"}]`))
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, []byte(`{"id": 12345678901234567891, "price": 19.90}`),
+			jsonpatch.WithExactNumbers())
+		require.NoError(t, err)
+		assert.Contains(t, string(result.Doc), `"id":12345678901234567891`)
+		assert.Contains(t, string(result.Doc), `"price":19.90`)
+	})
+
+	t.Run("Key segments address array elements by identifier", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.CompileJSON([]byte(`[
+			{"op": "replace", "path": "/items/[id=42]/name", "value": "nut"}
+		]`), jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.KeySegments))
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, map[string]any{"items": []any{
+			map[string]any{"id": 7.0, "name": "washer"},
+			map[string]any{"id": 42.0, "name": "bolt"},
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, "nut", result.Doc["items"].([]any)[1].(map[string]any)["name"])
+	})
+
+	t.Run("Patch encodes to wire forms", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.NewBuilder().Replace("/name", "Jane").Inc("/version", 1).Compile()
+		require.NoError(t, err)
+
+		data, err := patch.Encode(jsonpatch.EncodingBinary)
+		require.NoError(t, err)
+
+		received := jsonpatch.NewPatch(jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+		require.NoError(t, received.Decode(jsonpatch.EncodingBinary, data))
+
+		result, err := jsonpatch.Apply(received, map[string]any{"name": "John", "version": 1})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"name": "Jane", "version": float64(2)}, result.Doc)
+	})
+
 	t.Run("ApplyInPlace writes result back to the input variable", func(t *testing.T) {
 		t.Parallel()
 
@@ -107,6 +290,216 @@ func TestREADMEExamples(t *testing.T) {
 		assert.Equal(t, "Jane", doc["name"])
 	})
 
+	t.Run("dry run reports every failure", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(
+			op.NewRemove([]string{"missing"}),
+			op.NewReplace([]string{"name"}, "Jane"),
+			op.NewTest([]string{"name"}, "John"),
+		)
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, map[string]any{"name": "John"}, jsonpatch.WithDryRun())
+		require.NoError(t, err)
+
+		var failed []int
+		for _, step := range result.Steps {
+			if !step.Applied() {
+				failed = append(failed, step.Index())
+				assert.Error(t, step.Err())
+			}
+		}
+		assert.Equal(t, []int{0, 2}, failed)
+	})
+
+	t.Run("WithObserver reports each operation", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(
+			op.NewReplace([]string{"name"}, "Jane"),
+			op.NewAdd([]string{"email"}, "jane@example.com"),
+		)
+		require.NoError(t, err)
+
+		var observed []jsonpatch.OpType
+		_, err = jsonpatch.Apply(patch, map[string]any{"name": "John"}, jsonpatch.WithObserver(jsonpatch.ObserverFuncs{
+			After: func(event jsonpatch.OpEvent) {
+				assert.NoError(t, event.Err)
+				observed = append(observed, event.Op)
+			},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []jsonpatch.OpType{jsonpatch.OpReplaceType, jsonpatch.OpAddType}, observed)
+	})
+
+	t.Run("budgets stop a copy that doubles the document", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(op.NewCopy([]string{"backup"}, []string{"items"}))
+		require.NoError(t, err)
+
+		doc := map[string]any{"items": []any{"a", "b", "c"}}
+		_, err = jsonpatch.ApplyContext(t.Context(), patch, doc, jsonpatch.WithMaxDocumentSize(32))
+		assert.ErrorIs(t, err, jsonpatch.ErrBudgetExceeded)
+	})
+
+	t.Run("compile limits reject oversized payloads", func(t *testing.T) {
+		t.Parallel()
+
+		body := []byte(`[{"op":"remove","path":"/a"},{"op":"remove","path":"/b"}]`)
+		_, err := jsonpatch.CompileJSON(body, jsonpatch.WithMaxOperations(1))
+		assert.ErrorIs(t, err, jsonpatch.ErrPayloadInvalid)
+		assert.ErrorIs(t, err, jsonpatch.ErrLimitExceeded)
+	})
+	t.Run("path policy rejects operations outside allowed paths", func(t *testing.T) {
+		t.Parallel()
+
+		policy := jsonpatch.WithPathPolicy(
+			jsonpatch.AllowPath("/profile", jsonpatch.OpReplaceType, jsonpatch.OpTestType),
+			jsonpatch.DenyPath("/profile/roles"),
+		)
+
+		_, err := jsonpatch.CompileJSON([]byte(`[{"op":"replace","path":"/profile/name","value":"Ada"}]`), policy)
+		require.NoError(t, err)
+
+		_, err = jsonpatch.CompileJSON([]byte(`[{"op":"copy","path":"/profile/name","from":"/profile/roles/0"}]`), policy)
+		assert.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+	})
+
+	t.Run("WithValidator rejects documents that break the schema", func(t *testing.T) {
+		t.Parallel()
+
+		userSchema, err := schema.Compile([]byte(`{
+			"type": "object",
+			"properties": {"age": {"type": "integer", "minimum": 0}}
+		}`))
+		require.NoError(t, err)
+
+		patch, err := jsonpatch.Compile(op.NewReplace([]string{"age"}, -1))
+		require.NoError(t, err)
+
+		_, err = jsonpatch.Apply(patch, map[string]any{"age": 30}, jsonpatch.WithValidator(userSchema))
+		require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+		var patchErr *jsonpatch.Error
+		require.ErrorAs(t, err, &patchErr)
+		assert.Equal(t, "/age", patchErr.InstancePointer())
+		assert.Equal(t, "/properties/age/minimum", patchErr.SchemaPointer())
+	})
+
+	t.Run("Diff generates a patch between document versions", func(t *testing.T) {
+		t.Parallel()
+
+		before := map[string]any{"tags": []any{"go", "json"}}
+		after := map[string]any{"tags": []any{"patch", "go", "json"}}
+
+		patch, err := jsonpatch.Diff(before, after, jsonpatch.WithLCSArrays())
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, before)
+		require.NoError(t, err)
+		assert.Equal(t, after, result.Doc)
+		require.Len(t, result.Steps, 1)
+		assert.Equal(t, "add", result.Steps[0].Op())
+	})
+
+	t.Run("Invert builds an undo patch", func(t *testing.T) {
+		t.Parallel()
+
+		doc := map[string]any{"name": "John", "tags": []any{"golang"}}
+		patch, err := jsonpatch.Compile(
+			op.NewReplace([]string{"name"}, "Jane"),
+			op.NewAdd([]string{"tags", "-"}, "json"),
+		)
+		require.NoError(t, err)
+
+		undo, err := patch.Invert(doc)
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, doc)
+		require.NoError(t, err)
+
+		restored, err := jsonpatch.Apply(undo, result.Doc)
+		require.NoError(t, err)
+		assert.Equal(t, doc, restored.Doc)
+	})
+
+	t.Run("compose squashes patches", func(t *testing.T) {
+		t.Parallel()
+
+		saved, err := jsonpatch.CompileOps([]jsonpatch.Op{
+			op.NewAdd([]string{"title"}, ""),
+			op.NewStrIns([]string{"title"}, 0, "Hel"),
+		}, jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Extended))
+		require.NoError(t, err)
+		incoming, err := jsonpatch.CompileOps([]jsonpatch.Op{
+			op.NewStrIns([]string{"title"}, 3, "lo"),
+			op.NewInc([]string{"revision"}, 1),
+		}, jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Extended))
+		require.NoError(t, err)
+
+		patch, err := jsonpatch.Compose(saved, incoming)
+		require.NoError(t, err)
+		assert.Equal(t, 2, patch.Len())
+
+		result, err := jsonpatch.Apply(patch, map[string]any{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"title": "Hello", "revision": float64(1)}, result.Doc)
+	})
+
+	t.Run("transform rebases concurrent patches", func(t *testing.T) {
+		t.Parallel()
+
+		local, err := jsonpatch.Compile(op.NewAdd([]string{"tags", "0"}, "urgent"))
+		require.NoError(t, err)
+		remote, err := jsonpatch.Compile(op.NewRemove([]string{"tags", "1"}))
+		require.NoError(t, err)
+
+		aPrime, bPrime, err := transform.Transform(local, remote)
+		require.NoError(t, err)
+
+		doc := map[string]any{"tags": []any{"go", "json"}}
+		localDoc, err := jsonpatch.Apply(local, doc)
+		require.NoError(t, err)
+		result, err := jsonpatch.Apply(bPrime, localDoc.Doc)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"tags": []any{"urgent", "go"}}, result.Doc)
+
+		remoteDoc, err := jsonpatch.Apply(remote, doc)
+		require.NoError(t, err)
+		server, err := jsonpatch.Apply(aPrime, remoteDoc.Doc)
+		require.NoError(t, err)
+		assert.Equal(t, result.Doc, server.Doc)
+	})
+
+	t.Run("Conflicts reports colliding operations", func(t *testing.T) {
+		t.Parallel()
+
+		mine, err := jsonpatch.NewBuilder().Replace("/items/3", "done").Compile()
+		require.NoError(t, err)
+		theirs, err := jsonpatch.NewBuilder().Remove("/items").Compile()
+		require.NoError(t, err)
+
+		conflicts := jsonpatch.Conflicts(mine, theirs)
+		require.Len(t, conflicts, 1)
+		assert.Equal(t, "write/write", conflicts[0].Kind.String())
+		assert.Equal(t, "/items/3", conflicts[0].APath)
+		assert.Equal(t, "/items", conflicts[0].BPath)
+	})
+
+	t.Run("merge patch compiles and applies", func(t *testing.T) {
+		t.Parallel()
+
+		doc := map[string]any{"title": "Goodbye!", "author": map[string]any{"givenName": "John", "familyName": "Doe"}}
+		patch, err := jsonpatch.CompileMergePatch([]byte(`{"title":"Hello!","author":{"familyName":null}}`))
+		require.NoError(t, err)
+
+		result, err := jsonpatch.Apply(patch, doc)
+		require.NoError(t, err)
+		assert.Equal(t, "Hello!", result.Doc["title"])
+		assert.Equal(t, map[string]any{"givenName": "John"}, result.Doc["author"])
+	})
+
 	t.Run("structured errors expose failure context", func(t *testing.T) {
 		t.Parallel()
 
@@ -0,0 +1,763 @@
+// Package schema validates JSON documents against a subset of JSON Schema
+// 2020-12, for use with jsonpatch.WithValidator.
+//
+// The supported keywords are:
+//
+//   - type, enum, const
+//   - properties, patternProperties, additionalProperties, required,
+//     minProperties, maxProperties
+//   - prefixItems, items, minItems, maxItems, uniqueItems
+//   - minLength, maxLength, pattern
+//   - minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//   - allOf, anyOf, oneOf, not
+//   - $ref to a JSON Pointer fragment of the same schema, such as
+//     "#/$defs/address"; $defs. A $ref may recurse only through a keyword
+//     that descends into the instance, such as properties or items.
+//
+// Boolean schemas are supported. Other keywords, including format, are
+// ignored. Patterns use Go regexp syntax. Documents need not come from JSON:
+// the numeric keywords and the integer type accept any Go numeric type or a
+// jsonpatch.Number, and enum, const, and uniqueItems treat int64(3) and 3.0 as
+// the same value.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-json-experiment/json"
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// ErrInvalidSchema reports a schema that is not valid JSON or uses a
+// supported keyword incorrectly.
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// Schema is a compiled schema. It is safe for concurrent use.
+type Schema struct {
+	root *node
+}
+
+var _ jsonpatch.Validator = (*Schema)(nil)
+
+// Compile compiles a JSON Schema document.
+func Compile(data []byte) (*Schema, error) {
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+	c := &compiler{document: document, nodes: map[string]*node{}}
+	root, err := c.compile(document, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.resolveRefs(); err != nil {
+		return nil, err
+	}
+	if err := c.checkCycles(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// Validate reports the first value of doc the schema rejects as a
+// *jsonpatch.ValidationError, or nil when doc is valid.
+func (s *Schema) Validate(doc any) error {
+	if err := s.root.validate(doc, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// node is a compiled schema or subschema.
+type node struct {
+	pointer []string
+	// boolean is set for the true and false schemas.
+	boolean *bool
+
+	types    []string
+	enum     []any
+	hasEnum  bool
+	constVal any
+	hasConst bool
+
+	properties           []property
+	patternProperties    []patternProperty
+	additionalProperties *node
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+
+	prefixItems []*node
+	items       *node
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+
+	ref    string
+	target *node
+}
+
+type property struct {
+	name   string
+	schema *node
+}
+
+type patternProperty struct {
+	pattern *regexp.Regexp
+	schema  *node
+}
+
+type compiler struct {
+	document any
+	nodes    map[string]*node
+	refs     []*node
+}
+
+func (c *compiler) compile(value any, pointer []string) (*node, error) {
+	key := jsonpointer.Format(pointer...)
+	if compiled, ok := c.nodes[key]; ok {
+		return compiled, nil
+	}
+	n := &node{pointer: pointer}
+	c.nodes[key] = n
+
+	switch typed := value.(type) {
+	case bool:
+		n.boolean = &typed
+		return n, nil
+	case map[string]any:
+		return n, c.compileKeywords(n, typed)
+	default:
+		return nil, c.invalid(pointer, "schema must be an object or a boolean")
+	}
+}
+
+func (c *compiler) compileKeywords(n *node, keywords map[string]any) error {
+	if defs, ok := keywords["$defs"]; ok {
+		members, ok := defs.(map[string]any)
+		if !ok {
+			return c.invalid(n.keyword("$defs"), "must be an object")
+		}
+		for _, name := range sortedKeys(members) {
+			if _, err := c.compile(members[name], n.keyword("$defs", name)); err != nil {
+				return err
+			}
+		}
+	}
+	if ref, ok := keywords["$ref"]; ok {
+		target, ok := ref.(string)
+		if !ok || !strings.HasPrefix(target, "#") {
+			return c.invalid(n.keyword("$ref"), "must be a JSON Pointer fragment of this schema")
+		}
+		n.ref = target
+		c.refs = append(c.refs, n)
+	}
+
+	if err := c.compileType(n, keywords); err != nil {
+		return err
+	}
+	if value, ok := keywords["enum"]; ok {
+		values, ok := value.([]any)
+		if !ok {
+			return c.invalid(n.keyword("enum"), "must be an array")
+		}
+		n.enum, n.hasEnum = values, true
+	}
+	if value, ok := keywords["const"]; ok {
+		n.constVal, n.hasConst = value, true
+	}
+	if err := c.compileObject(n, keywords); err != nil {
+		return err
+	}
+	if err := c.compileArray(n, keywords); err != nil {
+		return err
+	}
+	if err := c.compileString(n, keywords); err != nil {
+		return err
+	}
+	if err := c.compileNumber(n, keywords); err != nil {
+		return err
+	}
+	return c.compileApplicators(n, keywords)
+}
+
+func (c *compiler) compileType(n *node, keywords map[string]any) error {
+	value, ok := keywords["type"]
+	if !ok {
+		return nil
+	}
+	switch typed := value.(type) {
+	case string:
+		n.types = []string{typed}
+	case []any:
+		for _, item := range typed {
+			name, ok := item.(string)
+			if !ok {
+				return c.invalid(n.keyword("type"), "must be a string or an array of strings")
+			}
+			n.types = append(n.types, name)
+		}
+	default:
+		return c.invalid(n.keyword("type"), "must be a string or an array of strings")
+	}
+	for _, name := range n.types {
+		if !internal.IsValidJSONPatchType(name) {
+			return c.invalid(n.keyword("type"), fmt.Sprintf("unknown type %q", name))
+		}
+	}
+	return nil
+}
+
+func (c *compiler) compileObject(n *node, keywords map[string]any) error {
+	if value, ok := keywords["properties"]; ok {
+		members, ok := value.(map[string]any)
+		if !ok {
+			return c.invalid(n.keyword("properties"), "must be an object")
+		}
+		for _, name := range sortedKeys(members) {
+			schema, err := c.compile(members[name], n.keyword("properties", name))
+			if err != nil {
+				return err
+			}
+			n.properties = append(n.properties, property{name: name, schema: schema})
+		}
+	}
+	if value, ok := keywords["patternProperties"]; ok {
+		members, ok := value.(map[string]any)
+		if !ok {
+			return c.invalid(n.keyword("patternProperties"), "must be an object")
+		}
+		for _, pattern := range sortedKeys(members) {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return c.invalid(n.keyword("patternProperties", pattern), err.Error())
+			}
+			schema, err := c.compile(members[pattern], n.keyword("patternProperties", pattern))
+			if err != nil {
+				return err
+			}
+			n.patternProperties = append(n.patternProperties, patternProperty{pattern: re, schema: schema})
+		}
+	}
+	if value, ok := keywords["additionalProperties"]; ok {
+		schema, err := c.compile(value, n.keyword("additionalProperties"))
+		if err != nil {
+			return err
+		}
+		n.additionalProperties = schema
+	}
+	if value, ok := keywords["required"]; ok {
+		names, ok := value.([]any)
+		if !ok {
+			return c.invalid(n.keyword("required"), "must be an array of strings")
+		}
+		for _, item := range names {
+			name, ok := item.(string)
+			if !ok {
+				return c.invalid(n.keyword("required"), "must be an array of strings")
+			}
+			n.required = append(n.required, name)
+		}
+	}
+	var err error
+	if n.minProperties, err = c.count(n, keywords, "minProperties"); err != nil {
+		return err
+	}
+	n.maxProperties, err = c.count(n, keywords, "maxProperties")
+	return err
+}
+
+func (c *compiler) compileArray(n *node, keywords map[string]any) error {
+	if value, ok := keywords["prefixItems"]; ok {
+		schemas, err := c.compileList(n, value, "prefixItems")
+		if err != nil {
+			return err
+		}
+		n.prefixItems = schemas
+	}
+	if value, ok := keywords["items"]; ok {
+		schema, err := c.compile(value, n.keyword("items"))
+		if err != nil {
+			return err
+		}
+		n.items = schema
+	}
+	if value, ok := keywords["uniqueItems"]; ok {
+		unique, ok := value.(bool)
+		if !ok {
+			return c.invalid(n.keyword("uniqueItems"), "must be a boolean")
+		}
+		n.uniqueItems = unique
+	}
+	var err error
+	if n.minItems, err = c.count(n, keywords, "minItems"); err != nil {
+		return err
+	}
+	n.maxItems, err = c.count(n, keywords, "maxItems")
+	return err
+}
+
+func (c *compiler) compileString(n *node, keywords map[string]any) error {
+	if value, ok := keywords["pattern"]; ok {
+		pattern, ok := value.(string)
+		if !ok {
+			return c.invalid(n.keyword("pattern"), "must be a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return c.invalid(n.keyword("pattern"), err.Error())
+		}
+		n.pattern = re
+	}
+	var err error
+	if n.minLength, err = c.count(n, keywords, "minLength"); err != nil {
+		return err
+	}
+	n.maxLength, err = c.count(n, keywords, "maxLength")
+	return err
+}
+
+func (c *compiler) compileNumber(n *node, keywords map[string]any) error {
+	limits := []struct {
+		keyword string
+		target  **float64
+	}{
+		{"minimum", &n.minimum},
+		{"maximum", &n.maximum},
+		{"exclusiveMinimum", &n.exclusiveMinimum},
+		{"exclusiveMaximum", &n.exclusiveMaximum},
+		{"multipleOf", &n.multipleOf},
+	}
+	for _, limit := range limits {
+		value, ok := keywords[limit.keyword]
+		if !ok {
+			continue
+		}
+		number, ok := internal.NumericValue(value)
+		if !ok {
+			return c.invalid(n.keyword(limit.keyword), "must be a number")
+		}
+		*limit.target = &number
+	}
+	if n.multipleOf != nil && *n.multipleOf <= 0 {
+		return c.invalid(n.keyword("multipleOf"), "must be greater than 0")
+	}
+	return nil
+}
+
+func (c *compiler) compileApplicators(n *node, keywords map[string]any) error {
+	lists := []struct {
+		keyword string
+		target  *[]*node
+	}{
+		{"allOf", &n.allOf},
+		{"anyOf", &n.anyOf},
+		{"oneOf", &n.oneOf},
+	}
+	for _, list := range lists {
+		value, ok := keywords[list.keyword]
+		if !ok {
+			continue
+		}
+		schemas, err := c.compileList(n, value, list.keyword)
+		if err != nil {
+			return err
+		}
+		if len(schemas) == 0 {
+			return c.invalid(n.keyword(list.keyword), "must not be empty")
+		}
+		*list.target = schemas
+	}
+	if value, ok := keywords["not"]; ok {
+		schema, err := c.compile(value, n.keyword("not"))
+		if err != nil {
+			return err
+		}
+		n.not = schema
+	}
+	return nil
+}
+
+func (c *compiler) compileList(n *node, value any, keyword string) ([]*node, error) {
+	items, ok := value.([]any)
+	if !ok {
+		return nil, c.invalid(n.keyword(keyword), "must be an array of schemas")
+	}
+	schemas := make([]*node, len(items))
+	for i, item := range items {
+		schema, err := c.compile(item, n.keyword(keyword, fmt.Sprint(i)))
+		if err != nil {
+			return nil, err
+		}
+		schemas[i] = schema
+	}
+	return schemas, nil
+}
+
+func (c *compiler) count(n *node, keywords map[string]any, keyword string) (*int, error) {
+	value, ok := keywords[keyword]
+	if !ok {
+		return nil, nil
+	}
+	number, ok := internal.NumericValue(value)
+	if !ok || number < 0 || number != math.Trunc(number) {
+		return nil, c.invalid(n.keyword(keyword), "must be a non-negative integer")
+	}
+	count := int(number)
+	return &count, nil
+}
+
+// resolveRefs links each $ref to the subschema it names, compiling
+// subschemas that live outside the keywords the compiler walks.
+func (c *compiler) resolveRefs() error {
+	for i := 0; i < len(c.refs); i++ {
+		n := c.refs[i]
+		fragment := strings.TrimPrefix(n.ref, "#")
+		if err := jsonpointer.Validate(fragment); err != nil {
+			return c.invalid(n.keyword("$ref"), err.Error())
+		}
+		pointer := []string(jsonpointer.Parse(fragment))
+		value, err := jsonpointer.Get(c.document, pointer...)
+		if err != nil {
+			return c.invalid(n.keyword("$ref"), fmt.Sprintf("%q not found", n.ref))
+		}
+		target, err := c.compile(value, pointer)
+		if err != nil {
+			return err
+		}
+		n.target = target
+	}
+	return nil
+}
+
+// checkCycles rejects $ref chains that lead back to a schema without
+// descending into the instance, such as {"$ref":"#"}; validating against one
+// would never terminate.
+func (c *compiler) checkCycles() error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[*node]int{}
+	var stack []*node
+	var visit func(n *node) error
+	visit = func(n *node) error {
+		switch state[n] {
+		case done:
+			return nil
+		case visiting:
+			start := slices.Index(stack, n)
+			for _, member := range stack[start:] {
+				if member.ref != "" {
+					return c.invalid(member.keyword("$ref"), "refers back to itself without descending into the instance")
+				}
+			}
+			return c.invalid(n.pointer, "refers back to itself without descending into the instance")
+		}
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, next := range n.inPlace() {
+			if err := visit(next); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		return nil
+	}
+	for _, key := range slices.Sorted(maps.Keys(c.nodes)) {
+		if err := visit(c.nodes[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) invalid(pointer []string, message string) error {
+	return fmt.Errorf("%w: %q: %s", ErrInvalidSchema, jsonpointer.Format(pointer...), message)
+}
+
+// keyword returns the pointer of a keyword of n, or of a member below it.
+func (n *node) keyword(segments ...string) []string {
+	pointer := make([]string, 0, len(n.pointer)+len(segments))
+	pointer = append(pointer, n.pointer...)
+	return append(pointer, segments...)
+}
+
+// inPlace returns the subschemas n applies to the same instance it is
+// validating.
+func (n *node) inPlace() []*node {
+	var nodes []*node
+	if n.target != nil {
+		nodes = append(nodes, n.target)
+	}
+	nodes = append(nodes, n.allOf...)
+	nodes = append(nodes, n.anyOf...)
+	nodes = append(nodes, n.oneOf...)
+	if n.not != nil {
+		nodes = append(nodes, n.not)
+	}
+	return nodes
+}
+
+func (n *node) validate(value any, instance []string) *jsonpatch.ValidationError {
+	if n.boolean != nil {
+		if *n.boolean {
+			return nil
+		}
+		return n.fail(instance, nil, "no value is allowed")
+	}
+	if n.target != nil {
+		if err := n.target.validate(value, instance); err != nil {
+			return err
+		}
+	}
+	if len(n.types) > 0 && !matchesType(value, n.types) {
+		return n.fail(instance, []string{"type"}, fmt.Sprintf("%s is not %s", jsonType(value), strings.Join(n.types, " or ")))
+	}
+	if n.hasEnum && !containsValue(n.enum, value) {
+		return n.fail(instance, []string{"enum"}, "value is not one of the enumerated values")
+	}
+	if n.hasConst && !op.DeepEqual(n.constVal, value) {
+		return n.fail(instance, []string{"const"}, "value does not equal the constant")
+	}
+
+	var err *jsonpatch.ValidationError
+	switch typed := value.(type) {
+	case map[string]any:
+		err = n.validateObject(typed, instance)
+	case []any:
+		err = n.validateArray(typed, instance)
+	case string:
+		err = n.validateString(typed, instance)
+	default:
+		if number, ok := internal.NumericValue(value); ok {
+			err = n.validateNumber(number, instance)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return n.validateApplicators(value, instance)
+}
+
+func (n *node) validateObject(object map[string]any, instance []string) *jsonpatch.ValidationError {
+	for _, name := range n.required {
+		if _, ok := object[name]; !ok {
+			return n.fail(instance, []string{"required"}, fmt.Sprintf("missing property %q", name))
+		}
+	}
+	if n.minProperties != nil && len(object) < *n.minProperties {
+		return n.fail(instance, []string{"minProperties"}, fmt.Sprintf("has %d properties, fewer than %d", len(object), *n.minProperties))
+	}
+	if n.maxProperties != nil && len(object) > *n.maxProperties {
+		return n.fail(instance, []string{"maxProperties"}, fmt.Sprintf("has %d properties, more than %d", len(object), *n.maxProperties))
+	}
+	for _, name := range sortedKeys(object) {
+		member := append(instance[:len(instance):len(instance)], name)
+		evaluated := false
+		for _, p := range n.properties {
+			if p.name != name {
+				continue
+			}
+			evaluated = true
+			if err := p.schema.validate(object[name], member); err != nil {
+				return err
+			}
+		}
+		for _, p := range n.patternProperties {
+			if !p.pattern.MatchString(name) {
+				continue
+			}
+			evaluated = true
+			if err := p.schema.validate(object[name], member); err != nil {
+				return err
+			}
+		}
+		if !evaluated && n.additionalProperties != nil {
+			if err := n.additionalProperties.validate(object[name], member); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (n *node) validateArray(array []any, instance []string) *jsonpatch.ValidationError {
+	if n.minItems != nil && len(array) < *n.minItems {
+		return n.fail(instance, []string{"minItems"}, fmt.Sprintf("has %d items, fewer than %d", len(array), *n.minItems))
+	}
+	if n.maxItems != nil && len(array) > *n.maxItems {
+		return n.fail(instance, []string{"maxItems"}, fmt.Sprintf("has %d items, more than %d", len(array), *n.maxItems))
+	}
+	if n.uniqueItems {
+		for i := range array {
+			for j := range i {
+				if op.DeepEqual(array[i], array[j]) {
+					return n.fail(instance, []string{"uniqueItems"}, fmt.Sprintf("items %d and %d are equal", j, i))
+				}
+			}
+		}
+	}
+	for i, item := range array {
+		element := append(instance[:len(instance):len(instance)], fmt.Sprint(i))
+		schema := n.items
+		if i < len(n.prefixItems) {
+			schema = n.prefixItems[i]
+		}
+		if schema == nil {
+			continue
+		}
+		if err := schema.validate(item, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *node) validateString(value string, instance []string) *jsonpatch.ValidationError {
+	length := utf8.RuneCountInString(value)
+	if n.minLength != nil && length < *n.minLength {
+		return n.fail(instance, []string{"minLength"}, fmt.Sprintf("length %d is less than %d", length, *n.minLength))
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		return n.fail(instance, []string{"maxLength"}, fmt.Sprintf("length %d is greater than %d", length, *n.maxLength))
+	}
+	if n.pattern != nil && !n.pattern.MatchString(value) {
+		return n.fail(instance, []string{"pattern"}, fmt.Sprintf("does not match %q", n.pattern.String()))
+	}
+	return nil
+}
+
+func (n *node) validateNumber(value float64, instance []string) *jsonpatch.ValidationError {
+	switch {
+	case n.minimum != nil && value < *n.minimum:
+		return n.fail(instance, []string{"minimum"}, fmt.Sprintf("%v is less than %v", value, *n.minimum))
+	case n.maximum != nil && value > *n.maximum:
+		return n.fail(instance, []string{"maximum"}, fmt.Sprintf("%v is greater than %v", value, *n.maximum))
+	case n.exclusiveMinimum != nil && value <= *n.exclusiveMinimum:
+		return n.fail(instance, []string{"exclusiveMinimum"}, fmt.Sprintf("%v is not greater than %v", value, *n.exclusiveMinimum))
+	case n.exclusiveMaximum != nil && value >= *n.exclusiveMaximum:
+		return n.fail(instance, []string{"exclusiveMaximum"}, fmt.Sprintf("%v is not less than %v", value, *n.exclusiveMaximum))
+	}
+	if n.multipleOf != nil && !isMultipleOf(value, *n.multipleOf) {
+		return n.fail(instance, []string{"multipleOf"}, fmt.Sprintf("%v is not a multiple of %v", value, *n.multipleOf))
+	}
+	return nil
+}
+
+func (n *node) validateApplicators(value any, instance []string) *jsonpatch.ValidationError {
+	for _, schema := range n.allOf {
+		if err := schema.validate(value, instance); err != nil {
+			return err
+		}
+	}
+	if len(n.anyOf) > 0 && !anyValid(n.anyOf, value, instance) {
+		return n.fail(instance, []string{"anyOf"}, "value matches none of the schemas")
+	}
+	if len(n.oneOf) > 0 {
+		matched := 0
+		for _, schema := range n.oneOf {
+			if schema.validate(value, instance) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return n.fail(instance, []string{"oneOf"}, fmt.Sprintf("value matches %d of the schemas, not exactly one", matched))
+		}
+	}
+	if n.not != nil && n.not.validate(value, instance) == nil {
+		return n.fail(instance, []string{"not"}, "value matches the schema it must not match")
+	}
+	return nil
+}
+
+// isMultipleOf reports whether value is an integer multiple of divisor. Both
+// are compared as the shortest decimals that round-trip to them, so 19.99 is
+// a multiple of 0.01 even though their float64 quotient is not an integer.
+func isMultipleOf(value, divisor float64) bool {
+	v, ok := new(big.Rat).SetString(strconv.FormatFloat(value, 'g', -1, 64))
+	if !ok {
+		return false
+	}
+	d, ok := new(big.Rat).SetString(strconv.FormatFloat(divisor, 'g', -1, 64))
+	if !ok {
+		return false
+	}
+	return v.Quo(v, d).IsInt()
+}
+
+// anyValid reports whether value is valid against one of schemas.
+func anyValid(schemas []*node, value any, instance []string) bool {
+	for _, schema := range schemas {
+		if schema.validate(value, instance) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *node) fail(instance, keyword []string, message string) *jsonpatch.ValidationError {
+	return &jsonpatch.ValidationError{
+		InstancePointer: jsonpointer.Format(instance...),
+		SchemaPointer:   jsonpointer.Format(n.keyword(keyword...)...),
+		Message:         message,
+	}
+}
+
+// matchesType reports whether value has one of the JSON Schema types. An
+// integer is also a number.
+func matchesType(value any, types []string) bool {
+	actual := jsonType(value)
+	for _, name := range types {
+		if name == actual || (name == string(internal.JSONPatchTypeNumber) && actual == string(internal.JSONPatchTypeInteger)) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value any) string {
+	return string(internal.GetJSONPatchType(value))
+}
+
+func containsValue(values []any, value any) bool {
+	for _, candidate := range values {
+		if op.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(members map[string]any) []string {
+	return slices.Sorted(maps.Keys(members))
+}
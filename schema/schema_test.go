@@ -0,0 +1,177 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-json-experiment/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		schema       string
+		doc          string
+		wantInstance string
+		wantSchema   string
+	}{
+		{name: "true schema", schema: `true`, doc: `{"a":1}`},
+		{name: "false schema", schema: `{"properties":{"a":false}}`, doc: `{"a":1}`, wantInstance: "/a", wantSchema: "/properties/a"},
+		{name: "type", schema: `{"type":"object"}`, doc: `[]`, wantInstance: "", wantSchema: "/type"},
+		{name: "integer is a number", schema: `{"type":"number"}`, doc: `3`},
+		{name: "type list", schema: `{"type":["string","null"]}`, doc: `1.5`, wantSchema: "/type"},
+		{name: "integer", schema: `{"type":"integer"}`, doc: `1.5`, wantSchema: "/type"},
+		{name: "enum", schema: `{"enum":["a",1,{"b":[2]}]}`, doc: `{"b":[2]}`},
+		{name: "enum mismatch", schema: `{"enum":["a"]}`, doc: `"b"`, wantSchema: "/enum"},
+		{name: "const", schema: `{"const":3}`, doc: `3.0`},
+		{name: "const mismatch", schema: `{"const":3}`, doc: `4`, wantSchema: "/const"},
+		{
+			name:         "required",
+			schema:       `{"properties":{"user":{"required":["name"]}}}`,
+			doc:          `{"user":{"age":3}}`,
+			wantInstance: "/user",
+			wantSchema:   "/properties/user/required",
+		},
+		{
+			name:         "additional properties",
+			schema:       `{"properties":{"a":true},"patternProperties":{"^x-":true},"additionalProperties":false}`,
+			doc:          `{"a":1,"x-b":2,"c":3}`,
+			wantInstance: "/c",
+			wantSchema:   "/additionalProperties",
+		},
+		{
+			name:         "pattern properties",
+			schema:       `{"patternProperties":{"^n_":{"type":"number"}}}`,
+			doc:          `{"n_a":"x"}`,
+			wantInstance: "/n_a",
+			wantSchema:   "/patternProperties/^n_/type",
+		},
+		{name: "min properties", schema: `{"minProperties":2}`, doc: `{"a":1}`, wantSchema: "/minProperties"},
+		{name: "max properties", schema: `{"maxProperties":1}`, doc: `{"a":1,"b":2}`, wantSchema: "/maxProperties"},
+		{
+			name:         "items",
+			schema:       `{"items":{"type":"string"}}`,
+			doc:          `["a",2]`,
+			wantInstance: "/1",
+			wantSchema:   "/items/type",
+		},
+		{
+			name:         "prefix items",
+			schema:       `{"prefixItems":[{"type":"string"}],"items":{"type":"number"}}`,
+			doc:          `["a",1,"b"]`,
+			wantInstance: "/2",
+			wantSchema:   "/items/type",
+		},
+		{name: "min items", schema: `{"minItems":1}`, doc: `[]`, wantSchema: "/minItems"},
+		{name: "max items", schema: `{"maxItems":1}`, doc: `[1,2]`, wantSchema: "/maxItems"},
+		{name: "unique items", schema: `{"uniqueItems":true}`, doc: `[1,{"a":1},{"a":1}]`, wantSchema: "/uniqueItems"},
+		{name: "min length counts code points", schema: `{"minLength":2}`, doc: `"é"`, wantSchema: "/minLength"},
+		{name: "max length", schema: `{"maxLength":2}`, doc: `"abc"`, wantSchema: "/maxLength"},
+		{name: "pattern", schema: `{"pattern":"^[a-z]+$"}`, doc: `"abc1"`, wantSchema: "/pattern"},
+		{name: "minimum", schema: `{"minimum":0}`, doc: `-1`, wantSchema: "/minimum"},
+		{name: "maximum", schema: `{"maximum":10}`, doc: `10`},
+		{name: "exclusive minimum", schema: `{"exclusiveMinimum":0}`, doc: `0`, wantSchema: "/exclusiveMinimum"},
+		{name: "exclusive maximum", schema: `{"exclusiveMaximum":10}`, doc: `10`, wantSchema: "/exclusiveMaximum"},
+		{name: "multiple of", schema: `{"multipleOf":0.5}`, doc: `1.25`, wantSchema: "/multipleOf"},
+		{name: "decimal multiple of", schema: `{"items":{"multipleOf":0.01}}`, doc: `[19.99,8.95,0.07,100]`},
+		{name: "decimal not a multiple of", schema: `{"multipleOf":0.01}`, doc: `0.075`, wantSchema: "/multipleOf"},
+		{name: "all of", schema: `{"allOf":[{"type":"number"},{"minimum":5}]}`, doc: `3`, wantSchema: "/allOf/1/minimum"},
+		{name: "any of", schema: `{"anyOf":[{"type":"string"},{"type":"null"}]}`, doc: `3`, wantSchema: "/anyOf"},
+		{name: "one of", schema: `{"oneOf":[{"type":"number"},{"type":"integer"}]}`, doc: `3`, wantSchema: "/oneOf"},
+		{name: "not", schema: `{"not":{"type":"null"}}`, doc: `null`, wantSchema: "/not"},
+		{
+			name:         "ref",
+			schema:       `{"$defs":{"age":{"type":"integer","minimum":0}},"properties":{"age":{"$ref":"#/$defs/age"}}}`,
+			doc:          `{"age":-1}`,
+			wantInstance: "/age",
+			wantSchema:   "/$defs/age/minimum",
+		},
+		{
+			name:         "recursive ref",
+			schema:       `{"properties":{"name":{"type":"string"},"children":{"items":{"$ref":"#"}}}}`,
+			doc:          `{"name":"a","children":[{"name":"b","children":[{"name":3}]}]}`,
+			wantInstance: "/children/0/children/0/name",
+			wantSchema:   "/properties/name/type",
+		},
+		{name: "unknown keywords are ignored", schema: `{"format":"email","title":"x"}`, doc: `"not an email"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schema, err := Compile([]byte(tt.schema))
+			require.NoError(t, err)
+			var doc any
+			require.NoError(t, json.Unmarshal([]byte(tt.doc), &doc))
+
+			err = schema.Validate(doc)
+			if tt.wantSchema == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var validationErr *jsonpatch.ValidationError
+			require.True(t, errors.As(err, &validationErr), "error %v", err)
+			assert.Equal(t, tt.wantInstance, validationErr.InstancePointer)
+			assert.Equal(t, tt.wantSchema, validationErr.SchemaPointer)
+			assert.NotEmpty(t, validationErr.Message)
+		})
+	}
+}
+
+func TestValidateGoValues(t *testing.T) {
+	t.Parallel()
+
+	schema, err := Compile([]byte(`{"properties":{"n":{"type":"integer","maximum":5}},"required":["n"]}`))
+	require.NoError(t, err)
+
+	require.NoError(t, schema.Validate(map[string]any{"n": int64(5)}))
+	require.Error(t, schema.Validate(map[string]any{"n": uint8(6)}))
+	require.Error(t, schema.Validate(map[string]any{}))
+	require.NoError(t, schema.Validate(map[string]any{"n": jsonpatch.Number("5.0")}))
+	require.Error(t, schema.Validate(map[string]any{"n": jsonpatch.Number("4.5")}))
+}
+
+func TestCompileInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{name: "not json", schema: `{`},
+		{name: "not a schema", schema: `3`},
+		{name: "unknown type", schema: `{"type":"text"}`},
+		{name: "bad type list", schema: `{"type":["string",1]}`},
+		{name: "bad properties", schema: `{"properties":[]}`},
+		{name: "bad subschema", schema: `{"properties":{"a":"string"}}`},
+		{name: "bad required", schema: `{"required":"a"}`},
+		{name: "negative count", schema: `{"minItems":-1}`},
+		{name: "fractional count", schema: `{"maxLength":1.5}`},
+		{name: "bad pattern", schema: `{"pattern":"("}`},
+		{name: "bad pattern property", schema: `{"patternProperties":{"(":true}}`},
+		{name: "bad minimum", schema: `{"minimum":"0"}`},
+		{name: "zero multiple of", schema: `{"multipleOf":0}`},
+		{name: "empty any of", schema: `{"anyOf":[]}`},
+		{name: "remote ref", schema: `{"$ref":"https://example.com/schema"}`},
+		{name: "missing ref", schema: `{"$ref":"#/$defs/missing"}`},
+		{name: "self ref", schema: `{"$ref":"#"}`},
+		{name: "self ref in defs", schema: `{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`},
+		{name: "ref cycle through applicators", schema: `{"$defs":{"a":{"anyOf":[{"type":"null"},{"$ref":"#/$defs/b"}]},"b":{"not":{"$ref":"#/$defs/a"}}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Compile([]byte(tt.schema))
+			assert.ErrorIs(t, err, ErrInvalidSchema)
+		})
+	}
+}
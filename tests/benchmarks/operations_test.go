@@ -342,6 +342,37 @@ func BenchmarkApplyInPlaceVsApply(b *testing.B) {
 	})
 }
 
+type benchmarkService struct {
+	Name     string            `json:"name"`
+	Replicas int               `json:"replicas"`
+	Labels   map[string]string `json:"labels"`
+}
+
+type benchmarkConfig struct {
+	Version  int                `json:"version"`
+	Services []benchmarkService `json:"services"`
+}
+
+func BenchmarkApplyStruct(b *testing.B) {
+	doc := benchmarkConfig{Version: 1, Services: make([]benchmarkService, 200)}
+	for i := range doc.Services {
+		doc.Services[i] = benchmarkService{Name: "svc", Replicas: i, Labels: map[string]string{"team": "core"}}
+	}
+
+	patch := compileBenchmarkPatch(b, []jsoncodec.Operation{
+		{Op: "replace", Path: "/version", Value: 2},
+		{Op: "replace", Path: "/services/10/replicas", Value: 3},
+		{Op: "add", Path: "/services/10/labels/tier", Value: "gold"},
+	})
+
+	b.ResetTimer()
+	for b.Loop() {
+		if _, err := jsonpatch.Apply(patch, doc); err != nil {
+			b.Fatalf("Apply failed: %v", err)
+		}
+	}
+}
+
 func compileBenchmarkPatch(b testing.TB, operations []jsoncodec.Operation) *jsonpatch.Patch {
 	b.Helper()
 	patch, err := jsonpatch.CompileOperations(operations, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
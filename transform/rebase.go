@@ -0,0 +1,479 @@
+package transform
+
+import (
+	"slices"
+	"strconv"
+)
+
+// fate describes what happened to a location after a concurrent operation.
+type fate int
+
+const (
+	// fateKept means the location still exists, possibly at a shifted path.
+	fateKept fate = iota
+	// fateGone means an ancestor of the location was removed or replaced.
+	fateGone
+	// fateRemoved means the location itself was removed.
+	fateRemoved
+	// fateOverwritten means a new value was written at the location itself.
+	fateOverwritten
+)
+
+// checkConflict rejects pairs whose result depends on values that only the
+// base document holds.
+func checkConflict(x, y change) error {
+	if y.kind == kindScoped {
+		for _, path := range x.touches() {
+			if related(path, y.scope()) {
+				return conflictError(x, y)
+			}
+		}
+	}
+	if x.kind == kindCopy {
+		for _, path := range y.writes() {
+			if related(path, x.from) {
+				return conflictError(x, y)
+			}
+		}
+	}
+	if x.kind == kindMove && overwrites(y, x.from) {
+		// The moved value is replaced, so neither order keeps both values.
+		return conflictError(x, y)
+	}
+	if appends(x) && appends(y) && slices.Equal(x.target(), y.target()) {
+		// The order of two appends depends on the length of the array.
+		return conflictError(x, y)
+	}
+	if x.kind == kindMove && y.kind == kindMove && slices.Equal(x.from, y.from) {
+		// One move loses; its destination write cannot be undone when it
+		// replaced an object member, and "-" leaves the element untraceable.
+		if !isPosition(x.path) || !isPosition(y.path) || last(x.path) == "-" || last(y.path) == "-" {
+			return conflictError(x, y)
+		}
+	}
+	if isEdit(x.kind) && isEdit(y.kind) && slices.Equal(x.path, y.path) {
+		switch {
+		case isText(x.kind) && isText(y.kind):
+			if x.pos < 0 || y.pos < 0 {
+				return conflictError(x, y)
+			}
+		case x.kind == kindUpdate && y.kind == kindUpdate && x.op.Op() == y.op.Op():
+		default:
+			return conflictError(x, y)
+		}
+	}
+	return nil
+}
+
+// overwrites reports whether c writes a new value at exactly path.
+func overwrites(c change, path []string) bool {
+	switch c.kind {
+	case kindSet:
+		return slices.Equal(c.path, path)
+	case kindMove, kindCopy:
+		return !isPosition(c.path) && slices.Equal(c.target(), path)
+	}
+	return false
+}
+
+// appends reports whether c adds a value at the end of an array.
+func appends(c change) bool {
+	return (c.kind == kindInsert || c.kind == kindMove || c.kind == kindCopy) && last(c.path) == "-"
+}
+
+// rebase returns the operations that apply x after y has been applied. When
+// both write the same location, x wins if xWins is set.
+func rebase(x, y change, xWins bool) ([]change, error) {
+	switch x.kind {
+	case kindInsert, kindSet:
+		path, f, err := rebasePath(x.path, x.kind == kindInsert, y, xWins)
+		if err != nil {
+			return nil, err
+		}
+		if f == fateGone || f == fateRemoved || (f == fateOverwritten && !xWins) {
+			return nil, nil
+		}
+		x.path = path
+		return []change{x}, nil
+
+	case kindRemove:
+		path, f, err := rebasePath(x.path, false, y, xWins)
+		if err != nil {
+			return nil, err
+		}
+		if f == fateGone || f == fateRemoved {
+			return nil, nil
+		}
+		x.path = path
+		return []change{x}, nil
+
+	case kindMove:
+		return rebaseMove(x, y, xWins)
+
+	case kindCopy:
+		from, _, err := rebasePath(x.from, false, y, xWins)
+		if err != nil {
+			return nil, err
+		}
+		path, f, err := rebasePath(x.path, isPosition(x.path), y, xWins)
+		if err != nil {
+			return nil, err
+		}
+		if f == fateGone || f == fateRemoved || (f == fateOverwritten && !xWins) {
+			return nil, nil
+		}
+		if slices.Equal(from, path) {
+			// Copying onto the source is rejected when compiled.
+			return nil, conflictError(x, y)
+		}
+		x.from, x.path = from, path
+		return []change{x}, nil
+
+	case kindStrIns, kindStrDel, kindUpdate, kindRead, kindScoped:
+		path, f, err := rebasePath(x.path, false, y, xWins)
+		if err != nil {
+			return nil, err
+		}
+		if f != fateKept {
+			return nil, nil
+		}
+		if x.kind == kindRead && readsWrite(x.path, y) {
+			return nil, nil
+		}
+		sameText := isText(x.kind) && isText(y.kind) && slices.Equal(x.path, y.path)
+		x.path = path
+		if sameText {
+			return rebaseText(x, y, xWins), nil
+		}
+		return []change{x}, nil
+	}
+	return []change{x}, nil
+}
+
+// rebaseMove rebases a move. Its destination is addressed after the source
+// was removed, so it is mapped against y as seen after that removal.
+func rebaseMove(x, y change, xWins bool) ([]change, error) {
+	if y.kind == kindMove && slices.Equal(x.from, y.from) {
+		if !xWins {
+			return nil, nil
+		}
+		// Removing the element from where y put it restores the document x
+		// addressed its destination in.
+		x.from = y.path
+		return movable(x, y)
+	}
+
+	from, fromFate, err := rebasePath(x.from, false, y, xWins)
+	if err != nil {
+		return nil, err
+	}
+
+	removal := change{op: x.op, kind: kindRemove, path: x.from}
+	path, pathFate := x.path, fateKept
+	for _, effect := range afterRemoval(y, x.from) {
+		var f fate
+		if path, f, err = rebasePath(path, isPosition(x.path), effect, xWins); err != nil {
+			return nil, err
+		}
+		if f != fateKept {
+			pathFate = f
+		}
+	}
+
+	switch {
+	case fromFate == fateGone || fromFate == fateRemoved:
+		// The moved value is gone. That is consistent only when its
+		// destination is gone too, or when y removed exactly the moved
+		// element and the destination is an array position, because y
+		// rebased against x removes the element at its destination.
+		if pathFate == fateGone || (fromFate == fateRemoved && isPosition(x.path)) {
+			return nil, nil
+		}
+		return nil, conflictError(x, y)
+	case pathFate == fateGone || pathFate == fateRemoved || (pathFate == fateOverwritten && !xWins):
+		removal.path = from
+		return []change{removal}, nil
+	}
+	x.from, x.path = from, path
+	return movable(x, y)
+}
+
+// movable rejects a rebased move whose path starts with its source. The
+// destination is valid after the source is removed, but such a move is
+// rejected when compiled.
+func movable(x, y change) ([]change, error) {
+	if len(x.path) > len(x.from) && hasPrefix(x.path, x.from) {
+		return nil, conflictError(x, y)
+	}
+	return []change{x}, nil
+}
+
+// afterRemoval returns the structural effects of y as seen in a document
+// where removed is already gone. Effects inside the removed value vanish.
+func afterRemoval(y change, removed []string) []change {
+	mapped := func(path []string, position bool) ([]string, bool) {
+		return keptAfterRemove(path, position, removed)
+	}
+	switch y.kind {
+	case kindInsert, kindSet, kindRemove:
+		if path, ok := mapped(y.path, y.kind == kindInsert); ok {
+			y.path = path
+			return []change{y}
+		}
+	case kindCopy:
+		if path, ok := mapped(y.path, isPosition(y.path)); ok {
+			y.kind, y.path = destinationKind(path), path
+			return []change{y}
+		}
+	case kindMove:
+		from, fromOK := mapped(y.from, false)
+		// The destination of y is addressed after its source was removed,
+		// so removed is mapped into that document first. When removed is
+		// inside the moved value, the destination is unaffected.
+		path, pathOK := y.path, true
+		if inner, f, _ := shiftRemove(removed, false, y.from); f == fateKept {
+			path, pathOK = keptAfterRemove(y.path, isPosition(y.path), inner)
+		}
+		switch {
+		case fromOK && pathOK:
+			y.from, y.path = from, path
+			return []change{y}
+		case fromOK:
+			return []change{{op: y.op, kind: kindRemove, path: from}}
+		case pathOK:
+			return []change{{op: y.op, kind: destinationKind(path), path: path}}
+		}
+	}
+	return nil
+}
+
+// keptAfterRemove maps path through the removal of target and reports
+// whether it still exists.
+func keptAfterRemove(path []string, position bool, target []string) ([]string, bool) {
+	shifted, f, _ := shiftRemove(path, position, target)
+	return shifted, f == fateKept
+}
+
+// destinationKind classifies the write of a move or copy destination.
+func destinationKind(path []string) kind {
+	if isPosition(path) {
+		return kindInsert
+	}
+	return kindSet
+}
+
+// rebasePath maps a location of x through y. A position is the insertion
+// point of an add, move, or copy into an array rather than an element.
+func rebasePath(path []string, position bool, y change, xWins bool) ([]string, fate, error) {
+	switch y.kind {
+	case kindInsert, kindSet, kindCopy:
+		if isPosition(y.path) && y.kind != kindSet {
+			return shiftInsert(path, position, y.path, xWins), fateKept, nil
+		}
+		return overwrite(path, position, y.path)
+	case kindRemove:
+		return shiftRemove(path, position, y.path)
+	case kindMove:
+		if hasPrefix(path, y.from) && (!position || len(path) > len(y.from)) {
+			if last(y.path) == "-" {
+				return nil, fateKept, conflictError(change{op: y.op, path: path}, y)
+			}
+			return slices.Concat(y.path, path[len(y.from):]), fateKept, nil
+		}
+		path, _, _ = shiftRemove(path, position, y.from)
+		if isPosition(y.path) {
+			return shiftInsert(path, position, y.path, xWins), fateKept, nil
+		}
+		return overwrite(path, position, y.path)
+	case kindUpdate:
+		// flip turns a container into a boolean and inc rejects one, so
+		// nothing below the target survives either.
+		if len(path) > len(y.path) && hasPrefix(path, y.path) {
+			return nil, fateGone, nil
+		}
+	}
+	return path, fateKept, nil
+}
+
+// shiftInsert maps path through an insertion at target. Positions tied with
+// the insertion stay in front of it only when x wins.
+func shiftInsert(path []string, position bool, target []string, xWins bool) []string {
+	depth := len(target) - 1
+	inserted, ok := arrayIndex(target[depth])
+	if !ok || len(path) <= depth || !hasPrefix(path, target[:depth]) {
+		return path
+	}
+	index, ok := arrayIndex(path[depth])
+	if !ok || index < inserted {
+		return path
+	}
+	if index == inserted && position && len(path) == len(target) && xWins {
+		return path
+	}
+	return withIndex(path, depth, index+1)
+}
+
+// shiftRemove maps path through the removal of target.
+func shiftRemove(path []string, position bool, target []string) ([]string, fate, error) {
+	if hasPrefix(path, target) {
+		switch {
+		case position && len(path) == len(target):
+			return path, fateKept, nil
+		case len(path) == len(target):
+			return nil, fateRemoved, nil
+		default:
+			return nil, fateGone, nil
+		}
+	}
+	if len(target) == 0 {
+		return path, fateKept, nil
+	}
+	depth := len(target) - 1
+	removed, ok := arrayIndex(target[depth])
+	if !ok || len(path) <= depth || !hasPrefix(path, target[:depth]) {
+		return path, fateKept, nil
+	}
+	if index, ok := arrayIndex(path[depth]); ok && index > removed {
+		return withIndex(path, depth, index-1), fateKept, nil
+	}
+	return path, fateKept, nil
+}
+
+// overwrite maps path through a write of a whole value at target.
+func overwrite(path []string, position bool, target []string) ([]string, fate, error) {
+	if !hasPrefix(path, target) {
+		return path, fateKept, nil
+	}
+	switch {
+	case len(path) > len(target):
+		return nil, fateGone, nil
+	case position:
+		return path, fateKept, nil
+	default:
+		return path, fateOverwritten, nil
+	}
+}
+
+// readsWrite reports whether a predicate at path reads a value y changes.
+func readsWrite(path []string, y change) bool {
+	for _, written := range y.writes() {
+		if hasPrefix(written, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// rebaseText rebases a str_ins or str_del against a concurrent edit of the
+// same string.
+func rebaseText(x, y change, xWins bool) []change {
+	yLen := y.len
+	if y.kind == kindStrIns {
+		yLen = len([]rune(y.str))
+	}
+
+	switch {
+	case x.kind == kindStrIns && y.kind == kindStrIns:
+		if y.pos < x.pos || (y.pos == x.pos && !xWins) {
+			x.pos += yLen
+		}
+	case x.kind == kindStrIns:
+		if x.pos > y.pos {
+			x.pos = max(y.pos, x.pos-yLen)
+		}
+	case y.kind == kindStrIns:
+		switch {
+		case y.pos <= x.pos:
+			x.pos += yLen
+		case y.pos < x.pos+x.len:
+			// The insertion lands inside the deleted range, so x deletes
+			// the text on both sides of it, the later part first.
+			head := y.pos - x.pos
+			tail := x
+			tail.pos = y.pos + yLen
+			tail.len = x.len - head
+			x.len = head
+			if x.str != "" {
+				runes := []rune(x.str)
+				tail.str = string(runes[head:])
+				x.str = string(runes[:head])
+			}
+			return []change{tail, x}
+		}
+	default:
+		start := max(x.pos, y.pos)
+		end := min(x.pos+x.len, y.pos+yLen)
+		if overlap := end - start; overlap > 0 {
+			if x.str != "" {
+				runes := []rune(x.str)
+				x.str = string(runes[:start-x.pos]) + string(runes[end-x.pos:])
+			}
+			x.len -= overlap
+			if x.len == 0 {
+				return nil
+			}
+		}
+		switch {
+		case x.pos >= y.pos+yLen:
+			x.pos -= yLen
+		case x.pos > y.pos:
+			x.pos = y.pos
+		}
+	}
+	return []change{x}
+}
+
+func isEdit(k kind) bool {
+	return k == kindStrIns || k == kindStrDel || k == kindUpdate
+}
+
+func isText(k kind) bool {
+	return k == kindStrIns || k == kindStrDel
+}
+
+// isPosition reports whether path ends in an array position.
+func isPosition(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	if last(path) == "-" {
+		return true
+	}
+	_, ok := arrayIndex(last(path))
+	return ok
+}
+
+// arrayIndex parses an RFC 6901 array index token.
+func arrayIndex(token string) (int, bool) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+	for _, r := range token {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	index, err := strconv.Atoi(token)
+	return index, err == nil
+}
+
+func withIndex(path []string, depth, index int) []string {
+	shifted := slices.Clone(path)
+	shifted[depth] = strconv.Itoa(index)
+	return shifted
+}
+
+func last(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return path[len(path)-1]
+}
+
+func hasPrefix(path, prefix []string) bool {
+	return len(prefix) <= len(path) && slices.Equal(path[:len(prefix)], prefix)
+}
+
+func related(a, b []string) bool {
+	return hasPrefix(a, b) || hasPrefix(b, a)
+}
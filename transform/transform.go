@@ -0,0 +1,371 @@
+// Package transform rebases concurrent JSON patches for operational
+// transformation.
+//
+// Two patches produced against the same base document are transformed into a
+// pair that can be applied after each other in either order. Array positions
+// are recognized by their tokens: a pointer segment that is a decimal array
+// index or "-" addresses an array element, and any other segment addresses an
+// object member. Patches whose pointers are resolved against the document,
+// such as those using wildcards, JSONPath queries, or key segments, are not
+// transformable.
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/kaptinlin/jsonpointer"
+
+	"github.com/kaptinlin/jsonpatch"
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+var (
+	// ErrNilPatch reports a nil patch argument.
+	ErrNilPatch = errors.New("nil patch")
+	// ErrNotTransformable reports concurrent operations whose effects cannot
+	// be reconciled without the base document.
+	ErrNotTransformable = errors.New("operations not transformable")
+)
+
+// Transform rebases two concurrent patches compiled against the same base
+// document. Applying a and then bPrime yields the same document as applying b
+// and then aPrime.
+//
+// When both patches write the same location, a wins: its value is kept and
+// the conflicting write from b is dropped. Removals win over concurrent
+// writes to the removed value, and replacements win over in-place edits such
+// as str_ins or inc. A flip or inc drops concurrent writes below its target,
+// because it leaves a scalar there. Predicates that read a value the other patch changes are
+// dropped, because they guarded the base document.
+//
+// Transform fails with ErrNotTransformable when reconciling two operations
+// would need values from the base document, for example a copy whose source
+// the other patch modifies, and for operations compiled with the Wildcard,
+// JSONPath, or KeySegments capability, whose array positions only the base
+// document knows.
+//
+// The rebased patches are compiled with every capability and then opts, which
+// should be the options a and b were compiled with. Predicates rebuilt at a
+// shifted path use the matcher set with WithCompileMatcher.
+func Transform(a, b *jsonpatch.Patch, opts ...jsonpatch.CompileOption) (aPrime, bPrime *jsonpatch.Patch, err error) {
+	if a == nil || b == nil {
+		return nil, nil, ErrNilPatch
+	}
+	if err := checkPositional(a); err != nil {
+		return nil, nil, err
+	}
+	if err := checkPositional(b); err != nil {
+		return nil, nil, err
+	}
+	as, err := changesOf(a.Ops())
+	if err != nil {
+		return nil, nil, err
+	}
+	bs, err := changesOf(b.Ops())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	as, bs, err = transformLists(as, bs)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = append([]jsonpatch.CompileOption{jsonpatch.WithCapabilities(jsonpatch.AllCapabilities)}, opts...)
+	if aPrime, err = compile(as, opts); err != nil {
+		return nil, nil, err
+	}
+	if bPrime, err = compile(bs, opts); err != nil {
+		return nil, nil, err
+	}
+	return aPrime, bPrime, nil
+}
+
+// checkPositional rejects operations whose pointers are resolved against the
+// document when the patch is applied.
+func checkPositional(p *jsonpatch.Patch) error {
+	const resolved = jsonpatch.Wildcard | jsonpatch.JSONPath | jsonpatch.KeySegments
+	for i, analysis := range p.Analyze().Ops {
+		if analysis.Capabilities&resolved != 0 {
+			return fmt.Errorf("%w: operation %d is resolved against the document", ErrNotTransformable, i)
+		}
+	}
+	return nil
+}
+
+// transformLists rebases a against b and b against a. Operations from a win
+// ties.
+func transformLists(a, b []change) (aPrime, bPrime []change, err error) {
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return a, b, nil
+	case len(a) == 1 && len(b) == 1:
+		return transformPair(a[0], b[0])
+	case len(a) > 1:
+		bPrime = b
+		for _, x := range a {
+			var xs []change
+			if xs, bPrime, err = transformLists([]change{x}, bPrime); err != nil {
+				return nil, nil, err
+			}
+			aPrime = append(aPrime, xs...)
+		}
+		return aPrime, bPrime, nil
+	default:
+		aPrime = a
+		for _, y := range b {
+			var ys []change
+			if aPrime, ys, err = transformLists(aPrime, []change{y}); err != nil {
+				return nil, nil, err
+			}
+			bPrime = append(bPrime, ys...)
+		}
+		return aPrime, bPrime, nil
+	}
+}
+
+func transformPair(x, y change) ([]change, []change, error) {
+	if err := checkConflict(x, y); err != nil {
+		return nil, nil, err
+	}
+	if err := checkConflict(y, x); err != nil {
+		return nil, nil, err
+	}
+	xs, err := rebase(x, y, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	ys, err := rebase(y, x, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return xs, ys, nil
+}
+
+type kind int
+
+const (
+	// kindInsert adds a value at an array position.
+	kindInsert kind = iota
+	// kindSet writes a whole value: add to an object member or the root, or replace.
+	kindSet
+	kindRemove
+	kindMove
+	kindCopy
+	kindStrIns
+	kindStrDel
+	// kindUpdate edits a value in place with inc or flip. Locations below it
+	// are gone afterwards, as under a replace.
+	kindUpdate
+	// kindRead is a predicate.
+	kindRead
+	// kindScoped is an operation that cannot be rebased against operations
+	// inside its scope: extend, merge_patch, split, merge, and composite
+	// predicates.
+	kindScoped
+)
+
+// change is the working form of one operation. Paths, text positions, and
+// lengths are rewritten while rebasing; payloads stay on op.
+type change struct {
+	op   jsonpatch.Op
+	kind kind
+	path []string
+	from []string
+	pos  int
+	str  string
+	len  int
+}
+
+func changesOf(ops []jsonpatch.Op) ([]change, error) {
+	changes := make([]change, len(ops))
+	for i, operation := range ops {
+		c, err := newChange(operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		changes[i] = c
+	}
+	return changes, nil
+}
+
+func newChange(operation jsonpatch.Op) (change, error) {
+	c := change{op: operation, path: operation.Path()}
+	switch typed := operation.(type) {
+	case *op.AddOperation:
+		c.kind = kindSet
+		if isPosition(c.path) {
+			c.kind = kindInsert
+		}
+	case *op.ReplaceOperation:
+		c.kind = kindSet
+	case *op.RemoveOperation:
+		c.kind = kindRemove
+	case *op.MoveOperation:
+		c.kind = kindMove
+		c.from = typed.From()
+	case *op.CopyOperation:
+		c.kind = kindCopy
+		c.from = typed.From()
+	case *op.StrInsOperation:
+		c.kind = kindStrIns
+		c.pos = typed.Pos
+		c.str = typed.Str
+	case *op.StrDelOperation:
+		c.kind = kindStrDel
+		c.pos = typed.Pos
+		c.len = typed.Len
+		if typed.HasStr {
+			c.str = typed.Str
+			c.len = utf8.RuneCountInString(typed.Str)
+		}
+	case *op.IncOperation, *op.FlipOperation:
+		c.kind = kindUpdate
+	case *op.ExtendOperation, *op.MergePatchOperation, *op.SplitOperation, *op.MergeOperation,
+		internal.SecondOrderPredicateOp:
+		c.kind = kindScoped
+	case internal.PredicateOp:
+		c.kind = kindRead
+	default:
+		return change{}, fmt.Errorf("%w: unsupported operation %q", ErrNotTransformable, operation.Op())
+	}
+	return c, nil
+}
+
+// scope returns the locations a scoped operation depends on. Splitting an
+// array element inserts a sibling, so the whole array is in scope.
+func (c change) scope() []string {
+	if _, ok := c.op.(*op.SplitOperation); ok && isPosition(c.path) {
+		return c.path[:len(c.path)-1]
+	}
+	return c.path
+}
+
+// target returns the destination of c in the document c applies to. The path
+// of a move is addressed after its source was removed, so an array index
+// after the source is shifted back.
+func (c change) target() []string {
+	if c.kind != kindMove {
+		return c.path
+	}
+	depth := len(c.from) - 1
+	removed, ok := arrayIndex(last(c.from))
+	if !ok || len(c.path) <= depth || !hasPrefix(c.path, c.from[:depth]) {
+		return c.path
+	}
+	if index, ok := arrayIndex(c.path[depth]); ok && index >= removed {
+		return withIndex(c.path, depth, index+1)
+	}
+	return c.path
+}
+
+// writes returns the locations whose value c changes.
+func (c change) writes() [][]string {
+	switch c.kind {
+	case kindRead:
+		return nil
+	case kindMove:
+		return [][]string{c.from, c.target()}
+	case kindScoped:
+		return [][]string{c.scope()}
+	default:
+		return [][]string{c.path}
+	}
+}
+
+// touches returns every location c reads or writes.
+func (c change) touches() [][]string {
+	switch c.kind {
+	case kindMove, kindCopy:
+		return [][]string{c.from, c.target()}
+	case kindScoped:
+		return [][]string{c.scope()}
+	default:
+		return [][]string{c.path}
+	}
+}
+
+func compile(changes []change, opts []jsonpatch.CompileOption) (*jsonpatch.Patch, error) {
+	ops := make([]jsonpatch.Op, len(changes))
+	for i, c := range changes {
+		built, err := c.build(opts)
+		if err != nil {
+			return nil, err
+		}
+		ops[i] = built
+	}
+	return jsonpatch.CompileOps(ops, opts...)
+}
+
+// build turns c back into an operation. A move that lost its destination
+// becomes a remove of its source. Predicates are rebuilt through their JSON
+// projection, compiled with opts, only when their path changed.
+func (c change) build(opts []jsonpatch.CompileOption) (jsonpatch.Op, error) {
+	switch typed := c.op.(type) {
+	case *op.AddOperation:
+		return op.NewAdd(c.path, typed.Value), nil
+	case *op.ReplaceOperation:
+		return op.NewReplaceWithOldValue(c.path, typed.Value, typed.OldValue), nil
+	case *op.RemoveOperation:
+		if typed.HasOldValue {
+			return op.NewRemoveWithOldValue(c.path, typed.OldValue), nil
+		}
+		return op.NewRemove(c.path), nil
+	case *op.MoveOperation:
+		if c.kind == kindRemove {
+			return op.NewRemove(c.path), nil
+		}
+		return op.NewMove(c.path, c.from), nil
+	case *op.CopyOperation:
+		return op.NewCopy(c.path, c.from), nil
+	case *op.StrInsOperation:
+		return op.NewStrIns(c.path, float64(c.pos), c.str), nil
+	case *op.StrDelOperation:
+		if typed.HasStr {
+			return op.NewStrDelWithStr(c.path, float64(c.pos), c.str), nil
+		}
+		return op.NewStrDel(c.path, float64(c.pos), float64(c.len)), nil
+	case *op.IncOperation:
+		return op.NewInc(c.path, typed.Inc), nil
+	case *op.FlipOperation:
+		return op.NewFlip(c.path), nil
+	case *op.ExtendOperation:
+		return op.NewExtend(c.path, typed.Properties, typed.DeleteNull), nil
+	case *op.MergePatchOperation:
+		return op.NewMergePatch(c.path, typed.Value), nil
+	case *op.SplitOperation:
+		return op.NewSplit(c.path, typed.Pos, typed.Props), nil
+	case *op.MergeOperation:
+		return op.NewMerge(c.path, typed.Pos, typed.Props), nil
+	}
+
+	if slices.Equal(c.path, c.op.Path()) {
+		return c.op, nil
+	}
+	if _, ok := c.op.(internal.SecondOrderPredicateOp); ok {
+		return nil, fmt.Errorf("%w: cannot rebase %q predicate", ErrNotTransformable, c.op.Op())
+	}
+	jsonOp, ok := c.op.(internal.JSONOp)
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot rebase %q operation", ErrNotTransformable, c.op.Op())
+	}
+	operation, err := jsonOp.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	operation.Path = jsonpointer.Format(c.path...)
+	rebuilt, err := jsonpatch.CompileOperations([]jsoncodec.Operation{operation}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return rebuilt.Ops()[0], nil
+}
+
+func conflictError(x, y change) error {
+	return fmt.Errorf("%w: %s %q and %s %q", ErrNotTransformable,
+		x.op.Op(), jsonpointer.Format(x.path...), y.op.Op(), jsonpointer.Format(y.path...))
+}
@@ -0,0 +1,278 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+func TestTransformConverges(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		doc      string
+		a        []jsonpatch.Op
+		b        []jsonpatch.Op
+		expected string
+	}{
+		{
+			name:     "insert ties keep a first",
+			doc:      `{"list":["x","y"]}`,
+			a:        []jsonpatch.Op{op.NewAdd([]string{"list", "1"}, "a")},
+			b:        []jsonpatch.Op{op.NewAdd([]string{"list", "1"}, "b")},
+			expected: `{"list":["x","a","b","y"]}`,
+		},
+		{
+			name:     "insert shifts later index",
+			doc:      `{"list":["x","y","z"]}`,
+			a:        []jsonpatch.Op{op.NewAdd([]string{"list", "0"}, "a")},
+			b:        []jsonpatch.Op{op.NewReplace([]string{"list", "2"}, "b")},
+			expected: `{"list":["a","x","y","b"]}`,
+		},
+		{
+			name:     "remove shifts later index",
+			doc:      `{"list":["x","y","z"]}`,
+			a:        []jsonpatch.Op{op.NewRemove([]string{"list", "0"})},
+			b:        []jsonpatch.Op{op.NewStrIns([]string{"list", "2"}, 1, "!")},
+			expected: `{"list":["y","z!"]}`,
+		},
+		{
+			name:     "same element removed twice",
+			doc:      `{"list":["x","y"]}`,
+			a:        []jsonpatch.Op{op.NewRemove([]string{"list", "1"})},
+			b:        []jsonpatch.Op{op.NewRemove([]string{"list", "1"})},
+			expected: `{"list":["x"]}`,
+		},
+		{
+			name:     "move carries concurrent edit",
+			doc:      `{"list":["x","y","z"]}`,
+			a:        []jsonpatch.Op{op.NewMove([]string{"list", "0"}, []string{"list", "2"})},
+			b:        []jsonpatch.Op{op.NewStrIns([]string{"list", "2"}, 0, ">"), op.NewRemove([]string{"list", "0"})},
+			expected: `{"list":[">z","y"]}`,
+		},
+		{
+			name:     "move into array shifts insert",
+			doc:      `{"item":"i","list":["x","y"]}`,
+			a:        []jsonpatch.Op{op.NewMove([]string{"list", "0"}, []string{"item"})},
+			b:        []jsonpatch.Op{op.NewAdd([]string{"list", "1"}, "b")},
+			expected: `{"list":["i","x","b","y"]}`,
+		},
+		{
+			name:     "concurrent moves of one element",
+			doc:      `{"list":["x","y","z"]}`,
+			a:        []jsonpatch.Op{op.NewMove([]string{"list", "2"}, []string{"list", "0"})},
+			b:        []jsonpatch.Op{op.NewMove([]string{"list", "1"}, []string{"list", "0"})},
+			expected: `{"list":["y","z","x"]}`,
+		},
+		{
+			name:     "str_ins shifts rune positions",
+			doc:      `{"text":"héllo"}`,
+			a:        []jsonpatch.Op{op.NewStrIns([]string{"text"}, 0, "¡")},
+			b:        []jsonpatch.Op{op.NewStrIns([]string{"text"}, 5, "!")},
+			expected: `{"text":"¡héllo!"}`,
+		},
+		{
+			name:     "str_ins inside deleted range",
+			doc:      `{"text":"héllo"}`,
+			a:        []jsonpatch.Op{op.NewStrDelWithStr([]string{"text"}, 1, "éll")},
+			b:        []jsonpatch.Op{op.NewStrIns([]string{"text"}, 2, "ö")},
+			expected: `{"text":"höo"}`,
+		},
+		{
+			name:     "overlapping str_del",
+			doc:      `{"text":"héllo wörld"}`,
+			a:        []jsonpatch.Op{op.NewStrDel([]string{"text"}, 0, 6)},
+			b:        []jsonpatch.Op{op.NewStrDel([]string{"text"}, 4, 4)},
+			expected: `{"text":"rld"}`,
+		},
+		{
+			name:     "a wins concurrent writes",
+			doc:      `{"name":"Ada"}`,
+			a:        []jsonpatch.Op{op.NewReplace([]string{"name"}, "Grace")},
+			b:        []jsonpatch.Op{op.NewReplace([]string{"name"}, "Hopper")},
+			expected: `{"name":"Grace"}`,
+		},
+		{
+			name:     "remove wins over nested write",
+			doc:      `{"user":{"name":"Ada"}}`,
+			a:        []jsonpatch.Op{op.NewAdd([]string{"user", "email"}, "ada@example.com")},
+			b:        []jsonpatch.Op{op.NewRemove([]string{"user"})},
+			expected: `{}`,
+		},
+		{
+			name:     "replace wins over inc",
+			doc:      `{"count":1}`,
+			a:        []jsonpatch.Op{op.NewInc([]string{"count"}, 1)},
+			b:        []jsonpatch.Op{op.NewReplace([]string{"count"}, float64(10))},
+			expected: `{"count":10}`,
+		},
+		{
+			name:     "inc sums",
+			doc:      `{"count":1}`,
+			a:        []jsonpatch.Op{op.NewInc([]string{"count"}, 2)},
+			b:        []jsonpatch.Op{op.NewInc([]string{"count"}, 3)},
+			expected: `{"count":6}`,
+		},
+		{
+			name:     "flip wins over nested write",
+			doc:      `{"l":[1,2,3]}`,
+			a:        []jsonpatch.Op{op.NewFlip([]string{"l"})},
+			b:        []jsonpatch.Op{op.NewRemove([]string{"l", "2"})},
+			expected: `{"l":false}`,
+		},
+		{
+			name:     "stale predicate dropped",
+			doc:      `{"name":"Ada","tags":[]}`,
+			a:        []jsonpatch.Op{op.NewTest([]string{"name"}, "Ada"), op.NewAdd([]string{"tags", "-"}, "a")},
+			b:        []jsonpatch.Op{op.NewReplace([]string{"name"}, "Grace")},
+			expected: `{"name":"Grace","tags":["a"]}`,
+		},
+		{
+			name:     "predicate follows shift",
+			doc:      `{"list":["x","y"]}`,
+			a:        []jsonpatch.Op{op.NewTest([]string{"list", "1"}, "y"), op.NewRemove([]string{"list", "1"})},
+			b:        []jsonpatch.Op{op.NewAdd([]string{"list", "0"}, "b")},
+			expected: `{"list":["b","x"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := jsonpatch.CompileOps(tt.a, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+			b, err := jsonpatch.CompileOps(tt.b, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+
+			aPrime, bPrime, err := Transform(a, b)
+			require.NoError(t, err)
+
+			assert.JSONEq(t, tt.expected, applyBoth(t, tt.doc, a, bPrime))
+			assert.JSONEq(t, tt.expected, applyBoth(t, tt.doc, b, aPrime))
+		})
+	}
+}
+
+func TestTransformErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		a    []jsonpatch.Op
+		b    []jsonpatch.Op
+	}{
+		{
+			name: "copy source changed",
+			a:    []jsonpatch.Op{op.NewCopy([]string{"backup"}, []string{"user"})},
+			b:    []jsonpatch.Op{op.NewReplace([]string{"user", "name"}, "Grace")},
+		},
+		{
+			name: "moved value replaced",
+			a:    []jsonpatch.Op{op.NewMove([]string{"archive"}, []string{"user"})},
+			b:    []jsonpatch.Op{op.NewReplace([]string{"user"}, nil)},
+		},
+		{
+			name: "concurrent appends",
+			a:    []jsonpatch.Op{op.NewAdd([]string{"list", "-"}, "a")},
+			b:    []jsonpatch.Op{op.NewAdd([]string{"list", "-"}, "b")},
+		},
+		{
+			name: "mixed edits",
+			a:    []jsonpatch.Op{op.NewInc([]string{"value"}, 1)},
+			b:    []jsonpatch.Op{op.NewFlip([]string{"value"})},
+		},
+		{
+			name: "scoped operation",
+			a:    []jsonpatch.Op{op.NewExtend([]string{"user"}, map[string]any{"role": "admin"}, false)},
+			b:    []jsonpatch.Op{op.NewRemove([]string{"user", "role"})},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := jsonpatch.CompileOps(tt.a, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+			b, err := jsonpatch.CompileOps(tt.b, jsonpatch.WithCapabilities(jsonpatch.AllCapabilities))
+			require.NoError(t, err)
+
+			aPrime, bPrime, err := Transform(a, b)
+			require.ErrorIs(t, err, ErrNotTransformable)
+			assert.Nil(t, aPrime)
+			assert.Nil(t, bPrime)
+		})
+	}
+
+	t.Run("nil patch", func(t *testing.T) {
+		t.Parallel()
+
+		patch, err := jsonpatch.Compile(op.NewRemove([]string{"name"}))
+		require.NoError(t, err)
+
+		_, _, err = Transform(patch, nil)
+		require.ErrorIs(t, err, ErrNilPatch)
+	})
+}
+
+func TestTransformCompileOptions(t *testing.T) {
+	t.Parallel()
+
+	matchAll := jsonpatch.WithCompileMatcher(func(string, bool) jsonpatch.RegexMatcher {
+		return func(string) bool { return true }
+	})
+	a, err := jsonpatch.CompileJSON([]byte(`[{"op":"matches","path":"/list/1","value":"^never$"}]`),
+		jsonpatch.WithCapabilities(jsonpatch.AllCapabilities), matchAll)
+	require.NoError(t, err)
+	b, err := jsonpatch.Compile(op.NewAdd([]string{"list", "0"}, "b"))
+	require.NoError(t, err)
+
+	aPrime, bPrime, err := Transform(a, b, matchAll)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"list":["b","x","y"]}`, applyBoth(t, `{"list":["x","y"]}`, a, bPrime))
+	assert.JSONEq(t, `{"list":["b","x","y"]}`, applyBoth(t, `{"list":["x","y"]}`, b, aPrime))
+}
+
+func TestTransformKeySegments(t *testing.T) {
+	t.Parallel()
+
+	doc := `{"items":[{"id":1,"name":"a"},{"id":2,"name":"b"}],"tags":{"[id=1]":"x"}}`
+	keyed := jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.KeySegments)
+	remove, err := jsonpatch.CompileJSON([]byte(`[{"op":"remove","path":"/items/[id=1]"}]`), keyed)
+	require.NoError(t, err)
+	rename, err := jsonpatch.CompileJSON([]byte(`[{"op":"replace","path":"/items/0/name","value":"z"}]`))
+	require.NoError(t, err)
+
+	aPrime, bPrime, err := Transform(remove, rename, keyed)
+	require.ErrorIs(t, err, ErrNotTransformable)
+	assert.Nil(t, aPrime)
+	assert.Nil(t, bPrime)
+	_, _, err = Transform(rename, remove, keyed)
+	require.ErrorIs(t, err, ErrNotTransformable)
+
+	// Without KeySegments the segment is a member name and rebases as one.
+	a, err := jsonpatch.CompileJSON([]byte(`[{"op":"remove","path":"/items/0"},{"op":"replace","path":"/tags/[id=1]","value":"y"}]`))
+	require.NoError(t, err)
+	b, err := jsonpatch.CompileJSON([]byte(`[{"op":"replace","path":"/items/1/name","value":"z"},{"op":"remove","path":"/tags/[id=1]"}]`))
+	require.NoError(t, err)
+	aPrime, bPrime, err = Transform(a, b)
+	require.NoError(t, err)
+	assert.JSONEq(t, applyBoth(t, doc, a, bPrime), applyBoth(t, doc, b, aPrime))
+}
+
+func applyBoth(t *testing.T, doc string, first, second *jsonpatch.Patch) string {
+	t.Helper()
+
+	result, err := jsonpatch.Apply(first, []byte(doc))
+	require.NoError(t, err)
+	result, err = jsonpatch.Apply(second, result.Doc)
+	require.NoError(t, err)
+	return string(result.Doc)
+}
@@ -0,0 +1,69 @@
+package jsonpatch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Validator checks a patched document before it is returned.
+type Validator interface {
+	// Validate returns nil when doc is valid. doc is the JSON value tree the
+	// patch produced: map[string]any, []any, string, bool, nil, or a number.
+	// Return a *ValidationError, possibly wrapped, to report where doc is
+	// invalid.
+	Validate(doc any) error
+}
+
+// ValidatorFunc adapts a function to the Validator interface.
+type ValidatorFunc func(doc any) error
+
+// Validate calls f(doc).
+func (f ValidatorFunc) Validate(doc any) error {
+	return f(doc)
+}
+
+// ValidationError locates a validation failure in the document and in the
+// schema or rule set that rejected it.
+type ValidationError struct {
+	// InstancePointer is the JSON Pointer of the rejected value.
+	InstancePointer string
+	// SchemaPointer is the JSON Pointer of the rule that rejected it, such
+	// as "/properties/age/minimum".
+	SchemaPointer string
+	// Message describes the failure.
+	Message string
+}
+
+// Error returns a human-readable failure description.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("instance %q: %s (schema %q)", e.InstancePointer, e.Message, e.SchemaPointer)
+}
+
+// WithValidator runs v on the patched document before it is returned. A
+// rejected document fails with ErrValidationFailed and, for ApplyInPlace, is
+// not written back. Documents patched with a validator use the JSON
+// round-trip, so v sees the same value tree for every document type. With
+// WithDryRun the document is still validated.
+func WithValidator(v Validator) ApplyOption {
+	return func(o *applyOptions) {
+		o.validator = v
+	}
+}
+
+// validateResult runs the configured validator on doc.
+func (o *applyOptions) validateResult(doc any) error {
+	if o.validator == nil {
+		return nil
+	}
+	err := o.validator.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	patchErr := &Error{kind: ErrValidationFailed, index: -1, cause: err}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		patchErr.schemaPointer = validationErr.SchemaPointer
+		patchErr.instancePointer = validationErr.InstancePointer
+	}
+	return patchErr
+}
@@ -0,0 +1,169 @@
+package jsonpatch_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+	"github.com/kaptinlin/jsonpatch/schema"
+)
+
+type validatedUser struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func newUserSchema(t *testing.T) *schema.Schema {
+	t.Helper()
+
+	userSchema, err := schema.Compile([]byte(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0}
+		},
+		"required": ["name"]
+	}`))
+	require.NoError(t, err)
+	return userSchema
+}
+
+func TestWithValidator(t *testing.T) {
+	t.Parallel()
+
+	userSchema := newUserSchema(t)
+
+	tests := []struct {
+		name         string
+		ops          []jsonpatch.Op
+		wantErr      bool
+		wantInstance string
+		wantSchema   string
+	}{
+		{name: "valid", ops: []jsonpatch.Op{op.NewReplace([]string{"age"}, 31)}},
+		{
+			name:         "minimum",
+			ops:          []jsonpatch.Op{op.NewReplace([]string{"age"}, -1)},
+			wantErr:      true,
+			wantInstance: "/age",
+			wantSchema:   "/properties/age/minimum",
+		},
+		{
+			name:         "required",
+			ops:          []jsonpatch.Op{op.NewRemove([]string{"name"})},
+			wantErr:      true,
+			wantInstance: "",
+			wantSchema:   "/required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.Compile(tt.ops...)
+			require.NoError(t, err)
+
+			doc := map[string]any{"name": "Ada", "age": 30}
+			result, err := jsonpatch.Apply(patch, doc, jsonpatch.WithValidator(userSchema))
+			if !tt.wantErr {
+				require.NoError(t, err)
+				assert.Equal(t, 31, result.Doc["age"])
+				return
+			}
+			require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+			assert.Nil(t, result)
+
+			var patchErr *jsonpatch.Error
+			require.True(t, errors.As(err, &patchErr))
+			assert.Equal(t, -1, patchErr.Index())
+			assert.Equal(t, tt.wantInstance, patchErr.InstancePointer())
+			assert.Equal(t, tt.wantSchema, patchErr.SchemaPointer())
+
+			var validationErr *jsonpatch.ValidationError
+			require.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, tt.wantSchema, validationErr.SchemaPointer)
+		})
+	}
+}
+
+func TestWithValidatorDocumentShapes(t *testing.T) {
+	t.Parallel()
+
+	userSchema := newUserSchema(t)
+	patch, err := jsonpatch.Compile(op.NewReplace([]string{"name"}, ""))
+	require.NoError(t, err)
+
+	_, err = jsonpatch.Apply(patch, validatedUser{Name: "Ada"}, jsonpatch.WithValidator(userSchema))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+
+	_, err = jsonpatch.Apply(patch, []byte(`{"name":"Ada"}`), jsonpatch.WithValidator(userSchema))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+
+	_, err = jsonpatch.Apply(patch, jsonpatch.JSONText(`{"name":"Ada"}`), jsonpatch.WithValidator(userSchema))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+
+	_, err = jsonpatch.Apply(patch, map[string]any{"name": "Ada"}, jsonpatch.WithValidator(userSchema), jsonpatch.WithDryRun())
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+}
+
+func TestWithValidatorApplyInPlace(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(
+		op.NewReplace([]string{"name"}, "Grace"),
+		op.NewReplace([]string{"age"}, -5),
+	)
+	require.NoError(t, err)
+
+	doc := map[string]any{"name": "Ada", "age": 30}
+	err = jsonpatch.ApplyInPlace(patch, &doc, jsonpatch.WithValidator(newUserSchema(t)))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+	assert.Equal(t, map[string]any{"name": "Ada", "age": 30}, doc)
+
+	user := validatedUser{Name: "Ada", Age: 30}
+	err = jsonpatch.ApplyInPlace(patch, &user, jsonpatch.WithValidator(newUserSchema(t)))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+	assert.Equal(t, validatedUser{Name: "Ada", Age: 30}, user)
+}
+
+func TestValidatorFunc(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.Compile(op.NewAdd([]string{"tags", "-"}, "c"))
+	require.NoError(t, err)
+
+	maxTags := jsonpatch.ValidatorFunc(func(doc any) error {
+		tags, _ := doc.(map[string]any)["tags"].([]any)
+		if len(tags) > 2 {
+			return fmt.Errorf("too many tags: %w", &jsonpatch.ValidationError{
+				InstancePointer: "/tags",
+				SchemaPointer:   "/maxTags",
+				Message:         "more than 2 tags",
+			})
+		}
+		return nil
+	})
+
+	_, err = jsonpatch.Apply(patch, map[string]any{"tags": []any{"a"}}, jsonpatch.WithValidator(maxTags))
+	require.NoError(t, err)
+
+	_, err = jsonpatch.Apply(patch, map[string]any{"tags": []any{"a", "b"}}, jsonpatch.WithValidator(maxTags))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+	var patchErr *jsonpatch.Error
+	require.True(t, errors.As(err, &patchErr))
+	assert.Equal(t, "/tags", patchErr.InstancePointer())
+	assert.Equal(t, "/maxTags", patchErr.SchemaPointer())
+	assert.Contains(t, err.Error(), `instance "/tags": more than 2 tags (schema "/maxTags")`)
+
+	plain := jsonpatch.ValidatorFunc(func(any) error { return errors.New("rejected") })
+	_, err = jsonpatch.Apply(patch, map[string]any{"tags": []any{}}, jsonpatch.WithValidator(plain))
+	require.ErrorIs(t, err, jsonpatch.ErrValidationFailed)
+	require.True(t, errors.As(err, &patchErr))
+	assert.Empty(t, patchErr.SchemaPointer())
+}
@@ -0,0 +1,345 @@
+package jsonpatch
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kaptinlin/deepclone"
+	"github.com/kaptinlin/jsonpointer"
+
+	jsoncodec "github.com/kaptinlin/jsonpatch/codec/json"
+	"github.com/kaptinlin/jsonpatch/internal"
+	"github.com/kaptinlin/jsonpatch/jsonpath"
+	oppkg "github.com/kaptinlin/jsonpatch/op"
+)
+
+// wildcardSegment is the path segment that a patch compiled with the Wildcard
+// capability expands to every array element or object member.
+const wildcardSegment = "*"
+
+// wildcardOp is a compiled operation whose pointers are only known against
+// the document: with wildcards its path has wildcard segments, with query set
+// its path is the pattern of a JSONPath query, and with keys its paths have
+// key segments. The apply loop expands it against the working document into
+// one concrete operation per matching pointer, rebuilt from the operation's
+// JSON projection with the pointer filled in and key segments resolved.
+type wildcardOp struct {
+	template      Op
+	operation     internal.Operation
+	query         *jsonpath.Query
+	wildcards     bool
+	keys          bool
+	createMatcher internal.CreateRegexMatcher
+}
+
+func hasWildcard(path []string) bool {
+	return slices.Contains(path, wildcardSegment)
+}
+
+// hasKeySegments reports whether the path or from of operation, or of one of
+// its operands, has a key segment.
+func hasKeySegments(operation Op) bool {
+	found := false
+	WalkPredicates(operation, func(visited Op, _ int) bool {
+		found = slices.ContainsFunc(visited.Path(), oppkg.IsKeySegment) ||
+			slices.ContainsFunc(operationFrom(visited), oppkg.IsKeySegment)
+		return !found
+	})
+	return found
+}
+
+// wrapWildcard wraps operation for expansion when its path has a wildcard
+// segment and the Wildcard capability is enabled, or it has key segments and
+// the KeySegments capability is enabled. Other operations are returned
+// unchanged.
+func wrapWildcard(operation Op, options compileOptions) (Op, error) {
+	if _, ok := operation.(*wildcardOp); ok {
+		return operation, nil
+	}
+	wildcards := options.capabilities&Wildcard != 0 && hasWildcard(operation.Path())
+	keys := options.capabilities&KeySegments != 0 && hasKeySegments(operation)
+	if !wildcards && !keys {
+		return operation, nil
+	}
+	return newWildcardOp(operation, nil, options)
+}
+
+// newWildcardOp wraps operation, resolving its key segments when options
+// enable KeySegments. Without query, its wildcard segments expand when
+// options enable Wildcard.
+func newWildcardOp(operation Op, query *jsonpath.Query, options compileOptions) (*wildcardOp, error) {
+	jsonOp, ok := operation.(internal.JSONOp)
+	if !ok {
+		return nil, fmt.Errorf("operation %T cannot be expanded over wildcards", operation)
+	}
+	projected, err := jsonOp.ToJSON()
+	if err != nil {
+		return nil, err
+	}
+	if err := jsoncodec.RelativizeOperands(&projected); err != nil {
+		return nil, err
+	}
+	return &wildcardOp{
+		template:      operation,
+		operation:     projected,
+		query:         query,
+		wildcards:     query == nil && options.capabilities&Wildcard != 0 && hasWildcard(operation.Path()),
+		keys:          options.capabilities&KeySegments != 0 && hasKeySegments(operation),
+		createMatcher: options.createMatcher,
+	}, nil
+}
+
+// Op returns the type of the expanded operation.
+func (w *wildcardOp) Op() OpType { return w.template.Op() }
+
+// Path returns the path with its wildcard and key segments, or the pattern
+// of the query.
+func (w *wildcardOp) Path() []string { return w.template.Path() }
+
+// Validate validates the expanded operation.
+func (w *wildcardOp) Validate() error { return w.template.Validate() }
+
+// Clone returns the wildcard operation with a clone of the operation it
+// expands.
+func (w *wildcardOp) Clone() (Op, error) {
+	cloneOp, ok := w.template.(internal.CloneOp)
+	if !ok {
+		return nil, fmt.Errorf("operation %T cannot be cloned for compilation", w.template)
+	}
+	cloned, err := cloneOp.Clone()
+	if err != nil {
+		return nil, err
+	}
+	wrapped := *w
+	wrapped.template = cloned
+	return &wrapped, nil
+}
+
+// Apply applies the operation at every pointer its path matches in doc.
+func (w *wildcardOp) Apply(doc any) (internal.OpResult[any], error) {
+	targets, err := w.expand(doc)
+	if err != nil {
+		return internal.OpResult[any]{}, err
+	}
+	for _, target := range targets {
+		result, err := target.Apply(doc)
+		if err != nil {
+			return internal.OpResult[any]{}, err
+		}
+		doc = result.Doc
+	}
+	return internal.OpResult[any]{Doc: doc}, nil
+}
+
+// expand returns one concrete operation per pointer the path matches in doc,
+// in document order. An add or remove whose last segment is the wildcard runs
+// from the last pointer back, so inserting or removing array elements does
+// not shift the pointers still to come. For a query the pointers are those
+// Locate returns, and an add or remove runs from the last one in document
+// order back. With keys, the key segments of each concrete operation are
+// resolved against doc.
+func (w *wildcardOp) expand(doc any) ([]Op, error) {
+	pattern := w.Path()
+	var paths [][]string
+	switch {
+	case w.query != nil:
+		paths = w.query.Locate(doc)
+		switch w.template.Op() {
+		case internal.OpAddType, internal.OpRemoveType:
+			slices.SortStableFunc(paths, func(a, b []string) int { return comparePaths(b, a) })
+		}
+	case w.wildcards:
+		var err error
+		if paths, err = expandWildcards(doc, pattern, w.keys); err != nil {
+			return nil, err
+		}
+	default:
+		paths = [][]string{pattern}
+	}
+	targets := make([]Op, len(paths))
+	for i, path := range paths {
+		operation := w.operation
+		if w.keys {
+			operation = resolveKeys(doc, path, operation)
+		} else {
+			operation.Path = jsonpointer.Format(path...)
+		}
+		decoded, err := jsoncodec.DecodeOperations([]internal.Operation{operation}, internal.JSONPatchOptions{
+			CreateMatcher: w.createMatcher,
+		})
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = decoded[0]
+	}
+	switch w.template.Op() {
+	case internal.OpAddType, internal.OpRemoveType:
+		if w.wildcards && pattern[len(pattern)-1] == wildcardSegment {
+			slices.Reverse(targets)
+		}
+	}
+	return targets, nil
+}
+
+// expandWildcards returns the concrete paths pattern matches in doc. A
+// wildcard matches every index of an array and every member of an object, in
+// sorted order. Segments up to the last wildcard must exist, and fail with
+// op.ErrPathNotFound otherwise; with keys, a key segment among them selects
+// its element. The rest are left for the operation to resolve.
+func expandWildcards(doc any, pattern []string, keys bool) ([][]string, error) {
+	last := -1
+	for i, segment := range pattern {
+		if segment == wildcardSegment {
+			last = i
+		}
+	}
+	var paths [][]string
+	var walk func(value any, path []string) error
+	walk = func(value any, path []string) error {
+		depth := len(path)
+		if depth > last {
+			paths = append(paths, slices.Concat(path, pattern[depth:]))
+			return nil
+		}
+		if segment := pattern[depth]; segment != wildcardSegment {
+			if keys {
+				segment = oppkg.ResolveKeySegments(value, []string{segment})[0]
+			}
+			child, err := jsonpointer.Get(value, segment)
+			if err != nil {
+				return fmt.Errorf("%w: %s", oppkg.ErrPathNotFound, jsonpointer.Format(slices.Concat(path, pattern[depth:depth+1])...))
+			}
+			return walk(child, append(slices.Clip(path), segment))
+		}
+		for _, segment := range childSegments(value) {
+			child, err := jsonpointer.Get(value, segment)
+			if err != nil {
+				continue
+			}
+			if err := walk(child, append(slices.Clip(path), segment)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(doc, nil); err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// resolveKeys returns operation at path with the key segments of its path,
+// from, and operands resolved against doc. The path of a move addresses the
+// document after its from is removed.
+func resolveKeys(doc any, path []string, operation internal.Operation) internal.Operation {
+	var from []string
+	if operation.From != "" {
+		from = oppkg.ResolveKeySegments(doc, jsonpointer.Parse(operation.From))
+		operation.From = jsonpointer.Format(from...)
+	}
+	if operation.Op == string(internal.OpMoveType) {
+		path = movedPath(path, from, doc)
+	} else {
+		path = oppkg.ResolveKeySegments(doc, path)
+	}
+	operation.Path = jsonpointer.Format(path...)
+	if len(operation.Apply) > 0 {
+		if value, err := jsonpointer.Get(doc, path...); err == nil {
+			operation.Apply = resolveOperandKeys(value, operation.Apply)
+		}
+	}
+	return operation
+}
+
+// resolveOperandKeys resolves the key segments of operand paths, which are
+// relative to value, the target of their composite.
+func resolveOperandKeys(value any, operands []internal.Operation) []internal.Operation {
+	resolved := slices.Clone(operands)
+	for i := range resolved {
+		path := oppkg.ResolveKeySegments(value, jsonpointer.Parse(resolved[i].Path))
+		resolved[i].Path = jsonpointer.Format(path...)
+		if len(resolved[i].Apply) == 0 {
+			continue
+		}
+		if child, err := jsonpointer.Get(value, path...); err == nil {
+			resolved[i].Apply = resolveOperandKeys(child, resolved[i].Apply)
+		}
+	}
+	return resolved
+}
+
+// movedPath resolves the key segments of the path of a move, which address
+// the document after the value at from is removed.
+func movedPath(path, from []string, doc any) []string {
+	if !slices.ContainsFunc(path, oppkg.IsKeySegment) {
+		return path
+	}
+	removed, err := oppkg.NewRemove(from).Apply(deepclone.Clone(doc))
+	if err != nil {
+		return path
+	}
+	return oppkg.ResolveKeySegments(removed.Doc, path)
+}
+
+// childSegments lists the indexes of an array or the sorted member names of
+// an object. Other values have no children.
+func childSegments(value any) []string {
+	switch typed := value.(type) {
+	case []any:
+		return indexSegments(len(typed))
+	case map[string]any:
+		return slices.Sorted(func(yield func(string) bool) {
+			for name := range typed {
+				if !yield(name) {
+					return
+				}
+			}
+		})
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return indexSegments(rv.Len())
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		names := make([]string, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			names = append(names, key.String())
+		}
+		slices.Sort(names)
+		return names
+	default:
+		return nil
+	}
+}
+
+// comparePaths orders paths in document order: segment by segment, indexes
+// numerically, with a path before the paths below it.
+func comparePaths(a, b []string) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] == b[i] {
+			continue
+		}
+		x, errX := strconv.Atoi(a[i])
+		y, errY := strconv.Atoi(b[i])
+		if errX == nil && errY == nil {
+			return cmp.Compare(x, y)
+		}
+		return strings.Compare(a[i], b[i])
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func indexSegments(n int) []string {
+	segments := make([]string, n)
+	for i := range segments {
+		segments[i] = strconv.Itoa(i)
+	}
+	return segments
+}
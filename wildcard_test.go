@@ -0,0 +1,272 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kaptinlin/jsonpatch"
+	"github.com/kaptinlin/jsonpatch/op"
+)
+
+// wildcardCaps enables the core and extended vocabularies with wildcards.
+var wildcardCaps = jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Predicate, jsonpatch.Extended, jsonpatch.Wildcard)
+
+func wildcardOrder() map[string]any {
+	return map[string]any{
+		"items": []any{
+			map[string]any{"price": 10.0, "currency": "USD"},
+			map[string]any{"price": 20.0, "currency": "USD"},
+		},
+		"totals": map[string]any{
+			"net":   map[string]any{"value": 30.0},
+			"gross": map[string]any{"value": 36.0},
+		},
+		"archived": []any{},
+	}
+}
+
+func TestWildcardApply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		patch     string
+		want      func(doc map[string]any)
+		wantPaths []string
+		wantErr   error
+	}{
+		{
+			name:  "replace array elements",
+			patch: `[{"op": "replace", "path": "/items/*/currency", "value": "EUR"}]`,
+			want: func(doc map[string]any) {
+				for _, item := range doc["items"].([]any) {
+					item.(map[string]any)["currency"] = "EUR"
+				}
+			},
+			wantPaths: []string{"/items/0/currency", "/items/1/currency"},
+		},
+		{
+			name:  "inc object members in key order",
+			patch: `[{"op": "inc", "path": "/totals/*/value", "inc": 1}]`,
+			want: func(doc map[string]any) {
+				totals := doc["totals"].(map[string]any)
+				totals["gross"].(map[string]any)["value"] = 37.0
+				totals["net"].(map[string]any)["value"] = 31.0
+			},
+			wantPaths: []string{"/totals/gross/value", "/totals/net/value"},
+		},
+		{
+			name:      "test every element",
+			patch:     `[{"op": "test", "path": "/items/*/currency", "value": "USD"}]`,
+			want:      func(map[string]any) {},
+			wantPaths: []string{"/items/0/currency", "/items/1/currency"},
+		},
+		{
+			name:    "failing test",
+			patch:   `[{"op": "test", "path": "/items/*/price", "value": 10}]`,
+			wantErr: jsonpatch.ErrTestFailed,
+		},
+		{
+			name:  "remove array elements",
+			patch: `[{"op": "remove", "path": "/items/*"}]`,
+			want: func(doc map[string]any) {
+				doc["items"] = []any{}
+			},
+			wantPaths: []string{"/items/1", "/items/0"},
+		},
+		{
+			name:      "predicate operands expand with the path",
+			patch:     `[{"op": "and", "path": "/items/*", "apply": [{"op": "test", "path": "/currency", "value": "USD"}]}]`,
+			want:      func(map[string]any) {},
+			wantPaths: []string{"/items/0", "/items/1"},
+		},
+		{
+			name:  "no matches",
+			patch: `[{"op": "replace", "path": "/archived/*/price", "value": 0}]`,
+			want:  func(map[string]any) {},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), wildcardCaps)
+			require.NoError(t, err)
+
+			doc := wildcardOrder()
+			result, err := jsonpatch.Apply(patch, doc)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				var patchErr *jsonpatch.Error
+				require.ErrorAs(t, err, &patchErr)
+				assert.Equal(t, "/items/1/price", patchErr.Path())
+				return
+			}
+			require.NoError(t, err)
+
+			want := wildcardOrder()
+			tt.want(want)
+			assert.Equal(t, want, result.Doc)
+			assert.Equal(t, wildcardOrder(), doc)
+
+			var paths []string
+			for _, step := range result.Steps {
+				assert.Equal(t, 0, step.Index())
+				assert.True(t, step.Applied())
+				paths = append(paths, step.Path())
+			}
+			assert.Equal(t, tt.wantPaths, paths)
+		})
+	}
+}
+
+func TestWildcardPrefix(t *testing.T) {
+	t.Parallel()
+
+	keyCaps := jsonpatch.WithCapabilities(jsonpatch.RFC6902, jsonpatch.Wildcard, jsonpatch.KeySegments)
+	doc := map[string]any{"items": []any{
+		map[string]any{"id": 1.0, "tags": []any{"a", "b"}},
+		map[string]any{"id": 2.0, "tags": []any{"c"}},
+	}}
+
+	patch, err := jsonpatch.CompileJSON([]byte(`[{"op": "replace", "path": "/items/[id=1]/tags/*", "value": "x"}]`), keyCaps)
+	require.NoError(t, err)
+	result, err := jsonpatch.Apply(patch, doc)
+	require.NoError(t, err)
+	assert.Equal(t, []any{"x", "x"}, result.Doc["items"].([]any)[0].(map[string]any)["tags"])
+	require.Len(t, result.Steps, 2)
+	assert.Equal(t, "/items/0/tags/1", result.Steps[1].Path())
+
+	tests := []struct {
+		name  string
+		patch string
+	}{
+		{name: "missing member", patch: `[{"op": "replace", "path": "/missing/*/price", "value": 0}]`},
+		{name: "missing below a wildcard", patch: `[{"op": "replace", "path": "/items/*/labels/*", "value": 0}]`},
+		{name: "key segment selects nothing", patch: `[{"op": "test", "path": "/items/[id=7]/tags/*", "value": "a"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patch, err := jsonpatch.CompileJSON([]byte(tt.patch), keyCaps)
+			require.NoError(t, err)
+			_, err = jsonpatch.Apply(patch, doc)
+			require.ErrorIs(t, err, op.ErrPathNotFound)
+			require.ErrorIs(t, err, jsonpatch.ErrRuntimeConflict)
+		})
+	}
+}
+
+func TestWildcardCapability(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`[{"op": "replace", "path": "/items/*/currency", "value": "EUR"}]`)
+
+	literal, err := jsonpatch.CompileJSON(data)
+	require.NoError(t, err)
+	_, err = jsonpatch.Apply(literal, wildcardOrder())
+	require.ErrorIs(t, err, jsonpatch.ErrRuntimeConflict)
+
+	patch, err := jsonpatch.CompileJSON(data, wildcardCaps)
+	require.NoError(t, err)
+	assert.Equal(t, jsonpatch.RFC6902|jsonpatch.Wildcard, patch.Analyze().Capabilities)
+
+	_, err = jsonpatch.CompileOps(patch.Ops())
+	require.ErrorIs(t, err, jsonpatch.ErrUnsupportedCapability)
+	recompiled, err := jsonpatch.CompileOps(patch.Ops(), wildcardCaps)
+	require.NoError(t, err)
+	result, err := jsonpatch.Apply(recompiled, wildcardOrder())
+	require.NoError(t, err)
+	assert.Len(t, result.Steps, 2)
+}
+
+func TestWildcardContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileJSON(
+		[]byte(`[{"op": "inc", "path": "/items/*/price", "inc": 5}]`),
+		wildcardCaps,
+	)
+	require.NoError(t, err)
+
+	doc := map[string]any{"items": []any{
+		map[string]any{"price": 1.0},
+		map[string]any{"price": "free"},
+		map[string]any{"price": 2.0},
+	}}
+	result, err := jsonpatch.Apply(patch, doc, jsonpatch.WithContinueOnError())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"items": []any{
+		map[string]any{"price": 6.0},
+		map[string]any{"price": "free"},
+		map[string]any{"price": 7.0},
+	}}, result.Doc)
+
+	require.Len(t, result.Steps, 3)
+	assert.True(t, result.Steps[0].Applied())
+	assert.False(t, result.Steps[1].Applied())
+	assert.Equal(t, "/items/1/price", result.Steps[1].Path())
+	require.Error(t, result.Steps[1].Err())
+	assert.True(t, result.Steps[2].Applied())
+
+	err = jsonpatch.ApplyInPlace(patch, &doc)
+	require.Error(t, err)
+	assert.Equal(t, 1.0, doc["items"].([]any)[0].(map[string]any)["price"])
+}
+
+func TestWildcardPathPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := jsonpatch.WithPathPolicy(
+		jsonpatch.AllowPath("/items"),
+		jsonpatch.DenyPath("/items/0/price"),
+	)
+
+	_, err := jsonpatch.CompileJSON(
+		[]byte(`[{"op": "replace", "path": "/items/*/price", "value": 0}]`),
+		wildcardCaps, policy,
+	)
+	require.ErrorIs(t, err, jsonpatch.ErrPathDenied)
+
+	_, err = jsonpatch.CompileJSON(
+		[]byte(`[{"op": "replace", "path": "/items/*/currency", "value": "EUR"}]`),
+		wildcardCaps, policy,
+	)
+	require.NoError(t, err)
+
+	_, err = jsonpatch.CompileJSON(
+		[]byte(`[{"op": "replace", "path": "/items/*/price", "value": 0}]`),
+		jsonpatch.WithCapabilities(jsonpatch.RFC6902), policy,
+	)
+	require.NoError(t, err)
+}
+
+func TestWildcardEncodings(t *testing.T) {
+	t.Parallel()
+
+	patch, err := jsonpatch.CompileJSON(
+		[]byte(`[{"op": "replace", "path": "/items/*/currency", "value": "EUR"}]`),
+		wildcardCaps,
+	)
+	require.NoError(t, err)
+
+	for _, encoding := range []jsonpatch.Encoding{jsonpatch.EncodingJSON, jsonpatch.EncodingCompact, jsonpatch.EncodingBinary} {
+		t.Run(string(encoding), func(t *testing.T) {
+			t.Parallel()
+
+			data, err := patch.Encode(encoding)
+			require.NoError(t, err)
+
+			decoded := jsonpatch.NewPatch(wildcardCaps)
+			require.NoError(t, decoded.Decode(encoding, data))
+			result, err := jsonpatch.Apply(decoded, wildcardOrder())
+			require.NoError(t, err)
+			assert.Len(t, result.Steps, 2)
+		})
+	}
+}